@@ -2,8 +2,11 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/gridlhq/yeager/internal/config"
@@ -12,6 +15,22 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// TestMain keeps the developer's own user and org config, and the cached
+// org config, out of the tests.
+func TestMain(m *testing.M) {
+	home, err := os.MkdirTemp("", "yeager-test-home-")
+	if err != nil {
+		panic(err)
+	}
+	for _, name := range []string{"HOME", "XDG_CONFIG_HOME", "XDG_CACHE_HOME"} {
+		os.Setenv(name, home)
+	}
+	os.Unsetenv(config.OrgConfigEnv)
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
 func TestRootHelpContainsAllSubcommands(t *testing.T) {
 	t.Parallel()
 
@@ -172,6 +191,18 @@ func TestLogsAcceptsRunID(t *testing.T) {
 	require.NoError(t, logsCmd.Args(logsCmd, []string{"007"}))
 }
 
+func TestProfileFlagOnEveryCommand(t *testing.T) {
+	t.Parallel()
+
+	root := newRootCmd("test")
+	for _, name := range []string{"status", "up", "stop", "destroy", "logs", "services"} {
+		cmd, _, err := root.Find([]string{name})
+		require.NoError(t, err)
+		assert.NotNil(t, cmd.Flag("profile"), "%s should accept --profile", name)
+	}
+	assert.NotNil(t, root.Flag("profile"))
+}
+
 func TestKillAcceptsRunID(t *testing.T) {
 	t.Parallel()
 
@@ -274,3 +305,211 @@ func TestInitOutput_AlreadyExists(t *testing.T) {
 	assert.Contains(t, stderr.String(), "use --force to overwrite", "should show fix on stderr")
 	assert.Empty(t, stdout.String(), "no stdout expected on error")
 }
+
+func TestPrintCloudInit(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x\n\ngo 1.22\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.FileName), []byte(`
+[setup]
+presets = ["protoc"]
+packages = ["libpq-dev"]
+`), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, RunPrintCloudInit(dir, &flags{}, output.NewWithWriters(&stdout, &stderr, output.ModeText)))
+
+	out := stdout.String()
+	assert.True(t, strings.HasPrefix(out, "#cloud-config\n"), "raw document, no yeager prefix")
+	assert.Contains(t, out, "  - libpq-dev\n")
+	assert.Contains(t, out, "go@1.22")
+	assert.Contains(t, out, "protoc")
+	assert.NoFileExists(t, filepath.Join(dir, "cloud-init.yaml"))
+}
+
+func TestPrintCloudInit_ProfileAndOverrides(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.FileName), []byte(`
+[setup]
+packages = ["libpq-dev"]
+
+[profiles.ci]
+setup = { packages = ["chromium"] }
+`), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, RunPrintCloudInit(dir, &flags{profile: "ci"}, output.NewWithWriters(&stdout, &stderr, output.ModeText)))
+	assert.Contains(t, stdout.String(), "  - chromium\n", "the profile's document")
+	assert.NotContains(t, stdout.String(), "yeager-idle-shutdown")
+
+	stdout.Reset()
+	require.NoError(t, RunPrintCloudInit(dir, &flags{size: "xlarge"}, output.NewWithWriters(&stdout, &stderr, output.ModeText)))
+	assert.NotContains(t, stdout.String(), "chromium")
+	assert.Contains(t, stdout.String(), "yeager-idle-shutdown", "a temporary VM's document")
+
+	stdout.Reset()
+	require.NoError(t, RunPrintCloudInit(dir, &flags{ephemeral: true}, output.NewWithWriters(&stdout, &stderr, output.ModeText)))
+	assert.Contains(t, stdout.String(), "yeager-idle-shutdown", "an ephemeral VM is temporary too")
+
+	err := RunPrintCloudInit(dir, &flags{profile: "nope"}, output.NewWithWriters(&stdout, &stderr, output.ModeText))
+	require.Error(t, err)
+	assert.Contains(t, stderr.String(), "nope")
+}
+
+func TestPrintCloudInit_Invalid(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.FileName), []byte(`
+[setup]
+packages = ["libpq-dev curl"]
+`), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	err := RunPrintCloudInit(dir, &flags{}, output.NewWithWriters(&stdout, &stderr, output.ModeText))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid apt package "libpq-dev curl"`)
+	assert.Contains(t, stdout.String(), "#cloud-config", "the document is still shown for debugging")
+}
+
+func TestConfigExplain(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	userPath := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(userPath, []byte("[compute]\nregion = \"eu-west-1\"\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.FileName), []byte("[compute]\nsize = \"large\"\n"), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, RunConfigExplain(dir, userPath, output.NewWithWriters(&stdout, &stderr, output.ModeText)))
+
+	out := stdout.String()
+	assert.Regexp(t, `compute\.region += "eu-west-1" +# user  `+regexp.QuoteMeta(userPath), out)
+	assert.Regexp(t, `compute\.size += "large" +# project  `, out)
+	assert.Regexp(t, `lifecycle\.idle_stop += \S+ +# default\n`, out)
+}
+
+func TestConfigExplain_JSON(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.FileName), []byte("[compute]\nsize = \"large\"\n"), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, RunConfigExplain(dir, "", output.NewWithWriters(&stdout, &stderr, output.ModeJSON)))
+
+	var settings []config.Setting
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &settings))
+	var found bool
+	for _, s := range settings {
+		if s.Key == "compute.size" {
+			found = true
+			assert.Equal(t, "large", s.Value)
+			assert.Equal(t, config.SourceProject, s.Source)
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestConfigGet(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.FileName), []byte("[compute]\nsize = \"large\"\n\n[setup]\npackages = [\"jq\"]\n"), 0o644))
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"compute.size", "large\n"},
+		{"lifecycle.idle_stop", "10m\n"},
+		{"setup.packages", "[\"jq\"]\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		require.NoError(t, RunConfigGet(dir, "", tt.key, output.NewWithWriters(&stdout, &stderr, output.ModeText)))
+		assert.Equal(t, tt.want, stdout.String(), tt.key)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err := RunConfigGet(dir, "", "compute.sise", output.NewWithWriters(&stdout, &stderr, output.ModeText))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "did you mean compute.size?")
+}
+
+func TestConfigSet(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	var stdout, stderr bytes.Buffer
+	w := output.NewWithWriters(&stdout, &stderr, output.ModeText)
+	require.NoError(t, RunConfigSet(configSetPath(dir, false), false, "lifecycle.idle_stop", "30m", w))
+
+	stdout.Reset()
+	require.NoError(t, RunConfigGet(dir, "", "lifecycle.idle_stop", w))
+	assert.Equal(t, "30m\n", stdout.String())
+
+	err := RunConfigSet(configSetPath(dir, false), false, "compute.size", "huge", w)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must be small, medium, large, or xlarge")
+}
+
+func TestConfigList_JSON(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.FileName), []byte("[compute]\nsize = \"large\"\n"), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, RunConfigList(dir, "", output.NewWithWriters(&stdout, &stderr, output.ModeJSON)))
+
+	var effective struct {
+		Compute struct {
+			Size   string `json:"size"`
+			Region string `json:"region"`
+		} `json:"compute"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &effective))
+	assert.Equal(t, "large", effective.Compute.Size)
+	assert.Equal(t, "us-east-1", effective.Compute.Region)
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.FileName), []byte("[artifact]\npaths = [\"coverage/\"]\n"), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	err := RunConfigValidate(dir, "", output.NewWithWriters(&stdout, &stderr, output.ModeText))
+	require.Error(t, err)
+	assert.Contains(t, stderr.String(), "unknown key artifact.paths (did you mean artifacts.paths?)")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.FileName), []byte("[artifacts]\npaths = [\"coverage/\"]\n"), 0o644))
+	stdout.Reset()
+	require.NoError(t, RunConfigValidate(dir, "", output.NewWithWriters(&stdout, &stderr, output.ModeText)))
+	assert.Contains(t, stdout.String(), "config is valid")
+
+	// A deprecated key is a warning, not a failure.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.FileName), []byte("[devcontainer]\ncontainer = true\n"), 0o644))
+	stdout.Reset()
+	stderr.Reset()
+	require.NoError(t, RunConfigValidate(dir, "", output.NewWithWriters(&stdout, &stderr, output.ModeText)))
+	assert.Contains(t, stderr.String(), "devcontainer.container is deprecated: use exec.container.devcontainer")
+	assert.Contains(t, stdout.String(), "config is valid")
+}
+
+func TestConfigSchema(t *testing.T) {
+	t.Parallel()
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, RunConfigSchema(output.NewWithWriters(&stdout, &stderr, output.ModeText)))
+
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &schema))
+	assert.Equal(t, "http://json-schema.org/draft-07/schema#", schema["$schema"])
+	assert.Contains(t, schema["properties"], "compute")
+}
@@ -31,6 +31,7 @@ type mockProvider struct {
 	ensureBucketFn       func(ctx context.Context) error
 	createVMFn           func(ctx context.Context, opts provider.CreateVMOpts) (provider.VMInfo, error)
 	findVMFn             func(ctx context.Context, projectHash string) (*provider.VMInfo, error)
+	findVariantVMsFn     func(ctx context.Context, projectPath, profile string) ([]provider.VMInfo, error)
 	startVMFn            func(ctx context.Context, instanceID string) error
 	stopVMFn             func(ctx context.Context, instanceID string) error
 	terminateVMFn        func(ctx context.Context, instanceID string) error
@@ -73,6 +74,12 @@ func (m *mockProvider) FindVM(ctx context.Context, projectHash string) (*provide
 	}
 	return nil, nil
 }
+func (m *mockProvider) FindVariantVMs(ctx context.Context, projectPath, profile string) ([]provider.VMInfo, error) {
+	if m.findVariantVMsFn != nil {
+		return m.findVariantVMsFn(ctx, projectPath, profile)
+	}
+	return nil, nil
+}
 func (m *mockProvider) StartVM(ctx context.Context, instanceID string) error {
 	if m.startVMFn != nil {
 		return m.startVMFn(ctx, instanceID)
@@ -533,6 +540,33 @@ func TestRunStatus_PendingVM(t *testing.T) {
 	assert.Contains(t, stdout.String(), "i-pend001")
 }
 
+func TestRunStatus_ShowsTemporaryVMs(t *testing.T) {
+	t.Parallel()
+
+	prov := &mockProvider{
+		findVariantVMsFn: func(ctx context.Context, projectPath, profile string) ([]provider.VMInfo, error) {
+			return []provider.VMInfo{
+				{InstanceID: "i-big001", State: "running", Region: "us-east-1", Variant: "xlarge"},
+				{InstanceID: "i-spot001", State: "stopped", Region: "us-east-1", Variant: "spot"},
+			}, nil
+		},
+	}
+	cc, stdout, _ := testCmdContext(t, prov)
+
+	require.NoError(t, RunStatus(context.Background(), cc))
+	assert.Contains(t, stdout.String(), "no VM found")
+	assert.Contains(t, stdout.String(), "temporary VMs: 2")
+	assert.Contains(t, stdout.String(), "i-big001 (running)  us-east-1  xlarge")
+	assert.Contains(t, stdout.String(), "yg destroy")
+
+	// A variant's own VM isn't listed as another one.
+	cc.Project = cc.Project.WithVariant("xlarge")
+	stdout.Reset()
+	require.NoError(t, RunStatus(context.Background(), cc))
+	assert.Contains(t, stdout.String(), "temporary VMs: 1")
+	assert.NotContains(t, stdout.String(), "i-big001")
+}
+
 func TestRunStatus_RunningNoIP(t *testing.T) {
 	t.Parallel()
 
@@ -594,6 +628,32 @@ func TestRunStop(t *testing.T) {
 		assert.Contains(t, stdout.String(), "VM stopped")
 	})
 
+	t.Run("terminates temporary VM", func(t *testing.T) {
+		t.Parallel()
+		var terminated string
+		prov := &mockProvider{
+			findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+				return &provider.VMInfo{InstanceID: "i-temp001", State: "running", Region: "us-east-1"}, nil
+			},
+			stopVMFn: func(ctx context.Context, instanceID string) error {
+				t.Fatal("a temporary VM must not be stopped")
+				return nil
+			},
+			terminateVMFn: func(ctx context.Context, instanceID string) error {
+				terminated = instanceID
+				return nil
+			},
+		}
+		cc, stdout, _ := testCmdContext(t, prov)
+		require.NoError(t, cc.State.SaveVM(cc.Project.Hash, state.VMState{InstanceID: "i-temp001", Region: "us-east-1", Temporary: true}))
+
+		require.NoError(t, RunStop(context.Background(), cc))
+		assert.Equal(t, "i-temp001", terminated)
+		assert.Contains(t, stdout.String(), "VM terminated")
+		_, err := cc.State.LoadVM(cc.Project.Hash)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
 	t.Run("already stopped VM is no-op", func(t *testing.T) {
 		t.Parallel()
 		prov := &mockProvider{
@@ -728,6 +788,34 @@ func TestRunDestroy(t *testing.T) {
 		require.Error(t, loadErr, "state file should be deleted after destroy")
 	})
 
+	t.Run("terminates the project's temporary VMs", func(t *testing.T) {
+		t.Parallel()
+		var terminated []string
+		prov := &mockProvider{
+			findVariantVMsFn: func(ctx context.Context, projectPath, profile string) ([]provider.VMInfo, error) {
+				assert.Equal(t, "/home/user/myproject", projectPath)
+				assert.Empty(t, profile)
+				return []provider.VMInfo{{InstanceID: "i-big001", State: "running", Region: "us-east-1", Variant: "xlarge spot"}}, nil
+			},
+			terminateVMFn: func(ctx context.Context, instanceID string) error {
+				terminated = append(terminated, instanceID)
+				return nil
+			},
+		}
+		cc, stdout, _ := testCmdContext(t, prov)
+		variantHash := cc.Project.WithVariant("xlarge spot").Hash
+		saveTestVMState(t, cc.State, variantHash)
+
+		require.NoError(t, RunDestroyWithOptions(context.Background(), cc, DestroyOptions{}))
+		assert.Contains(t, stdout.String(), "temporary VM i-big001 (xlarge spot)")
+		assert.Empty(t, terminated, "nothing is destroyed without --force")
+
+		require.NoError(t, RunDestroy(context.Background(), cc))
+		assert.Equal(t, []string{"i-big001"}, terminated)
+		_, loadErr := cc.State.LoadVM(variantHash)
+		assert.ErrorIs(t, loadErr, os.ErrNotExist)
+	})
+
 	t.Run("cleans state when VM already gone from AWS", func(t *testing.T) {
 		t.Parallel()
 		prov := &mockProvider{
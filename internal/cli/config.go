@@ -0,0 +1,317 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gridlhq/yeager/internal/config"
+	"github.com/gridlhq/yeager/internal/output"
+	"github.com/spf13/cobra"
+)
+
+func newConfigCmd(f *flags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Get, set and check settings",
+		Long: `Settings are merged from, highest precedence first: YEAGER_* environment
+variables, the project's .yeager.toml, your user config
+(~/.config/yeager/config.toml), an org config the user config points at
+with org_config = "<path or URL>", and built-in defaults.`,
+		Example: `  yg config get compute.size              # the effective value
+  yg config set lifecycle.idle_stop 30m   # edit .yeager.toml, keeping comments
+  yg config set --user compute.region eu-west-1
+  yg config list --json                   # the merged config
+  yg config validate                      # unknown keys, typos and bad values
+  yg config explain                       # every setting and the file that set it
+  yg config schema > .yeager.schema.json  # JSON Schema for editors`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+	}
+
+	get := &cobra.Command{
+		Use:   "get <key>",
+		Short: "Print the effective value of a setting",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("getting working directory: %w", err)
+			}
+			return RunConfigGet(cwd, config.UserConfigPath(), args[0], output.New(f.outputMode()))
+		},
+	}
+
+	var user bool
+	set := &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Set a value in .yeager.toml, or in the user config with --user",
+		Long: `Sets a value, keeping the file's comments and layout. A commented-out
+line written by yg init is uncommented in place. Lists take a TOML array
+or comma-separated items. The file is only written if the result is valid.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("getting working directory: %w", err)
+			}
+			return RunConfigSet(configSetPath(cwd, user), user, args[0], args[1], output.New(f.outputMode()))
+		},
+	}
+	set.Flags().BoolVar(&user, "user", false, "edit the user config instead of the project's")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "Print the effective merged config",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("getting working directory: %w", err)
+			}
+			return RunConfigList(cwd, config.UserConfigPath(), output.New(f.outputMode()))
+		},
+	}
+
+	validate := &cobra.Command{
+		Use:   "validate",
+		Short: "Check config files for unknown keys, typos and invalid values",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("getting working directory: %w", err)
+			}
+			return RunConfigValidate(cwd, config.UserConfigPath(), output.New(f.outputMode()))
+		},
+	}
+
+	explain := &cobra.Command{
+		Use:   "explain",
+		Short: "Show each effective setting and where it was set",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("getting working directory: %w", err)
+			}
+			return RunConfigExplain(cwd, config.UserConfigPath(), output.New(f.outputMode()))
+		},
+	}
+
+	schema := &cobra.Command{
+		Use:   "schema",
+		Short: "Print a JSON Schema for .yeager.toml",
+		Long: `Prints a JSON Schema for .yeager.toml, for completion and validation in
+editors. With Taplo (Even Better TOML), save it and point the config at it:
+
+  yg config schema > .yeager.schema.json
+  # then add this first line to .yeager.toml:
+  #:schema ./.yeager.schema.json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return RunConfigSchema(output.New(f.outputMode()))
+		},
+	}
+
+	cmd.AddCommand(get, set, list, validate, explain, schema)
+	return cmd
+}
+
+// configSetPath returns the file yg config set edits: the user config, or
+// the project's .yeager.toml, which is created in dir if there is none.
+func configSetPath(dir string, user bool) string {
+	if user {
+		return config.UserConfigPath()
+	}
+	if path := config.FindConfig(dir); path != "" {
+		return path
+	}
+	return filepath.Join(dir, config.FileName)
+}
+
+// RunConfigGet prints the effective value of one key. Strings are printed
+// bare so the output can be used in scripts.
+func RunConfigGet(dir, userPath, key string, w *output.Writer) error {
+	if _, ok := config.LookupKey(key); !ok {
+		return unknownKeyError(key)
+	}
+	settings, err := config.Explain(dir, userPath)
+	if err != nil {
+		return err
+	}
+	for _, s := range settings {
+		if s.Key != key {
+			continue
+		}
+		if w.Mode() == output.ModeJSON {
+			return w.WriteJSON(s)
+		}
+		if str, ok := s.Value.(string); ok {
+			w.StreamLine(str)
+		} else {
+			w.StreamLine(formatConfigValue(s.Value))
+		}
+		return nil
+	}
+	if w.Mode() == output.ModeJSON {
+		return w.WriteJSON(config.Setting{Key: key, Source: config.SourceDefault})
+	}
+	// Nothing on stdout, so $(yg config get ...) is empty.
+	w.Warn(key+" is not set", "")
+	return nil
+}
+
+// RunConfigSet sets key to value in the config file at path.
+func RunConfigSet(path string, user bool, key, value string, w *output.Writer) error {
+	if path == "" {
+		return fmt.Errorf("can't find the user config directory")
+	}
+	set := config.SetValue
+	if user {
+		set = config.SetUserValue
+	}
+	if err := set(path, key, value); err != nil {
+		return err
+	}
+	w.Infof("set %s in %s", key, path)
+	return nil
+}
+
+// RunConfigList prints the effective merged config.
+func RunConfigList(dir, userPath string, w *output.Writer) error {
+	if w.Mode() == output.ModeJSON {
+		effective, err := config.Effective(dir, userPath)
+		if err != nil {
+			return err
+		}
+		return w.WriteJSON(effective)
+	}
+
+	settings, err := config.Explain(dir, userPath)
+	if err != nil {
+		return err
+	}
+	width := 0
+	for _, s := range settings {
+		width = max(width, len(s.Key))
+	}
+	for _, s := range settings {
+		w.StreamLine(fmt.Sprintf("%-*s = %s", width, s.Key, formatConfigValue(s.Value)))
+	}
+	return nil
+}
+
+// RunConfigValidate reports unknown and deprecated keys and invalid values
+// in the config files for dir. Deprecated keys alone don't fail validation.
+func RunConfigValidate(dir, userPath string, w *output.Writer) error {
+	problems, err := config.Check(dir, userPath)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, p := range problems {
+		if !p.Warning {
+			failed++
+		}
+	}
+	if w.Mode() == output.ModeJSON {
+		if problems == nil {
+			problems = []config.Problem{}
+		}
+		if err := w.WriteJSON(problems); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range problems {
+			if p.Warning {
+				w.Warn(p.String(), "")
+			} else {
+				w.Error(p.String(), "")
+			}
+		}
+	}
+
+	if failed > 0 {
+		return displayed(fmt.Errorf("%d config problem(s)", failed))
+	}
+	if w.Mode() != output.ModeJSON {
+		w.Success("config is valid")
+	}
+	return nil
+}
+
+// RunConfigSchema prints the JSON Schema for .yeager.toml.
+func RunConfigSchema(w *output.Writer) error {
+	if w.Mode() == output.ModeJSON {
+		return w.WriteJSON(config.Schema())
+	}
+	data, err := json.MarshalIndent(config.Schema(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling schema: %w", err)
+	}
+	w.Stream(append(data, '\n'))
+	return nil
+}
+
+func unknownKeyError(key string) error {
+	if suggestion := config.SuggestKey(key); suggestion != "" {
+		return fmt.Errorf("unknown key %s (did you mean %s?)", key, suggestion)
+	}
+	return fmt.Errorf("unknown key %s: see yg config list", key)
+}
+
+// RunConfigExplain prints every effective setting for the project in dir
+// with the layer that set it.
+func RunConfigExplain(dir, userPath string, w *output.Writer) error {
+	settings, err := config.Explain(dir, userPath)
+	if err != nil {
+		return err
+	}
+	if w.Mode() == output.ModeJSON {
+		return w.WriteJSON(settings)
+	}
+
+	keyWidth, valueWidth := 0, 0
+	values := make([]string, len(settings))
+	for i, s := range settings {
+		values[i] = formatConfigValue(s.Value)
+		keyWidth = max(keyWidth, len(s.Key))
+		valueWidth = max(valueWidth, len(values[i]))
+	}
+	for i, s := range settings {
+		source := s.Source
+		if s.Origin != "" {
+			source += "  " + s.Origin
+		}
+		w.StreamLine(fmt.Sprintf("%-*s = %-*s  # %s", keyWidth, s.Key, valueWidth, values[i], source))
+	}
+	return nil
+}
+
+// formatConfigValue renders a value the way it would be written in TOML.
+func formatConfigValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = formatConfigValue(item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case []string:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = fmt.Sprintf("%q", item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
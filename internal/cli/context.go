@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/gridlhq/yeager/internal/config"
 	fkexec "github.com/gridlhq/yeager/internal/exec"
@@ -12,6 +13,8 @@ import (
 	"github.com/gridlhq/yeager/internal/preflight"
 	"github.com/gridlhq/yeager/internal/project"
 	"github.com/gridlhq/yeager/internal/provider"
+	"github.com/gridlhq/yeager/internal/provision"
+	"github.com/gridlhq/yeager/internal/secrets"
 	fkssh "github.com/gridlhq/yeager/internal/ssh"
 	"github.com/gridlhq/yeager/internal/state"
 	fkstorage "github.com/gridlhq/yeager/internal/storage"
@@ -29,12 +32,30 @@ type SSHClientFactory func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh
 // StorageFactory creates a storage store for a given bucket.
 type StorageFactory func(ctx context.Context) (*fkstorage.Store, error)
 
-// SyncFunc runs rsync to sync project files to a VM and returns transfer stats.
-type SyncFunc func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo) (*fksync.SyncResult, error)
+// BlobStoreFactory creates the store sync stages a project's large files in.
+type BlobStoreFactory func(ctx context.Context, projectName string) (fksync.BlobStore, error)
+
+// SyncFunc syncs project files to a VM and returns transfer stats. The
+// native engine syncs over client; rsync opens its own connection.
+type SyncFunc func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, client *gossh.Client) (*fksync.SyncResult, error)
+
+// PullFunc copies the project files matching patterns that changed on the VM
+// back over client. force overwrites files changed locally too.
+type PullFunc func(ctx context.Context, cc *cmdContext, client *gossh.Client, patterns []string, force bool) (*fksync.PullResult, error)
+
+// WatchFunc reports batches of changed project files until ctx is done,
+// then closes the channel.
+type WatchFunc func(ctx context.Context, cc *cmdContext) (<-chan []string, error)
+
+// KillFunc cancels a run on the VM.
+type KillFunc func(client *gossh.Client, runID fkexec.RunID) error
 
 // ExecFunc runs a command on the remote VM via an SSH client.
 type ExecFunc func(client *gossh.Client, opts fkexec.RunOpts, stdout, stderr io.Writer) (*fkexec.RunResult, error)
 
+// ScriptFunc runs provisioning commands on the VM and waits for them to finish.
+type ScriptFunc func(client *gossh.Client, workDir string, commands []string, stdout, stderr io.Writer) error
+
 // ListRunsFunc queries active runs on the VM via SSH.
 type ListRunsFunc func(client *gossh.Client) ([]fkexec.ActiveRun, error)
 
@@ -47,6 +68,15 @@ type TailLogFunc func(client *gossh.Client, runID fkexec.RunID, stdout io.Writer
 // ReadRemoteFileFunc reads a file from the VM over SSH.
 type ReadRemoteFileFunc func(client *gossh.Client, remotePath string) ([]byte, error)
 
+// WriteEnvFileFunc writes a run's env file on the VM.
+type WriteEnvFileFunc func(client *gossh.Client, remotePath string, env []fkexec.EnvVar) error
+
+// SecretResolverFunc fetches the value of an ssm: or secretsmanager: reference.
+type SecretResolverFunc func(ctx context.Context, ref string) (string, error)
+
+// ProfileContextFunc resolves a command context for another profile.
+type ProfileContextFunc func(ctx context.Context, profile string) (*cmdContext, error)
+
 // AWSCredStatusFunc checks AWS credential status and returns the account ID.
 type AWSCredStatusFunc func(ctx context.Context) (accountID string, err error)
 
@@ -59,42 +89,63 @@ type cmdContext struct {
 	State    *state.Store
 	Output   *output.Writer
 
+	// Extra env for remote commands, from --env and --env-file.
+	EnvFlags []string
+	EnvFile  string
+
+	// The [tasks] entry being run, if any.
+	Task *taskRun
+
+	// Spot launches the VM as a spot instance. Temporary VMs are terminated
+	// instead of stopped once idle. Both come from the compute overrides.
+	Spot      bool
+	Temporary bool
+
 	// Factories for execution pipeline (set in resolveCmdContext, overridable in tests).
 	NewSSHConnector    SSHConnectorFactory
 	ConnectSSH         SSHClientFactory
 	NewStorage         StorageFactory
+	NewBlobStore       BlobStoreFactory
 	RunSync            SyncFunc
+	RunPull            PullFunc
+	WatchFiles         WatchFunc
+	KillRun            KillFunc
 	RunExec            ExecFunc
+	RunScript          ScriptFunc
 	ListRuns           ListRunsFunc
 	IsRunActive        IsRunActiveFunc
 	TailLog            TailLogFunc
 	ReadRemoteFile     ReadRemoteFileFunc
+	WriteEnvFile       WriteEnvFileFunc
+	ResolveSecret      SecretResolverFunc
+	LookupEnv          func(name string) (string, bool)
 	CheckAWSCredStatus AWSCredStatusFunc
+	ForProfile         ProfileContextFunc
 }
 
 // resolveCmdContext builds the full context needed by VM-interacting commands.
-func resolveCmdContext(ctx context.Context, mode output.Mode) (*cmdContext, error) {
-	w := output.New(mode)
+func resolveCmdContext(ctx context.Context, f *flags) (*cmdContext, error) {
+	w := output.New(f.outputMode())
 
 	cwd, err := os.Getwd()
 	if err != nil {
 		return nil, fmt.Errorf("getting working directory: %w", err)
 	}
 
-	proj, err := project.Resolve(cwd)
+	proj, cfg, temporary, err := resolveProjectConfig(w, cwd, f)
 	if err != nil {
-		return nil, fmt.Errorf("resolving project: %w", err)
-	}
-
-	cfg, _, err := config.Load(cwd)
-	if err != nil {
-		w.Error("invalid configuration", "check .yeager.toml syntax, or delete it and run: yg init")
-		return nil, displayed(err)
+		return nil, err
 	}
 
 	// Preflight checks — detect missing prerequisites with actionable errors.
 	homeDir, _ := os.UserHomeDir()
-	if failures := preflight.RunAll(os.LookupEnv, fileExists, homeDir); len(failures) > 0 {
+	failures := preflight.RunAll(os.LookupEnv, fileExists, homeDir)
+	if cfg.Sync.Engine == config.SyncEngineRsync {
+		if r := preflight.CheckRsync(); !r.OK {
+			failures = append(failures, r)
+		}
+	}
+	if len(failures) > 0 {
 		for _, f := range failures {
 			w.Error(f.Message, f.Fix)
 		}
@@ -113,30 +164,108 @@ func resolveCmdContext(ctx context.Context, mode output.Mode) (*cmdContext, erro
 	}
 
 	cc := &cmdContext{
-		Project:  proj,
-		Config:   cfg,
-		Provider: prov,
-		State:    store,
-		Output:   w,
+		Project:   proj,
+		Config:    cfg,
+		Provider:  prov,
+		State:     store,
+		Output:    w,
+		Spot:      f.spot,
+		Temporary: temporary,
 	}
 
 	// Set default factories that create real AWS-backed clients.
 	cc.NewSSHConnector = defaultSSHConnectorFactory(prov)
 	cc.NewStorage = defaultStorageFactory(prov)
+	cc.NewBlobStore = defaultBlobStoreFactory(prov)
 	cc.RunSync = defaultSyncFunc
+	cc.RunPull = defaultPullFunc
+	cc.WatchFiles = defaultWatchFunc
+	cc.KillRun = fkexec.Kill
 	cc.RunExec = fkexec.Run
+	cc.RunScript = fkexec.RunScript
 	cc.ListRuns = fkexec.ListRuns
 	cc.IsRunActive = fkexec.IsRunActive
 	cc.TailLog = fkexec.TailLog
 	cc.ReadRemoteFile = fkexec.ReadRemoteFile
+	cc.WriteEnvFile = fkexec.WriteEnvFile
+	cc.ResolveSecret = defaultSecretResolver(cfg.Compute.Region)
+	cc.LookupEnv = os.LookupEnv
 	cc.ConnectSSH = defaultConnectSSH(cc)
 	cc.CheckAWSCredStatus = func(ctx context.Context) (string, error) {
 		return prov.AccountID(ctx)
 	}
+	cc.ForProfile = func(ctx context.Context, profile string) (*cmdContext, error) {
+		pf := *f
+		pf.profile = profile
+		return resolveCmdContext(ctx, &pf)
+	}
 
 	return cc, nil
 }
 
+// resolveProjectConfig resolves the project in dir and its config as f
+// selects them: the profile (--profile or YEAGER_PROFILE), then [setup]
+// presets, then the compute overrides. It also reports whether the VM is
+// temporary. Config errors are shown on w.
+func resolveProjectConfig(w *output.Writer, dir string, f *flags) (project.Project, config.Config, bool, error) {
+	proj, err := project.Resolve(dir)
+	if err != nil {
+		return proj, config.Config{}, false, fmt.Errorf("resolving project: %w", err)
+	}
+
+	cfg, _, err := config.Load(dir)
+	if err != nil {
+		w.Error("invalid configuration: "+err.Error(), "fix the config file, or check it with: yg config validate")
+		return proj, cfg, false, displayed(err)
+	}
+
+	// A profile overlays the config and gets its own VM.
+	profile := f.profile
+	if profile == "" {
+		profile = os.Getenv(config.ProfileEnv)
+	}
+	if cfg, err = cfg.WithProfile(profile); err != nil {
+		w.Error(err.Error(), "profiles are [profiles.<name>] tables in .yeager.toml")
+		return proj, cfg, false, displayed(err)
+	}
+	if err := provision.ApplyPresets(&cfg); err != nil {
+		w.Error(err.Error(), "fix [setup] presets in .yeager.toml")
+		return proj, cfg, false, displayed(err)
+	}
+	proj = proj.WithProfile(profile)
+	proj, temporary, err := applyOverrides(&cfg, proj, f)
+	if err != nil {
+		w.Error(err.Error(), "")
+		return proj, cfg, false, displayed(err)
+	}
+	return proj, cfg, temporary, nil
+}
+
+// applyOverrides applies --size, --region and --spot on top of the loaded
+// config. Settings that differ from the config get a VM of their own, so a
+// one-off xlarge build doesn't recreate the project's VM. That VM, like any
+// VM used with --ephemeral, is temporary: terminated once idle.
+func applyOverrides(cfg *config.Config, proj project.Project, f *flags) (project.Project, bool, error) {
+	var variant []string
+	if f.size != "" {
+		if !config.ValidSizes[f.size] {
+			return proj, false, fmt.Errorf("invalid --size %q: must be one of small, medium, large, xlarge", f.size)
+		}
+		if f.size != cfg.Compute.Size {
+			cfg.Compute.Size = f.size
+			variant = append(variant, f.size)
+		}
+	}
+	if f.region != "" && f.region != cfg.Compute.Region {
+		cfg.Compute.Region = f.region
+		variant = append(variant, f.region)
+	}
+	if f.spot {
+		variant = append(variant, "spot")
+	}
+	return proj.WithVariant(strings.Join(variant, " ")), f.ephemeral || len(variant) > 0, nil
+}
+
 // defaultSSHConnectorFactory creates an SSH connector using EC2 Instance Connect.
 func defaultSSHConnectorFactory(prov *provider.AWSProvider) SSHConnectorFactory {
 	return func(ctx context.Context, region, az string) (*fkssh.Connector, error) {
@@ -159,6 +288,22 @@ func defaultConnectSSH(cc *cmdContext) SSHClientFactory {
 	}
 }
 
+// defaultSecretResolver resolves secrets with real AWS clients, created on
+// first use so commands without secrets never load them.
+func defaultSecretResolver(region string) SecretResolverFunc {
+	var resolver *secrets.Resolver
+	return func(ctx context.Context, ref string) (string, error) {
+		if resolver == nil {
+			r, err := provider.NewSecretsResolver(ctx, region)
+			if err != nil {
+				return "", err
+			}
+			resolver = r
+		}
+		return resolver.Resolve(ctx, ref)
+	}
+}
+
 // fileExists returns true if a file exists at the given path.
 func fileExists(path string) bool {
 	_, err := os.Stat(path)
@@ -184,6 +329,20 @@ func printError(w *output.Writer, err error) {
 }
 
 // defaultStorageFactory creates a storage store using the provider's S3 client.
+func defaultBlobStoreFactory(prov *provider.AWSProvider) BlobStoreFactory {
+	return func(ctx context.Context, projectName string) (fksync.BlobStore, error) {
+		bucketName, err := prov.BucketName(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s3Client, presigner, err := provider.NewS3BlobClients(ctx, prov.Region())
+		if err != nil {
+			return nil, fmt.Errorf("creating S3 client: %w", err)
+		}
+		return fkstorage.NewBlobStore(s3Client, presigner, bucketName, projectName), nil
+	}
+}
+
 func defaultStorageFactory(prov *provider.AWSProvider) StorageFactory {
 	return func(ctx context.Context) (*fkstorage.Store, error) {
 		bucketName, err := prov.BucketName(ctx)
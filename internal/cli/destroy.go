@@ -7,6 +7,7 @@ import (
 	"os"
 
 	"github.com/gridlhq/yeager/internal/monitor"
+	"github.com/gridlhq/yeager/internal/provider"
 	"github.com/spf13/cobra"
 )
 
@@ -17,11 +18,12 @@ func newDestroyCmd(f *flags) *cobra.Command {
 		Short: "Terminate the VM and clean up all resources",
 		Long: `Terminates the VM, deletes the EBS volume, and removes local state.
 The next command will create a fresh VM from scratch. S3 output history
-is not affected.
+is not affected. Temporary VMs launched with --size, --region or --spot
+are terminated too.
 
 Use --force to skip the confirmation warning.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			cc, err := resolveCmdContext(cmd.Context(), f.outputMode())
+			cc, err := resolveCmdContext(cmd.Context(), f)
 			if err != nil {
 				return err
 			}
@@ -42,45 +44,82 @@ func RunDestroy(ctx context.Context, cc *cmdContext) error {
 	return RunDestroyWithOptions(ctx, cc, DestroyOptions{Force: true})
 }
 
-// RunDestroyWithOptions terminates the VM and deletes local state.
+// RunDestroyWithOptions terminates the VM and deletes local state, along
+// with the project's temporary VMs unless cc is for one of them itself.
 // Without Force, shows a warning about data loss and exits without destroying.
 func RunDestroyWithOptions(ctx context.Context, cc *cmdContext, opts DestroyOptions) error {
 	w := cc.Output
-	w.Infof("project: %s", cc.Project.DisplayName)
+	w.Infof("project: %s", cc.Project.Label())
 
 	vmState, err := cc.State.LoadVM(cc.Project.Hash)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			w.Info("no VM found — nothing to destroy")
-			return nil
-		}
+	hasVM := err == nil
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		return fmt.Errorf("loading VM state: %w", err)
 	}
 
+	// Temporary VMs launched with --size, --region or --spot go too: one
+	// left running because yg couldn't terminate it would bill forever.
+	var variants []provider.VMInfo
+	if cc.Project.Variant == "" {
+		if variants, err = variantVMs(ctx, cc); err != nil {
+			return fmt.Errorf("querying temporary VMs: %w", err)
+		}
+	}
+
+	if !hasVM && len(variants) == 0 {
+		w.Info("no VM found — nothing to destroy")
+		return nil
+	}
+
 	if !opts.Force {
 		w.Warn("destroying this VM will permanently delete:", "")
 		w.Info("  - cached build artifacts")
 		w.Info("  - installed packages and toolchains")
 		w.Info("  - accumulated state from previous runs")
+		for _, vm := range variants {
+			w.Infof("  - temporary VM %s (%s)", vm.InstanceID, vm.Variant)
+		}
 		w.Info("")
 		w.Info("your run history and logs in S3 are not affected.")
 		w.Hint("run again with --force to proceed")
 		return nil
 	}
 
+	if hasVM {
+		// Try to terminate in AWS (best-effort — might already be gone).
+		info, err := cc.Provider.FindVM(ctx, cc.Project.Hash)
+		if err != nil {
+			return fmt.Errorf("querying VM state: %w", err)
+		}
+		if info == nil {
+			w.Infof("VM %s no longer exists in AWS", vmState.InstanceID)
+		}
+		if err := destroyVM(ctx, cc, cc.Project.Hash, info); err != nil {
+			return err
+		}
+	}
+	for _, vm := range variants {
+		if err := destroyVM(ctx, cc, cc.Project.WithVariant(vm.Variant).Hash, &vm); err != nil {
+			return err
+		}
+	}
+
+	w.Success("VM destroyed and local state cleaned up")
+	return nil
+}
+
+// destroyVM stops the monitor daemon of the VM with the given project hash,
+// terminates the VM unless info is nil, and deletes its local state.
+func destroyVM(ctx context.Context, cc *cmdContext, hash string, info *provider.VMInfo) error {
+	w := cc.Output
+
 	// Stop any running monitor daemon before destroying.
-	m := monitor.New(cc.Project.Hash, cc.State, cc.Provider, 0) // grace period doesn't matter for Stop
+	m := monitor.New(hash, cc.State, cc.Provider, 0) // grace period doesn't matter for Stop
 	if err := m.Stop(); err != nil {
 		// Log but don't fail - we still want to destroy even if monitor cleanup fails.
 		w.Warn(fmt.Sprintf("failed to stop monitor daemon: %v", err), "")
 	}
 
-	// Try to terminate in AWS (best-effort — might already be gone).
-	info, err := cc.Provider.FindVM(ctx, cc.Project.Hash)
-	if err != nil {
-		return fmt.Errorf("querying VM state: %w", err)
-	}
-
 	if info != nil {
 		w.StartSpinner(fmt.Sprintf("terminating VM %s...", info.InstanceID))
 		if err := cc.Provider.TerminateVM(ctx, info.InstanceID); err != nil {
@@ -88,15 +127,11 @@ func RunDestroyWithOptions(ctx context.Context, cc *cmdContext, opts DestroyOpti
 			return err
 		}
 		w.StopSpinner(fmt.Sprintf("terminated VM %s", info.InstanceID), true)
-	} else {
-		w.Infof("VM %s no longer exists in AWS", vmState.InstanceID)
 	}
 
 	// Clean up local state.
-	if err := cc.State.DeleteVM(cc.Project.Hash); err != nil {
+	if err := cc.State.DeleteVM(hash); err != nil {
 		return fmt.Errorf("cleaning up local state: %w", err)
 	}
-
-	w.Success("VM destroyed and local state cleaned up")
 	return nil
 }
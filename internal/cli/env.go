@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+
+	fkexec "github.com/gridlhq/yeager/internal/exec"
+	"github.com/gridlhq/yeager/internal/provision"
+)
+
+// envNameRe matches env var names accepted from flags and env files.
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// envList is an ordered set of env vars where later values replace earlier ones.
+type envList struct {
+	vars  []fkexec.EnvVar
+	index map[string]int
+}
+
+func (l *envList) set(name, value string) {
+	l.add(fkexec.EnvVar{Name: name, Value: value})
+}
+
+func (l *envList) add(v fkexec.EnvVar) {
+	if l.index == nil {
+		l.index = map[string]int{}
+	}
+	if i, ok := l.index[v.Name]; ok {
+		l.vars[i] = v
+		return
+	}
+	l.index[v.Name] = len(l.vars)
+	l.vars = append(l.vars, v)
+}
+
+// resolveCommandEnv collects the env for a remote command. Later sources
+// win: [env] vars, passthrough, secrets, then --env-file and --env.
+func resolveCommandEnv(ctx context.Context, cc *cmdContext) ([]fkexec.EnvVar, error) {
+	var env envList
+	cfg := cc.Config.Env
+
+	for _, kv := range cfg.Vars {
+		name, value, _ := strings.Cut(kv, "=")
+		env.set(name, value)
+	}
+
+	for _, name := range cfg.Passthrough {
+		value, ok := cc.LookupEnv(name)
+		if !ok {
+			slog.Debug("passthrough variable not set locally", "name", name)
+			continue
+		}
+		env.set(name, value)
+	}
+
+	for _, kv := range cfg.Secrets {
+		name, ref, _ := strings.Cut(kv, "=")
+		value, err := cc.ResolveSecret(ctx, ref)
+		if err != nil {
+			return nil, fmt.Errorf("resolving secret %s: %w", name, err)
+		}
+		env.set(name, value)
+	}
+
+	if cc.EnvFile != "" {
+		data, err := os.ReadFile(cc.EnvFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading env file: %w", err)
+		}
+		vars, err := parseEnvFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", cc.EnvFile, err)
+		}
+		for _, v := range vars {
+			env.set(v.Name, v.Value)
+		}
+	}
+
+	for _, arg := range cc.EnvFlags {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !envNameRe.MatchString(name) {
+			return nil, fmt.Errorf("invalid --env %q: want KEY=VALUE or KEY", arg)
+		}
+		if !hasValue {
+			var ok bool
+			if value, ok = cc.LookupEnv(name); !ok {
+				return nil, fmt.Errorf("--env %s: not set in the local environment", name)
+			}
+		}
+		env.set(name, value)
+	}
+
+	return env.vars, nil
+}
+
+// withLanguageEnv puts the env the languages set, like devcontainer
+// remoteEnv, before env, whose values win.
+func withLanguageEnv(langs []provision.Language, env []fkexec.EnvVar) []fkexec.EnvVar {
+	var l envList
+	for _, lang := range langs {
+		for _, kv := range lang.Env {
+			name, word, _ := strings.Cut(kv, "=")
+			l.add(fkexec.EnvVar{Name: name, Value: word, Shell: true})
+		}
+	}
+	for _, v := range env {
+		l.add(v)
+	}
+	return l.vars
+}
+
+// parseEnvFile parses a dotenv file: KEY=VALUE lines, with optional
+// "export " prefixes, # comments, and single- or double-quoted values.
+func parseEnvFile(data []byte) ([]fkexec.EnvVar, error) {
+	var vars []fkexec.EnvVar
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !envNameRe.MatchString(name) {
+			return nil, fmt.Errorf("line %d: want KEY=VALUE", n)
+		}
+		value, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		vars = append(vars, fkexec.EnvVar{Name: name, Value: value})
+	}
+	return vars, scanner.Err()
+}
+
+// parseEnvValue unquotes a dotenv value. Single quotes are literal; double
+// quotes allow \n, \" and \\ escapes; unquoted values end at " #".
+func parseEnvValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	switch quote := value[0]; quote {
+	case '\'', '"':
+		end := strings.LastIndexByte(value, quote)
+		if end == 0 {
+			return "", fmt.Errorf("unterminated %c quote", quote)
+		}
+		inner := value[1:end]
+		if quote == '"' {
+			inner = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(inner)
+		}
+		return inner, nil
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value, nil
+}
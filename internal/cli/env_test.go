@@ -0,0 +1,202 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fkexec "github.com/gridlhq/yeager/internal/exec"
+	"github.com/gridlhq/yeager/internal/provider"
+	fkstorage "github.com/gridlhq/yeager/internal/storage"
+	fksync "github.com/gridlhq/yeager/internal/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func fakeLookupEnv(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+}
+
+func TestParseEnvFile(t *testing.T) {
+	t.Parallel()
+
+	data := `
+# comment
+PLAIN=value
+export EXPORTED=yes
+SPACED = padded  # trailing comment
+SINGLE='it has # and \n'
+DOUBLE="line1\nline2 \"q\""
+EMPTY=
+URL=postgres://u:p@host/db?x=1
+`
+	vars, err := parseEnvFile([]byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, []fkexec.EnvVar{
+		{Name: "PLAIN", Value: "value"},
+		{Name: "EXPORTED", Value: "yes"},
+		{Name: "SPACED", Value: "padded"},
+		{Name: "SINGLE", Value: `it has # and \n`},
+		{Name: "DOUBLE", Value: "line1\nline2 \"q\""},
+		{Name: "EMPTY", Value: ""},
+		{Name: "URL", Value: "postgres://u:p@host/db?x=1"},
+	}, vars)
+
+	_, err = parseEnvFile([]byte("OK=1\nnot a var\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 2")
+
+	_, err = parseEnvFile([]byte(`A="open`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unterminated")
+}
+
+func TestResolveCommandEnv_Precedence(t *testing.T) {
+	t.Parallel()
+
+	cc, _, _ := testCmdContext(t, &mockProvider{})
+	cc.Config.Env.Vars = []string{"A=vars", "B=vars", "C=vars", "D=vars"}
+	cc.Config.Env.Passthrough = []string{"B", "MISSING"}
+	cc.Config.Env.Secrets = []string{"C=ssm:/c"}
+	cc.LookupEnv = fakeLookupEnv(map[string]string{"B": "local", "SHELL_VAR": "from-shell"})
+	cc.ResolveSecret = func(ctx context.Context, ref string) (string, error) {
+		return "secret:" + ref, nil
+	}
+	cc.EnvFile = filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(cc.EnvFile, []byte("D=file\nE=file\n"), 0o600))
+	cc.EnvFlags = []string{"E=flag", "SHELL_VAR"}
+
+	env, err := resolveCommandEnv(context.Background(), cc)
+	require.NoError(t, err)
+	assert.Equal(t, []fkexec.EnvVar{
+		{Name: "A", Value: "vars"},
+		{Name: "B", Value: "local"},
+		{Name: "C", Value: "secret:ssm:/c"},
+		{Name: "D", Value: "file"},
+		{Name: "E", Value: "flag"},
+		{Name: "SHELL_VAR", Value: "from-shell"},
+	}, env)
+}
+
+func TestResolveCommandEnv_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		setup   func(cc *cmdContext)
+		wantErr string
+	}{
+		{"secret fetch fails", func(cc *cmdContext) {
+			cc.Config.Env.Secrets = []string{"TOKEN=ssm:/t"}
+		}, "resolving secret TOKEN: AccessDenied"},
+		{"bad flag", func(cc *cmdContext) {
+			cc.EnvFlags = []string{"1BAD=x"}
+		}, `invalid --env "1BAD=x"`},
+		{"unset flag var", func(cc *cmdContext) {
+			cc.EnvFlags = []string{"NOPE"}
+		}, "--env NOPE: not set"},
+		{"missing env file", func(cc *cmdContext) {
+			cc.EnvFile = "/nonexistent/.env"
+		}, "reading env file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cc, _, _ := testCmdContext(t, &mockProvider{})
+			cc.LookupEnv = fakeLookupEnv(nil)
+			cc.ResolveSecret = func(ctx context.Context, ref string) (string, error) {
+				return "", fmt.Errorf("AccessDenied")
+			}
+			tt.setup(cc)
+			_, err := resolveCommandEnv(context.Background(), cc)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestRunCommand_EnvFile(t *testing.T) {
+	t.Parallel()
+
+	prov := &mockProvider{
+		findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+			return &provider.VMInfo{InstanceID: "i-env001", State: "running", PublicIP: "10.0.0.1", Region: "us-east-1"}, nil
+		},
+	}
+	cc, stdout, _ := testCmdContext(t, prov)
+	saveTestVMState(t, cc.State, cc.Project.Hash)
+	cc.Project.AbsPath = t.TempDir()
+	cc.Config.Env.Secrets = []string{"API_KEY=ssm:/app/key"}
+	cc.ResolveSecret = func(ctx context.Context, ref string) (string, error) {
+		return "hunter2", nil
+	}
+	cc.EnvFlags = []string{"DEBUG=1"}
+	writeDevcontainer(t, cc.Project.AbsPath, `{"remoteEnv": {"APP_TOKEN": "tok3n", "DEBUG": "0"}}`)
+
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
+		return &fksync.SyncResult{}, nil
+	}
+	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
+		return nil, nil
+	}
+	var writtenPath string
+	var written []fkexec.EnvVar
+	cc.WriteEnvFile = func(client *gossh.Client, remotePath string, env []fkexec.EnvVar) error {
+		writtenPath, written = remotePath, env
+		return nil
+	}
+	var gotOpts fkexec.RunOpts
+	cc.RunExec = func(client *gossh.Client, opts fkexec.RunOpts, stdout, stderr io.Writer) (*fkexec.RunResult, error) {
+		gotOpts = opts
+		return &fkexec.RunResult{RunID: opts.RunID, StartTime: time.Now().UTC(), EndTime: time.Now().UTC()}, nil
+	}
+	cc.NewStorage = func(ctx context.Context) (*fkstorage.Store, error) {
+		return nil, fmt.Errorf("test: no S3")
+	}
+
+	_, err := RunCommand(context.Background(), cc, "make deploy")
+	require.NoError(t, err)
+
+	assert.Equal(t, fkexec.EnvFileName(gotOpts.RunID), gotOpts.EnvFile)
+	assert.Equal(t, remoteProjectDir+"/"+gotOpts.EnvFile, writtenPath)
+	assert.Equal(t, []fkexec.EnvVar{
+		{Name: "APP_TOKEN", Value: "'tok3n'", Shell: true},
+		{Name: "DEBUG", Value: "1"},
+		{Name: "API_KEY", Value: "hunter2"},
+	}, written, "devcontainer env comes first; [env] and --env win")
+
+	assert.Equal(t, "make deploy", gotOpts.Command)
+	assert.NotContains(t, fmt.Sprint(gotOpts.Init), "hunter2")
+	assert.NotContains(t, fmt.Sprint(gotOpts.Init), "tok3n")
+	assert.NotContains(t, stdout.String(), "hunter2")
+	history, err := cc.State.LoadRunHistory(cc.Project.Hash)
+	require.NoError(t, err)
+	assert.NotContains(t, fmt.Sprint(history), "hunter2")
+}
+
+func TestRunCommand_SecretFailureStopsEarly(t *testing.T) {
+	t.Parallel()
+
+	cc, _, _ := testCmdContext(t, &mockProvider{
+		findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+			t.Fatal("the VM must not be touched when a secret cannot be resolved")
+			return nil, nil
+		},
+	})
+	cc.Config.Env.Secrets = []string{"API_KEY=ssm:/app/key"}
+	cc.ResolveSecret = func(ctx context.Context, ref string) (string, error) {
+		return "", fmt.Errorf("ParameterNotFound")
+	}
+
+	_, err := RunCommand(context.Background(), cc, "make deploy")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "resolving secret API_KEY")
+}
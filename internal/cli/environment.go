@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+
+	"github.com/gridlhq/yeager/internal/provision"
+	fksync "github.com/gridlhq/yeager/internal/sync"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// envWarning is a provisioning problem shown to the user, with a fix.
+type envWarning struct {
+	msg string
+	fix string
+}
+
+// detectLanguages returns the languages to provision for the project:
+// runtimes detected across the workspace plus the devcontainer, translated
+// to the VM. In devcontainer container mode only the devcontainer itself is
+// provisioned, since commands run inside its image.
+// With [exec] container, only Docker is provisioned and commands run inside
+// the project's own image.
+// Configured [services] come first; they're started, by startServices,
+// before dependency installs and [setup] commands run.
+// Also returns warnings about settings that can't be applied.
+func detectLanguages(cc *cmdContext) ([]provision.Language, []envWarning) {
+	langs, warnings := detectRuntimes(cc)
+	if services, ok := provision.ServicesLanguage(cc.Config); ok {
+		langs = append([]provision.Language{services}, langs...)
+	}
+	return langs, warnings
+}
+
+// detectRuntimes returns the languages that provide the command
+// environment: see detectLanguages.
+func detectRuntimes(cc *cmdContext) ([]provision.Language, []envWarning) {
+	if cc.Config.Exec.Container.Enabled() {
+		lang, err := provision.ExecContainer(cc.Project.AbsPath, cc.Config.Exec.Container)
+		if err != nil {
+			return nil, []envWarning{{
+				msg: fmt.Sprintf("can't run commands in a container: %s", err),
+				fix: "fix [exec] container in .yeager.toml",
+			}}
+		}
+		return []provision.Language{lang}, nil
+	}
+
+	langs := provision.DetectWorkspaceLanguages(cc.Project.AbsPath, cc.Config.Workspace.Members, cc.Config.Devcontainer.Ignore, syncExcluded(cc))
+	if cc.Config.Devcontainer.Ignore {
+		return langs, nil
+	}
+
+	dc, err := provision.LoadDevcontainer(cc.Project.AbsPath)
+	if err != nil {
+		return langs, []envWarning{{
+			msg: fmt.Sprintf("ignoring devcontainer: %s", err),
+			fix: "fix the file, or set devcontainer.ignore = true in .yeager.toml",
+		}}
+	}
+	if dc == nil {
+		return langs, nil
+	}
+	if cc.Config.Exec.Container.Devcontainer {
+		return []provision.Language{dc.ContainerLanguage()}, nil
+	}
+
+	lang, unsupported := dc.Language(langs)
+	var warnings []envWarning
+	for _, ref := range unsupported {
+		warnings = append(warnings, envWarning{
+			msg: fmt.Sprintf("devcontainer feature %s has no VM equivalent — skipped", ref),
+			fix: "run commands inside the devcontainer image: set exec.container.devcontainer = true in .yeager.toml",
+		})
+	}
+	return append(langs, lang), warnings
+}
+
+// syncExcluded reports the paths sync leaves out, by .gitignore,
+// .yeagerignore and [sync], so workspace detection doesn't take a fixture
+// or build output for a member. Nil if the filter can't be built.
+func syncExcluded(cc *cmdContext) provision.ExcludeFunc {
+	filter, err := fksync.NewFilter(cc.Project.AbsPath, cc.Config.Sync, nil)
+	if err != nil {
+		slog.Debug("workspace: sync filter unavailable", "error", err)
+		return nil
+	}
+	return func(rel string) bool {
+		d, err := filter.Explain(rel)
+		return err == nil && d.Excluded
+	}
+}
+
+// execEnvironment returns the shell init and wrapper for remote commands.
+// In container mode, commands run through the container's wrapper, which
+// applies the container's own env.
+func execEnvironment(langs []provision.Language) (init []string, wrapper string) {
+	return provision.ShellInit(langs), provision.ExecWrapper(langs)
+}
+
+// prepareEnvironment readies the exec environment on an existing VM, e.g.
+// starts the container after the VM was stopped or rebuilds a pruned
+// Nix dev shell. Best-effort: if it fails,
+// the command's own failure explains why.
+func prepareEnvironment(cc *cmdContext, client *gossh.Client, langs []provision.Language) {
+	cmds := provision.PrepareCommands(langs)
+	if len(cmds) == 0 || cc.RunScript == nil {
+		return
+	}
+	w := cc.Output
+	w.StartSpinner("preparing environment...")
+	var out bytes.Buffer
+	if err := cc.RunScript(client, remoteProjectDir, cmds, &out, &out); err != nil {
+		w.StopSpinner("environment setup failed", false)
+		w.Warn(fmt.Sprintf("environment setup failed: %s", err), "rerun with --verbose to see the setup output")
+		slog.Debug("environment setup output", "output", out.String())
+		return
+	}
+	w.StopSpinner("environment ready", true)
+}
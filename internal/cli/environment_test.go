@@ -0,0 +1,285 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	fkexec "github.com/gridlhq/yeager/internal/exec"
+	"github.com/gridlhq/yeager/internal/provider"
+	"github.com/gridlhq/yeager/internal/provision"
+	"github.com/gridlhq/yeager/internal/state"
+	fkstorage "github.com/gridlhq/yeager/internal/storage"
+	fksync "github.com/gridlhq/yeager/internal/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const testDevcontainerJSON = `{
+	// comments are allowed
+	"features": {
+		"ghcr.io/devcontainers/features/terraform:1": {},
+		"ghcr.io/devcontainers/features/desktop-lite:1": {}
+	},
+	"postCreateCommand": "make setup",
+	"remoteEnv": {"APP_ENV": "test"}
+}`
+
+func writeDevcontainer(t *testing.T, dir, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".devcontainer"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".devcontainer", "devcontainer.json"), []byte(content), 0o644))
+}
+
+func TestDetectLanguages_SkipsIgnoredMembers(t *testing.T) {
+	t.Parallel()
+
+	cc, _, _ := testCmdContext(t, &mockProvider{})
+	cc.Project.AbsPath = t.TempDir()
+	for name, content := range map[string]string{
+		".gitignore":                    "generated/\n",
+		".yeagerignore":                 "e2e/\n",
+		"go.mod":                        "module m\n",
+		"generated/client/package.json": "{}",
+		"e2e/requirements.txt":          "pytest\n",
+	} {
+		path := filepath.Join(cc.Project.AbsPath, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	langs, _ := detectLanguages(cc)
+	require.Len(t, langs, 1)
+	assert.Equal(t, provision.Go, langs[0].Name)
+}
+
+func TestDetectLanguages_Devcontainer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("translated to the VM", func(t *testing.T) {
+		t.Parallel()
+		cc, _, _ := testCmdContext(t, &mockProvider{})
+		cc.Project.AbsPath = t.TempDir()
+		writeDevcontainer(t, cc.Project.AbsPath, testDevcontainerJSON)
+		require.NoError(t, os.WriteFile(filepath.Join(cc.Project.AbsPath, "go.mod"), []byte("module m\n\ngo 1.22.0\n"), 0o644))
+
+		langs, warnings := detectLanguages(cc)
+		require.Len(t, langs, 2)
+		assert.Equal(t, provision.Go, langs[0].Name)
+		assert.Equal(t, provision.Devcontainer, langs[1].Name)
+		assert.Equal(t, []string{"make setup"}, langs[1].DepInstall)
+
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0].msg, "desktop-lite")
+		assert.Contains(t, warnings[0].fix, "exec.container.devcontainer = true")
+
+		init, wrapper := execEnvironment(langs)
+		assert.NotContains(t, fmt.Sprint(init), "APP_ENV")
+		assert.Empty(t, wrapper)
+		assert.Equal(t, []fkexec.EnvVar{{Name: "APP_ENV", Value: "'test'", Shell: true}}, withLanguageEnv(langs, nil))
+	})
+
+	t.Run("container mode", func(t *testing.T) {
+		t.Parallel()
+		cc, _, _ := testCmdContext(t, &mockProvider{})
+		cc.Project.AbsPath = t.TempDir()
+		cc.Config.Exec.Container.Devcontainer = true
+		writeDevcontainer(t, cc.Project.AbsPath, testDevcontainerJSON)
+		require.NoError(t, os.WriteFile(filepath.Join(cc.Project.AbsPath, "go.mod"), []byte("module m\n"), 0o644))
+
+		langs, warnings := detectLanguages(cc)
+		require.Len(t, langs, 1, "host runtimes aren't installed in container mode")
+		assert.Equal(t, provision.Devcontainer, langs[0].Name)
+		assert.Empty(t, warnings)
+
+		init, wrapper := execEnvironment(langs)
+		assert.Empty(t, init)
+		assert.Equal(t, langs[0].Wrapper, wrapper)
+	})
+
+	t.Run("ignored", func(t *testing.T) {
+		t.Parallel()
+		cc, _, _ := testCmdContext(t, &mockProvider{})
+		cc.Project.AbsPath = t.TempDir()
+		cc.Config.Devcontainer.Ignore = true
+		writeDevcontainer(t, cc.Project.AbsPath, testDevcontainerJSON)
+
+		langs, warnings := detectLanguages(cc)
+		assert.Empty(t, langs)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("ignored versions", func(t *testing.T) {
+		t.Parallel()
+		cc, _, _ := testCmdContext(t, &mockProvider{})
+		cc.Project.AbsPath = t.TempDir()
+		cc.Config.Devcontainer.Ignore = true
+		writeDevcontainer(t, cc.Project.AbsPath, `{"features": {"ghcr.io/devcontainers/features/node:1": {"version": "20"}}}`)
+		require.NoError(t, os.WriteFile(filepath.Join(cc.Project.AbsPath, "package.json"), []byte("{}"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(cc.Project.AbsPath, ".nvmrc"), []byte("18\n"), 0o644))
+
+		langs, _ := detectLanguages(cc)
+		require.Len(t, langs, 1)
+		assert.Equal(t, "18", langs[0].Version, "the devcontainer doesn't pin the version")
+	})
+
+	t.Run("malformed file warns", func(t *testing.T) {
+		t.Parallel()
+		cc, _, _ := testCmdContext(t, &mockProvider{})
+		cc.Project.AbsPath = t.TempDir()
+		writeDevcontainer(t, cc.Project.AbsPath, `{"features": `)
+
+		langs, warnings := detectLanguages(cc)
+		assert.Empty(t, langs)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0].msg, "ignoring devcontainer")
+	})
+}
+
+func TestRunCommand_DevcontainerContainerMode(t *testing.T) {
+	t.Parallel()
+
+	prov := &mockProvider{
+		findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+			return &provider.VMInfo{InstanceID: "i-dc001", State: "running", PublicIP: "10.0.0.1", Region: "us-east-1"}, nil
+		},
+	}
+	cc, _, _ := testCmdContext(t, prov)
+	cc.Project.AbsPath = t.TempDir()
+	cc.Config.Exec.Container.Devcontainer = true
+	writeDevcontainer(t, cc.Project.AbsPath, testDevcontainerJSON)
+
+	dc, err := provision.LoadDevcontainer(cc.Project.AbsPath)
+	require.NoError(t, err)
+	require.NoError(t, cc.State.SaveVM(cc.Project.Hash, state.VMState{
+		InstanceID:      "i-dc001",
+		Region:          "us-east-1",
+		RuntimeVersions: provision.RuntimeVersions([]provision.Language{dc.ContainerLanguage()}),
+		DepsHash:        provision.DependencyHash(cc.Project.AbsPath, []provision.Language{dc.ContainerLanguage()}, cc.Config.Setup),
+	}))
+
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
+		return &fksync.SyncResult{}, nil
+	}
+	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
+		return nil, nil
+	}
+	var scripts []string
+	cc.RunScript = func(client *gossh.Client, workDir string, commands []string, stdout, stderr io.Writer) error {
+		scripts = append(scripts, strings.Join(commands, "\n"))
+		return nil
+	}
+	var gotOpts fkexec.RunOpts
+	cc.RunExec = func(client *gossh.Client, opts fkexec.RunOpts, stdout, stderr io.Writer) (*fkexec.RunResult, error) {
+		gotOpts = opts
+		return &fkexec.RunResult{RunID: opts.RunID, StartTime: time.Now().UTC(), EndTime: time.Now().UTC()}, nil
+	}
+	cc.NewStorage = func(ctx context.Context) (*fkstorage.Store, error) {
+		return nil, fmt.Errorf("test: no S3")
+	}
+
+	_, err = RunCommand(context.Background(), cc, "make test")
+	require.NoError(t, err)
+
+	require.Len(t, scripts, 1, "only devcontainer up runs on a warm VM")
+	assert.Contains(t, scripts[0], "devcontainer up --workspace-folder /home/ubuntu/project")
+	assert.Equal(t, "make test", gotOpts.Command)
+	assert.Equal(t, dc.ContainerLanguage().Wrapper, gotOpts.Wrapper)
+	assert.Empty(t, gotOpts.Init)
+}
+
+func TestDetectLanguages_ExecContainer(t *testing.T) {
+	t.Parallel()
+
+	cc, _, _ := testCmdContext(t, &mockProvider{})
+	cc.Project.AbsPath = t.TempDir()
+	cc.Config.Exec.Container.Dockerfile = "Dockerfile"
+	writeDevcontainer(t, cc.Project.AbsPath, testDevcontainerJSON)
+	require.NoError(t, os.WriteFile(filepath.Join(cc.Project.AbsPath, "Dockerfile"), []byte("FROM golang:1.22\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(cc.Project.AbsPath, "go.mod"), []byte("module m\n"), 0o644))
+
+	langs, warnings := detectLanguages(cc)
+	require.Len(t, langs, 1, "the container takes precedence over host runtimes and the devcontainer")
+	assert.Equal(t, provision.Container, langs[0].Name)
+	assert.Empty(t, warnings)
+
+	init, wrapper := execEnvironment(langs)
+	assert.Empty(t, init)
+	assert.Contains(t, wrapper, "sudo docker run")
+}
+
+func TestRunCommand_ExecContainerMissingDockerfile(t *testing.T) {
+	t.Parallel()
+
+	prov := &mockProvider{
+		findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+			t.Fatal("the VM must not be touched")
+			return nil, nil
+		},
+	}
+	cc, _, _ := testCmdContext(t, prov)
+	cc.Project.AbsPath = t.TempDir()
+	cc.Config.Exec.Container.Dockerfile = "Dockerfile.ci"
+
+	code, err := RunCommand(context.Background(), cc, "make test")
+	require.Error(t, err)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, err.Error(), "exec.container.dockerfile")
+}
+
+func TestRunCommand_NixDevShell(t *testing.T) {
+	t.Parallel()
+
+	prov := &mockProvider{
+		findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+			return &provider.VMInfo{InstanceID: "i-nix001", State: "running", PublicIP: "10.0.0.1", Region: "us-east-1"}, nil
+		},
+	}
+	cc, _, _ := testCmdContext(t, prov)
+	cc.Project.AbsPath = t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(cc.Project.AbsPath, "flake.nix"), []byte("{ }\n"), 0o644))
+
+	langs, _ := detectLanguages(cc)
+	require.Len(t, langs, 1)
+	require.Equal(t, provision.Nix, langs[0].Name)
+	require.NoError(t, cc.State.SaveVM(cc.Project.Hash, state.VMState{
+		InstanceID:      "i-nix001",
+		Region:          "us-east-1",
+		RuntimeVersions: provision.RuntimeVersions(langs),
+		DepsHash:        provision.DependencyHash(cc.Project.AbsPath, langs, cc.Config.Setup),
+	}))
+
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
+		return &fksync.SyncResult{}, nil
+	}
+	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
+		return nil, nil
+	}
+	var scripts []string
+	cc.RunScript = func(client *gossh.Client, workDir string, commands []string, stdout, stderr io.Writer) error {
+		scripts = append(scripts, strings.Join(commands, "\n"))
+		return nil
+	}
+	var gotOpts fkexec.RunOpts
+	cc.RunExec = func(client *gossh.Client, opts fkexec.RunOpts, stdout, stderr io.Writer) (*fkexec.RunResult, error) {
+		gotOpts = opts
+		return &fkexec.RunResult{RunID: opts.RunID, StartTime: time.Now().UTC(), EndTime: time.Now().UTC()}, nil
+	}
+	cc.NewStorage = func(ctx context.Context) (*fkstorage.Store, error) {
+		return nil, fmt.Errorf("test: no S3")
+	}
+
+	_, err := RunCommand(context.Background(), cc, "make test")
+	require.NoError(t, err)
+
+	require.Len(t, scripts, 1, "only the cached dev shell check runs on a warm VM")
+	assert.Equal(t, strings.Join(langs[0].Prepare, "\n"), scripts[0])
+	assert.Equal(t, langs[0].Wrapper, gotOpts.Wrapper)
+	assert.Empty(t, gotOpts.Init)
+}
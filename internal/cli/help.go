@@ -2,8 +2,10 @@ package cli
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
+	"github.com/gridlhq/yeager/internal/config"
 	"github.com/gridlhq/yeager/internal/output"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -90,8 +92,8 @@ func renderHelp(cmd *cobra.Command, _ []string) {
 	fmt.Fprintln(w)
 
 	// Commands — grouped by purpose (gh-style layout).
-	mainOrder := []string{"status", "logs", "kill", "stop", "up", "destroy"}
-	setupOrder := []string{"configure", "init"}
+	mainOrder := []string{"status", "logs", "kill", "pull", "sync", "watch", "run", "tasks", "services", "stop", "up", "destroy"}
+	setupOrder := []string{"configure", "init", "config"}
 
 	// Build name→command lookup from registered subcommands.
 	subByName := make(map[string]*cobra.Command)
@@ -99,6 +101,9 @@ func renderHelp(cmd *cobra.Command, _ []string) {
 		subByName[sub.Name()] = sub
 	}
 
+	// Tasks from the project's .yeager.toml, if any.
+	cfg := loadTasksConfig()
+
 	// Calculate padding based on longest command name.
 	maxLen := 0
 	for _, names := range [][]string{mainOrder, setupOrder} {
@@ -109,6 +114,9 @@ func renderHelp(cmd *cobra.Command, _ []string) {
 			}
 		}
 	}
+	for _, name := range cfg.TaskNames() {
+		maxLen = max(maxLen, len("yg run "+name))
+	}
 
 	// Daily-use commands.
 	fmt.Fprintf(w, "%s\n", s.bold("Commands:"))
@@ -136,6 +144,8 @@ func renderHelp(cmd *cobra.Command, _ []string) {
 	}
 	fmt.Fprintln(w)
 
+	writeTaskHelp(w, s, cfg, maxLen)
+
 	// Examples.
 	if cmd.HasExample() {
 		fmt.Fprintf(w, "%s\n", s.bold("Examples:"))
@@ -177,6 +187,20 @@ func renderHelp(cmd *cobra.Command, _ []string) {
 	fmt.Fprintf(w, "  %s\n", s.dim("  Use \"yg <command> --help\" for more information about a command."))
 }
 
+// writeTaskHelp lists the project's tasks, if it has any.
+func writeTaskHelp(w io.Writer, s style, cfg config.Config, width int) {
+	if len(cfg.Tasks) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s\n", s.bold("Tasks:"))
+	for _, name := range cfg.TaskNames() {
+		full := "yg run " + name
+		padded := full + strings.Repeat(" ", max(width-len(full), 0))
+		fmt.Fprintf(w, "  %s   %s\n", s.greenBold(padded), s.dim(taskSummary(cfg.Tasks[name])))
+	}
+	fmt.Fprintln(w)
+}
+
 // rpad right-pads a string to the given minimum width.
 func rpad(s string, minWidth int) string {
 	if len(s) >= minWidth {
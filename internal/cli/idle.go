@@ -19,6 +19,7 @@ type IdleMonitor struct {
 	connectSSH   SSHClientFactory
 	listRuns     ListRunsFunc
 	vmInfo       *provider.VMInfo
+	terminate    bool
 
 	// For testing: override the clock.
 	now func() time.Time
@@ -33,6 +34,7 @@ type IdleMonitorOpts struct {
 	ConnectSSH   SSHClientFactory
 	ListRuns     ListRunsFunc
 	VMInfo       *provider.VMInfo
+	Terminate    bool // terminate the VM instead of stopping it, for temporary VMs
 }
 
 // NewIdleMonitor creates an idle monitor. Returns nil if timeout is zero (disabled).
@@ -52,6 +54,7 @@ func NewIdleMonitor(opts IdleMonitorOpts) *IdleMonitor {
 		connectSSH:   opts.ConnectSSH,
 		listRuns:     opts.ListRuns,
 		vmInfo:       opts.VMInfo,
+		terminate:    opts.Terminate,
 		now:          time.Now,
 	}
 }
@@ -82,7 +85,7 @@ func (m *IdleMonitor) CheckAndStop(ctx context.Context) bool {
 		return false
 	}
 	slog.Info("idle check: no active runs, stopping VM", "instance", m.instanceID)
-	if err := m.provider.StopVM(ctx, m.instanceID); err != nil {
+	if err := m.halt(ctx); err != nil {
 		slog.Debug("idle check: stop failed", "error", err)
 		return false
 	}
@@ -120,7 +123,7 @@ func (m *IdleMonitor) run(ctx context.Context) {
 			if now.Sub(idleSince) >= m.idleTimeout {
 				slog.Info("idle monitor: stopping VM", "instance", m.instanceID,
 					"idle_for", now.Sub(idleSince).Truncate(time.Second))
-				if err := m.provider.StopVM(ctx, m.instanceID); err != nil {
+				if err := m.halt(ctx); err != nil {
 					slog.Debug("idle monitor: stop failed", "error", err)
 				}
 				return
@@ -129,6 +132,14 @@ func (m *IdleMonitor) run(ctx context.Context) {
 	}
 }
 
+// halt stops the VM, or terminates it if it's temporary.
+func (m *IdleMonitor) halt(ctx context.Context) error {
+	if m.terminate {
+		return m.provider.TerminateVM(ctx, m.instanceID)
+	}
+	return m.provider.StopVM(ctx, m.instanceID)
+}
+
 // checkActiveRuns connects via SSH and checks for active marker files.
 func (m *IdleMonitor) checkActiveRuns(ctx context.Context) (bool, error) {
 	client, err := m.connectSSH(ctx, m.vmInfo)
@@ -103,6 +103,50 @@ func TestIdleMonitor_StopsVMAfterIdleTimeout(t *testing.T) {
 	assert.True(t, stopCalled.Load(), "StopVM should have been called after idle timeout")
 }
 
+func TestIdleMonitor_TerminatesTemporaryVM(t *testing.T) {
+	t.Parallel()
+
+	var terminateCalled atomic.Bool
+
+	prov := &mockProvider{
+		stopVMFn: func(ctx context.Context, instanceID string) error {
+			t.Error("a temporary VM must not be stopped")
+			return nil
+		},
+		terminateVMFn: func(ctx context.Context, instanceID string) error {
+			assert.Equal(t, "i-idle002", instanceID)
+			terminateCalled.Store(true)
+			return nil
+		},
+	}
+
+	m := NewIdleMonitor(IdleMonitorOpts{
+		IdleTimeout:  50 * time.Millisecond,
+		PollInterval: 10 * time.Millisecond,
+		InstanceID:   "i-idle002",
+		Provider:     prov,
+		VMInfo:       &provider.VMInfo{InstanceID: "i-idle002", State: "running"},
+		ConnectSSH: func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
+			return nil, nil
+		},
+		ListRuns: func(client *gossh.Client) ([]fkexec.ActiveRun, error) {
+			return nil, nil
+		},
+		Terminate: true,
+	})
+	require.NotNil(t, m)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	select {
+	case <-m.Start(ctx):
+	case <-time.After(2 * time.Second):
+		t.Fatal("monitor did not terminate VM within timeout")
+	}
+	assert.True(t, terminateCalled.Load())
+}
+
 func TestIdleMonitor_ResetsTimerWhenRunsActive(t *testing.T) {
 	t.Parallel()
 
@@ -11,7 +11,7 @@ import (
 )
 
 func newInitCmd(f *flags) *cobra.Command {
-	var force bool
+	var force, printCloudInit bool
 
 	cmd := &cobra.Command{
 		Use:   "init",
@@ -26,11 +26,15 @@ to customize settings before your first run.`,
 			if err != nil {
 				return fmt.Errorf("getting working directory: %w", err)
 			}
+			if printCloudInit {
+				return RunPrintCloudInit(cwd, f, output.New(f.outputMode()))
+			}
 			return RunInit(cwd, force, f.outputMode())
 		},
 	}
 
 	cmd.Flags().BoolVar(&force, "force", false, "overwrite existing .yeager.toml")
+	cmd.Flags().BoolVar(&printCloudInit, "print-cloud-init", false, "print the cloud-init a new VM would boot with, and exit")
 
 	return cmd
 }
@@ -63,3 +67,23 @@ func RunInitWithWriter(dir string, force bool, w *output.Writer) error {
 	w.Hint("next: yg <command>")
 	return nil
 }
+
+// RunPrintCloudInit prints the cloud-init document a new VM for the project
+// in dir would get, with the profile and overrides f selects, then
+// validates it. Nothing is written or launched.
+func RunPrintCloudInit(dir string, f *flags, w *output.Writer) error {
+	proj, cfg, temporary, err := resolveProjectConfig(w, dir, f)
+	if err != nil {
+		return err
+	}
+
+	cc := &cmdContext{Project: proj, Config: cfg, Output: w, Temporary: temporary}
+	langs, warnings := detectLanguages(cc)
+	for _, warning := range warnings {
+		w.Warn(warning.msg, warning.fix)
+	}
+
+	ci := vmCloudInit(cc, langs)
+	w.Stream([]byte(ci.Render()))
+	return ci.Validate()
+}
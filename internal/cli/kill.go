@@ -21,7 +21,7 @@ active run IDs.`,
   yg kill 007          # cancel run 007`,
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cc, err := resolveCmdContext(cmd.Context(), f.outputMode())
+			cc, err := resolveCmdContext(cmd.Context(), f)
 			if err != nil {
 				return err
 			}
@@ -40,7 +40,7 @@ active run IDs.`,
 // RunKill cancels a running command on the VM.
 func RunKill(ctx context.Context, cc *cmdContext, runID string) error {
 	w := cc.Output
-	w.Infof("project: %s", cc.Project.DisplayName)
+	w.Infof("project: %s", cc.Project.Label())
 
 	// Resolve run ID.
 	if runID == "" {
@@ -25,7 +25,7 @@ If the run is complete, replays from S3.`,
   yg logs --tail 50    # last 50 lines, then stream if active`,
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cc, err := resolveCmdContext(cmd.Context(), f.outputMode())
+			cc, err := resolveCmdContext(cmd.Context(), f)
 			if err != nil {
 				return err
 			}
@@ -51,7 +51,7 @@ If the run is complete, replays from S3.`,
 // If the run is complete, replays from S3.
 func RunLogs(ctx context.Context, cc *cmdContext, runID string, tail int) error {
 	w := cc.Output
-	w.Infof("project: %s", cc.Project.DisplayName)
+	w.Infof("project: %s", cc.Project.Label())
 
 	// Resolve run ID.
 	if runID == "" {
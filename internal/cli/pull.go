@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/gridlhq/yeager/internal/output"
+	fksync "github.com/gridlhq/yeager/internal/sync"
+	"github.com/spf13/cobra"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func newPullCmd(f *flags) *cobra.Command {
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "pull [paths...]",
+		Short: "Copy files changed on the VM back into the project",
+		Long: `Copies files that changed on the VM since the last sync back into the
+local project. Without paths, pulls the [sync] pull paths, which are also
+pulled after every run. Paths use the same patterns as [sync] exclude.
+
+A file that was also edited locally since the last sync is left alone and
+reported. --force overwrites it with the VM's copy.`,
+		Example: `  yg pull                        # pull the [sync] pull paths
+  yg pull src/generated/         # pull one directory
+  yg pull --force Cargo.lock     # take the VM's copy even if edited here`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cc, err := resolveCmdContext(cmd.Context(), f)
+			if err != nil {
+				return err
+			}
+			return RunPull(cmd.Context(), cc, args, force)
+		},
+	}
+	cmd.Flags().BoolVar(&force, "force", false, "overwrite files edited locally since the last sync")
+	return cmd
+}
+
+// RunPull copies files changed on the VM back into the project.
+func RunPull(ctx context.Context, cc *cmdContext, paths []string, force bool) error {
+	w := cc.Output
+	w.Infof("project: %s", cc.Project.Label())
+
+	if len(paths) == 0 {
+		paths = cc.Config.Sync.Pull
+	}
+	if len(paths) == 0 {
+		err := errors.New("nothing to pull")
+		w.Error(err.Error(), `pass paths (yg pull src/generated/) or set [sync] pull = ["src/generated/"] in .yeager.toml`)
+		return displayed(err)
+	}
+
+	vmState, err := cc.State.LoadVM(cc.Project.Hash)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			w.Info("no VM found — nothing to pull")
+			return nil
+		}
+		return fmt.Errorf("loading VM state: %w", err)
+	}
+
+	info, err := cc.Provider.FindVM(ctx, cc.Project.Hash)
+	if err != nil {
+		return fmt.Errorf("querying VM state: %w", err)
+	}
+	if info == nil {
+		w.Infof("VM %s no longer exists", vmState.InstanceID)
+		return nil
+	}
+	if info.State != "running" {
+		w.Infof("VM %s is %s — start it with: yg up", info.InstanceID, info.State)
+		return nil
+	}
+
+	client, err := cc.ConnectSSH(ctx, info)
+	if err != nil {
+		return fmt.Errorf("SSH connection failed: %w", err)
+	}
+	if client != nil {
+		defer client.Close()
+	}
+
+	w.StartSpinner("pulling changed files...")
+	result, err := cc.RunPull(ctx, cc, client, paths, force)
+	if err != nil {
+		w.StopSpinner("pull failed", false)
+		return fmt.Errorf("pulling files: %w", err)
+	}
+	reportPull(w, result)
+	return nil
+}
+
+// pullAfterRun pulls the [sync] pull paths once a command has finished.
+// This is best-effort — a failure is warned about, not fatal.
+func pullAfterRun(ctx context.Context, cc *cmdContext, client *gossh.Client) {
+	w := cc.Output
+	w.StartSpinner("pulling changed files...")
+	result, err := cc.RunPull(ctx, cc, client, cc.Config.Sync.Pull, false)
+	if err != nil {
+		w.StopSpinner("pull failed", false)
+		w.Warn(fmt.Sprintf("failed to pull changed files: %s", err), "retry with: yg pull")
+		return
+	}
+	reportPull(w, result)
+}
+
+// reportPull stops the pull spinner with what was pulled and warns about
+// each conflict.
+func reportPull(w *output.Writer, r *fksync.PullResult) {
+	switch n := len(r.Pulled); {
+	case n == 0:
+		w.StopSpinner("nothing to pull", true)
+	case n == 1:
+		w.StopSpinner(fmt.Sprintf("pulled %s (%s)", r.Pulled[0], fksync.FormatBytes(r.Bytes)), true)
+	default:
+		w.StopSpinner(fmt.Sprintf("pulled %d files (%s)", n, fksync.FormatBytes(r.Bytes)), true)
+	}
+	for _, p := range r.Conflicts {
+		w.Warn(fmt.Sprintf("kept local %s: it changed here and on the VM", p), "take the VM's copy with: yg pull --force "+p)
+	}
+}
+
+// defaultPullFunc pulls over the run's SSH connection, whatever the sync
+// engine: conflicts are checked against the native engine's manifest, and
+// without one any differing local file counts as a conflict.
+func defaultPullFunc(ctx context.Context, cc *cmdContext, client *gossh.Client, patterns []string, force bool) (*fksync.PullResult, error) {
+	if client == nil {
+		return nil, fmt.Errorf("no SSH connection to pull over")
+	}
+	opts := syncOptions(cc)
+	opts.SyncConfig.Pull = patterns
+	result, err := fksync.Pull(ctx, fksync.SSHRemote{Client: client}, opts, force)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
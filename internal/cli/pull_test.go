@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	fkexec "github.com/gridlhq/yeager/internal/exec"
+	"github.com/gridlhq/yeager/internal/provider"
+	fkstorage "github.com/gridlhq/yeager/internal/storage"
+	fksync "github.com/gridlhq/yeager/internal/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// pullCall records one RunPull call.
+type pullCall struct {
+	patterns []string
+	force    bool
+}
+
+// pullTestContext returns a context with a running VM whose pulls are
+// recorded and answered with result.
+func pullTestContext(t *testing.T, vmState string, result *fksync.PullResult) (*cmdContext, *[]pullCall, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+	prov := &mockProvider{
+		findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+			return &provider.VMInfo{InstanceID: "i-test001", State: vmState, PublicIP: "10.0.0.1"}, nil
+		},
+	}
+	cc, stdout, stderr := testCmdContext(t, prov)
+	saveTestVMState(t, cc.State, cc.Project.Hash)
+
+	var calls []pullCall
+	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
+		return nil, nil
+	}
+	cc.RunPull = func(ctx context.Context, cc *cmdContext, client *gossh.Client, patterns []string, force bool) (*fksync.PullResult, error) {
+		calls = append(calls, pullCall{patterns: patterns, force: force})
+		return result, nil
+	}
+	return cc, &calls, stdout, stderr
+}
+
+func TestRunPull(t *testing.T) {
+	t.Parallel()
+
+	t.Run("pulls the configured paths", func(t *testing.T) {
+		t.Parallel()
+		cc, calls, stdout, stderr := pullTestContext(t, "running", &fksync.PullResult{
+			Pulled:    []string{"gen/a.go", "gen/b.go"},
+			Conflicts: []string{"gen/c.go"},
+			Bytes:     2048,
+		})
+		cc.Config.Sync.Pull = []string{"gen/"}
+
+		require.NoError(t, RunPull(context.Background(), cc, nil, false))
+		assert.Equal(t, []pullCall{{patterns: []string{"gen/"}}}, *calls)
+		assert.Contains(t, stdout.String(), "pulled 2 files (2.0 KB)")
+		assert.Contains(t, stderr.String(), "kept local gen/c.go: it changed here and on the VM")
+		assert.Contains(t, stderr.String(), "yg pull --force gen/c.go")
+	})
+
+	t.Run("paths override the config", func(t *testing.T) {
+		t.Parallel()
+		cc, calls, stdout, _ := pullTestContext(t, "running", &fksync.PullResult{})
+		cc.Config.Sync.Pull = []string{"gen/"}
+
+		require.NoError(t, RunPull(context.Background(), cc, []string{"Cargo.lock"}, true))
+		assert.Equal(t, []pullCall{{patterns: []string{"Cargo.lock"}, force: true}}, *calls)
+		assert.Contains(t, stdout.String(), "nothing to pull")
+	})
+
+	t.Run("needs paths", func(t *testing.T) {
+		t.Parallel()
+		cc, calls, _, stderr := pullTestContext(t, "running", &fksync.PullResult{})
+
+		err := RunPull(context.Background(), cc, nil, false)
+		require.Error(t, err)
+		var de *displayedError
+		assert.True(t, errors.As(err, &de), "the error is already shown")
+		assert.Empty(t, *calls)
+		assert.Contains(t, stderr.String(), "[sync] pull")
+	})
+
+	t.Run("stopped VM", func(t *testing.T) {
+		t.Parallel()
+		cc, calls, stdout, _ := pullTestContext(t, "stopped", &fksync.PullResult{})
+
+		require.NoError(t, RunPull(context.Background(), cc, []string{"gen/"}, false))
+		assert.Empty(t, *calls)
+		assert.Contains(t, stdout.String(), "is stopped")
+	})
+
+	t.Run("pull error", func(t *testing.T) {
+		t.Parallel()
+		cc, _, _, _ := pullTestContext(t, "running", nil)
+		cc.RunPull = func(ctx context.Context, cc *cmdContext, client *gossh.Client, patterns []string, force bool) (*fksync.PullResult, error) {
+			return nil, fmt.Errorf("tar: not found")
+		}
+
+		err := RunPull(context.Background(), cc, []string{"gen/"}, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "pulling files: tar: not found")
+	})
+}
+
+func TestRunCommand_PullsAfterRun(t *testing.T) {
+	t.Parallel()
+
+	cc, calls, stdout, _ := pullTestContext(t, "running", &fksync.PullResult{Pulled: []string{"Cargo.lock"}, Bytes: 10})
+	cc.Config.Sync.Pull = []string{"Cargo.lock"}
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
+		return &fksync.SyncResult{}, nil
+	}
+	cc.RunExec = func(client *gossh.Client, opts fkexec.RunOpts, stdout, stderr io.Writer) (*fkexec.RunResult, error) {
+		assert.Empty(t, *calls, "pull waits for the command")
+		return &fkexec.RunResult{RunID: opts.RunID, ExitCode: 1, StartTime: time.Now(), EndTime: time.Now()}, nil
+	}
+	cc.NewStorage = func(ctx context.Context) (*fkstorage.Store, error) {
+		return nil, fmt.Errorf("test: no S3")
+	}
+
+	exitCode, err := RunCommand(context.Background(), cc, "cargo update")
+	require.NoError(t, err)
+	assert.Equal(t, 1, exitCode)
+	assert.Equal(t, []pullCall{{patterns: []string{"Cargo.lock"}}}, *calls, "pulled even when the command fails")
+	assert.Contains(t, stdout.String(), "pulled Cargo.lock (10 B)")
+}
@@ -34,6 +34,15 @@ type flags struct {
 	json    bool
 	quiet   bool
 	verbose bool
+	profile string
+	env     []string
+	envFile string
+
+	// Compute overrides for this invocation.
+	size      string
+	region    string
+	spot      bool
+	ephemeral bool
 }
 
 func (f *flags) outputMode() output.Mode {
@@ -123,18 +132,32 @@ Your laptop stays free for editing. The cloud does the compute.`,
 	root.PersistentFlags().BoolVarP(&f.json, "json", "j", false, "output in JSON format")
 	root.PersistentFlags().BoolVarP(&f.quiet, "quiet", "q", false, "suppress yeager messages, show only command output")
 	root.PersistentFlags().BoolVarP(&f.verbose, "verbose", "v", false, "enable debug logging")
+	root.PersistentFlags().StringVar(&f.profile, "profile", "", "use a [profiles.<name>] config overlay and its own VM (or set YEAGER_PROFILE)")
+	root.PersistentFlags().StringVar(&f.size, "size", "", "use a temporary VM of this size: small, medium, large, xlarge")
+	root.PersistentFlags().StringVar(&f.region, "region", "", "use a temporary VM in this AWS region")
+	root.PersistentFlags().BoolVar(&f.spot, "spot", false, "use a temporary spot instance")
+	root.PersistentFlags().BoolVar(&f.ephemeral, "ephemeral", false, "terminate the VM once idle instead of stopping it")
+	root.Flags().StringArrayVar(&f.env, "env", nil, "env var for the command: KEY=VALUE, or KEY to copy yours")
+	root.Flags().StringVar(&f.envFile, "env-file", "", "load env vars for the command from a dotenv file")
 
 	root.AddCommand(
 		// Daily-use commands (ordered by frequency).
 		newStatusCmd(f),
 		newLogsCmd(f),
 		newKillCmd(f),
+		newPullCmd(f),
+		newWatchCmd(f),
+		newSyncCmd(f),
+		newRunCmd(f),
+		newTasksCmd(f),
+		newServicesCmd(f),
 		newStopCmd(f),
 		newUpCmd(f),
 		newDestroyCmd(f),
 		// Setup commands (typically run once).
 		newConfigureCmd(f),
 		newInitCmd(f),
+		newConfigCmd(f),
 		// Hidden internal commands.
 		newMonitorDaemonCmd(),
 	)
@@ -160,10 +183,12 @@ func runRemoteCommand(cmd *cobra.Command, args []string, f *flags) error {
 
 	command := strings.Join(args, " ")
 
-	cc, err := resolveCmdContext(cmd.Context(), f.outputMode())
+	cc, err := resolveCmdContext(cmd.Context(), f)
 	if err != nil {
 		return err
 	}
+	cc.EnvFlags = f.env
+	cc.EnvFile = f.envFile
 
 	exitCode, err := RunCommand(cmd.Context(), cc, command)
 	if err != nil {
@@ -9,9 +9,12 @@ import (
 	"log/slog"
 	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/gridlhq/yeager/internal/config"
 	"github.com/gridlhq/yeager/internal/monitor"
 	"github.com/gridlhq/yeager/internal/output"
 	"github.com/gridlhq/yeager/internal/provider"
@@ -25,60 +28,34 @@ import (
 	gossh "golang.org/x/crypto/ssh"
 )
 
-const remoteProjectDir = "/home/ubuntu/project"
+const remoteProjectDir = config.RemoteProjectDir
 
 // RunCommand executes a command on the remote VM.
-// This is the core execution path: ensure VM → sync → execute → stream → upload.
+// This is the core execution path: ensure VM → connect → sync → execute → stream → upload.
 // Returns the exit code from the remote command.
 func RunCommand(ctx context.Context, cc *cmdContext, command string) (int, error) {
 	w := cc.Output
-	w.Infof("project: %s", cc.Project.DisplayName)
+	w.Infof("project: %s", cc.Project.Label())
 
-	// Step 0: Cancel any existing grace period monitor (new activity).
-	// This is best-effort — if it fails, we still proceed with the command.
-	cancelGracePeriodMonitor(cc)
-
-	// Step 1: Ensure VM is running.
-	vmInfo, freshVM, err := ensureVMRunning(ctx, cc)
+	// Steps 0-3: Ensure the VM is running, connect and sync.
+	sess, err := openSession(ctx, cc)
 	if err != nil {
-		printError(w, err)
-		return 1, displayed(err)
+		return 1, err
 	}
+	defer sess.close()
+	vmInfo, client, env := sess.vmInfo, sess.client, sess.env
+	init, wrapper := execEnvironment(sess.langs)
 
-	// Step 2: Sync files.
-	w.StartSpinner("syncing files...")
-	syncResult, err := cc.RunSync(ctx, cc, vmInfo)
-	if err != nil {
-		w.StopSpinner("sync failed", false)
-		return 1, fmt.Errorf("syncing files: %w", err)
-	}
-	if syncResult != nil {
-		w.StopSpinner(formatSyncResult(syncResult, freshVM), true)
-	} else {
-		w.StopSpinner("synced", true)
-	}
-
-	// Step 3: Establish SSH connection for command execution.
-	if freshVM {
-		w.StartSpinner("installing toolchain (first run)...")
-	} else {
-		w.StartSpinner("connecting...")
-	}
-	client, err := cc.ConnectSSH(ctx, vmInfo)
-	if err != nil {
-		w.StopSpinner("connection failed", false)
-		if freshVM {
-			w.Hint("the VM may still be provisioning — wait a minute and try again")
+	// Step 4: Execute command. Env values go through a private file the
+	// command deletes, so they stay out of the command line and run metadata.
+	runID := fkexec.GenerateRunID()
+	envFile := ""
+	if len(env) > 0 {
+		envFile = fkexec.EnvFileName(runID)
+		if err := cc.WriteEnvFile(client, remoteProjectDir+"/"+envFile, env); err != nil {
+			return 1, err
 		}
-		return 1, fmt.Errorf("SSH connection failed: %w", err)
 	}
-	w.StopSpinner("connected", true)
-	if client != nil {
-		defer client.Close()
-	}
-
-	// Step 4: Execute command.
-	runID := fkexec.GenerateRunID()
 	w.Infof("running: %s", command)
 	w.Hint("Ctrl+C detaches — the command keeps running on the VM.")
 	w.Separator()
@@ -92,6 +69,10 @@ func RunCommand(ctx context.Context, cc *cmdContext, command string) (int, error
 		Command: command,
 		WorkDir: remoteProjectDir,
 		RunID:   runID,
+		Init:    init,
+		Wrapper: wrapper,
+		EnvFile: envFile,
+		Timeout: cc.Task.timeout(),
 	}, stdoutWriter, stderrWriter)
 
 	w.Separator()
@@ -125,6 +106,11 @@ func RunCommand(ctx context.Context, cc *cmdContext, command string) (int, error
 		slog.Debug("failed to save last run ID", "error", err)
 	}
 
+	// Step 5b: Copy back [sync] pull paths the command changed (best-effort).
+	if len(cc.Config.Sync.Pull) > 0 {
+		pullAfterRun(ctx, cc, client)
+	}
+
 	// Step 6: Save run history (best-effort).
 	if err := cc.State.SaveRunHistory(cc.Project.Hash, state.RunHistoryEntry{
 		RunID:     runID.String(),
@@ -162,6 +148,213 @@ func RunCommand(ctx context.Context, cc *cmdContext, command string) (int, error
 	return result.ExitCode, nil
 }
 
+// session is an SSH connection to a running VM whose project files are
+// synced and whose toolchain is ready to run commands.
+type session struct {
+	vmInfo  *provider.VMInfo
+	client  *gossh.Client
+	freshVM bool
+	langs   []provision.Language
+	env     []fkexec.EnvVar // resolved language, [env] and --env vars for commands
+}
+
+func (s *session) close() {
+	if s.client != nil {
+		s.client.Close()
+	}
+}
+
+// openSession gets the project ready to run commands on its VM. Errors
+// from starting the VM are already displayed.
+func openSession(ctx context.Context, cc *cmdContext) (*session, error) {
+	w := cc.Output
+
+	// Step 0: Cancel any existing grace period monitor (new activity).
+	// This is best-effort — if it fails, we still proceed with the command.
+	cancelGracePeriodMonitor(cc)
+
+	// Fail before touching the VM rather than silently run the command
+	// outside the configured container.
+	if cc.Config.Exec.Container.Enabled() {
+		if _, err := provision.ExecContainer(cc.Project.AbsPath, cc.Config.Exec.Container); err != nil {
+			return nil, err
+		}
+	}
+
+	// Resolve env (and fetch secrets) up front so a typo fails fast.
+	env, err := resolveCommandEnv(ctx, cc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Step 1: Ensure VM is running.
+	vmInfo, freshVM, err := ensureVMRunning(ctx, cc)
+	if err != nil {
+		printError(w, err)
+		return nil, displayed(err)
+	}
+
+	// Step 2: Establish the SSH connection used to sync and run the command.
+	if freshVM {
+		w.StartSpinner("installing toolchain (first run)...")
+	} else {
+		w.StartSpinner("connecting...")
+	}
+	client, err := cc.ConnectSSH(ctx, vmInfo)
+	if err != nil {
+		w.StopSpinner("connection failed", false)
+		if freshVM {
+			w.Hint("the VM may still be provisioning — wait a minute and try again")
+		}
+		return nil, fmt.Errorf("SSH connection failed: %w", err)
+	}
+	w.StopSpinner("connected", true)
+	sess := &session{vmInfo: vmInfo, client: client, freshVM: freshVM, env: env}
+
+	// Step 3: Sync files.
+	if err := syncProject(ctx, cc, sess); err != nil {
+		sess.close()
+		return nil, err
+	}
+
+	// Step 3b: On an existing VM, apply any runtime version bumps in place.
+	// Then install dependencies now that project files exist, unless they
+	// were already, for these lockfiles.
+	sess.langs, _ = detectLanguages(cc)
+	sess.env = withLanguageEnv(sess.langs, sess.env)
+	if !freshVM {
+		reprovisionRuntimes(cc, client, sess.langs)
+	}
+	if err := startServices(cc, client); err != nil {
+		sess.close()
+		return nil, err
+	}
+	installDependencies(cc, client, sess.langs)
+	if !freshVM {
+		prepareEnvironment(cc, client, sess.langs)
+	}
+	return sess, nil
+}
+
+// syncProject syncs the project files to the session's VM with a spinner.
+func syncProject(ctx context.Context, cc *cmdContext, sess *session) error {
+	w := cc.Output
+	w.StartSpinner("syncing files...")
+	syncResult, err := cc.RunSync(ctx, cc, sess.vmInfo, sess.client)
+	if err != nil {
+		w.StopSpinner("sync failed", false)
+		var tooLarge *fksync.TooLargeError
+		if errors.As(err, &tooLarge) {
+			w.Error(err.Error(), tooLargeFix(tooLarge))
+			return displayed(err)
+		}
+		return fmt.Errorf("syncing files: %w", err)
+	}
+	if syncResult != nil {
+		w.StopSpinner(formatSyncResult(syncResult, sess.freshVM), true)
+	} else {
+		w.StopSpinner("synced", true)
+	}
+	if syncResult != nil && len(syncResult.Deleted) > 0 {
+		// Files made on the VM are deleted too; say so, so it's no surprise.
+		fix := ""
+		for _, p := range syncResult.Deleted {
+			// Mounts' paths start with "../" or "/"; preserve is for the project.
+			if !strings.HasPrefix(p, "../") && !strings.HasPrefix(p, "/") {
+				fix = fmt.Sprintf("if they should stay, set [sync] preserve = [%q] in .yeager.toml", p)
+				break
+			}
+		}
+		w.Warn(fmt.Sprintf("sync deleted from the VM: %s", describeChanges(syncResult.Deleted)), fix)
+	}
+	return nil
+}
+
+// tooLargeFix suggests what to do about a sync over max_sync_bytes.
+func tooLargeFix(e *fksync.TooLargeError) string {
+	return fmt.Sprintf("if the VM doesn't need %s, set [sync] exclude = [%q] in .yeager.toml, or raise [sync] max_sync_bytes; yg sync --dry-run lists the largest files", e.Biggest().Path, e.Biggest().Path)
+}
+
+// installDependencies runs per-language dependency installs and [setup] run
+// commands after a sync, unless the VM state records an install that
+// succeeded for the same commands and lockfiles: after the first sync on a
+// new VM (yg up's too), after a lockfile changes, and after a failure.
+// This is best-effort — a failure is warned about and the command still runs.
+func installDependencies(cc *cmdContext, client *gossh.Client, langs []provision.Language) {
+	cmds := provision.PostSyncCommands(langs, cc.Config.Setup)
+	if len(cmds) == 0 || cc.RunScript == nil {
+		return
+	}
+	// Without VM state, install every time rather than never.
+	vmState, stateErr := cc.State.LoadVM(cc.Project.Hash)
+	if stateErr != nil {
+		slog.Debug("installDependencies: loading VM state failed", "error", stateErr)
+	}
+	hash := provision.DependencyHash(cc.Project.AbsPath, langs, cc.Config.Setup)
+	if stateErr == nil && vmState.DepsHash == hash {
+		return
+	}
+
+	w := cc.Output
+	w.StartSpinner("installing dependencies...")
+	var out bytes.Buffer
+	if err := cc.RunScript(client, remoteProjectDir, cmds, &out, &out); err != nil {
+		w.StopSpinner("dependency install failed", false)
+		w.Warn(fmt.Sprintf("dependency install failed: %s", err), "rerun with --verbose to see the install output; it's retried on the next run")
+		slog.Debug("dependency install output", "output", out.String())
+		return
+	}
+	w.StopSpinner("dependencies installed", true)
+
+	if stateErr != nil {
+		return
+	}
+	vmState.DepsHash = hash
+	if err := cc.State.SaveVM(cc.Project.Hash, vmState); err != nil {
+		slog.Debug("installDependencies: saving VM state failed", "error", err)
+	}
+}
+
+// reprovisionRuntimes installs runtimes whose pinned version changed since the
+// VM was provisioned, then reinstalls their dependencies. The new versions are
+// recorded only on success so a failed install is retried on the next run.
+// This is best-effort — failures are warned about, not returned.
+func reprovisionRuntimes(cc *cmdContext, client *gossh.Client, langs []provision.Language) {
+	if cc.RunScript == nil {
+		return
+	}
+	vmState, err := cc.State.LoadVM(cc.Project.Hash)
+	if err != nil {
+		slog.Debug("reprovisionRuntimes: loading VM state failed", "error", err)
+		return
+	}
+	changed := provision.ChangedRuntimes(vmState.RuntimeVersions, langs)
+	if len(changed) == 0 {
+		return
+	}
+
+	w := cc.Output
+	for _, lang := range changed {
+		if old, ok := vmState.RuntimeVersions[string(lang.Name)]; ok {
+			w.Infof("%s version changed (%s → %s)", lang.Name, old, lang.Version)
+		}
+	}
+	w.StartSpinner("updating runtimes...")
+	var out bytes.Buffer
+	if err := cc.RunScript(client, remoteProjectDir, provision.ReprovisionCommands(changed), &out, &out); err != nil {
+		w.StopSpinner("runtime update failed", false)
+		w.Warn(fmt.Sprintf("runtime update failed: %s", err), "rerun with --verbose to see the install output")
+		slog.Debug("runtime update output", "output", out.String())
+		return
+	}
+	w.StopSpinner("runtimes updated", true)
+
+	vmState.RuntimeVersions = provision.RuntimeVersions(langs)
+	if err := cc.State.SaveVM(cc.Project.Hash, vmState); err != nil {
+		slog.Debug("reprovisionRuntimes: saving VM state failed", "error", err)
+	}
+}
+
 // checkIdleAndStop checks if the VM should be stopped after a command finishes.
 // If no other commands are running, starts a background monitor that will stop
 // the VM after the grace period elapses. This keeps the VM "warm" for quick
@@ -196,6 +389,15 @@ func checkIdleAndStop(ctx context.Context, cc *cmdContext, vmInfo *provider.VMIn
 	}
 
 	if len(runs) == 0 {
+		// The monitor reads from the VM state whether to stop or terminate,
+		// which follows this invocation's --ephemeral.
+		if vmState, err := cc.State.LoadVM(cc.Project.Hash); err == nil && vmState.Temporary != cc.Temporary {
+			vmState.Temporary = cc.Temporary
+			if err := cc.State.SaveVM(cc.Project.Hash, vmState); err != nil {
+				slog.Debug("checkIdleAndStop: saving VM state failed", "error", err)
+			}
+		}
+
 		// No active commands — start background monitor to stop VM after grace period.
 		m := monitor.New(cc.Project.Hash, cc.State, cc.Provider, gracePeriod)
 		if err := m.Start(); err != nil {
@@ -203,7 +405,11 @@ func checkIdleAndStop(ctx context.Context, cc *cmdContext, vmInfo *provider.VMIn
 			return
 		}
 
-		cc.Output.Infof("VM idle (auto-stopping in %s — run another command to cancel)", formatDuration(gracePeriod))
+		if cc.Temporary {
+			cc.Output.Infof("VM idle (terminating in %s — run another command to cancel)", formatDuration(gracePeriod))
+		} else {
+			cc.Output.Infof("VM idle (auto-stopping in %s — run another command to cancel)", formatDuration(gracePeriod))
+		}
 		cc.Output.Hint("change grace period: .yeager.toml lifecycle.grace_period")
 	}
 }
@@ -343,16 +549,43 @@ func ensureVMRunning(ctx context.Context, cc *cmdContext) (*provider.VMInfo, boo
 	return info, true, err
 }
 
+// vmCloudInit returns the cloud-init document a new VM for cc boots with.
+func vmCloudInit(cc *cmdContext, langs []provision.Language) *provision.CloudInit {
+	ci := provision.GenerateCloudInit(langs, cc.Config.Setup)
+	if cc.Temporary {
+		ci.AddIdleShutdown(temporaryIdleShutdown(cc.Config.Lifecycle))
+	}
+	return ci
+}
+
+// temporaryIdleShutdown is how long a temporary VM stays idle before it
+// powers itself off: lifecycle.idle_stop, but never disabled, and never before the
+// grace period after which yg terminates it from the laptop.
+func temporaryIdleShutdown(lc config.LifecycleConfig) time.Duration {
+	idle, err := lc.IdleStopDuration()
+	if err != nil || idle <= 0 {
+		idle = 10 * time.Minute
+	}
+	grace, _ := lc.GracePeriodDuration()
+	return max(idle, grace)
+}
+
 // createVMForRun handles VM creation and returns the live VMInfo.
 func createVMForRun(ctx context.Context, cc *cmdContext) (*provider.VMInfo, error) {
 	w := cc.Output
 
-	langs := provision.DetectLanguages(cc.Project.AbsPath)
+	langs, warnings := detectLanguages(cc)
 	for _, lang := range langs {
 		w.Infof("detected %s", lang.DisplayName)
 	}
+	for _, warning := range warnings {
+		w.Warn(warning.msg, warning.fix)
+	}
 
-	ci := provision.GenerateCloudInit(langs, cc.Config.Setup)
+	ci := vmCloudInit(cc, langs)
+	if err := ci.Validate(); err != nil {
+		return nil, fmt.Errorf("%w\n       check [setup] in .yeager.toml, then preview: yg init --print-cloud-init", err)
+	}
 	userData := base64.StdEncoding.EncodeToString([]byte(ci.Render()))
 
 	sgID, err := cc.Provider.EnsureSecurityGroup(ctx)
@@ -384,7 +617,11 @@ func createVMForRun(ctx context.Context, cc *cmdContext) (*provider.VMInfo, erro
 	info, err := cc.Provider.CreateVM(ctx, provider.CreateVMOpts{
 		ProjectHash:     cc.Project.Hash,
 		ProjectPath:     cc.Project.AbsPath,
+		Profile:         cc.Project.Profile,
+		Variant:         cc.Project.Variant,
 		Size:            cc.Config.Compute.Size,
+		Spot:            cc.Spot,
+		Temporary:       cc.Temporary,
 		SecurityGroupID: sgID,
 		UserData:        userData,
 	})
@@ -421,6 +658,8 @@ func createVMForRun(ctx context.Context, cc *cmdContext) (*provider.VMInfo, erro
 		ProjectDir:       cc.Project.AbsPath,
 		SetupHash:        setupHash,
 		CloudInitVersion: provision.CloudInitVersion,
+		RuntimeVersions:  provision.RuntimeVersions(langs),
+		Temporary:        cc.Temporary,
 	}); err != nil {
 		w.StopSpinner("VM launched", true)
 		return nil, fmt.Errorf("saving VM state: %w", err)
@@ -492,8 +731,170 @@ func waitForSSH(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, w
 	return fmt.Errorf("SSH not available after %d attempts", maxAttempts)
 }
 
-// defaultSyncFunc runs rsync to sync project files to the VM.
-func defaultSyncFunc(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo) (*fksync.SyncResult, error) {
+// remoteManifestPath is where the native sync engine keeps the manifest of
+// the files it synced to remoteProjectDir.
+const remoteManifestPath = "/home/ubuntu/.yeager/sync-manifest.json"
+
+// remoteStageManifestPath is where the rsync engine keeps the manifest of
+// the large files it staged through S3.
+const remoteStageManifestPath = "/home/ubuntu/.yeager/staged-manifest.json"
+
+// remoteMountManifestDir is where the manifests of [[sync.mounts]] are kept.
+const remoteMountManifestDir = "/home/ubuntu/.yeager/mounts"
+
+// defaultSyncFunc syncs project files, then [[sync.mounts]], to the VM with
+// the configured engine.
+func defaultSyncFunc(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, client *gossh.Client) (*fksync.SyncResult, error) {
+	syncOpts := syncOptions(cc)
+	syncOpts.Progress = func(p fksync.Progress) {
+		cc.Output.UpdateSpinner("syncing files... " + formatSyncProgress(p))
+	}
+	syncOpts.Blobs = stageStore(ctx, cc)
+	result, err := syncDir(ctx, cc, vmInfo, client, syncOpts)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range cc.Config.Sync.Mounts {
+		mountOpts, err := mountSyncOptions(cc, m, syncOpts)
+		if err != nil {
+			return nil, err
+		}
+		mounted, err := syncDir(ctx, cc, vmInfo, client, mountOpts)
+		if err != nil {
+			return nil, fmt.Errorf("syncing %s: %w", m.Local, err)
+		}
+		addSyncResult(result, mounted, m.RemotePath())
+	}
+	return result, nil
+}
+
+// syncDir syncs opts.SourceDir to opts.RemoteDir with the configured engine.
+func syncDir(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, client *gossh.Client, opts fksync.Options) (*fksync.SyncResult, error) {
+	if cc.Config.Sync.Engine == config.SyncEngineRsync {
+		return rsyncToVM(ctx, cc, vmInfo, client, opts)
+	}
+	if client == nil {
+		return nil, fmt.Errorf("no SSH connection to sync over")
+	}
+	result, err := fksync.Push(ctx, fksync.SSHRemote{Client: client}, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// mountSyncOptions returns the options for syncing a [[sync.mounts]]
+// directory, given the project's. Relative paths are resolved against the
+// project, locally and on the VM, so path dependencies resolve the same on
+// both. A mount has only its own excludes, besides .gitignore files and
+// the default excludes.
+func mountSyncOptions(cc *cmdContext, m config.SyncMount, project fksync.Options) (fksync.Options, error) {
+	local := m.Local
+	if !filepath.IsAbs(local) {
+		local = filepath.Join(cc.Project.AbsPath, local)
+	}
+	if info, err := os.Stat(local); err != nil || !info.IsDir() {
+		return fksync.Options{}, fmt.Errorf("[[sync.mounts]] local %q: %s is not a directory", m.Local, local)
+	}
+	remote := m.RemoteDir()
+	manifest := strings.ReplaceAll(strings.TrimPrefix(remote, "/"), "/", "_")
+
+	opts := project
+	opts.SourceDir = local + "/"
+	opts.RemoteDir = remote + "/"
+	opts.ManifestPath = path.Join(remoteMountManifestDir, manifest+".json")
+	opts.StageManifestPath = path.Join(remoteMountManifestDir, manifest+".staged.json")
+	opts.SyncConfig = config.SyncConfig{
+		Exclude:        m.Exclude,
+		Engine:         project.SyncConfig.Engine,
+		MaxSyncBytes:   project.SyncConfig.MaxSyncBytes,
+		LargeFileBytes: project.SyncConfig.LargeFileBytes,
+	}
+	opts.Languages = nil
+	return opts, nil
+}
+
+// addSyncResult adds a mount's sync result to the project's. The mount's
+// deleted paths are prefixed with where it is relative to the project.
+func addSyncResult(total, r *fksync.SyncResult, mount string) {
+	total.FilesTransferred += r.FilesTransferred
+	total.TotalFiles += r.TotalFiles
+	total.BytesTransferred += r.BytesTransferred
+	total.FilesDeleted += r.FilesDeleted
+	total.TotalBytes += r.TotalBytes
+	total.BytesSent += r.BytesSent
+	total.Duration += r.Duration
+	total.FilesStaged += r.FilesStaged
+	total.BytesUploaded += r.BytesUploaded
+	for _, p := range r.Deleted {
+		total.Deleted = append(total.Deleted, mount+"/"+p)
+	}
+}
+
+// stageStore returns where to stage files of [sync] large_file_bytes or
+// more, or nil to send everything over SSH. Staging is only an
+// optimization, so a store that can't be set up is warned about.
+func stageStore(ctx context.Context, cc *cmdContext) fksync.BlobStore {
+	if cc.Config.Sync.LargeFileBytes <= 0 || cc.NewBlobStore == nil {
+		return nil
+	}
+	blobs, err := cc.NewBlobStore(ctx, cc.Project.DisplayName)
+	if err != nil {
+		cc.Output.Warn(fmt.Sprintf("can't stage large files through S3: %s", err), "they're sent over SSH instead")
+		return nil
+	}
+	return blobs
+}
+
+// syncOptions returns the engine-independent sync options for the project.
+func syncOptions(cc *cmdContext) fksync.Options {
+	langs, _ := detectLanguages(cc)
+	var langNames []provision.LanguageName
+	for _, l := range langs {
+		langNames = append(langNames, l.Name)
+	}
+
+	sourceDir := cc.Project.AbsPath
+	if !strings.HasSuffix(sourceDir, "/") {
+		sourceDir += "/"
+	}
+
+	return fksync.Options{
+		SourceDir:    sourceDir,
+		RemoteDir:    remoteProjectDir + "/",
+		ManifestPath: remoteManifestPath,
+		SyncConfig:   cc.Config.Sync,
+		Languages:    langNames,
+
+		StageManifestPath: remoteStageManifestPath,
+	}
+}
+
+// rsyncToVM runs rsync to sync project files to the VM. Large files are
+// staged through S3 first, over client, and rsync leaves them alone.
+func rsyncToVM(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, client *gossh.Client, syncOpts fksync.Options) (*fksync.SyncResult, error) {
+	start := time.Now()
+	var staged fksync.StageResult
+	if syncOpts.SyncConfig.MaxSyncBytes > 0 || (syncOpts.Blobs != nil && client != nil) {
+		filter, err := fksync.NewFilter(syncOpts.SourceDir, syncOpts.SyncConfig, syncOpts.Languages)
+		if err != nil {
+			return nil, err
+		}
+		if err := fksync.CheckSize(filter, syncOpts.SyncConfig.MaxSyncBytes); err != nil {
+			return nil, err
+		}
+		if syncOpts.Blobs != nil && client != nil {
+			syncOpts.Staged, staged, err = fksync.StageLarge(ctx, fksync.SSHRemote{Client: client}, filter, syncOpts)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Older rsyncs, like the one macOS ships, can't report progress.
+	if version, err := exec.CommandContext(ctx, "rsync", "--version").Output(); err != nil || !fksync.RsyncHasProgress2(string(version)) {
+		syncOpts.Progress = nil
+	}
+
 	// Generate ephemeral key for rsync.
 	authorizedKey, privKey, err := fkssh.GenerateEphemeralKeyForSync()
 	if err != nil {
@@ -526,33 +927,17 @@ func defaultSyncFunc(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInf
 	keyFile.Close()
 
 	// Build rsync args.
-	langs := provision.DetectLanguages(cc.Project.AbsPath)
-	var langNames []provision.LanguageName
-	for _, l := range langs {
-		langNames = append(langNames, l.Name)
-	}
-
-	sourceDir := cc.Project.AbsPath
-	if !strings.HasSuffix(sourceDir, "/") {
-		sourceDir += "/"
-	}
-
-	syncOpts := fksync.Options{
-		SourceDir:  sourceDir,
-		RemoteDir:  remoteProjectDir + "/",
-		Host:       vmInfo.PublicIP,
-		User:       "ubuntu",
-		SSHPort:    22,
-		SSHKeyPath: keyFile.Name(),
-		SyncConfig: cc.Config.Sync,
-		Languages:  langNames,
-	}
+	syncOpts.Host = vmInfo.PublicIP
+	syncOpts.User = "ubuntu"
+	syncOpts.SSHPort = 22
+	syncOpts.SSHKeyPath = keyFile.Name()
 
 	args := fksync.BuildArgs(syncOpts)
 	cmd := exec.CommandContext(ctx, "rsync", args...)
 
-	var rsyncOut, rsyncErr bytes.Buffer
-	cmd.Stdout = &rsyncOut
+	rsyncOut := fksync.NewRsyncOutput(syncOpts.Progress)
+	var rsyncErr bytes.Buffer
+	cmd.Stdout = rsyncOut
 	cmd.Stderr = &rsyncErr
 
 	if err := cmd.Run(); err != nil {
@@ -562,13 +947,52 @@ func defaultSyncFunc(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInf
 	}
 
 	result := fksync.ParseStats(rsyncOut.String())
+	result.TotalFiles += len(syncOpts.Staged)
+	result.FilesTransferred += staged.Files
+	result.BytesTransferred += staged.Bytes
+	result.FilesStaged = staged.Files
+	result.BytesUploaded = staged.BytesUploaded
+	result.Duration = time.Since(start)
 	return &result, nil
 }
 
+// formatSyncProgress formats a transfer's progress for the spinner:
+// "120.0 MB of 1.2 GB (10%), 12.3 MB/s".
+func formatSyncProgress(p fksync.Progress) string {
+	s := fksync.FormatBytes(p.Bytes)
+	if p.Total > 0 {
+		pct := min(p.Bytes*100/p.Total, 100)
+		s += fmt.Sprintf(" of %s (%d%%)", fksync.FormatBytes(p.Total), pct)
+	}
+	if p.Rate > 0 {
+		s += fmt.Sprintf(", %s/s", fksync.FormatBytes(int64(p.Rate)))
+	}
+	return s
+}
+
 // formatSyncResult formats a sync result for display.
 // On first run (freshVM=true): "synced 847 files (12.0 MB)"
 // On warm run (freshVM=false): "synced 3 changed files" or "no files changed"
 func formatSyncResult(r *fksync.SyncResult, freshVM bool) string {
+	msg := syncCounts(r, freshVM)
+	// Only a slow sync is worth timing.
+	if r.Duration >= time.Second && r.BytesTransferred > 0 {
+		msg += " in " + formatDuration(r.Duration.Truncate(time.Second))
+	}
+	if r.FilesStaged > 0 {
+		files := "files"
+		if r.FilesStaged == 1 {
+			files = "file"
+		}
+		msg += fmt.Sprintf("; %d large %s came from S3", r.FilesStaged, files)
+		if r.BytesUploaded > 0 {
+			msg += fmt.Sprintf(" (%s uploaded)", fksync.FormatBytes(r.BytesUploaded))
+		}
+	}
+	return msg
+}
+
+func syncCounts(r *fksync.SyncResult, freshVM bool) string {
 	if freshVM {
 		if r.BytesTransferred > 0 {
 			return fmt.Sprintf("synced %d files (%s)", r.TotalFiles, fksync.FormatBytes(r.BytesTransferred))
@@ -631,6 +1055,7 @@ func uploadOutput(ctx context.Context, cc *cmdContext, runID fkexec.RunID, comma
 		StartTime: result.StartTime,
 		EndTime:   result.EndTime,
 		Duration:  formatDuration(result.Duration().Truncate(time.Second)),
+		Task:      cc.Task.meta(),
 	}
 
 	return store.UploadOutput(ctx, cc.Project.DisplayName, runID.String(), stdout, stderr, meta)
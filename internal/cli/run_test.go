@@ -3,13 +3,17 @@ package cli
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gridlhq/yeager/internal/config"
 	fkexec "github.com/gridlhq/yeager/internal/exec"
 	"github.com/gridlhq/yeager/internal/output"
 	"github.com/gridlhq/yeager/internal/provider"
@@ -418,8 +422,10 @@ func TestEnsureVMRunning_StoppedVMSameSizeStarts(t *testing.T) {
 func TestEnsureVMRunning_CreatesNewVM(t *testing.T) {
 	t.Parallel()
 
+	var created provider.CreateVMOpts
 	prov := &mockProvider{
 		createVMFn: func(ctx context.Context, opts provider.CreateVMOpts) (provider.VMInfo, error) {
+			created = opts
 			return provider.VMInfo{
 				InstanceID: "i-new001",
 				State:      "pending",
@@ -453,6 +459,120 @@ func TestEnsureVMRunning_CreatesNewVM(t *testing.T) {
 	// Verify cost indicator is shown during VM creation.
 	assert.Contains(t, stdout.String(), "VM size: medium")
 	assert.Contains(t, stdout.String(), "~$0.034/hr")
+
+	userData, err := base64.StdEncoding.DecodeString(created.UserData)
+	require.NoError(t, err)
+	assert.NotContains(t, string(userData), "yeager-idle-shutdown", "the project's VM is stopped from the laptop")
+}
+
+func TestTemporaryIdleShutdown(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		lifecycle config.LifecycleConfig
+		want      time.Duration
+	}{
+		{"idle_stop", config.LifecycleConfig{IdleStop: "20m", GracePeriod: "2m"}, 20 * time.Minute},
+		{"never before the grace period", config.LifecycleConfig{IdleStop: "5m", GracePeriod: "30m"}, 30 * time.Minute},
+		{"idle_stop disabled", config.LifecycleConfig{IdleStop: "0"}, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, temporaryIdleShutdown(tt.lifecycle))
+		})
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		flags         flags
+		wantVariant   string
+		wantTemporary bool
+		wantSize      string
+		wantRegion    string
+		wantErr       string
+	}{
+		{name: "none", wantSize: "medium", wantRegion: "us-east-1"},
+		{name: "same as config", flags: flags{size: "medium", region: "us-east-1"}, wantSize: "medium", wantRegion: "us-east-1"},
+		{name: "size", flags: flags{size: "xlarge"}, wantVariant: "xlarge", wantTemporary: true, wantSize: "xlarge", wantRegion: "us-east-1"},
+		{name: "region and spot", flags: flags{region: "eu-west-1", spot: true}, wantVariant: "eu-west-1 spot", wantTemporary: true, wantSize: "medium", wantRegion: "eu-west-1"},
+		{name: "ephemeral keeps the VM", flags: flags{ephemeral: true}, wantTemporary: true, wantSize: "medium", wantRegion: "us-east-1"},
+		{name: "invalid size", flags: flags{size: "huge"}, wantErr: `invalid --size "huge"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := config.Defaults()
+			proj := testProject()
+			got, temporary, err := applyOverrides(&cfg, proj, &tt.flags)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVariant, got.Variant)
+			assert.Equal(t, tt.wantVariant == "", got.Hash == proj.Hash, "only a variant changes the VM")
+			assert.Equal(t, tt.wantTemporary, temporary)
+			assert.Equal(t, tt.wantSize, cfg.Compute.Size)
+			assert.Equal(t, tt.wantRegion, cfg.Compute.Region)
+		})
+	}
+}
+
+func TestEnsureVMRunning_SizeOverrideKeepsMainVM(t *testing.T) {
+	t.Parallel()
+
+	var created provider.CreateVMOpts
+	cc, _, _ := testCmdContext(t, nil)
+	mainHash := cc.Project.Hash
+	saveTestVMState(t, cc.State, mainHash)
+
+	proj, temporary, err := applyOverrides(&cc.Config, cc.Project, &flags{size: "xlarge", spot: true})
+	require.NoError(t, err)
+	cc.Project, cc.Temporary, cc.Spot = proj, temporary, true
+
+	cc.Provider = &mockProvider{
+		createVMFn: func(ctx context.Context, opts provider.CreateVMOpts) (provider.VMInfo, error) {
+			created = opts
+			return provider.VMInfo{InstanceID: "i-big001", State: "pending", Region: "us-east-1"}, nil
+		},
+		findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+			require.NotEqual(t, mainHash, projectHash, "the main VM isn't looked at")
+			return &provider.VMInfo{InstanceID: "i-big001", State: "running", PublicIP: "5.6.7.8", Region: "us-east-1", InstanceType: "t4g.2xlarge"}, nil
+		},
+		terminateVMFn: func(ctx context.Context, instanceID string) error {
+			t.Fatal("no VM is terminated for a size override")
+			return nil
+		},
+	}
+	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
+		return nil, nil
+	}
+
+	info, fresh, err := ensureVMRunning(context.Background(), cc)
+	require.NoError(t, err)
+	assert.True(t, fresh)
+	assert.Equal(t, "i-big001", info.InstanceID)
+	assert.Equal(t, "xlarge", created.Size)
+	assert.Equal(t, "xlarge spot", created.Variant)
+	assert.True(t, created.Spot)
+	assert.True(t, created.Temporary)
+	userData, err := base64.StdEncoding.DecodeString(created.UserData)
+	require.NoError(t, err)
+	assert.Contains(t, string(userData), "/etc/cron.d/yeager-idle-shutdown", "a temporary VM powers itself off once idle")
+
+	vmState, err := cc.State.LoadVM(cc.Project.Hash)
+	require.NoError(t, err)
+	assert.True(t, vmState.Temporary)
+	mainState, err := cc.State.LoadVM(mainHash)
+	require.NoError(t, err)
+	assert.Equal(t, "i-existing001", mainState.InstanceID, "the main VM's state is kept")
 }
 
 func TestEnsureVMRunning_StartsStoppedVM(t *testing.T) {
@@ -527,16 +647,19 @@ func TestRunCommand_FullPipeline(t *testing.T) {
 	saveTestVMState(t, cc.State, cc.Project.Hash)
 
 	// Mock sync — just succeed.
-	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo) (*fksync.SyncResult, error) {
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
 		syncCalled = true
 		assert.Equal(t, "10.0.0.1", vmInfo.PublicIP)
 		return &fksync.SyncResult{TotalFiles: 10, FilesTransferred: 3, BytesTransferred: 1024}, nil
 	}
 
-	// Mock SSH connection — return error since we can't run real SSH in tests.
-	// The test verifies the pipeline up to SSH connection.
+	// Mock SSH connection — returns nil client; exec fails since we can't
+	// run real SSH in tests. The test verifies the pipeline up to exec.
 	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
-		return nil, fmt.Errorf("test: SSH not available")
+		return nil, nil
+	}
+	cc.RunExec = func(client *gossh.Client, opts fkexec.RunOpts, stdoutW, stderrW io.Writer) (*fkexec.RunResult, error) {
+		return nil, fmt.Errorf("test: exec not available")
 	}
 
 	// Mock storage.
@@ -546,13 +669,13 @@ func TestRunCommand_FullPipeline(t *testing.T) {
 	}
 
 	_, err := RunCommand(context.Background(), cc, "cargo test")
-	// Will fail at SSH connection stage, which is expected in unit tests.
+	// Will fail at the exec stage, which is expected in unit tests.
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "SSH not available")
+	assert.Contains(t, err.Error(), "exec not available")
 
 	// Verify pipeline reached sync step.
 	assert.True(t, syncCalled, "sync should have been called")
-	// Storage shouldn't be called since we failed at SSH.
+	// Storage shouldn't be called since we failed at exec.
 	assert.False(t, storageCalled)
 
 	// Verify output includes expected messages.
@@ -580,7 +703,10 @@ func TestRunCommand_SyncError(t *testing.T) {
 	cc, _, _ := testCmdContext(t, prov)
 	saveTestVMState(t, cc.State, cc.Project.Hash)
 
-	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo) (*fksync.SyncResult, error) {
+	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
+		return nil, nil
+	}
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
 		return nil, fmt.Errorf("rsync failed: connection refused")
 	}
 
@@ -661,7 +787,7 @@ func TestRunCommand_SSHFailOnFreshVMShowsHint(t *testing.T) {
 	}
 	cc, _, _ := testCmdContext(t, prov)
 
-	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo) (*fksync.SyncResult, error) {
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
 		return &fksync.SyncResult{TotalFiles: 5, FilesTransferred: 5}, nil
 	}
 	// Cancel context after a few SSH attempts to avoid 12x retry delay.
@@ -698,7 +824,7 @@ func TestRunCommand_CtrlCDetach(t *testing.T) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo) (*fksync.SyncResult, error) {
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
 		return &fksync.SyncResult{TotalFiles: 5, FilesTransferred: 1}, nil
 	}
 
@@ -750,7 +876,7 @@ func TestRunCommand_CtrlCDoesNotUploadToS3(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	storageCalled := false
 
-	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo) (*fksync.SyncResult, error) {
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
 		return &fksync.SyncResult{TotalFiles: 5, FilesTransferred: 1}, nil
 	}
 	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
@@ -808,6 +934,30 @@ func TestFormatSyncResult(t *testing.T) {
 			freshVM: false,
 			want:    "no files changed",
 		},
+		{
+			name:    "slow sync is timed",
+			result:  &fksync.SyncResult{TotalFiles: 847, FilesTransferred: 847, BytesTransferred: 1 << 30, Duration: 83500 * time.Millisecond},
+			freshVM: true,
+			want:    "synced 847 files (1.0 GB) in 1m 23s",
+		},
+		{
+			name:    "fast sync isn't",
+			result:  &fksync.SyncResult{TotalFiles: 100, FilesTransferred: 1, BytesTransferred: 512, Duration: 300 * time.Millisecond},
+			freshVM: false,
+			want:    "synced 1 changed file",
+		},
+		{
+			name:    "large files staged through S3",
+			result:  &fksync.SyncResult{TotalFiles: 847, FilesTransferred: 847, BytesTransferred: 3 << 30, FilesStaged: 2, BytesUploaded: 1 << 30, Duration: 40 * time.Second},
+			freshVM: true,
+			want:    "synced 847 files (3.0 GB) in 40s; 2 large files came from S3 (1.0 GB uploaded)",
+		},
+		{
+			name:    "large file already in S3",
+			result:  &fksync.SyncResult{TotalFiles: 100, FilesTransferred: 1, BytesTransferred: 1 << 30, FilesStaged: 1},
+			freshVM: false,
+			want:    "synced 1 changed file; 1 large file came from S3",
+		},
 	}
 
 	for _, tt := range tests {
@@ -836,11 +986,14 @@ func TestRunCommand_SyncProgressOutput_WarmRun(t *testing.T) {
 	cc, stdout, _ := testCmdContext(t, prov)
 	saveTestVMState(t, cc.State, cc.Project.Hash)
 
-	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo) (*fksync.SyncResult, error) {
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
 		return &fksync.SyncResult{TotalFiles: 100, FilesTransferred: 3, BytesTransferred: 4096}, nil
 	}
 	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
-		return nil, fmt.Errorf("test: SSH not available")
+		return nil, nil
+	}
+	cc.RunExec = func(client *gossh.Client, opts fkexec.RunOpts, stdoutW, stderrW io.Writer) (*fkexec.RunResult, error) {
+		return nil, fmt.Errorf("test: exec not available")
 	}
 
 	RunCommand(context.Background(), cc, "cargo test")
@@ -865,11 +1018,14 @@ func TestRunCommand_SyncProgressOutput_NoChanges(t *testing.T) {
 	cc, stdout, _ := testCmdContext(t, prov)
 	saveTestVMState(t, cc.State, cc.Project.Hash)
 
-	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo) (*fksync.SyncResult, error) {
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
 		return &fksync.SyncResult{TotalFiles: 200, FilesTransferred: 0, BytesTransferred: 0}, nil
 	}
 	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
-		return nil, fmt.Errorf("test: SSH not available")
+		return nil, nil
+	}
+	cc.RunExec = func(client *gossh.Client, opts fkexec.RunOpts, stdoutW, stderrW io.Writer) (*fkexec.RunResult, error) {
+		return nil, fmt.Errorf("test: exec not available")
 	}
 
 	RunCommand(context.Background(), cc, "cargo test")
@@ -894,7 +1050,7 @@ func TestRunCommand_SavesRunHistory(t *testing.T) {
 	cc, _, _ := testCmdContext(t, prov)
 	saveTestVMState(t, cc.State, cc.Project.Hash)
 
-	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo) (*fksync.SyncResult, error) {
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
 		return &fksync.SyncResult{}, nil
 	}
 	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
@@ -926,6 +1082,208 @@ func TestRunCommand_SavesRunHistory(t *testing.T) {
 	assert.True(t, history[0].Duration > 0, "duration should be positive")
 }
 
+func TestRunCommand_PassesShellInitForPythonVenv(t *testing.T) {
+	t.Parallel()
+
+	prov := &mockProvider{
+		findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+			return &provider.VMInfo{InstanceID: "i-py001", State: "running", PublicIP: "10.0.0.1", Region: "us-east-1"}, nil
+		},
+	}
+	cc, _, _ := testCmdContext(t, prov)
+	cc.Project.AbsPath = t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(cc.Project.AbsPath, "requirements.txt"), []byte("pytest\n"), 0o644))
+	require.NoError(t, cc.State.SaveVM(cc.Project.Hash, state.VMState{
+		InstanceID:      "i-py001",
+		Region:          "us-east-1",
+		RuntimeVersions: map[string]string{"python": "3"},
+	}))
+
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
+		return &fksync.SyncResult{}, nil
+	}
+	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
+		return nil, nil
+	}
+	scriptCalled := false
+	cc.RunScript = func(client *gossh.Client, workDir string, commands []string, stdout, stderr io.Writer) error {
+		scriptCalled = true
+		return nil
+	}
+	var gotOpts fkexec.RunOpts
+	cc.RunExec = func(client *gossh.Client, opts fkexec.RunOpts, stdout, stderr io.Writer) (*fkexec.RunResult, error) {
+		gotOpts = opts
+		return &fkexec.RunResult{RunID: opts.RunID, StartTime: time.Now().UTC(), EndTime: time.Now().UTC()}, nil
+	}
+	cc.NewStorage = func(ctx context.Context) (*fkstorage.Store, error) {
+		return nil, fmt.Errorf("test: no S3")
+	}
+
+	_, err := RunCommand(context.Background(), cc, "pytest")
+	require.NoError(t, err)
+	assert.Equal(t, "pytest", gotOpts.Command)
+	require.Len(t, gotOpts.Init, 2)
+	assert.Contains(t, gotOpts.Init[0], "mise/shims")
+	assert.Equal(t, "if [ -f .venv/bin/activate ]; then . .venv/bin/activate; fi", gotOpts.Init[1])
+	assert.True(t, scriptCalled, "a VM without a recorded install gets one, even if it isn't new")
+
+	scriptCalled = false
+	_, err = RunCommand(context.Background(), cc, "pytest")
+	require.NoError(t, err)
+	assert.False(t, scriptCalled, "dependencies are installed once per lockfile")
+}
+
+func TestInstallDependencies(t *testing.T) {
+	t.Parallel()
+
+	langs := []provision.Language{{Name: provision.Node, DepInstall: []string{"npm ci"}}}
+
+	t.Run("runs deps and setup in the project dir", func(t *testing.T) {
+		t.Parallel()
+		cc, stdout, _ := testCmdContext(t, &mockProvider{})
+		cc.Config.Setup.Run = []string{"npx playwright install"}
+		var gotDir string
+		var gotCmds []string
+		cc.RunScript = func(client *gossh.Client, workDir string, commands []string, stdout, stderr io.Writer) error {
+			gotDir = workDir
+			gotCmds = commands
+			return nil
+		}
+
+		installDependencies(cc, nil, langs)
+		assert.Equal(t, remoteProjectDir, gotDir)
+		assert.Contains(t, gotCmds, "npm ci")
+		assert.Contains(t, gotCmds, "npx playwright install")
+		assert.Contains(t, stdout.String(), "dependencies installed")
+	})
+
+	t.Run("failure warns and continues", func(t *testing.T) {
+		t.Parallel()
+		cc, _, stderr := testCmdContext(t, &mockProvider{})
+		cc.RunScript = func(client *gossh.Client, workDir string, commands []string, stdout, stderr io.Writer) error {
+			return fmt.Errorf("Process exited with status 1")
+		}
+
+		installDependencies(cc, nil, langs)
+		assert.Contains(t, stderr.String(), "dependency install failed")
+	})
+
+	t.Run("installs again only when the lockfile changes or the last install failed", func(t *testing.T) {
+		t.Parallel()
+		cc, _, _ := testCmdContext(t, &mockProvider{})
+		cc.Project.AbsPath = t.TempDir()
+		lockfile := filepath.Join(cc.Project.AbsPath, "package-lock.json")
+		require.NoError(t, os.WriteFile(lockfile, []byte(`{"v":1}`), 0o644))
+		saveTestVMState(t, cc.State, cc.Project.Hash)
+		installs := 0
+		var installErr error
+		cc.RunScript = func(client *gossh.Client, workDir string, commands []string, stdout, stderr io.Writer) error {
+			installs++
+			return installErr
+		}
+
+		installErr = fmt.Errorf("Process exited with status 1")
+		installDependencies(cc, nil, langs)
+		installErr = nil
+		installDependencies(cc, nil, langs)
+		assert.Equal(t, 2, installs, "a failed install is retried")
+		vmState, err := cc.State.LoadVM(cc.Project.Hash)
+		require.NoError(t, err)
+		assert.Equal(t, provision.DependencyHash(cc.Project.AbsPath, langs, cc.Config.Setup), vmState.DepsHash)
+
+		installDependencies(cc, nil, langs)
+		assert.Equal(t, 2, installs, "installed already")
+
+		require.NoError(t, os.WriteFile(lockfile, []byte(`{"v":2}`), 0o644))
+		installDependencies(cc, nil, langs)
+		assert.Equal(t, 3, installs, "the lockfile changed")
+	})
+
+	t.Run("nothing to install skips the script", func(t *testing.T) {
+		t.Parallel()
+		cc, _, _ := testCmdContext(t, &mockProvider{})
+		called := false
+		cc.RunScript = func(client *gossh.Client, workDir string, commands []string, stdout, stderr io.Writer) error {
+			called = true
+			return nil
+		}
+
+		installDependencies(cc, nil, nil)
+		assert.False(t, called)
+	})
+}
+
+func TestReprovisionRuntimes(t *testing.T) {
+	t.Parallel()
+
+	langs := []provision.Language{
+		{Name: provision.Node, Version: "22", Tool: "node", RuntimeInstall: []string{"install node 22"}, DepInstall: []string{"npm ci"}},
+		{Name: provision.Go, Version: "1.22.0", Tool: "go", RuntimeInstall: []string{"install go"}, DepInstall: []string{"go mod download"}},
+	}
+
+	saveState := func(t *testing.T, cc *cmdContext, versions map[string]string) {
+		t.Helper()
+		require.NoError(t, cc.State.SaveVM(cc.Project.Hash, state.VMState{
+			InstanceID:      "i-existing001",
+			Region:          "us-east-1",
+			RuntimeVersions: versions,
+		}))
+	}
+
+	t.Run("version bump installs only the changed runtime", func(t *testing.T) {
+		t.Parallel()
+		cc, stdout, _ := testCmdContext(t, &mockProvider{})
+		saveState(t, cc, map[string]string{"node": "20", "go": "1.22.0"})
+		var gotCmds []string
+		cc.RunScript = func(client *gossh.Client, workDir string, commands []string, stdout, stderr io.Writer) error {
+			gotCmds = commands
+			return nil
+		}
+
+		reprovisionRuntimes(cc, nil, langs)
+
+		script := strings.Join(gotCmds, "\n")
+		assert.Contains(t, script, "install node 22")
+		assert.Contains(t, script, "npm ci")
+		assert.NotContains(t, script, "install go")
+		assert.Contains(t, stdout.String(), "node version changed (20 → 22)")
+
+		vmState, err := cc.State.LoadVM(cc.Project.Hash)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"node": "22", "go": "1.22.0"}, vmState.RuntimeVersions)
+	})
+
+	t.Run("unchanged versions skip the script", func(t *testing.T) {
+		t.Parallel()
+		cc, _, _ := testCmdContext(t, &mockProvider{})
+		saveState(t, cc, map[string]string{"node": "22", "go": "1.22.0"})
+		called := false
+		cc.RunScript = func(client *gossh.Client, workDir string, commands []string, stdout, stderr io.Writer) error {
+			called = true
+			return nil
+		}
+
+		reprovisionRuntimes(cc, nil, langs)
+		assert.False(t, called)
+	})
+
+	t.Run("failure warns and keeps old versions", func(t *testing.T) {
+		t.Parallel()
+		cc, _, stderr := testCmdContext(t, &mockProvider{})
+		saveState(t, cc, map[string]string{"node": "20", "go": "1.22.0"})
+		cc.RunScript = func(client *gossh.Client, workDir string, commands []string, stdout, stderr io.Writer) error {
+			return fmt.Errorf("Process exited with status 1")
+		}
+
+		reprovisionRuntimes(cc, nil, langs)
+		assert.Contains(t, stderr.String(), "runtime update failed")
+
+		vmState, err := cc.State.LoadVM(cc.Project.Hash)
+		require.NoError(t, err)
+		assert.Equal(t, "20", vmState.RuntimeVersions["node"])
+	})
+}
+
 func TestRunCommand_SavesHistoryOnNonZeroExit(t *testing.T) {
 	t.Parallel()
 
@@ -943,7 +1301,7 @@ func TestRunCommand_SavesHistoryOnNonZeroExit(t *testing.T) {
 	cc, _, _ := testCmdContext(t, prov)
 	saveTestVMState(t, cc.State, cc.Project.Hash)
 
-	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo) (*fksync.SyncResult, error) {
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
 		return &fksync.SyncResult{}, nil
 	}
 	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
@@ -1001,7 +1359,7 @@ func TestRunCommand_UploadsArtifacts(t *testing.T) {
 	// Configure artifacts.
 	cc.Config.Artifacts.Paths = []string{"output/result.txt", "coverage/report.html"}
 
-	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo) (*fksync.SyncResult, error) {
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
 		return &fksync.SyncResult{}, nil
 	}
 	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
@@ -1076,7 +1434,7 @@ func TestRunCommand_ArtifactMissing_ContinuesUpload(t *testing.T) {
 	// Two artifacts: first exists, second does not.
 	cc.Config.Artifacts.Paths = []string{"output/result.txt", "missing/file.txt"}
 
-	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo) (*fksync.SyncResult, error) {
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
 		return &fksync.SyncResult{}, nil
 	}
 	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
@@ -1129,7 +1487,7 @@ func TestRunCommand_NoArtifactsConfigured_SkipsUpload(t *testing.T) {
 	saveTestVMState(t, cc.State, cc.Project.Hash)
 
 	// No artifacts configured (default config).
-	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo) (*fksync.SyncResult, error) {
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
 		return &fksync.SyncResult{}, nil
 	}
 	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
@@ -1489,7 +1847,7 @@ func TestRunCommand_CancelsGracePeriodMonitorOnNewCommand(t *testing.T) {
 	// Configure grace period.
 	cc.Config.Lifecycle.GracePeriod = "5m"
 
-	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo) (*fksync.SyncResult, error) {
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
 		return &fksync.SyncResult{}, nil
 	}
 	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
@@ -0,0 +1,256 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/gridlhq/yeager/internal/provision"
+	"github.com/spf13/cobra"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func newServicesCmd(f *flags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "services",
+		Short: "List, restart and show logs of sidecar services",
+		Long: `Shows the sidecar services declared in [services.<name>] in .yeager.toml
+and their state on the VM. Services are started and health-checked
+before every run.`,
+		Example: `  yg services                  # list services and their state
+  yg services logs postgres    # show a service's logs
+  yg services restart          # recreate every service`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cc, err := resolveCmdContext(cmd.Context(), f)
+			if err != nil {
+				return err
+			}
+			return RunServices(cmd.Context(), cc)
+		},
+	}
+
+	logs := &cobra.Command{
+		Use:   "logs <service>",
+		Short: "Show a service's logs",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cc, err := resolveCmdContext(cmd.Context(), f)
+			if err != nil {
+				return err
+			}
+			tail, _ := cmd.Flags().GetInt("tail")
+			follow, _ := cmd.Flags().GetBool("follow")
+			return RunServiceLogs(cmd.Context(), cc, args[0], tail, follow)
+		},
+	}
+	logs.Flags().Int("tail", 0, "show last N lines")
+	logs.Flags().BoolP("follow", "f", false, "stream new output")
+
+	restart := &cobra.Command{
+		Use:   "restart [service...]",
+		Short: "Recreate services (all by default) and wait until they're healthy",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cc, err := resolveCmdContext(cmd.Context(), f)
+			if err != nil {
+				return err
+			}
+			return RunServicesRestart(cmd.Context(), cc, args)
+		},
+	}
+
+	cmd.AddCommand(logs, restart)
+	return cmd
+}
+
+// RunServices lists the configured services and their state on the VM.
+func RunServices(ctx context.Context, cc *cmdContext) error {
+	w := cc.Output
+	w.Infof("project: %s", cc.Project.Label())
+	if len(cc.Config.Services) == 0 {
+		w.Info("no services configured")
+		w.Hint("declare one in .yeager.toml: [services.postgres] image = \"postgres:16\"")
+		return nil
+	}
+
+	client, ok, err := connectRunningVM(ctx, cc)
+	if err != nil || !ok {
+		return err
+	}
+	if client != nil {
+		defer client.Close()
+	}
+	showServices(cc, client)
+	return nil
+}
+
+// RunServiceLogs prints a service's logs from the VM.
+func RunServiceLogs(ctx context.Context, cc *cmdContext, name string, tail int, follow bool) error {
+	if err := checkServiceNames(cc, []string{name}); err != nil {
+		return err
+	}
+	client, ok, err := connectRunningVM(ctx, cc)
+	if err != nil || !ok {
+		return err
+	}
+	if client != nil {
+		defer client.Close()
+	}
+
+	w := cc.Output
+	stream := newStreamWriter(w)
+	cmd := provision.ServiceLogsCommand(name, tail, follow)
+	if err := cc.RunScript(client, remoteProjectDir, []string{cmd}, stream, stream); err != nil {
+		return fmt.Errorf("reading %s logs: %w", name, err)
+	}
+	return nil
+}
+
+// RunServicesRestart recreates the named services (all if none are named)
+// and waits until they're healthy.
+func RunServicesRestart(ctx context.Context, cc *cmdContext, names []string) error {
+	if len(names) == 0 {
+		names = cc.Config.ServiceNames()
+	}
+	if len(names) == 0 {
+		cc.Output.Info("no services configured")
+		return nil
+	}
+	if err := checkServiceNames(cc, names); err != nil {
+		return err
+	}
+	client, ok, err := connectRunningVM(ctx, cc)
+	if err != nil || !ok {
+		return err
+	}
+	if client != nil {
+		defer client.Close()
+	}
+
+	w := cc.Output
+	w.StartSpinner(fmt.Sprintf("restarting %s...", strings.Join(names, ", ")))
+	var out bytes.Buffer
+	if err := cc.RunScript(client, remoteProjectDir, provision.RestartServicesCommands(cc.Config, names), &out, &out); err != nil {
+		w.StopSpinner("restart failed", false)
+		slog.Debug("services restart output", "output", out.String())
+		return fmt.Errorf("restarting services: %w: %s", err, strings.TrimSpace(out.String()))
+	}
+	w.StopSpinner("services healthy", true)
+	return nil
+}
+
+// startServices starts the configured services and waits until they're
+// healthy, before dependency installs and the command. A service that
+// doesn't start or become healthy fails the run, after its logs are shown.
+func startServices(cc *cmdContext, client *gossh.Client) error {
+	cmds := provision.ServicesUpCommands(cc.Config)
+	if len(cmds) == 0 || cc.RunScript == nil {
+		return nil
+	}
+
+	w := cc.Output
+	w.StartSpinner("starting services...")
+	var out bytes.Buffer
+	if err := cc.RunScript(client, remoteProjectDir, cmds, &out, &out); err != nil {
+		w.StopSpinner("services failed", false)
+		w.Stream(out.Bytes())
+		err = fmt.Errorf("services failed to start: %w", err)
+		w.Error(err.Error(), "fix [services] in .yeager.toml; yg services logs <name> shows a service's logs")
+		return displayed(err)
+	}
+	w.StopSpinner("services healthy", true)
+	return nil
+}
+
+// checkServiceNames returns an error if a name isn't a configured service.
+func checkServiceNames(cc *cmdContext, names []string) error {
+	for _, name := range names {
+		if _, ok := cc.Config.Services[name]; !ok {
+			configured := strings.Join(cc.Config.ServiceNames(), ", ")
+			if configured == "" {
+				configured = "none"
+			}
+			return fmt.Errorf("unknown service %q (configured: %s)", name, configured)
+		}
+	}
+	return nil
+}
+
+// connectRunningVM connects to the project's VM if it's running.
+// Returns false, after telling the user why, if there's no running VM.
+func connectRunningVM(ctx context.Context, cc *cmdContext) (*gossh.Client, bool, error) {
+	w := cc.Output
+	vmState, err := cc.State.LoadVM(cc.Project.Hash)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			w.Info("no VM found")
+			w.Hint("run a command to create one: yg <command>")
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("loading VM state: %w", err)
+	}
+
+	info, err := cc.Provider.FindVM(ctx, cc.Project.Hash)
+	if err != nil {
+		return nil, false, fmt.Errorf("querying VM state: %w", err)
+	}
+	if info == nil {
+		w.Infof("VM %s no longer exists", vmState.InstanceID)
+		return nil, false, nil
+	}
+	if info.State != "running" {
+		w.Infof("VM %s is %s", info.InstanceID, info.State)
+		w.Hint("start it with: yg up")
+		return nil, false, nil
+	}
+
+	client, err := cc.ConnectSSH(ctx, info)
+	if err != nil {
+		return nil, false, fmt.Errorf("SSH connection failed: %w", err)
+	}
+	return client, true, nil
+}
+
+// showServices lists the configured services with their container state.
+// Best-effort — if the VM can't be queried, states show as unknown.
+func showServices(cc *cmdContext, client *gossh.Client) {
+	names := cc.Config.ServiceNames()
+	if len(names) == 0 || cc.RunScript == nil {
+		return
+	}
+
+	var out bytes.Buffer
+	states := make(map[string]string)
+	missing := "not started"
+	if err := cc.RunScript(client, remoteProjectDir, []string{provision.ServiceStatusCommand()}, &out, &out); err != nil {
+		slog.Debug("listing services failed (best-effort)", "error", err)
+		missing = "unknown"
+	} else {
+		scanner := bufio.NewScanner(&out)
+		for scanner.Scan() {
+			if name, state, ok := strings.Cut(scanner.Text(), "\t"); ok {
+				states[name] = state
+			}
+		}
+	}
+
+	w := cc.Output
+	w.Info("")
+	w.Info("services:")
+	for _, name := range names {
+		state, ok := states[name]
+		if !ok {
+			state = missing
+		}
+		ports := ""
+		if p := cc.Config.Services[name].Ports; len(p) > 0 {
+			ports = "  ports " + strings.Join(p, ", ")
+		}
+		w.Infof("  %s  %s  %s%s", name, cc.Config.Services[name].Image, state, ports)
+	}
+}
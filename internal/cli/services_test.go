@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gridlhq/yeager/internal/config"
+	fkexec "github.com/gridlhq/yeager/internal/exec"
+	"github.com/gridlhq/yeager/internal/provider"
+	"github.com/gridlhq/yeager/internal/provision"
+	fksync "github.com/gridlhq/yeager/internal/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// servicesCmdContext returns a context with a running VM and two services.
+// Scripts run on the VM are recorded; script answers their output.
+func servicesCmdContext(t *testing.T, script func(commands []string) (string, error)) (*cmdContext, *bytes.Buffer, *[]string) {
+	t.Helper()
+	prov := &mockProvider{
+		findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+			return &provider.VMInfo{InstanceID: "i-svc001", State: "running", PublicIP: "1.2.3.4", Region: "us-east-1"}, nil
+		},
+	}
+	cc, stdout, _ := testCmdContext(t, prov)
+	saveTestVMState(t, cc.State, cc.Project.Hash)
+	cc.Config.Services = map[string]config.ServiceConfig{
+		"postgres": {Image: "postgres:16", Ports: []string{"5432"}},
+		"redis":    {Image: "redis:7"},
+	}
+	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
+		return nil, nil
+	}
+
+	var scripts []string
+	cc.RunScript = func(client *gossh.Client, workDir string, commands []string, stdout, stderr io.Writer) error {
+		scripts = append(scripts, strings.Join(commands, "\n"))
+		out, err := script(commands)
+		_, _ = io.WriteString(stdout, out)
+		return err
+	}
+	return cc, stdout, &scripts
+}
+
+func TestRunServices_ListsState(t *testing.T) {
+	t.Parallel()
+
+	cc, stdout, scripts := servicesCmdContext(t, func(commands []string) (string, error) {
+		return "postgres\tUp 3 minutes\n", nil
+	})
+
+	require.NoError(t, RunServices(context.Background(), cc))
+	require.Len(t, *scripts, 1)
+	assert.Equal(t, provision.ServiceStatusCommand(), (*scripts)[0])
+
+	out := stdout.String()
+	assert.Contains(t, out, "postgres  postgres:16  Up 3 minutes  ports 5432")
+	assert.Contains(t, out, "redis  redis:7  not started")
+}
+
+func TestRunServices_NoneConfigured(t *testing.T) {
+	t.Parallel()
+
+	cc, stdout, _ := testCmdContext(t, &mockProvider{})
+	require.NoError(t, RunServices(context.Background(), cc))
+	assert.Contains(t, stdout.String(), "no services configured")
+}
+
+func TestRunStatus_ShowsServices(t *testing.T) {
+	t.Parallel()
+
+	cc, stdout, _ := servicesCmdContext(t, func(commands []string) (string, error) {
+		return "", fmt.Errorf("ssh: broken pipe")
+	})
+	cc.ListRuns = func(client *gossh.Client) ([]fkexec.ActiveRun, error) {
+		return nil, nil
+	}
+
+	require.NoError(t, RunStatus(context.Background(), cc))
+	out := stdout.String()
+	assert.Contains(t, out, "services:")
+	assert.Contains(t, out, "postgres  postgres:16  unknown", "state is best-effort")
+}
+
+func TestRunServiceLogs(t *testing.T) {
+	t.Parallel()
+
+	cc, stdout, scripts := servicesCmdContext(t, func(commands []string) (string, error) {
+		return "database system is ready to accept connections\n", nil
+	})
+
+	require.NoError(t, RunServiceLogs(context.Background(), cc, "postgres", 50, true))
+	assert.Equal(t, []string{provision.ServiceLogsCommand("postgres", 50, true)}, *scripts)
+	assert.Contains(t, stdout.String(), "ready to accept connections")
+
+	err := RunServiceLogs(context.Background(), cc, "mysql", 0, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown service "mysql" (configured: postgres, redis)`)
+}
+
+func TestRunServicesRestart(t *testing.T) {
+	t.Parallel()
+
+	t.Run("all by default", func(t *testing.T) {
+		t.Parallel()
+		cc, _, scripts := servicesCmdContext(t, func(commands []string) (string, error) {
+			return "", nil
+		})
+		require.NoError(t, RunServicesRestart(context.Background(), cc, nil))
+		require.Len(t, *scripts, 1)
+		assert.Equal(t, strings.Join(provision.RestartServicesCommands(cc.Config, []string{"postgres", "redis"}), "\n"), (*scripts)[0])
+	})
+
+	t.Run("health check failure", func(t *testing.T) {
+		t.Parallel()
+		cc, _, _ := servicesCmdContext(t, func(commands []string) (string, error) {
+			return "service redis is not healthy after 120s\n", fmt.Errorf("exit status 1")
+		})
+		err := RunServicesRestart(context.Background(), cc, []string{"redis"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "service redis is not healthy")
+	})
+
+	t.Run("stopped VM", func(t *testing.T) {
+		t.Parallel()
+		cc, _, scripts := servicesCmdContext(t, func(commands []string) (string, error) {
+			return "", nil
+		})
+		cc.Provider = &mockProvider{
+			findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+				return &provider.VMInfo{InstanceID: "i-svc001", State: "stopped"}, nil
+			},
+		}
+		require.NoError(t, RunServicesRestart(context.Background(), cc, nil))
+		assert.Empty(t, *scripts)
+	})
+}
+
+func TestDetectLanguages_ServicesFirst(t *testing.T) {
+	t.Parallel()
+
+	cc, _, _ := servicesCmdContext(t, func(commands []string) (string, error) {
+		return "", nil
+	})
+	cc.Project.AbsPath = t.TempDir()
+	writeDevcontainer(t, cc.Project.AbsPath, `{}`)
+
+	langs, _ := detectLanguages(cc)
+	require.NotEmpty(t, langs)
+	assert.Equal(t, provision.Services, langs[0].Name)
+
+	init, _ := execEnvironment(langs)
+	assert.Contains(t, init, "export POSTGRES_PORT=5432")
+}
+
+func TestRunCommand_UnhealthyServiceStopsTheRun(t *testing.T) {
+	t.Parallel()
+
+	cc, stdout, scripts := servicesCmdContext(t, func(commands []string) (string, error) {
+		if strings.Contains(strings.Join(commands, "\n"), "docker run") {
+			return "service postgres exited; its last logs:\nFATAL: password missing\n", fmt.Errorf("Process exited with status 1")
+		}
+		return "", nil
+	})
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
+		return &fksync.SyncResult{}, nil
+	}
+	ran := false
+	cc.RunExec = func(client *gossh.Client, opts fkexec.RunOpts, stdout, stderr io.Writer) (*fkexec.RunResult, error) {
+		ran = true
+		return &fkexec.RunResult{}, nil
+	}
+
+	_, err := RunCommand(context.Background(), cc, "make test")
+	var de *displayedError
+	require.ErrorAs(t, err, &de)
+	assert.Contains(t, err.Error(), "services failed to start")
+	assert.False(t, ran, "the command doesn't run without its services")
+	assert.Contains(t, stdout.String(), "FATAL: password missing", "the service's logs are shown")
+	assert.Contains(t, (*scripts)[len(*scripts)-1], "docker run", "nothing runs after the services fail")
+}
@@ -23,6 +23,17 @@ type statusJSON struct {
 	AvailabilityZone string `json:"availability_zone,omitempty"`
 	PublicIP         string `json:"public_ip,omitempty"`
 	Project          string `json:"project,omitempty"`
+	Profile          string `json:"profile,omitempty"`
+
+	TemporaryVMs []temporaryVMJSON `json:"temporary_vms,omitempty"`
+}
+
+// temporaryVMJSON is a temporary VM launched with --size, --region or --spot.
+type temporaryVMJSON struct {
+	InstanceID string `json:"instance_id"`
+	State      string `json:"state"`
+	Region     string `json:"region"`
+	Variant    string `json:"variant"`
 }
 
 func newStatusCmd(f *flags) *cobra.Command {
@@ -30,9 +41,10 @@ func newStatusCmd(f *flags) *cobra.Command {
 		Use:   "status",
 		Short: "Show VM state, active commands, and recent history",
 		Long: `Shows the current state of the project's VM, any commands actively
-running on it, and a summary of recent completed runs.`,
+running on it, and a summary of recent completed runs. Temporary VMs
+launched with --size, --region or --spot are listed too.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			cc, err := resolveCmdContext(cmd.Context(), f.outputMode())
+			cc, err := resolveCmdContext(cmd.Context(), f)
 			if err != nil {
 				return err
 			}
@@ -48,11 +60,22 @@ func RunStatus(ctx context.Context, cc *cmdContext) error {
 	}
 
 	w := cc.Output
-	w.Infof("project: %s", cc.Project.DisplayName)
+	w.Infof("project: %s", cc.Project.Label())
 
 	// Show AWS credential status (best-effort).
 	showAWSCredentialStatus(ctx, cc)
 
+	if err := showVM(ctx, cc); err != nil {
+		return err
+	}
+	showVariantVMs(ctx, cc)
+	return nil
+}
+
+// showVM shows the project's VM, what's running on it and recent runs.
+func showVM(ctx context.Context, cc *cmdContext) error {
+	w := cc.Output
+
 	// Check local state first.
 	vmState, err := cc.State.LoadVM(cc.Project.Hash)
 	if err != nil {
@@ -117,6 +140,16 @@ func RunStatus(ctx context.Context, cc *cmdContext) error {
 func runStatusJSON(ctx context.Context, cc *cmdContext) error {
 	s := statusJSON{
 		Project: cc.Project.DisplayName,
+		Profile: cc.Project.Profile,
+	}
+	vms, err := variantVMs(ctx, cc)
+	if err != nil {
+		slog.Debug("status: listing temporary VMs failed (best-effort)", "error", err)
+	}
+	for _, vm := range vms {
+		s.TemporaryVMs = append(s.TemporaryVMs, temporaryVMJSON{
+			InstanceID: vm.InstanceID, State: vm.State, Region: vm.Region, Variant: vm.Variant,
+		})
 	}
 
 	// Check local state.
@@ -154,7 +187,47 @@ func runStatusJSON(ctx context.Context, cc *cmdContext) error {
 	return cc.Output.WriteJSON(s)
 }
 
-// showActiveCommands SSHs into the VM to list active runs.
+// variantVMs lists the project's temporary VMs launched with --size,
+// --region or --spot, which have hashes of their own, other than cc's own
+// VM. Only cc's region is searched: VMs elsewhere are listed with
+// --region, and power themselves off once idle regardless.
+func variantVMs(ctx context.Context, cc *cmdContext) ([]provider.VMInfo, error) {
+	vms, err := cc.Provider.FindVariantVMs(ctx, cc.Project.AbsPath, cc.Project.Profile)
+	if err != nil {
+		return nil, err
+	}
+	others := vms[:0]
+	for _, vm := range vms {
+		if vm.Variant != cc.Project.Variant {
+			others = append(others, vm)
+		}
+	}
+	return others, nil
+}
+
+// showVariantVMs lists the project's temporary VMs (best-effort), so one
+// left running when yg couldn't terminate it doesn't go unnoticed.
+func showVariantVMs(ctx context.Context, cc *cmdContext) {
+	w := cc.Output
+
+	vms, err := variantVMs(ctx, cc)
+	if err != nil {
+		slog.Debug("status: listing temporary VMs failed (best-effort)", "error", err)
+		return
+	}
+	if len(vms) == 0 {
+		return
+	}
+
+	w.Info("")
+	w.Infof("temporary VMs: %d", len(vms))
+	for _, vm := range vms {
+		w.Infof("  %s %s  %s  %s", vm.InstanceID, stateIndicator(vm.State, w.ColorOut()), vm.Region, vm.Variant)
+	}
+	w.Hint("terminate them: yg destroy")
+}
+
+// showActiveCommands SSHs into the VM to list active runs and services.
 // Best-effort — failures are logged but not returned.
 func showActiveCommands(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo) {
 	w := cc.Output
@@ -181,6 +254,7 @@ func showActiveCommands(ctx context.Context, cc *cmdContext, vmInfo *provider.VM
 	if len(runs) == 0 {
 		w.Info("no active commands")
 		showIdleStatus(cc)
+		showServices(cc, client)
 		return
 	}
 
@@ -198,6 +272,7 @@ func showActiveCommands(ctx context.Context, cc *cmdContext, vmInfo *provider.VM
 		}
 		w.Infof("  %s  %s%s", run.RunID, cmd, elapsed)
 	}
+	showServices(cc, client)
 }
 
 // showAWSCredentialStatus displays AWS credential status (best-effort).
@@ -16,7 +16,7 @@ func newStopCmd(f *flags) *cobra.Command {
 		Long: `Stops the VM but keeps the EBS volume so it can restart quickly.
 No compute charges while stopped. Use "yg up" or run any command to restart.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			cc, err := resolveCmdContext(cmd.Context(), f.outputMode())
+			cc, err := resolveCmdContext(cmd.Context(), f)
 			if err != nil {
 				return err
 			}
@@ -28,7 +28,7 @@ No compute charges while stopped. Use "yg up" or run any command to restart.`,
 // RunStop stops the VM for the current project.
 func RunStop(ctx context.Context, cc *cmdContext) error {
 	w := cc.Output
-	w.Infof("project: %s", cc.Project.DisplayName)
+	w.Infof("project: %s", cc.Project.Label())
 
 	// Cancel any grace period monitor since user is explicitly stopping.
 	cancelGracePeriodMonitorBestEffort(cc)
@@ -62,6 +62,18 @@ func RunStop(ctx context.Context, cc *cmdContext) error {
 		return nil
 	}
 
+	// Temporary VMs aren't kept around, and spot ones can't be stopped.
+	if vmState.Temporary || cc.Temporary {
+		w.StartSpinner(fmt.Sprintf("terminating temporary VM %s...", info.InstanceID))
+		if err := cc.Provider.TerminateVM(ctx, info.InstanceID); err != nil {
+			w.StopSpinner("failed to terminate VM", false)
+			return err
+		}
+		_ = cc.State.DeleteVM(cc.Project.Hash)
+		w.StopSpinner("VM terminated", true)
+		return nil
+	}
+
 	w.StartSpinner(fmt.Sprintf("stopping VM %s...", info.InstanceID))
 	if err := cc.Provider.StopVM(ctx, info.InstanceID); err != nil {
 		w.StopSpinner("failed to stop VM", false)
@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gridlhq/yeager/internal/config"
+	"github.com/gridlhq/yeager/internal/output"
+	"github.com/gridlhq/yeager/internal/project"
+	fksync "github.com/gridlhq/yeager/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+// syncReportTop is how many of the largest files and directories
+// yg sync --dry-run lists.
+const syncReportTop = 10
+
+func newSyncCmd(f *flags) *cobra.Command {
+	var explain string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Sync the project to the VM without running anything",
+		Long: `Syncs the project files to the VM, starting it if needed. Every run
+syncs first anyway; this is for getting the VM ready ahead of time.
+
+Files are chosen by [sync] include and exclude, .yeagerignore files, and
+either .gitignore files plus yeager's default excludes or, with
+[sync] mode = "git", whatever git ls-files lists. --explain shows which
+rule decides a path, and --dry-run sizes up what would be synced; neither
+touches the VM.
+
+Files of [sync] large_file_bytes or more are uploaded once to the
+project's S3 prefix, by content, and downloaded on the VM from there.
+Files deleted locally are deleted on the VM too (with [sync] engine =
+"rsync", so is anything else the project doesn't have), with a warning
+listing them, unless [sync] preserve matches them. [sync] max_sync_bytes
+stops a sync that adds up to more, before anything is sent.
+
+Directories outside the project, like a sibling checkout a path
+dependency points to, are synced after it with [[sync.mounts]], each with
+its own excludes. --dry-run and --explain cover the project only.`,
+		Example: `  yg sync                         # sync now
+  yg sync --dry-run               # what would be synced, largest first
+  yg sync --explain build/gen.go  # why is it synced, or not?`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if explain != "" || dryRun {
+				cwd, err := os.Getwd()
+				if err != nil {
+					return fmt.Errorf("getting working directory: %w", err)
+				}
+				profile := f.profile
+				if profile == "" {
+					profile = os.Getenv(config.ProfileEnv)
+				}
+				w := output.New(f.outputMode())
+				if dryRun {
+					return SyncDryRun(cwd, profile, w)
+				}
+				return ExplainSync(cwd, profile, explain, w)
+			}
+			cc, err := resolveCmdContext(cmd.Context(), f)
+			if err != nil {
+				return err
+			}
+			return SyncFiles(cmd.Context(), cc)
+		},
+	}
+	cmd.Flags().StringVar(&explain, "explain", "", "show why a path is synced or not")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list what would be synced, with sizes")
+	cmd.MarkFlagsMutuallyExclusive("explain", "dry-run")
+	return cmd
+}
+
+// SyncFiles syncs the project to its VM, starting the VM if needed.
+func SyncFiles(ctx context.Context, cc *cmdContext) error {
+	cc.Output.Infof("project: %s", cc.Project.Label())
+	sess, err := openSession(ctx, cc)
+	if err != nil {
+		return err
+	}
+	defer sess.close()
+	checkIdleAndStop(ctx, cc, sess.vmInfo)
+	return nil
+}
+
+// syncExplanation is yg sync --explain's answer in JSON.
+type syncExplanation struct {
+	Path    string `json:"path"`
+	Synced  bool   `json:"synced"`
+	Pattern string `json:"pattern,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Dir     string `json:"dir,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// localSyncFilter returns the sync filter for the project in dir, with
+// its config for profile. It only looks at local files.
+func localSyncFilter(dir, profile string) (project.Project, config.Config, *fksync.Filter, error) {
+	proj, err := project.Resolve(dir)
+	if err != nil {
+		return project.Project{}, config.Config{}, nil, fmt.Errorf("resolving project: %w", err)
+	}
+	cfg, _, err := config.Load(dir)
+	if err != nil {
+		return project.Project{}, config.Config{}, nil, err
+	}
+	if cfg, err = cfg.WithProfile(profile); err != nil {
+		return project.Project{}, config.Config{}, nil, err
+	}
+	opts := syncOptions(&cmdContext{Project: proj, Config: cfg})
+	filter, err := fksync.NewFilter(proj.AbsPath, cfg.Sync, opts.Languages)
+	if err != nil {
+		return project.Project{}, config.Config{}, nil, err
+	}
+	return proj, cfg, filter, nil
+}
+
+// ExplainSync reports why p, relative to dir, is synced or not, using the
+// config in dir.
+func ExplainSync(dir, profile, p string, w *output.Writer) error {
+	proj, _, filter, err := localSyncFilter(dir, profile)
+	if err != nil {
+		return err
+	}
+
+	abs := p
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(dir, p)
+	}
+	rel, err := filepath.Rel(proj.AbsPath, abs)
+	if err != nil {
+		return fmt.Errorf("%s is not inside the project", p)
+	}
+	d, err := filter.Explain(rel)
+	if err != nil {
+		return err
+	}
+	rel = filepath.ToSlash(filepath.Clean(rel))
+
+	if w.Mode() == output.ModeJSON {
+		return w.WriteJSON(syncExplanation{
+			Path:    rel,
+			Synced:  !d.Excluded,
+			Pattern: d.Pattern,
+			Source:  d.Source,
+			Dir:     d.Dir,
+			Reason:  d.Reason(),
+		})
+	}
+	verdict := "synced"
+	if d.Excluded {
+		verdict = "not synced"
+	}
+	w.Infof("%s: %s — %s", rel, verdict, d.Reason())
+	return nil
+}
+
+// syncDryRun is yg sync --dry-run's answer in JSON.
+type syncDryRun struct {
+	fksync.Report
+	MaxSyncBytes int64 `json:"max_sync_bytes,omitempty"`
+}
+
+// SyncDryRun lists what a sync of the project in dir to a new VM would
+// send, largest first, and whether it's within [sync] max_sync_bytes.
+func SyncDryRun(dir, profile string, w *output.Writer) error {
+	_, cfg, filter, err := localSyncFilter(dir, profile)
+	if err != nil {
+		return err
+	}
+	r, err := fksync.Scan(filter, syncReportTop)
+	if err != nil {
+		return err
+	}
+	if w.Mode() == output.ModeJSON {
+		return w.WriteJSON(syncDryRun{Report: r, MaxSyncBytes: cfg.Sync.MaxSyncBytes})
+	}
+
+	w.Infof("would sync %d files (%s); after the first sync, only changes are sent", r.Files, fksync.FormatBytes(r.Bytes))
+	for _, list := range []struct {
+		title   string
+		entries []fksync.PathSize
+	}{
+		{"largest directories:", r.LargestDirs},
+		{"largest files:", r.LargestFiles},
+	} {
+		if len(list.entries) == 0 {
+			continue
+		}
+		width := 0
+		for _, e := range list.entries {
+			width = max(width, len(e.Path))
+		}
+		w.Info(list.title)
+		for _, e := range list.entries {
+			w.StreamLine(fmt.Sprintf("  %-*s  %9s", width, e.Path, fksync.FormatBytes(e.Bytes)))
+		}
+	}
+
+	if limit := cfg.Sync.MaxSyncBytes; limit > 0 {
+		if r.Bytes > limit {
+			tooLarge := &fksync.TooLargeError{Max: limit, Report: r}
+			w.Warn(tooLarge.Error(), tooLargeFix(tooLarge))
+		} else {
+			w.Infof("within max_sync_bytes (%s)", fksync.FormatBytes(limit))
+		}
+	}
+	return nil
+}
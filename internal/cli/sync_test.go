@@ -0,0 +1,250 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gridlhq/yeager/internal/config"
+	"github.com/gridlhq/yeager/internal/output"
+	"github.com/gridlhq/yeager/internal/provider"
+	fksync "github.com/gridlhq/yeager/internal/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestExplainSync(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		config.FileName:   "[sync]\nexclude = [\"data/\"]\n\n[profiles.all.sync]\ninclude = [\"data/\"]\n",
+		".gitignore":      "*.log\n",
+		"main.go":         "package main",
+		"data/big.csv":    "",
+		"build/output.js": "",
+	} {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+
+	explain := func(profile, path string) string {
+		var stdout, stderr bytes.Buffer
+		require.NoError(t, ExplainSync(dir, profile, path, output.NewWithWriters(&stdout, &stderr, output.ModeText)))
+		return stdout.String()
+	}
+	assert.Contains(t, explain("", "main.go"), "main.go: synced — no rule matches it")
+	assert.Contains(t, explain("", "debug.log"), `debug.log: not synced — matches "*.log" in .gitignore`)
+	assert.Contains(t, explain("", "data/big.csv"), `data/big.csv: not synced — it's in data/, which matches "data/" in [sync] exclude`)
+	assert.Contains(t, explain("", filepath.Join(dir, "build", "output.js")), `build/output.js: not synced — it's in build/, which matches "build/" in default excludes`)
+	assert.Contains(t, explain("all", "data/big.csv"), "data/big.csv: synced", "profiles apply")
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, ExplainSync(dir, "", "debug.log", output.NewWithWriters(&stdout, &stderr, output.ModeJSON)))
+	var got syncExplanation
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &got))
+	assert.Equal(t, syncExplanation{
+		Path:    "debug.log",
+		Pattern: "*.log",
+		Source:  ".gitignore",
+		Reason:  `matches "*.log" in .gitignore`,
+	}, got)
+
+	err := ExplainSync(dir, "", "../outside", output.NewWithWriters(&stdout, &stderr, output.ModeText))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not inside the project")
+}
+
+func TestSyncFiles(t *testing.T) {
+	t.Parallel()
+
+	prov := &mockProvider{
+		findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+			return &provider.VMInfo{InstanceID: "i-test001", State: "running", PublicIP: "10.0.0.1"}, nil
+		},
+	}
+	cc, stdout, _ := testCmdContext(t, prov)
+	saveTestVMState(t, cc.State, cc.Project.Hash)
+	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
+		return nil, nil
+	}
+	syncs := 0
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
+		syncs++
+		return &fksync.SyncResult{FilesTransferred: 3}, nil
+	}
+
+	require.NoError(t, SyncFiles(context.Background(), cc))
+	assert.Equal(t, 1, syncs)
+	assert.Contains(t, stdout.String(), "synced")
+}
+
+func TestSyncFiles_WarnsAboutDeletions(t *testing.T) {
+	t.Parallel()
+
+	prov := &mockProvider{
+		findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+			return &provider.VMInfo{InstanceID: "i-test001", State: "running", PublicIP: "10.0.0.1"}, nil
+		},
+	}
+	cc, _, stderr := testCmdContext(t, prov)
+	saveTestVMState(t, cc.State, cc.Project.Hash)
+	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
+		return nil, nil
+	}
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
+		deleted := []string{"fixtures/downloaded/", "test.sqlite", ".env.test", "gen/a.go"}
+		return &fksync.SyncResult{FilesDeleted: len(deleted), Deleted: deleted}, nil
+	}
+
+	require.NoError(t, SyncFiles(context.Background(), cc))
+	assert.Contains(t, stderr.String(), "sync deleted from the VM: fixtures/downloaded/, test.sqlite, .env.test and 1 more")
+	assert.Contains(t, stderr.String(), `[sync] preserve = ["fixtures/downloaded/"]`)
+}
+
+func TestStageStore(t *testing.T) {
+	t.Parallel()
+
+	cc, _, stderr := testCmdContext(t, &mockProvider{})
+	var projects []string
+	cc.NewBlobStore = func(ctx context.Context, projectName string) (fksync.BlobStore, error) {
+		projects = append(projects, projectName)
+		return nil, errors.New("no bucket")
+	}
+
+	assert.Nil(t, stageStore(context.Background(), cc), "off by default")
+	assert.Empty(t, projects)
+
+	cc.Config.Sync.LargeFileBytes = 50_000_000
+	assert.Nil(t, stageStore(context.Background(), cc))
+	assert.Equal(t, []string{cc.Project.DisplayName}, projects)
+	assert.Contains(t, stderr.String(), "can't stage large files through S3: no bucket")
+}
+
+func TestSyncDryRun(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		config.FileName:   "[sync]\nmax_sync_bytes = 1000\n\n[profiles.big.sync]\nmax_sync_bytes = 100000\n",
+		"main.go":         "package main",
+		"data/a.csv":      strings.Repeat("x", 900),
+		"data/b.csv":      strings.Repeat("x", 300),
+		"node_modules/x":  strings.Repeat("x", 5000),
+		"docs/readme.txt": "hi",
+	} {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	configSize := int64(len("[sync]\nmax_sync_bytes = 1000\n\n[profiles.big.sync]\nmax_sync_bytes = 100000\n"))
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, SyncDryRun(dir, "", output.NewWithWriters(&stdout, &stderr, output.ModeText)))
+	out := stdout.String()
+	assert.Contains(t, out, "would sync 5 files (1.3 KB)")
+	assert.Contains(t, out, "largest directories:")
+	assert.Contains(t, out, "  data/     1.2 KB\n")
+	assert.Contains(t, out, "  data/a.csv           900 B\n")
+	assert.NotContains(t, out, "node_modules")
+	assert.Contains(t, stderr.String(), "over max_sync_bytes (1000 B); data/ is 1.2 KB")
+	assert.Contains(t, stderr.String(), `[sync] exclude = ["data/"]`)
+
+	stdout.Reset()
+	stderr.Reset()
+	require.NoError(t, SyncDryRun(dir, "big", output.NewWithWriters(&stdout, &stderr, output.ModeText)))
+	assert.Contains(t, stdout.String(), "within max_sync_bytes (97.7 KB)")
+	assert.Empty(t, stderr.String())
+
+	stdout.Reset()
+	require.NoError(t, SyncDryRun(dir, "", output.NewWithWriters(&stdout, &stderr, output.ModeJSON)))
+	var got syncDryRun
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &got))
+	assert.Equal(t, 5, got.Files)
+	assert.Equal(t, 1214+configSize, got.Bytes)
+	assert.Equal(t, int64(1000), got.MaxSyncBytes)
+	assert.Equal(t, fksync.PathSize{Path: "data/a.csv", Bytes: 900}, got.LargestFiles[0])
+}
+
+func TestRunCommand_SyncTooLarge(t *testing.T) {
+	t.Parallel()
+
+	prov := &mockProvider{
+		findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+			return &provider.VMInfo{InstanceID: "i-test001", State: "running", PublicIP: "10.0.0.1"}, nil
+		},
+	}
+	cc, _, stderr := testCmdContext(t, prov)
+	saveTestVMState(t, cc.State, cc.Project.Hash)
+	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
+		return nil, nil
+	}
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
+		return nil, &fksync.TooLargeError{Max: 1 << 30, Report: fksync.Report{
+			Bytes:       5 << 30,
+			LargestDirs: []fksync.PathSize{{Path: "datasets/", Bytes: 5 << 30}},
+		}}
+	}
+
+	_, err := RunCommand(context.Background(), cc, "make test")
+	var de *displayedError
+	require.ErrorAs(t, err, &de)
+	assert.Contains(t, stderr.String(), "the files to sync add up to 5.0 GB, over max_sync_bytes (1.0 GB); datasets/ is 5.0 GB")
+	assert.Contains(t, stderr.String(), `[sync] exclude = ["datasets/"]`)
+}
+
+func TestFormatSyncProgress(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "120.0 MB of 1.2 GB (10%), 12.0 MB/s", formatSyncProgress(fksync.Progress{Bytes: 120 << 20, Total: 1258291200, Rate: 12 << 20}))
+	assert.Equal(t, "5.0 KB", formatSyncProgress(fksync.Progress{Bytes: 5 << 10}))
+	assert.Equal(t, "2.0 KB of 1.0 KB (100%)", formatSyncProgress(fksync.Progress{Bytes: 2 << 10, Total: 1 << 10}), "tar headers can take it past the total")
+}
+
+func TestMountSyncOptions(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "app"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "shared-protos"), 0o755))
+	cc, _, _ := testCmdContext(t, &mockProvider{})
+	cc.Project.AbsPath = filepath.Join(root, "app")
+	cc.Config.Sync = config.SyncConfig{Exclude: []string{"data/"}, Preserve: []string{"*.db"}, MaxSyncBytes: 1000}
+	project := syncOptions(cc)
+
+	opts, err := mountSyncOptions(cc, config.SyncMount{Local: "../shared-protos", Exclude: []string{"gen/"}}, project)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "shared-protos")+"/", opts.SourceDir)
+	assert.Equal(t, "/home/ubuntu/shared-protos/", opts.RemoteDir)
+	assert.Equal(t, "/home/ubuntu/.yeager/mounts/home_ubuntu_shared-protos.json", opts.ManifestPath)
+	assert.Equal(t, "/home/ubuntu/.yeager/mounts/home_ubuntu_shared-protos.staged.json", opts.StageManifestPath)
+	assert.Equal(t, []string{"gen/"}, opts.SyncConfig.Exclude, "the project's excludes and preserves are its own")
+	assert.Empty(t, opts.SyncConfig.Preserve)
+	assert.Equal(t, int64(1000), opts.SyncConfig.MaxSyncBytes)
+
+	opts, err = mountSyncOptions(cc, config.SyncMount{Local: filepath.Join(root, "shared-protos"), Remote: "/opt/protos"}, project)
+	require.NoError(t, err)
+	assert.Equal(t, "/opt/protos/", opts.RemoteDir)
+
+	_, err = mountSyncOptions(cc, config.SyncMount{Local: "../missing"}, project)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not a directory")
+}
+
+func TestAddSyncResult(t *testing.T) {
+	t.Parallel()
+
+	total := &fksync.SyncResult{FilesTransferred: 2, BytesTransferred: 100, Deleted: []string{"old.go"}}
+	addSyncResult(total, &fksync.SyncResult{FilesTransferred: 3, BytesTransferred: 50, FilesDeleted: 1, Deleted: []string{"a.proto"}}, "../shared-protos")
+	assert.Equal(t, 5, total.FilesTransferred)
+	assert.Equal(t, int64(150), total.BytesTransferred)
+	assert.Equal(t, 1, total.FilesDeleted)
+	assert.Equal(t, []string{"old.go", "../shared-protos/a.proto"}, total.Deleted)
+}
@@ -0,0 +1,260 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gridlhq/yeager/internal/config"
+	fkexec "github.com/gridlhq/yeager/internal/exec"
+	"github.com/gridlhq/yeager/internal/output"
+	fkstorage "github.com/gridlhq/yeager/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// taskRun is the [tasks] entry a RunCommand call comes from.
+type taskRun struct {
+	name   string
+	target string // the task that was asked for
+	config.TaskConfig
+}
+
+// timeout returns the task's timeout; Validate already rejected bad ones.
+func (t *taskRun) timeout() time.Duration {
+	if t == nil {
+		return 0
+	}
+	d, _ := t.TimeoutDuration()
+	return d
+}
+
+// meta returns the task metadata recorded with the run's output.
+func (t *taskRun) meta() *fkstorage.TaskMeta {
+	if t == nil {
+		return nil
+	}
+	m := &fkstorage.TaskMeta{
+		Name:        t.name,
+		Description: t.Description,
+		Profile:     t.Profile,
+		Timeout:     t.Timeout,
+		DependsOn:   t.DependsOn,
+	}
+	if t.target != t.name {
+		m.Target = t.target
+	}
+	return m
+}
+
+func newRunCmd(f *flags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run <task> [args...]",
+		Short: "Run a named task from [tasks] in .yeager.toml",
+		Long: `Runs a [tasks.<name>] entry from .yeager.toml. Its depends_on tasks run
+first, in order, and the task doesn't run if one of them fails. Extra
+args are appended to the task's command.
+
+To run a remote command that is literally called "run": yg -- run`,
+		Example: `  yg run test                # run the test task and its dependencies
+  yg run test --nocapture    # append args to the task's command
+  yg tasks                   # list tasks`,
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeTasks,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cc, err := resolveCmdContext(cmd.Context(), f)
+			if err != nil {
+				return err
+			}
+			cc.EnvFlags = f.env
+			cc.EnvFile = f.envFile
+
+			exitCode, err := RunTask(cmd.Context(), cc, args[0], args[1:])
+			if err != nil {
+				return err
+			}
+			if exitCode != 0 {
+				return &exitCodeError{code: exitCode}
+			}
+			return nil
+		},
+	}
+	// Flags after the task name belong to the task's command.
+	cmd.Flags().SetInterspersed(false)
+	cmd.Flags().StringArrayVar(&f.env, "env", nil, "env var for the command: KEY=VALUE, or KEY to copy yours")
+	cmd.Flags().StringVar(&f.envFile, "env-file", "", "load env vars for the command from a dotenv file")
+	return cmd
+}
+
+func newTasksCmd(f *flags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "tasks",
+		Short: "List the tasks in .yeager.toml",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("getting working directory: %w", err)
+			}
+			return RunTasks(cwd, output.New(f.outputMode()))
+		},
+	}
+}
+
+// completeTasks completes task names, with their descriptions.
+func completeTasks(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	cfg := loadTasksConfig()
+	var names []string
+	for _, name := range cfg.TaskNames() {
+		names = append(names, name+"\t"+taskSummary(cfg.Tasks[name]))
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// loadTasksConfig loads the config for the working directory, for help
+// and completion, which mustn't wait on the network: an org config is
+// only used if it's cached. Errors leave it without tasks.
+func loadTasksConfig() config.Config {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return config.Config{}
+	}
+	cfg, _, err := config.LoadCached(cwd)
+	if err != nil {
+		return config.Config{}
+	}
+	return cfg
+}
+
+// taskSummary is a task's description, or its command if it has none.
+func taskSummary(t config.TaskConfig) string {
+	if t.Description != "" {
+		return t.Description
+	}
+	return t.Command
+}
+
+// taskInfo is a task as listed by yg tasks --json.
+type taskInfo struct {
+	Name        string   `json:"name"`
+	Command     string   `json:"command"`
+	Description string   `json:"description,omitempty"`
+	Profile     string   `json:"profile,omitempty"`
+	Timeout     string   `json:"timeout,omitempty"`
+	Artifacts   []string `json:"artifacts,omitempty"`
+	DependsOn   []string `json:"depends_on,omitempty"`
+}
+
+// RunTasks lists the tasks configured for the project in dir.
+func RunTasks(dir string, w *output.Writer) error {
+	cfg, _, err := config.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	if w.Mode() == output.ModeJSON {
+		tasks := []taskInfo{}
+		for _, name := range cfg.TaskNames() {
+			t := cfg.Tasks[name]
+			tasks = append(tasks, taskInfo{
+				Name:        name,
+				Command:     t.Command,
+				Description: t.Description,
+				Profile:     t.Profile,
+				Timeout:     t.Timeout,
+				Artifacts:   t.Artifacts,
+				DependsOn:   t.DependsOn,
+			})
+		}
+		return w.WriteJSON(tasks)
+	}
+
+	if len(cfg.Tasks) == 0 {
+		w.Info("no tasks configured")
+		w.Hint("add one to .yeager.toml: [tasks.test] command = \"make test\"")
+		return nil
+	}
+	width := 0
+	for _, name := range cfg.TaskNames() {
+		width = max(width, len(name))
+	}
+	for _, name := range cfg.TaskNames() {
+		t := cfg.Tasks[name]
+		line := fmt.Sprintf("%-*s  %s", width, name, taskSummary(t))
+		if len(t.DependsOn) > 0 {
+			line += fmt.Sprintf(" (after %s)", strings.Join(t.DependsOn, ", "))
+		}
+		w.StreamLine(line)
+	}
+	return nil
+}
+
+// RunTask runs a task after its dependencies, stopping at the first one
+// that fails. Args are appended to the task's own command. A task with a
+// profile runs with that profile's config and VM unless --profile was given.
+// Returns the exit code of the last command run.
+func RunTask(ctx context.Context, cc *cmdContext, name string, args []string) (int, error) {
+	w := cc.Output
+	order, err := cc.Config.TaskOrder(name)
+	if err != nil {
+		return 1, err
+	}
+
+	contexts := map[string]*cmdContext{"": cc}
+	for i, taskName := range order {
+		task := cc.Config.Tasks[taskName]
+
+		profile := ""
+		if cc.Project.Profile == "" {
+			profile = task.Profile
+		}
+		base, ok := contexts[profile]
+		if !ok {
+			if base, err = cc.ForProfile(ctx, profile); err != nil {
+				return 1, err
+			}
+			base.EnvFlags, base.EnvFile = cc.EnvFlags, cc.EnvFile
+			contexts[profile] = base
+		}
+
+		tcc := *base
+		tcc.Config.Env.Vars = append(append([]string(nil), base.Config.Env.Vars...), task.Env...)
+		tcc.Config.Artifacts.Paths = append(append([]string(nil), base.Config.Artifacts.Paths...), task.Artifacts...)
+		tcc.Task = &taskRun{name: taskName, target: name, TaskConfig: task}
+
+		command := task.Command
+		if taskName == name && len(args) > 0 {
+			command += " " + strings.Join(args, " ")
+		}
+
+		if len(order) > 1 {
+			w.Infof("task %s (%d/%d)", taskName, i+1, len(order))
+		} else {
+			w.Infof("task %s", taskName)
+		}
+		exitCode, err := RunCommand(ctx, &tcc, command)
+		if err != nil {
+			return exitCode, err
+		}
+		if ctx.Err() != nil {
+			if taskName != name {
+				w.Hint(fmt.Sprintf("detached before %s finished, so %s wasn't started", taskName, name))
+			}
+			return exitCode, nil
+		}
+		if exitCode == fkexec.TimeoutExitCode && task.Timeout != "" {
+			w.Warn(fmt.Sprintf("task %s timed out after %s", taskName, task.Timeout), "raise tasks."+taskName+".timeout in .yeager.toml")
+		}
+		if exitCode != 0 {
+			if taskName != name {
+				w.Error(fmt.Sprintf("task %s failed (exit %d), so %s didn't run", taskName, exitCode, name), "")
+			}
+			return exitCode, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,275 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gridlhq/yeager/internal/config"
+	fkexec "github.com/gridlhq/yeager/internal/exec"
+	"github.com/gridlhq/yeager/internal/output"
+	"github.com/gridlhq/yeager/internal/provider"
+	fkstorage "github.com/gridlhq/yeager/internal/storage"
+	fksync "github.com/gridlhq/yeager/internal/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// taskRecorder captures what a task run sent to the VM and to S3.
+type taskRecorder struct {
+	mu        sync.Mutex
+	opts      []fkexec.RunOpts
+	env       [][]fkexec.EnvVar
+	metas     []fkstorage.RunMeta
+	artifacts []string
+	exitCodes map[string]int // by command
+}
+
+// taskTestContext returns a context whose run pipeline is mocked end to end.
+func taskTestContext(t *testing.T, tasks map[string]config.TaskConfig) (*cmdContext, *taskRecorder, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+	rec := &taskRecorder{exitCodes: map[string]int{}}
+
+	cc, stdout, stderr := testCmdContext(t, &mockProvider{
+		findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+			return &provider.VMInfo{InstanceID: "i-task001", State: "running", PublicIP: "10.0.0.1", Region: "us-east-1"}, nil
+		},
+	})
+	saveTestVMState(t, cc.State, cc.Project.Hash)
+	cc.Project.AbsPath = t.TempDir()
+	cc.Config.Tasks = tasks
+	cc.Config.Profiles = map[string]config.ProfileConfig{"ci": {}}
+	require.NoError(t, cc.Config.Validate())
+
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
+		return &fksync.SyncResult{}, nil
+	}
+	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
+		return nil, nil
+	}
+	cc.WriteEnvFile = func(client *gossh.Client, remotePath string, env []fkexec.EnvVar) error {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.env = append(rec.env, env)
+		return nil
+	}
+	cc.RunExec = func(client *gossh.Client, opts fkexec.RunOpts, stdout, stderr io.Writer) (*fkexec.RunResult, error) {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.opts = append(rec.opts, opts)
+		now := time.Now().UTC()
+		return &fkexec.RunResult{RunID: opts.RunID, ExitCode: rec.exitCodes[opts.Command], StartTime: now, EndTime: now}, nil
+	}
+	cc.ReadRemoteFile = func(client *gossh.Client, remotePath string) ([]byte, error) {
+		return []byte("artifact"), nil
+	}
+	cc.NewStorage = mockStorageFactory(&testS3{
+		putObjectFn: func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			rec.mu.Lock()
+			defer rec.mu.Unlock()
+			key := *params.Key
+			switch {
+			case strings.HasSuffix(key, "/meta.json"):
+				var meta fkstorage.RunMeta
+				data, _ := io.ReadAll(params.Body)
+				require.NoError(t, json.Unmarshal(data, &meta))
+				rec.metas = append(rec.metas, meta)
+			case strings.Contains(key, "/artifacts/"):
+				rec.artifacts = append(rec.artifacts, key[strings.Index(key, "/artifacts/")+len("/artifacts/"):])
+			}
+			return &s3.PutObjectOutput{}, nil
+		},
+	})
+	return cc, rec, stdout, stderr
+}
+
+func TestRunTask_DependenciesRunFirst(t *testing.T) {
+	t.Parallel()
+
+	cc, rec, stdout, _ := taskTestContext(t, map[string]config.TaskConfig{
+		"build": {Command: "make build"},
+		"test": {
+			Command:     "make test",
+			Description: "unit tests",
+			Env:         []string{"LOG=debug"},
+			Timeout:     "30m",
+			Artifacts:   []string{"junit.xml"},
+			DependsOn:   []string{"build"},
+		},
+	})
+	cc.Config.Env.Vars = []string{"LOG=info", "CI=1"}
+
+	exitCode, err := RunTask(context.Background(), cc, "test", []string{"-run", "TestFoo"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, exitCode)
+
+	require.Len(t, rec.opts, 2)
+	assert.Equal(t, "make build", rec.opts[0].Command)
+	assert.Zero(t, rec.opts[0].Timeout)
+	assert.Equal(t, "make test -run TestFoo", rec.opts[1].Command, "args go to the requested task only")
+	assert.Equal(t, 30*time.Minute, rec.opts[1].Timeout)
+
+	require.Len(t, rec.env, 2)
+	assert.Equal(t, []fkexec.EnvVar{{Name: "LOG", Value: "info"}, {Name: "CI", Value: "1"}}, rec.env[0])
+	assert.Equal(t, []fkexec.EnvVar{{Name: "LOG", Value: "debug"}, {Name: "CI", Value: "1"}}, rec.env[1], "task env wins over [env] vars")
+	assert.Equal(t, []string{"LOG=info", "CI=1"}, cc.Config.Env.Vars, "the shared config is untouched")
+
+	assert.Equal(t, []string{"junit.xml"}, rec.artifacts, "task artifacts are uploaded for the task only")
+
+	require.Len(t, rec.metas, 2)
+	assert.Equal(t, &fkstorage.TaskMeta{Name: "build", Target: "test"}, rec.metas[0].Task)
+	assert.Equal(t, &fkstorage.TaskMeta{
+		Name:        "test",
+		Description: "unit tests",
+		Timeout:     "30m",
+		DependsOn:   []string{"build"},
+	}, rec.metas[1].Task)
+
+	assert.Contains(t, stdout.String(), "task build (1/2)")
+	assert.Contains(t, stdout.String(), "task test (2/2)")
+}
+
+func TestRunTask_FailedDependencyStops(t *testing.T) {
+	t.Parallel()
+
+	cc, rec, _, stderr := taskTestContext(t, map[string]config.TaskConfig{
+		"lint": {Command: "make lint"},
+		"test": {Command: "make test", DependsOn: []string{"lint"}},
+	})
+	rec.exitCodes["make lint"] = 2
+
+	exitCode, err := RunTask(context.Background(), cc, "test", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, exitCode)
+	require.Len(t, rec.opts, 1)
+	assert.Contains(t, stderr.String(), "task lint failed (exit 2), so test didn't run")
+}
+
+func TestRunTask_Timeout(t *testing.T) {
+	t.Parallel()
+
+	cc, rec, _, stderr := taskTestContext(t, map[string]config.TaskConfig{
+		"slow": {Command: "sleep 999", Timeout: "1s"},
+	})
+	rec.exitCodes["sleep 999"] = fkexec.TimeoutExitCode
+
+	exitCode, err := RunTask(context.Background(), cc, "slow", nil)
+	require.NoError(t, err)
+	assert.Equal(t, fkexec.TimeoutExitCode, exitCode)
+	assert.Contains(t, stderr.String(), "task slow timed out after 1s")
+}
+
+func TestRunTask_Profile(t *testing.T) {
+	t.Parallel()
+
+	tasks := map[string]config.TaskConfig{
+		"e2e":  {Command: "make e2e", Profile: "ci", DependsOn: []string{"unit"}},
+		"unit": {Command: "make unit"},
+	}
+
+	t.Run("task profile gets its own context", func(t *testing.T) {
+		t.Parallel()
+		cc, rec, _, _ := taskTestContext(t, tasks)
+		var profiles []string
+		cc.ForProfile = func(ctx context.Context, profile string) (*cmdContext, error) {
+			profiles = append(profiles, profile)
+			pcc := *cc
+			pcc.Project = cc.Project.WithProfile(profile)
+			return &pcc, nil
+		}
+
+		_, err := RunTask(context.Background(), cc, "e2e", nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ci"}, profiles)
+		require.Len(t, rec.metas, 2)
+		assert.Equal(t, "ci", rec.metas[1].Task.Profile)
+	})
+
+	t.Run("--profile wins", func(t *testing.T) {
+		t.Parallel()
+		cc, rec, _, _ := taskTestContext(t, tasks)
+		cc.Project = cc.Project.WithProfile("ci")
+		cc.ForProfile = func(ctx context.Context, profile string) (*cmdContext, error) {
+			t.Fatal("no other profile should be resolved")
+			return nil, nil
+		}
+
+		_, err := RunTask(context.Background(), cc, "e2e", nil)
+		require.NoError(t, err)
+		assert.Len(t, rec.opts, 2)
+	})
+}
+
+func TestRunTask_Unknown(t *testing.T) {
+	t.Parallel()
+
+	cc, _, _ := testCmdContext(t, &mockProvider{
+		findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+			t.Fatal("the VM must not be touched for an unknown task")
+			return nil, nil
+		},
+	})
+	_, err := RunTask(context.Background(), cc, "test", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown task "test": no [tasks] in .yeager.toml`)
+}
+
+func TestRunTasks(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.FileName), []byte(`
+[tasks.test]
+command = "make test"
+description = "unit tests"
+depends_on = ["build"]
+
+[tasks.build]
+command = "make build"
+`), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, RunTasks(dir, output.NewWithWriters(&stdout, &stderr, output.ModeText)))
+	assert.Equal(t, "build  make build\ntest   unit tests (after build)\n", stdout.String())
+
+	stdout.Reset()
+	require.NoError(t, RunTasks(dir, output.NewWithWriters(&stdout, &stderr, output.ModeJSON)))
+	var tasks []taskInfo
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &tasks))
+	require.Len(t, tasks, 2)
+	assert.Equal(t, taskInfo{Name: "test", Command: "make test", Description: "unit tests", DependsOn: []string{"build"}}, tasks[1])
+}
+
+func TestWriteTaskHelp(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	writeTaskHelp(&buf, style{}, config.Config{}, 10)
+	assert.Empty(t, buf.String(), "no section without tasks")
+
+	writeTaskHelp(&buf, style{}, config.Config{Tasks: map[string]config.TaskConfig{
+		"test": {Command: "make test", Description: "unit tests"},
+		"lint": {Command: "make lint"},
+	}}, 12)
+	assert.Equal(t, "Tasks:\n  yg run lint    make lint\n  yg run test    unit tests\n\n", buf.String())
+}
+
+func TestRunCmdPassesFlagsToTask(t *testing.T) {
+	t.Parallel()
+
+	root := newRootCmd("test")
+	cmd, args, err := root.Find([]string{"run", "test", "--nocapture", "-v"})
+	require.NoError(t, err)
+	require.Equal(t, "run", cmd.Name())
+	require.NoError(t, cmd.ParseFlags(args))
+	assert.Equal(t, []string{"test", "--nocapture", "-v"}, cmd.Flags().Args())
+}
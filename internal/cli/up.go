@@ -17,7 +17,7 @@ func newUpCmd(f *flags) *cobra.Command {
 		Long: `Creates or starts the VM and syncs your project files, but does not run
 a command. Useful for warming up the VM before you need it.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			cc, err := resolveCmdContext(cmd.Context(), f.outputMode())
+			cc, err := resolveCmdContext(cmd.Context(), f)
 			if err != nil {
 				return err
 			}
@@ -34,7 +34,7 @@ a command. Useful for warming up the VM before you need it.`,
 // If keepAlive is true, keeps the VM running until Ctrl+C or manual stop.
 func RunUp(ctx context.Context, cc *cmdContext, keepAlive bool) error {
 	w := cc.Output
-	w.Infof("project: %s", cc.Project.DisplayName)
+	w.Infof("project: %s", cc.Project.Label())
 
 	// Cancel any grace period monitor since user is explicitly starting the VM.
 	cancelGracePeriodMonitorBestEffort(cc)
@@ -69,6 +69,7 @@ func RunUp(ctx context.Context, cc *cmdContext, keepAlive bool) error {
 		ConnectSSH:  cc.ConnectSSH,
 		ListRuns:    listRuns,
 		VMInfo:      vmInfo,
+		Terminate:   cc.Temporary,
 	})
 
 	if monitor == nil {
@@ -86,7 +87,12 @@ func RunUp(ctx context.Context, cc *cmdContext, keepAlive bool) error {
 
 	select {
 	case <-done:
-		w.Info("VM stopped (idle timeout)")
+		if cc.Temporary {
+			_ = cc.State.DeleteVM(cc.Project.Hash)
+			w.Info("VM terminated (idle timeout)")
+		} else {
+			w.Info("VM stopped (idle timeout)")
+		}
 	case <-ctx.Done():
 		w.Info("detached (VM still running)")
 	}
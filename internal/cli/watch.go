@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	fkexec "github.com/gridlhq/yeager/internal/exec"
+	fksync "github.com/gridlhq/yeager/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+// watchDebounce is how long the project must be quiet after a change before
+// yg watch syncs, so a save-all or a git checkout is one rerun.
+const watchDebounce = 300 * time.Millisecond
+
+func newWatchCmd(f *flags) *cobra.Command {
+	var syncOnly bool
+	cmd := &cobra.Command{
+		Use:   "watch <command...>",
+		Short: "Sync on every change and rerun a command",
+		Long: `Runs a command on the VM, then watches the project. When files change,
+the running command is cancelled, the changes are synced and the command
+runs again. Files excluded from sync are not watched.
+
+With --sync-only, no command runs: the VM's copy of the project just
+follows your edits. Ctrl+C stops watching and cancels the command.`,
+		Example: `  yg watch cargo test             # rerun tests on every change
+  yg watch go test ./... -run X   # flags after the command are its own
+  yg watch --env DEBUG=1 npm test # env for the command, as with yg run
+  yg watch --sync-only            # keep the VM in sync for another terminal`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if syncOnly != (len(args) == 0) {
+				return fmt.Errorf("give a command to run, or --sync-only")
+			}
+			cc, err := resolveCmdContext(cmd.Context(), f)
+			if err != nil {
+				return err
+			}
+			cc.EnvFlags = f.env
+			cc.EnvFile = f.envFile
+			return RunWatch(cmd.Context(), cc, strings.Join(args, " "))
+		},
+	}
+	cmd.Flags().BoolVar(&syncOnly, "sync-only", false, "only keep the VM's project files in sync")
+	cmd.Flags().StringArrayVar(&f.env, "env", nil, "env var for the command: KEY=VALUE, or KEY to copy yours")
+	cmd.Flags().StringVar(&f.envFile, "env-file", "", "load env vars for the command from a dotenv file")
+	cmd.Flags().SetInterspersed(false)
+	return cmd
+}
+
+// watchRun is a command started by yg watch.
+type watchRun struct {
+	id   fkexec.RunID
+	done chan watchOutcome
+}
+
+type watchOutcome struct {
+	result *fkexec.RunResult
+	err    error
+}
+
+// RunWatch syncs the project on every change and, unless command is empty,
+// reruns command after each sync. It returns once ctx is done.
+func RunWatch(ctx context.Context, cc *cmdContext, command string) error {
+	w := cc.Output
+	w.Infof("project: %s", cc.Project.Label())
+
+	sess, err := openSession(ctx, cc)
+	if err != nil {
+		return err
+	}
+	defer sess.close()
+
+	changes, err := cc.WatchFiles(ctx, cc)
+	if err != nil {
+		return err
+	}
+
+	var current *watchRun
+	start := func() {
+		run, err := startWatchRun(cc, sess, command)
+		if err != nil {
+			w.Warn(err.Error(), "save a file to retry")
+			return
+		}
+		current = run
+	}
+	stop := func() {
+		if current == nil {
+			return
+		}
+		if err := cc.KillRun(sess.client, current.id); err != nil {
+			slog.Debug("failed to kill run", "run", current.id, "error", err)
+		}
+		<-current.done
+		current = nil
+	}
+
+	if command != "" {
+		start()
+	} else {
+		w.Info("watching for changes — Ctrl+C to stop")
+	}
+
+	for {
+		// A nil channel blocks, so only a live run can report finishing.
+		var done chan watchOutcome
+		if current != nil {
+			done = current.done
+		}
+
+		select {
+		case <-ctx.Done():
+			stop()
+			w.Info("stopped watching")
+			checkIdleAndStop(context.WithoutCancel(ctx), cc, sess.vmInfo)
+			return nil
+
+		case batch, ok := <-changes:
+			if !ok {
+				if ctx.Err() != nil {
+					changes = nil // Ctrl+C: the case above takes it from here
+					continue
+				}
+				stop()
+				return errors.New("stopped watching: the file watcher failed")
+			}
+			if current != nil {
+				w.Info("changes detected — cancelling the run")
+			}
+			stop()
+			w.Infof("changed: %s", describeChanges(batch))
+			if err := syncProject(ctx, cc, sess); err != nil {
+				w.Warn(err.Error(), "save a file to retry")
+				continue
+			}
+			if command != "" {
+				start()
+			}
+
+		case outcome := <-done:
+			current = nil
+			w.Separator()
+			switch {
+			case outcome.err != nil:
+				w.Warn(fmt.Sprintf("running command: %s", outcome.err), "")
+			case outcome.result.ExitCode == 0:
+				w.Success(fmt.Sprintf("done (exit 0) in %s", formatDuration(outcome.result.Duration().Truncate(time.Second))))
+			default:
+				w.Warn(fmt.Sprintf("done (exit %d) in %s", outcome.result.ExitCode, formatDuration(outcome.result.Duration().Truncate(time.Second))), "")
+			}
+			w.Info("waiting for changes — Ctrl+C to stop")
+		}
+	}
+}
+
+// startWatchRun starts command on the VM in the background.
+func startWatchRun(cc *cmdContext, sess *session, command string) (*watchRun, error) {
+	w := cc.Output
+	runID := fkexec.GenerateRunID()
+	envFile := ""
+	if len(sess.env) > 0 {
+		envFile = fkexec.EnvFileName(runID)
+		if err := cc.WriteEnvFile(sess.client, remoteProjectDir+"/"+envFile, sess.env); err != nil {
+			return nil, err
+		}
+	}
+	init, wrapper := execEnvironment(sess.langs)
+
+	w.Infof("running: %s", command)
+	w.Separator()
+	// Save last run ID so yg logs and yg kill find it (best-effort).
+	if err := cc.State.SaveLastRun(cc.Project.Hash, runID.String()); err != nil {
+		slog.Debug("failed to save last run ID", "error", err)
+	}
+
+	run := &watchRun{id: runID, done: make(chan watchOutcome, 1)}
+	go func() {
+		result, err := cc.RunExec(sess.client, fkexec.RunOpts{
+			Command: command,
+			WorkDir: remoteProjectDir,
+			RunID:   runID,
+			Init:    init,
+			Wrapper: wrapper,
+			EnvFile: envFile,
+		}, newStreamWriter(w), newStreamWriter(w))
+		run.done <- watchOutcome{result: result, err: err}
+	}()
+	return run, nil
+}
+
+// describeChanges summarizes changed paths for one line of output.
+func describeChanges(paths []string) string {
+	const shown = 3
+	if len(paths) <= shown {
+		return strings.Join(paths, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(paths[:shown], ", "), len(paths)-shown)
+}
+
+// defaultWatchFunc watches the project files that are synced. The channel
+// closes when ctx is done or the watcher fails.
+func defaultWatchFunc(ctx context.Context, cc *cmdContext) (<-chan []string, error) {
+	opts := syncOptions(cc)
+	filter, err := fksync.NewFilter(cc.Project.AbsPath, opts.SyncConfig, opts.Languages)
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := fksync.NewWatcher(cc.Project.AbsPath, filter)
+	if err != nil {
+		return nil, fmt.Errorf("watching project files: %w", err)
+	}
+	changes := make(chan []string)
+	go func() {
+		defer close(changes)
+		defer watcher.Close()
+		if err := watcher.Run(ctx, watchDebounce, changes); err != nil && ctx.Err() == nil {
+			cc.Output.Error(fmt.Sprintf("watching project files: %s", err), "")
+		}
+	}()
+	return changes, nil
+}
@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	fkexec "github.com/gridlhq/yeager/internal/exec"
+	"github.com/gridlhq/yeager/internal/output"
+	"github.com/gridlhq/yeager/internal/provider"
+	fksync "github.com/gridlhq/yeager/internal/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// lockedBuffer is a bytes.Buffer safe to read while RunWatch writes to it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// watchHarness fakes the VM side of yg watch. Each run blocks until it's
+// killed, or until exit is sent an exit code.
+type watchHarness struct {
+	cc      *cmdContext
+	out     *lockedBuffer // stdout and stderr
+	changes chan []string
+	started chan fkexec.RunID
+	exit    chan int
+
+	mu     sync.Mutex
+	syncs  int
+	killed []fkexec.RunID
+}
+
+func newWatchHarness(t *testing.T) *watchHarness {
+	t.Helper()
+	prov := &mockProvider{
+		findVMFn: func(ctx context.Context, projectHash string) (*provider.VMInfo, error) {
+			return &provider.VMInfo{InstanceID: "i-test001", State: "running", PublicIP: "10.0.0.1"}, nil
+		},
+	}
+	cc, _, _ := testCmdContext(t, prov)
+	saveTestVMState(t, cc.State, cc.Project.Hash)
+	h := &watchHarness{
+		cc:      cc,
+		out:     &lockedBuffer{},
+		changes: make(chan []string),
+		started: make(chan fkexec.RunID, 10),
+		exit:    make(chan int),
+	}
+	cc.Output = output.NewWithWriters(h.out, h.out, output.ModeText)
+
+	kills := make(chan struct{}, 10)
+	cc.ConnectSSH = func(ctx context.Context, vmInfo *provider.VMInfo) (*gossh.Client, error) {
+		return nil, nil
+	}
+	cc.RunSync = func(ctx context.Context, cc *cmdContext, vmInfo *provider.VMInfo, _ *gossh.Client) (*fksync.SyncResult, error) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.syncs++
+		return &fksync.SyncResult{FilesTransferred: 1}, nil
+	}
+	cc.WatchFiles = func(ctx context.Context, cc *cmdContext) (<-chan []string, error) {
+		return h.changes, nil
+	}
+	cc.RunExec = func(client *gossh.Client, opts fkexec.RunOpts, stdout, stderr io.Writer) (*fkexec.RunResult, error) {
+		h.started <- opts.RunID
+		select {
+		case <-kills:
+			return nil, fmt.Errorf("session closed")
+		case code := <-h.exit:
+			return &fkexec.RunResult{RunID: opts.RunID, ExitCode: code, StartTime: time.Now(), EndTime: time.Now()}, nil
+		}
+	}
+	cc.KillRun = func(client *gossh.Client, runID fkexec.RunID) error {
+		h.mu.Lock()
+		h.killed = append(h.killed, runID)
+		h.mu.Unlock()
+		kills <- struct{}{}
+		return nil
+	}
+	return h
+}
+
+// run starts RunWatch and returns a func that stops it and returns its error.
+func (h *watchHarness) run(t *testing.T, command string) func() error {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- RunWatch(ctx, h.cc, command) }()
+	return func() error {
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("RunWatch didn't return")
+			return nil
+		}
+	}
+}
+
+func (h *watchHarness) nextRun(t *testing.T) fkexec.RunID {
+	t.Helper()
+	select {
+	case id := <-h.started:
+		return id
+	case <-time.After(5 * time.Second):
+		t.Fatal("no run started")
+		return ""
+	}
+}
+
+func (h *watchHarness) counts() (syncs int, killed []fkexec.RunID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.syncs, append([]fkexec.RunID(nil), h.killed...)
+}
+
+func TestRunWatch_RerunsOnChange(t *testing.T) {
+	t.Parallel()
+	h := newWatchHarness(t)
+	stop := h.run(t, "go test ./...")
+
+	first := h.nextRun(t)
+	h.changes <- []string{"a.go", "b.go", "c.go", "d.go"}
+	second := h.nextRun(t)
+	assert.NotEqual(t, first, second)
+
+	syncs, killed := h.counts()
+	assert.Equal(t, 2, syncs)
+	assert.Equal(t, []fkexec.RunID{first}, killed, "the previous run is killed before rerunning")
+
+	// Ctrl+C cancels the run in flight.
+	require.NoError(t, stop())
+	_, killed = h.counts()
+	assert.Equal(t, []fkexec.RunID{first, second}, killed)
+
+	out := h.out.String()
+	assert.Contains(t, out, "changes detected — cancelling the run")
+	assert.Contains(t, out, "changed: a.go, b.go, c.go and 1 more")
+	assert.Contains(t, out, "stopped watching")
+	lastRun, err := h.cc.State.LoadLastRun(h.cc.Project.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, second.String(), lastRun)
+}
+
+func TestRunWatch_ReportsFinishedRun(t *testing.T) {
+	t.Parallel()
+	h := newWatchHarness(t)
+	stop := h.run(t, "go test ./...")
+
+	h.nextRun(t)
+	h.exit <- 3
+	require.Eventually(t, func() bool {
+		return strings.Contains(h.out.String(), "waiting for changes")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// A change reruns it; nothing is left to kill.
+	h.changes <- []string{"a.go"}
+	h.nextRun(t)
+	_, killed := h.counts()
+	assert.Empty(t, killed)
+
+	require.NoError(t, stop())
+	assert.Contains(t, h.out.String(), "done (exit 3)")
+}
+
+func TestRunWatch_SyncOnly(t *testing.T) {
+	t.Parallel()
+	h := newWatchHarness(t)
+	stop := h.run(t, "")
+
+	h.changes <- []string{"a.go"}
+	h.changes <- []string{"b.go"}
+	require.NoError(t, stop())
+
+	syncs, killed := h.counts()
+	assert.Equal(t, 3, syncs, "the first sync, then one per change")
+	assert.Empty(t, killed)
+	assert.Empty(t, h.started, "no command runs")
+	assert.Contains(t, h.out.String(), "changed: b.go")
+}
+
+func TestDescribeChanges(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "a.go", describeChanges([]string{"a.go"}))
+	assert.Equal(t, "a, b, c", describeChanges([]string{"a", "b", "c"}))
+	assert.Equal(t, "a, b, c and 2 more", describeChanges([]string{"a", "b", "c", "d", "e"}))
+}
+
+func TestWatchCmdTakesEnvFlags(t *testing.T) {
+	t.Parallel()
+
+	root := newRootCmd("test")
+	cmd, args, err := root.Find([]string{"watch", "--env", "A=1", "--env-file", ".env.test", "echo", "--env", "B=2"})
+	require.NoError(t, err)
+	require.Equal(t, "watch", cmd.Name())
+	require.NoError(t, cmd.ParseFlags(args))
+	env, err := cmd.Flags().GetStringArray("env")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A=1"}, env)
+	assert.Equal(t, []string{"echo", "--env", "B=2"}, cmd.Flags().Args())
+}
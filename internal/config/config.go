@@ -3,12 +3,18 @@ package config
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
+
+	"github.com/gridlhq/yeager/internal/secrets"
 )
 
 const (
@@ -16,13 +22,123 @@ const (
 	EnvPrefix = "YEAGER"
 )
 
+// ProfileEnv selects a profile when --profile isn't given.
+const ProfileEnv = EnvPrefix + "_PROFILE"
+
 // Config is the full yeager configuration.
 type Config struct {
-	Compute   ComputeConfig   `mapstructure:"compute"`
-	Lifecycle LifecycleConfig `mapstructure:"lifecycle"`
-	Setup     SetupConfig     `mapstructure:"setup"`
-	Sync      SyncConfig      `mapstructure:"sync"`
-	Artifacts ArtifactsConfig `mapstructure:"artifacts"`
+	Compute      ComputeConfig            `mapstructure:"compute"`
+	Lifecycle    LifecycleConfig          `mapstructure:"lifecycle"`
+	Setup        SetupConfig              `mapstructure:"setup"`
+	Sync         SyncConfig               `mapstructure:"sync"`
+	Artifacts    ArtifactsConfig          `mapstructure:"artifacts"`
+	Workspace    WorkspaceConfig          `mapstructure:"workspace"`
+	Devcontainer DevcontainerConfig       `mapstructure:"devcontainer"`
+	Exec         ExecConfig               `mapstructure:"exec"`
+	Env          EnvConfig                `mapstructure:"env"`
+	Services     map[string]ServiceConfig `mapstructure:"services"`
+	Profiles     map[string]ProfileConfig `mapstructure:"profiles"`
+	Tasks        map[string]TaskConfig    `mapstructure:"tasks"`
+}
+
+// ProfileConfig is a [profiles.<name>] overlay, selected with --profile or
+// YEAGER_PROFILE. Set scalars replace the base config's; lists are appended
+// to it, and services are added (or replaced by name).
+type ProfileConfig struct {
+	Compute   ComputeConfig            `mapstructure:"compute"`
+	Setup     SetupConfig              `mapstructure:"setup"`
+	Sync      SyncConfig               `mapstructure:"sync"`
+	Artifacts ArtifactsConfig          `mapstructure:"artifacts"`
+	Env       EnvConfig                `mapstructure:"env"`
+	Services  map[string]ServiceConfig `mapstructure:"services"`
+}
+
+// ProfileNames returns the configured profile names, sorted.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithProfile returns the config with the named profile overlaid.
+// An empty name returns the config unchanged.
+func (c Config) WithProfile(name string) (Config, error) {
+	if name == "" {
+		return c, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return Config{}, fmt.Errorf("unknown profile %q: no [profiles] in %s", name, FileName)
+		}
+		return Config{}, fmt.Errorf("unknown profile %q (configured: %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+
+	if p.Compute.Size != "" {
+		c.Compute.Size = p.Compute.Size
+	}
+	if p.Compute.Region != "" {
+		c.Compute.Region = p.Compute.Region
+	}
+	c.Setup = SetupConfig{
+		Presets:  concat(c.Setup.Presets, p.Setup.Presets),
+		Packages: concat(c.Setup.Packages, p.Setup.Packages),
+		Run:      concat(c.Setup.Run, p.Setup.Run),
+	}
+	c.Sync = SyncConfig{
+		Include: concat(c.Sync.Include, p.Sync.Include),
+		Exclude: concat(c.Sync.Exclude, p.Sync.Exclude),
+		Engine:  c.Sync.Engine,
+		Mode:    c.Sync.Mode,
+		Pull:    concat(c.Sync.Pull, p.Sync.Pull),
+
+		Preserve:     concat(c.Sync.Preserve, p.Sync.Preserve),
+		MaxSyncBytes: c.Sync.MaxSyncBytes,
+
+		LargeFileBytes: c.Sync.LargeFileBytes,
+
+		Mounts: append(append([]SyncMount(nil), c.Sync.Mounts...), p.Sync.Mounts...),
+	}
+	if p.Sync.Engine != "" {
+		c.Sync.Engine = p.Sync.Engine
+	}
+	if p.Sync.Mode != "" {
+		c.Sync.Mode = p.Sync.Mode
+	}
+	if p.Sync.MaxSyncBytes != 0 {
+		c.Sync.MaxSyncBytes = p.Sync.MaxSyncBytes
+	}
+	if p.Sync.LargeFileBytes != 0 {
+		c.Sync.LargeFileBytes = p.Sync.LargeFileBytes
+	}
+	c.Artifacts.Paths = concat(c.Artifacts.Paths, p.Artifacts.Paths)
+	c.Env = EnvConfig{
+		Vars:        concat(c.Env.Vars, p.Env.Vars),
+		Passthrough: concat(c.Env.Passthrough, p.Env.Passthrough),
+		Secrets:     concat(c.Env.Secrets, p.Env.Secrets),
+	}
+	if len(p.Services) > 0 {
+		services := make(map[string]ServiceConfig, len(c.Services)+len(p.Services))
+		for n, svc := range c.Services {
+			services[n] = svc
+		}
+		for n, svc := range p.Services {
+			services[n] = svc
+		}
+		c.Services = services
+	}
+	return c, nil
+}
+
+// concat appends b to a copy of a, so overlays never share backing arrays.
+func concat(a, b []string) []string {
+	if len(b) == 0 {
+		return a
+	}
+	return append(append([]string(nil), a...), b...)
 }
 
 // ComputeConfig controls VM size and region.
@@ -42,6 +158,7 @@ type LifecycleConfig struct {
 
 // SetupConfig controls extra packages and setup commands.
 type SetupConfig struct {
+	Presets  []string `mapstructure:"presets"` // built-in recipes, "name" or "name@version"
 	Packages []string `mapstructure:"packages"`
 	Run      []string `mapstructure:"run"`
 }
@@ -50,13 +167,335 @@ type SetupConfig struct {
 type SyncConfig struct {
 	Include []string `mapstructure:"include"`
 	Exclude []string `mapstructure:"exclude"`
+	Engine  string   `mapstructure:"engine"` // SyncEngineNative (the default) or SyncEngineRsync
+	Mode    string   `mapstructure:"mode"`   // SyncModeRules (the default) or SyncModeGit
+	Pull    []string `mapstructure:"pull"`   // VM files copied back after each run
+
+	// Preserve lists files on the VM that sync never deletes, like
+	// downloaded fixtures or local databases, even when they're gone or
+	// were never there locally.
+	Preserve []string `mapstructure:"preserve"`
+
+	// MaxSyncBytes is the most the synced files may add up to; a sync
+	// over it fails before sending anything. 0 means no limit.
+	MaxSyncBytes int64 `mapstructure:"max_sync_bytes"`
+
+	// LargeFileBytes is the size from which a file is uploaded once to
+	// the project's S3 prefix, by content, and downloaded from there on
+	// the VM instead of sent over SSH. 0 means never.
+	LargeFileBytes int64 `mapstructure:"large_file_bytes"`
+
+	// Mounts are directories outside the project synced beside it, from
+	// [[sync.mounts]] tables.
+	Mounts []SyncMount `mapstructure:"mounts"`
+}
+
+// SyncMount is a local directory outside the project, like a sibling
+// checkout that a path dependency points at, synced to the VM too.
+type SyncMount struct {
+	Local   string   `mapstructure:"local"`   // relative to the project, or absolute
+	Remote  string   `mapstructure:"remote"`  // on the VM, relative to the project there, or absolute; default: Local
+	Exclude []string `mapstructure:"exclude"` // extra paths to skip
+}
+
+// RemotePath returns where the mount goes on the VM: Remote, or Local if
+// that's not set, cleaned and slash-separated.
+func (m SyncMount) RemotePath() string {
+	if m.Remote != "" {
+		return path.Clean(m.Remote)
+	}
+	return path.Clean(filepath.ToSlash(m.Local))
+}
+
+// RemoteProjectDir is where the project is synced to on the VM.
+const RemoteProjectDir = "/home/ubuntu/project"
+
+// RemoteDir returns the absolute path the mount goes to on the VM, with a
+// relative RemotePath resolved against RemoteProjectDir.
+func (m SyncMount) RemoteDir() string {
+	remote := m.RemotePath()
+	if path.IsAbs(remote) {
+		return remote
+	}
+	return path.Join(RemoteProjectDir, remote)
 }
 
+func (m SyncMount) validate(i int) error {
+	if m.Local == "" {
+		return fmt.Errorf("invalid sync.mounts[%d]: local is required", i)
+	}
+	if m.Remote == "" && filepath.IsAbs(m.Local) {
+		return fmt.Errorf("invalid sync.mounts[%d]: local %q is absolute, so set remote too", i, m.Local)
+	}
+	remote, dir := m.RemotePath(), m.RemoteDir()
+	if dir == RemoteProjectDir || strings.HasPrefix(dir, RemoteProjectDir+"/") {
+		return fmt.Errorf("invalid sync.mounts[%d]: remote %q is inside the project, which is synced already; mounts go beside it, like \"../shared-protos\"", i, remote)
+	}
+	if dir == "/" || strings.HasPrefix(RemoteProjectDir, dir+"/") {
+		// Syncing deletes what the mount doesn't have, which would be the
+		// project, yeager's state and the toolchains.
+		return fmt.Errorf("invalid sync.mounts[%d]: remote %q is %s, which holds the project; mounts go beside it, like \"../shared-protos\"", i, remote, dir)
+	}
+	return nil
+}
+
+// Sync engines for [sync] engine.
+const (
+	SyncEngineNative = "native" // built in, over yeager's own SSH connection
+	SyncEngineRsync  = "rsync"  // the local rsync binary
+)
+
+// Sync modes for [sync] mode: how the files to sync are chosen.
+const (
+	SyncModeRules = "rules" // .gitignore files plus yeager's default excludes
+	SyncModeGit   = "git"   // whatever git ls-files lists, tracked or not ignored
+)
+
 // ArtifactsConfig controls which paths are uploaded to S3 after each run.
 type ArtifactsConfig struct {
 	Paths []string `mapstructure:"paths"`
 }
 
+// WorkspaceConfig lists monorepo member directories for language detection,
+// in addition to those discovered from workspace manifests.
+type WorkspaceConfig struct {
+	Members []string `mapstructure:"members"`
+}
+
+// DevcontainerConfig controls how .devcontainer/devcontainer.json is applied.
+// By default its features, lifecycle commands and env are translated to the VM.
+type DevcontainerConfig struct {
+	Ignore    bool `mapstructure:"ignore"`    // don't read devcontainer.json
+	Container bool `mapstructure:"container"` // deprecated: exec.container.devcontainer, which decode sets from it
+}
+
+// ExecConfig controls where remote commands run.
+type ExecConfig struct {
+	Container ExecContainerConfig `mapstructure:"container"`
+}
+
+// ExecContainerConfig runs remote commands inside a container built from the
+// project's Dockerfile, a docker compose service or the devcontainer image,
+// instead of on the VM.
+type ExecContainerConfig struct {
+	Devcontainer   bool   `mapstructure:"devcontainer"`    // the image of .devcontainer/devcontainer.json
+	Dockerfile     string `mapstructure:"dockerfile"`      // path relative to the project
+	Context        string `mapstructure:"context"`         // build context, default "."
+	ComposeService string `mapstructure:"compose_service"` // service to run commands in
+	ComposeFile    string `mapstructure:"compose_file"`    // default: compose.yaml, docker-compose.yml, ...
+	User           string `mapstructure:"user"`            // container user, default the VM user's uid:gid
+}
+
+// Enabled reports whether commands run in the project's own container,
+// built from a Dockerfile or compose service. The devcontainer image is
+// handled with the rest of devcontainer.json.
+func (c ExecContainerConfig) Enabled() bool {
+	return c.Dockerfile != "" || c.ComposeService != ""
+}
+
+// EnvConfig sets environment variables for remote commands. Entries are
+// KEY=VALUE strings, since TOML table keys lose their case. Values reach the
+// VM through a private env file, never the command line or run metadata.
+type EnvConfig struct {
+	Vars        []string `mapstructure:"vars"`        // "KEY=VALUE"
+	Passthrough []string `mapstructure:"passthrough"` // local variables copied as-is, e.g. "DATABASE_URL"
+	Secrets     []string `mapstructure:"secrets"`     // "KEY=ssm:/path" or "KEY=secretsmanager:id[#key]"
+}
+
+func (e EnvConfig) validate() error {
+	for _, env := range []struct {
+		key     string
+		entries []string
+	}{{"vars", e.Vars}, {"secrets", e.Secrets}} {
+		for _, kv := range env.entries {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok || !envNameRe.MatchString(k) {
+				return fmt.Errorf("invalid env.%s entry %q: want KEY=VALUE", env.key, kv)
+			}
+			if env.key == "secrets" {
+				if err := secrets.ValidateRef(v); err != nil {
+					return fmt.Errorf("invalid env.secrets entry for %s: %w", k, err)
+				}
+			}
+		}
+	}
+	for _, name := range e.Passthrough {
+		if !envNameRe.MatchString(name) {
+			return fmt.Errorf("invalid env.passthrough entry %q: want a variable name", name)
+		}
+	}
+	return nil
+}
+
+// ServiceConfig is a sidecar container (a database, a cache) started on
+// the VM before each run. Env entries are KEY=VALUE strings, since TOML
+// table keys lose their case.
+type ServiceConfig struct {
+	Image       string   `mapstructure:"image"`
+	Ports       []string `mapstructure:"ports"`       // "5432" or "15432:5432" (host:container), bound to localhost
+	Env         []string `mapstructure:"env"`         // container env, e.g. "POSTGRES_PASSWORD=test"
+	Export      []string `mapstructure:"export"`      // env for remote commands, e.g. "DATABASE_URL=postgres://..."
+	Healthcheck string   `mapstructure:"healthcheck"` // command run in the container; default: wait for the ports
+}
+
+// ServiceNames returns the configured service names, sorted.
+func (c *Config) ServiceNames() []string {
+	names := make([]string, 0, len(c.Services))
+	for name := range c.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// serviceNameRe matches service names, which become container names and
+// env var prefixes. Profile names follow the same rules.
+var serviceNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// envNameRe matches env var names.
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParsePort splits a "host:container" or "port" mapping.
+func ParsePort(mapping string) (host, container int, err error) {
+	hostPart, containerPart, found := strings.Cut(mapping, ":")
+	if !found {
+		containerPart = hostPart
+	}
+	host, err = strconv.Atoi(hostPart)
+	if err == nil {
+		container, err = strconv.Atoi(containerPart)
+	}
+	if err != nil || host < 1 || host > 65535 || container < 1 || container > 65535 {
+		return 0, 0, fmt.Errorf("invalid port %q: want \"port\" or \"host:container\"", mapping)
+	}
+	return host, container, nil
+}
+
+func (s ServiceConfig) validate(name string) error {
+	if !serviceNameRe.MatchString(name) {
+		return fmt.Errorf("invalid service name %q: use lowercase letters, digits, - and _", name)
+	}
+	if s.Image == "" {
+		return fmt.Errorf("invalid services.%s: image is required", name)
+	}
+	for _, port := range s.Ports {
+		if _, _, err := ParsePort(port); err != nil {
+			return fmt.Errorf("invalid services.%s.ports: %w", name, err)
+		}
+	}
+	for _, env := range []struct {
+		key     string
+		entries []string
+	}{{"env", s.Env}, {"export", s.Export}} {
+		for _, kv := range env.entries {
+			if k, _, ok := strings.Cut(kv, "="); !ok || !envNameRe.MatchString(k) {
+				return fmt.Errorf("invalid services.%s.%s entry %q: want KEY=VALUE", name, env.key, kv)
+			}
+		}
+	}
+	return nil
+}
+
+// TaskConfig is a named command run with yg run <name>. Env entries are
+// KEY=VALUE strings, added to [env] vars for the task.
+type TaskConfig struct {
+	Command     string   `mapstructure:"command"`
+	Description string   `mapstructure:"description"`
+	Env         []string `mapstructure:"env"`
+	Profile     string   `mapstructure:"profile"`    // run with this profile, unless --profile is given
+	Timeout     string   `mapstructure:"timeout"`    // kill the command after this long, e.g. "30m"
+	Artifacts   []string `mapstructure:"artifacts"`  // added to [artifacts] paths for the task
+	DependsOn   []string `mapstructure:"depends_on"` // tasks run first, in order
+}
+
+// TaskNames returns the configured task names, sorted.
+func (c *Config) TaskNames() []string {
+	names := make([]string, 0, len(c.Tasks))
+	for name := range c.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TaskOrder returns the tasks to run for name: its dependencies, depth
+// first and each at most once, then the task itself.
+func (c *Config) TaskOrder(name string) ([]string, error) {
+	var order []string
+	done := map[string]bool{}
+	visiting := map[string]bool{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		task, ok := c.Tasks[name]
+		if !ok {
+			if len(path) == 0 {
+				if len(c.Tasks) == 0 {
+					return fmt.Errorf("unknown task %q: no [tasks] in %s", name, FileName)
+				}
+				return fmt.Errorf("unknown task %q (configured: %s)", name, strings.Join(c.TaskNames(), ", "))
+			}
+			return fmt.Errorf("task %s depends on unknown task %q", path[len(path)-1], name)
+		}
+		if done[name] {
+			return nil
+		}
+		path = append(path, name)
+		if visiting[name] {
+			return fmt.Errorf("tasks depend on each other: %s", strings.Join(path, " -> "))
+		}
+		visiting[name] = true
+		for _, dep := range task.DependsOn {
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		done[name] = true
+		order = append(order, name)
+		return nil
+	}
+	if err := visit(name, nil); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// TimeoutDuration returns the parsed timeout, or 0 if none is set.
+func (t TaskConfig) TimeoutDuration() (time.Duration, error) {
+	if t.Timeout == "" {
+		return 0, nil
+	}
+	return ParseDuration(t.Timeout)
+}
+
+func (t TaskConfig) validate(name string, c *Config) error {
+	if !serviceNameRe.MatchString(name) {
+		return fmt.Errorf("invalid task name %q: use lowercase letters, digits, - and _", name)
+	}
+	if strings.TrimSpace(t.Command) == "" {
+		return fmt.Errorf("invalid tasks.%s: command is required", name)
+	}
+	for _, kv := range t.Env {
+		if k, _, ok := strings.Cut(kv, "="); !ok || !envNameRe.MatchString(k) {
+			return fmt.Errorf("invalid tasks.%s.env entry %q: want KEY=VALUE", name, kv)
+		}
+	}
+	if t.Profile != "" {
+		if _, ok := c.Profiles[t.Profile]; !ok {
+			return fmt.Errorf("invalid tasks.%s.profile: unknown profile %q", name, t.Profile)
+		}
+	}
+	if d, err := t.TimeoutDuration(); err != nil {
+		return fmt.Errorf("invalid tasks.%s.timeout: %w", name, err)
+	} else if d < 0 {
+		return fmt.Errorf("invalid tasks.%s.timeout: must be positive", name)
+	}
+	if _, err := c.TaskOrder(name); err != nil {
+		return err
+	}
+	return nil
+}
+
 // ParseDuration parses a duration string with support for "Nd" day syntax.
 func ParseDuration(s string) (time.Duration, error) {
 	if strings.HasSuffix(s, "d") {
@@ -143,43 +582,145 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("invalid lifecycle.terminated_delete_ami: %w", err)
 		}
 	}
+	switch c.Sync.Engine {
+	case "", SyncEngineNative, SyncEngineRsync:
+	default:
+		return fmt.Errorf("invalid sync.engine %q (must be %s or %s)", c.Sync.Engine, SyncEngineNative, SyncEngineRsync)
+	}
+	switch c.Sync.Mode {
+	case "", SyncModeRules:
+	case SyncModeGit:
+		if c.Sync.Engine == SyncEngineRsync {
+			return fmt.Errorf("sync.mode %q needs sync.engine %q", SyncModeGit, SyncEngineNative)
+		}
+	default:
+		return fmt.Errorf("invalid sync.mode %q (must be %s or %s)", c.Sync.Mode, SyncModeRules, SyncModeGit)
+	}
+	if c.Sync.MaxSyncBytes < 0 {
+		return fmt.Errorf("invalid sync.max_sync_bytes %d (must be 0 for no limit, or more)", c.Sync.MaxSyncBytes)
+	}
+	if c.Sync.LargeFileBytes < 0 {
+		return fmt.Errorf("invalid sync.large_file_bytes %d (must be 0 to never stage files through S3, or more)", c.Sync.LargeFileBytes)
+	}
+	remotes := map[string]bool{}
+	for i, m := range c.Sync.Mounts {
+		if err := m.validate(i); err != nil {
+			return err
+		}
+		if remotes[m.RemoteDir()] {
+			return fmt.Errorf("invalid sync.mounts[%d]: another mount goes to %q too", i, m.RemotePath())
+		}
+		remotes[m.RemoteDir()] = true
+	}
+	if err := c.Exec.Container.validate(); err != nil {
+		return err
+	}
+	if err := c.Env.validate(); err != nil {
+		return err
+	}
+	for _, name := range c.ServiceNames() {
+		if err := c.Services[name].validate(name); err != nil {
+			return err
+		}
+	}
+	for _, name := range c.TaskNames() {
+		if err := c.Tasks[name].validate(name, c); err != nil {
+			return err
+		}
+	}
+	for _, name := range c.ProfileNames() {
+		if !serviceNameRe.MatchString(name) {
+			return fmt.Errorf("invalid profile name %q: use lowercase letters, digits, - and _", name)
+		}
+		merged, _ := c.WithProfile(name)
+		merged.Profiles, merged.Tasks = nil, nil
+		if err := merged.Validate(); err != nil {
+			return fmt.Errorf("in profile %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (c ExecContainerConfig) validate() error {
+	if c.Dockerfile != "" && c.ComposeService != "" {
+		return fmt.Errorf("invalid exec.container: set dockerfile or compose_service, not both")
+	}
+	if c.Devcontainer && c.Enabled() {
+		return fmt.Errorf("invalid exec.container: set devcontainer or dockerfile/compose_service, not both")
+	}
+	for _, p := range []struct{ key, path string }{
+		{"dockerfile", c.Dockerfile},
+		{"context", c.Context},
+		{"compose_file", c.ComposeFile},
+	} {
+		if p.path == "" {
+			continue
+		}
+		if filepath.IsAbs(p.path) || strings.HasPrefix(filepath.Clean(p.path), "..") {
+			return fmt.Errorf("invalid exec.container.%s %q: must be a path inside the project", p.key, p.path)
+		}
+	}
 	return nil
 }
 
 // Load reads configuration from .yeager.toml (discovered by walking up from startDir),
+// the user config (see UserConfigPath), an org config it references, and
 // environment variables (YEAGER_*), and applies defaults.
 // CLI flag overrides should be applied by the caller after Load returns.
+// Returns the project config path, or empty string if there is none.
 func Load(startDir string) (Config, string, error) {
-	cfg := Defaults()
+	cfg, files, err := LoadFiles(startDir, UserConfigPath())
+	return cfg, files.Project, err
+}
 
-	v := viper.New()
-	v.SetConfigType("toml")
-	v.SetEnvPrefix(EnvPrefix)
-	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	v.AutomaticEnv()
+// LoadFiles is Load with an explicit user config path (empty for none).
+// Precedence, highest first: env > project > user > org > defaults.
+// Tables merge key by key; a list replaces the lower layer's list.
+// Unknown keys in any file are an error, reported with their line.
+func LoadFiles(startDir, userPath string) (Config, Files, error) {
+	return loadFiles(startDir, userPath, false)
+}
 
-	setViperDefaults(v, cfg)
+// LoadCached is Load for commands that must not wait on the network, like
+// help and shell completion: an org config URL is never downloaded, and
+// its cached copy, however old, is used if there is one.
+func LoadCached(startDir string) (Config, string, error) {
+	cfg, files, err := loadFiles(startDir, UserConfigPath(), true)
+	return cfg, files.Project, err
+}
 
-	configPath := FindConfig(startDir)
-	if configPath != "" {
-		v.SetConfigFile(configPath)
-		if err := v.ReadInConfig(); err != nil {
-			return Config{}, "", fmt.Errorf("reading %s: %w", configPath, err)
-		}
+func loadFiles(startDir, userPath string, cachedOnly bool) (Config, Files, error) {
+	layers, files, err := readLayers(startDir, userPath, cachedOnly)
+	if err != nil {
+		return Config{}, Files{}, err
+	}
+	if err := unknownKeys(layers); err != nil {
+		return Config{}, Files{}, err
+	}
+	cfg, err := decode(mergedViper(layers))
+	if err != nil {
+		return Config{}, Files{}, err
 	}
+	return cfg, files, nil
+}
 
+// decode unmarshals v over the defaults and validates the result.
+func decode(v *viper.Viper) (Config, error) {
+	cfg := Defaults()
 	decoderOpt := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
 		mapstructure.StringToBasicTypeHookFunc(),
 	))
 	if err := v.Unmarshal(&cfg, decoderOpt); err != nil {
-		return Config{}, "", fmt.Errorf("parsing config: %w", err)
+		return Config{}, fmt.Errorf("parsing config: %w", err)
+	}
+	// devcontainer.container is still read: see deprecatedKeys.
+	if cfg.Devcontainer.Container {
+		cfg.Exec.Container.Devcontainer = true
 	}
-
 	if err := cfg.Validate(); err != nil {
-		return Config{}, "", err
+		return Config{}, err
 	}
-
-	return cfg, configPath, nil
+	return cfg, nil
 }
 
 // FindConfig walks up from startDir looking for .yeager.toml.
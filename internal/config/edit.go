@@ -0,0 +1,273 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// SetValue sets a key in the project config file at path, creating the file
+// if it doesn't exist. The value is parsed for the key's kind: lists take a
+// TOML array or comma-separated items. The rest of the file, comments
+// included, is kept as is, and the file is only written if the result is a
+// valid config.
+func SetValue(path, key, value string) error {
+	return setValue(path, key, value, false)
+}
+
+// SetUserValue is SetValue for the user config, which also takes org_config.
+func SetUserValue(path, key, value string) error {
+	return setValue(path, key, value, true)
+}
+
+func setValue(path, key, value string, user bool) error {
+	kind := KindString
+	if !(user && key == orgConfigKey) {
+		info, ok := LookupKey(key)
+		if !ok {
+			if suggestion := SuggestKey(key); suggestion != "" {
+				return fmt.Errorf("unknown key %s (did you mean %s?)", key, suggestion)
+			}
+			return fmt.Errorf("unknown key %s", key)
+		}
+		kind = info.Kind
+	}
+
+	literal, err := tomlLiteral(kind, value)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	perm := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	table, leaf := "", key
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		table, leaf = key[:i], key[i+1:]
+	}
+	updated := setTOMLValue(data, table, leaf, literal)
+
+	if err := checkDocument(updated, user); err != nil {
+		return fmt.Errorf("setting %s: %w", key, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, updated, perm); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// tomlLiteral renders a command-line value as a TOML value of the given kind.
+func tomlLiteral(kind, value string) (string, error) {
+	switch kind {
+	case KindBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("want true or false, got %q", value)
+		}
+		return strconv.FormatBool(b), nil
+	case KindInt:
+		n, err := strconv.ParseInt(strings.ReplaceAll(value, "_", ""), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("want a whole number, got %q", value)
+		}
+		return strconv.FormatInt(n, 10), nil
+	case KindList:
+		if strings.HasPrefix(strings.TrimSpace(value), "[") {
+			v := viper.New()
+			v.SetConfigType("toml")
+			if err := v.ReadConfig(strings.NewReader("list = " + value)); err != nil {
+				return "", fmt.Errorf("not a TOML array: %w", err)
+			}
+			if _, ok := v.Get("list").([]any); !ok {
+				return "", fmt.Errorf("not a TOML array: %s", value)
+			}
+			return strings.TrimSpace(value), nil
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, strconv.Quote(item))
+			}
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	default:
+		return strconv.Quote(value), nil
+	}
+}
+
+// checkDocument parses an edited config file on its own and validates it.
+func checkDocument(data []byte, user bool) error {
+	v := viper.New()
+	v.SetConfigType("toml")
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("result is not valid TOML: %w", err)
+	}
+	settings := v.AllSettings()
+	if user {
+		delete(settings, orgConfigKey)
+	}
+
+	merged := viper.New()
+	setViperDefaults(merged, Defaults())
+	// Merging maps of already-decoded values can't fail.
+	_ = merged.MergeConfigMap(settings)
+	_, err := decode(merged)
+	return err
+}
+
+// tableHeaderRe matches a [table] header line. Array tables ([[x]]) aren't
+// part of the config format.
+var tableHeaderRe = regexp.MustCompile(`^\s*\[\s*([^\[\]#]+?)\s*\]\s*(#.*)?$`)
+
+// setTOMLValue sets leaf = literal in table. An existing assignment is
+// replaced, keeping its trailing comment; otherwise a commented-out one,
+// as written by yg init, is uncommented; otherwise the assignment is added
+// to the table, which is created at the end of the file if needed.
+func setTOMLValue(data []byte, table, leaf, literal string) []byte {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+
+	assignRe := regexp.MustCompile(`^(\s*)(#\s*)?` + regexp.QuoteMeta(leaf) + `\s*=\s*(.*)$`)
+
+	// Find the table's lines: [start, end).
+	start, end, found := 0, len(lines), table == ""
+	current := ""
+	for i, line := range lines {
+		m := tableHeaderRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		name := strings.NewReplacer(" ", "", `"`, "").Replace(m[1])
+		if found && current == table {
+			end = i
+			break
+		}
+		current = name
+		if name == table {
+			start, found = i+1, true
+		}
+	}
+	if !found {
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+table+"]", leaf+" = "+literal)
+		return []byte(strings.Join(lines, "\n") + "\n")
+	}
+
+	commented, lastAssign := -1, -1
+	for i := start; i < end; i++ {
+		line := lines[i]
+		m := assignRe.FindStringSubmatch(line)
+		if m == nil {
+			if t := strings.TrimSpace(line); t != "" && !strings.HasPrefix(t, "#") && strings.Contains(t, "=") {
+				_, after, _ := strings.Cut(t, "=")
+				_, _, depth := splitTOMLValue(after)
+				for depth > 0 && i+1 < end {
+					i++
+					_, _, d := splitTOMLValue(lines[i])
+					depth += d
+				}
+				lastAssign = i
+			}
+			continue
+		}
+		_, _, depth := splitTOMLValue(m[3])
+		if m[2] != "" {
+			if commented < 0 && depth == 0 {
+				commented = i
+			}
+			continue
+		}
+
+		// Replace an active assignment, including continuation lines of
+		// a multi-line array.
+		last := i
+		for depth > 0 && last+1 < len(lines) {
+			last++
+			_, _, d := splitTOMLValue(lines[last])
+			depth += d
+		}
+		lines[i] = assignment(line, m[1], leaf, literal, m[3])
+		lines = append(lines[:i+1], lines[last+1:]...)
+		return []byte(strings.Join(lines, "\n") + "\n")
+	}
+
+	if commented >= 0 {
+		m := assignRe.FindStringSubmatch(lines[commented])
+		lines[commented] = assignment(lines[commented], m[1], leaf, literal, m[3])
+		return []byte(strings.Join(lines, "\n") + "\n")
+	}
+
+	at := start
+	if lastAssign >= 0 {
+		at = lastAssign + 1
+	}
+	newLine := leaf + " = " + literal
+	if table == "" && lastAssign < 0 {
+		// Top-level keys go before the first table.
+		at = end
+		if at < len(lines) {
+			newLine += "\n"
+		}
+	}
+	lines = append(lines[:at], append([]string{newLine}, lines[at:]...)...)
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// assignment renders leaf = literal in place of line, keeping the trailing
+// comment of the old value in the same column.
+func assignment(line, indent, leaf, literal, oldValue string) string {
+	out := indent + leaf + " = " + literal
+	_, comment, _ := splitTOMLValue(oldValue)
+	if comment == "" {
+		return out
+	}
+	pad := strings.LastIndex(line, comment) - len(out)
+	return out + strings.Repeat(" ", max(pad, 2)) + comment
+}
+
+// splitTOMLValue splits the text after "=" into the value and a trailing
+// comment, and returns how many brackets and braces the value leaves open.
+func splitTOMLValue(s string) (value, comment string, depth int) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == '#':
+			return strings.TrimSpace(s[:i]), s[i:], depth
+		}
+	}
+	return strings.TrimSpace(s), "", depth
+}
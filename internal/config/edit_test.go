@@ -0,0 +1,144 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetTOMLValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		table string
+		leaf  string
+		value string
+		want  string
+	}{
+		{
+			name:  "replaces and keeps the comment",
+			input: "[compute]\nsize = \"small\"   # cheap\nregion = \"us-east-1\"\n",
+			table: "compute", leaf: "size", value: `"large"`,
+			want: "[compute]\nsize = \"large\"   # cheap\nregion = \"us-east-1\"\n",
+		},
+		{
+			name:  "uncomments a template line",
+			input: "# header\n[lifecycle]\n# grace_period = \"2m\"\n# idle_stop = \"10m\"           # stop VM after this much idle time\n",
+			table: "lifecycle", leaf: "idle_stop", value: `"30m"`,
+			want: "# header\n[lifecycle]\n# grace_period = \"2m\"\nidle_stop = \"30m\"             # stop VM after this much idle time\n",
+		},
+		{
+			name:  "replaces a multi-line array",
+			input: "[setup]\nrun = [\n  \"a\",\n  \"b\",\n]\npackages = [\"x\"]\n",
+			table: "setup", leaf: "run", value: `["c"]`,
+			want: "[setup]\nrun = [\"c\"]\npackages = [\"x\"]\n",
+		},
+		{
+			name:  "adds to an existing table",
+			input: "[compute]\nsize = \"small\"\n\n[sync]\nexclude = [\"data/\"]\n",
+			table: "compute", leaf: "region", value: `"eu-west-1"`,
+			want: "[compute]\nsize = \"small\"\nregion = \"eu-west-1\"\n\n[sync]\nexclude = [\"data/\"]\n",
+		},
+		{
+			name:  "adds a table",
+			input: "[compute]\nsize = \"small\"\n",
+			table: "services.db", leaf: "image", value: `"postgres:16"`,
+			want: "[compute]\nsize = \"small\"\n\n[services.db]\nimage = \"postgres:16\"\n",
+		},
+		{
+			name:  "creates a file",
+			input: "",
+			table: "compute", leaf: "size", value: `"large"`,
+			want: "[compute]\nsize = \"large\"\n",
+		},
+		{
+			name:  "top-level key goes before the first table",
+			input: "# user settings\n[compute]\nsize = \"small\"\n",
+			table: "", leaf: "org_config", value: `"org.toml"`,
+			want: "# user settings\norg_config = \"org.toml\"\n\n[compute]\nsize = \"small\"\n",
+		},
+		{
+			name:  "ignores the key in other tables",
+			input: "[compute]\nsize = \"small\"\n\n[profiles.ci.compute]\nsize = \"xlarge\"\n",
+			table: "profiles.ci.compute", leaf: "size", value: `"large"`,
+			want: "[compute]\nsize = \"small\"\n\n[profiles.ci.compute]\nsize = \"large\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := setTOMLValue([]byte(tt.input), tt.table, tt.leaf, tt.value)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestSetValue(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), FileName)
+	require.NoError(t, os.WriteFile(path, []byte(Template), 0o644))
+
+	require.NoError(t, SetValue(path, "lifecycle.idle_stop", "30m"))
+	require.NoError(t, SetValue(path, "setup.packages", "libpq-dev, jq"))
+	require.NoError(t, SetValue(path, "devcontainer.ignore", "true"))
+	require.NoError(t, SetValue(path, "sync.max_sync_bytes", "2_000_000_000"))
+
+	cfg, _, err := LoadFiles(filepath.Dir(path), "")
+	require.NoError(t, err)
+	assert.Equal(t, "30m", cfg.Lifecycle.IdleStop)
+	assert.Equal(t, []string{"libpq-dev", "jq"}, cfg.Setup.Packages)
+	assert.True(t, cfg.Devcontainer.Ignore)
+	assert.Equal(t, int64(2_000_000_000), cfg.Sync.MaxSyncBytes)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "# generated by yeager", "comments are kept")
+}
+
+func TestSetValue_Errors(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), FileName)
+	original := "[compute]\nsize = \"small\"\n"
+	require.NoError(t, os.WriteFile(path, []byte(original), 0o644))
+
+	tests := []struct {
+		key, value string
+		wantErr    string
+	}{
+		{"compute.sise", "large", "unknown key compute.sise (did you mean compute.size?)"},
+		{"compute.size", "huge", `invalid compute.size "huge"`},
+		{"devcontainer.ignore", "maybe", "want true or false"},
+		{"sync.max_sync_bytes", "2GB", "want a whole number"},
+		{"sync.max_sync_bytes", "-1", "invalid sync.max_sync_bytes -1"},
+		{"setup.packages", "[1, ", "not a TOML array"},
+		{"org_config", "org.toml", "unknown key org_config"},
+	}
+	for _, tt := range tests {
+		err := SetValue(path, tt.key, tt.value)
+		require.Error(t, err, tt.key)
+		assert.Contains(t, err.Error(), tt.wantErr, tt.key)
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, original, string(data), "invalid edits aren't written")
+}
+
+func TestSetUserValue(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "yeager", UserFileName)
+	require.NoError(t, SetUserValue(path, "compute.region", "eu-west-1"))
+	require.NoError(t, SetUserValue(path, "org_config", "org.toml"))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "org_config = \"org.toml\"\n\n[compute]\nregion = \"eu-west-1\"\n", string(data))
+}
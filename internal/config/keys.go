@@ -0,0 +1,376 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Key kinds, as written in TOML.
+const (
+	KindString = "string"
+	KindList   = "list"
+	KindBool   = "bool"
+	KindInt    = "int"
+)
+
+// NamePlaceholder stands for a user-chosen table name in a key, as in
+// "services.<name>.image".
+const NamePlaceholder = "<name>"
+
+// KeyInfo describes one settable config key.
+type KeyInfo struct {
+	Key  string `json:"key"`  // dotted, e.g. "compute.size" or "services.<name>.image"
+	Kind string `json:"kind"` // one of the Kind constants
+
+	// Items are the keys of each table in a list of tables, like
+	// [[sync.mounts]], relative to the table.
+	Items []KeyInfo `json:"items,omitempty"`
+}
+
+// deprecatedKeys maps keys that are still read but no longer recommended
+// to what to use instead. Add an entry when a key is renamed or replaced.
+var deprecatedKeys = map[string]string{
+	"devcontainer.container": "use exec.container.devcontainer",
+}
+
+// Keys returns every key of .yeager.toml, sorted.
+func Keys() []KeyInfo {
+	var keys []KeyInfo
+	collectKeys("", reflect.TypeOf(Config{}), &keys)
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	return keys
+}
+
+// collectKeys walks a config struct's mapstructure tags. Maps of structs
+// become NamePlaceholder tables; slices of structs are lists of tables,
+// whose keys are the list's Items.
+func collectKeys(prefix string, t reflect.Type, out *[]KeyInfo) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			collectKeys(key, field.Type, out)
+		case reflect.Map:
+			collectKeys(key+"."+NamePlaceholder, field.Type.Elem(), out)
+		case reflect.Slice:
+			info := KeyInfo{Key: key, Kind: KindList}
+			if field.Type.Elem().Kind() == reflect.Struct {
+				collectKeys("", field.Type.Elem(), &info.Items)
+			}
+			*out = append(*out, info)
+		case reflect.Bool:
+			*out = append(*out, KeyInfo{Key: key, Kind: KindBool})
+		case reflect.Int, reflect.Int64:
+			*out = append(*out, KeyInfo{Key: key, Kind: KindInt})
+		default:
+			*out = append(*out, KeyInfo{Key: key, Kind: KindString})
+		}
+	}
+}
+
+// LookupKey returns the info for a dotted key. Table names in
+// NamePlaceholder positions match any valid name.
+func LookupKey(key string) (KeyInfo, bool) {
+	return lookupKeyIn(Keys(), key)
+}
+
+func lookupKeyIn(infos []KeyInfo, key string) (KeyInfo, bool) {
+	for _, info := range infos {
+		if matchKey(info.Key, key) {
+			return info, true
+		}
+	}
+	return KeyInfo{}, false
+}
+
+// matchKey reports whether key fits the pattern of a known key.
+func matchKey(pattern, key string) bool {
+	want, got := strings.Split(pattern, "."), strings.Split(key, ".")
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if want[i] == NamePlaceholder {
+			if !serviceNameRe.MatchString(got[i]) {
+				return false
+			}
+			continue
+		}
+		if want[i] != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SuggestKey returns the known key closest to a misspelled one, or empty
+// if nothing is close.
+func SuggestKey(key string) string {
+	return suggestKeyIn(Keys(), key)
+}
+
+func suggestKeyIn(infos []KeyInfo, key string) string {
+	got := strings.Split(key, ".")
+	best, bestDist := "", len(key)/3+2
+	for _, info := range infos {
+		candidate := strings.Split(info.Key, ".")
+		// Fill name placeholders from the misspelled key, so
+		// services.db.imag suggests services.db.image.
+		for i := range candidate {
+			if candidate[i] == NamePlaceholder && i < len(got) {
+				candidate[i] = got[i]
+			}
+		}
+		c := strings.Join(candidate, ".")
+		normalized := strings.ReplaceAll(key, "-", "_")
+		if d := editDistance(normalized, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// Problem is an issue found by Check. Invalid values and unknown keys are
+// errors; deprecated keys are warnings.
+type Problem struct {
+	File       string `json:"file,omitempty"`       // empty for the merged config
+	Line       int    `json:"line,omitempty"`       // 1-based, if known
+	Key        string `json:"key,omitempty"`        // dotted key, if the problem is about one
+	Message    string `json:"message"`              // what's wrong
+	Suggestion string `json:"suggestion,omitempty"` // the closest valid key, for typos
+	Warning    bool   `json:"warning,omitempty"`
+}
+
+func (p Problem) String() string {
+	switch {
+	case p.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+	case p.File != "":
+		return p.File + ": " + p.Message
+	}
+	return p.Message
+}
+
+// Check reports unknown and deprecated keys in each config file and
+// invalid values in the merged config. A non-nil error means the files
+// couldn't be read at all.
+func Check(startDir, userPath string) ([]Problem, error) {
+	layers, _, err := readLayers(startDir, userPath, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	for _, l := range layers {
+		problems = append(problems, checkKeys(l)...)
+	}
+
+	if _, err := decode(mergedViper(layers)); err != nil {
+		problems = append(problems, Problem{Message: err.Error()})
+	}
+	return problems, nil
+}
+
+// unknownKeys returns an error listing the unknown keys in any layer.
+func unknownKeys(layers []layer) error {
+	var lines []string
+	for _, l := range layers {
+		for _, p := range checkKeys(l) {
+			if !p.Warning {
+				lines = append(lines, p.String())
+			}
+		}
+	}
+	switch len(lines) {
+	case 0:
+		return nil
+	case 1:
+		return errors.New(lines[0])
+	}
+	return fmt.Errorf("%d unknown keys:\n  %s", len(lines), strings.Join(lines, "\n  "))
+}
+
+// checkKeys reports the unknown and deprecated keys of one layer.
+func checkKeys(l layer) []Problem {
+	set := map[string]any{}
+	flatten("", l.settings, set)
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Line numbers are best effort: the file was already parsed once.
+	data, _ := os.ReadFile(l.path)
+
+	var problems []Problem
+	for _, key := range keys {
+		if instead, ok := deprecatedKeys[key]; ok {
+			problems = append(problems, Problem{
+				File:    l.origin,
+				Line:    keyLine(data, key),
+				Key:     key,
+				Message: fmt.Sprintf("%s is deprecated: %s", key, instead),
+				Warning: true,
+			})
+			continue
+		}
+		if info, ok := LookupKey(key); ok {
+			problems = append(problems, checkTableList(l, data, info, set[key])...)
+			continue
+		}
+		p := Problem{File: l.origin, Line: keyLine(data, key), Key: key, Message: fmt.Sprintf("unknown key %s", key)}
+		if p.Suggestion = SuggestKey(key); p.Suggestion != "" {
+			p.Message += fmt.Sprintf(" (did you mean %s?)", p.Suggestion)
+		}
+		problems = append(problems, p)
+	}
+	return problems
+}
+
+// checkTableList reports the unknown keys in each table of a list of
+// tables, like [[sync.mounts]], as "sync.mounts[1].remote_pth".
+func checkTableList(l layer, data []byte, info KeyInfo, value any) []Problem {
+	if len(info.Items) == 0 {
+		return nil
+	}
+	var problems []Problem
+	for i, table := range tables(value) {
+		set := map[string]any{}
+		flatten("", table, set)
+		keys := make([]string, 0, len(set))
+		for key := range set {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		prefix := fmt.Sprintf("%s[%d].", info.Key, i)
+		for _, key := range keys {
+			if _, ok := lookupKeyIn(info.Items, key); ok {
+				continue
+			}
+			p := Problem{
+				File:    l.origin,
+				Line:    tableListKeyLine(data, info.Key, i, key),
+				Key:     prefix + key,
+				Message: fmt.Sprintf("unknown key %s", prefix+key),
+			}
+			if suggestion := suggestKeyIn(info.Items, key); suggestion != "" {
+				p.Suggestion = prefix + suggestion
+				p.Message += fmt.Sprintf(" (did you mean %s?)", p.Suggestion)
+			}
+			problems = append(problems, p)
+		}
+	}
+	return problems
+}
+
+// tables returns the tables in a list of tables, as decoded from TOML.
+func tables(value any) []map[string]any {
+	switch list := value.(type) {
+	case []map[string]any:
+		return list
+	case []any:
+		var out []map[string]any
+		for _, v := range list {
+			if table, ok := v.(map[string]any); ok {
+				out = append(out, table)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// tableListHeaderRe matches a [[list]] header line.
+var tableListHeaderRe = regexp.MustCompile(`^\s*\[\[\s*([^\[\]#]+?)\s*\]\]\s*(#.*)?$`)
+
+// tableListKeyLine returns the 1-based line of data that sets key in the
+// index'th [[list]] table, or the line of the list itself if it's written
+// inline, or 0.
+func tableListKeyLine(data []byte, list string, index int, key string) int {
+	normalize := strings.NewReplacer(" ", "", `"`, "", "'", "")
+	seen, in := -1, false
+	for i, line := range strings.Split(string(data), "\n") {
+		if m := tableListHeaderRe.FindStringSubmatch(line); m != nil {
+			if strings.ToLower(normalize.Replace(m[1])) == list {
+				seen++
+			}
+			in = seen == index && strings.ToLower(normalize.Replace(m[1])) == list
+			continue
+		}
+		if tableHeaderRe.MatchString(line) {
+			in = false
+			continue
+		}
+		if m := assignKeyRe.FindStringSubmatch(line); in && m != nil {
+			full := strings.ToLower(normalize.Replace(m[1]))
+			if full == key || strings.HasPrefix(key, full+".") {
+				return i + 1
+			}
+		}
+	}
+	return keyLine(data, list)
+}
+
+// assignKeyRe matches the key of a TOML assignment, which may be dotted.
+var assignKeyRe = regexp.MustCompile(`^\s*([A-Za-z0-9_\-."' ]+?)\s*=`)
+
+// keyLine returns the 1-based line of data that sets a dotted key, or 0.
+// A key inside an inline table is reported at the table's line.
+func keyLine(data []byte, key string) int {
+	normalize := strings.NewReplacer(" ", "", `"`, "", "'", "")
+	table := ""
+	for i, line := range strings.Split(string(data), "\n") {
+		if m := tableHeaderRe.FindStringSubmatch(line); m != nil {
+			table = strings.ToLower(normalize.Replace(m[1]))
+			continue
+		}
+		m := assignKeyRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		full := strings.ToLower(normalize.Replace(m[1]))
+		if table != "" {
+			full = table + "." + full
+		}
+		if full == key || strings.HasPrefix(key, full+".") {
+			return i + 1
+		}
+	}
+	return 0
+}
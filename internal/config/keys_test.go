@@ -0,0 +1,213 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeys(t *testing.T) {
+	t.Parallel()
+
+	kinds := map[string]string{}
+	for _, info := range Keys() {
+		kinds[info.Key] = info.Kind
+	}
+	assert.Equal(t, KindString, kinds["compute.size"])
+	assert.Equal(t, KindList, kinds["setup.packages"])
+	assert.Equal(t, KindBool, kinds["devcontainer.ignore"])
+	assert.Equal(t, KindInt, kinds["sync.max_sync_bytes"])
+	assert.Equal(t, KindString, kinds["exec.container.dockerfile"])
+	assert.Equal(t, KindString, kinds["services.<name>.image"])
+	assert.Equal(t, KindString, kinds["profiles.<name>.compute.size"])
+	assert.Equal(t, KindList, kinds["profiles.<name>.services.<name>.ports"])
+}
+
+func TestLookupKey(t *testing.T) {
+	t.Parallel()
+
+	for _, key := range []string{"compute.size", "services.db.image", "profiles.ci.env.vars"} {
+		_, ok := LookupKey(key)
+		assert.True(t, ok, key)
+	}
+	for _, key := range []string{"compute", "compute.cpu", "services.DB.image", "idle_stop"} {
+		_, ok := LookupKey(key)
+		assert.False(t, ok, key)
+	}
+}
+
+func TestSuggestKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"artifact.paths", "artifacts.paths"},
+		{"lifecycle.idle-stop", "lifecycle.idle_stop"},
+		{"compute.sise", "compute.size"},
+		{"services.db.imag", "services.db.image"},
+		{"completely.different", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, SuggestKey(tt.key), tt.key)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(`
+[compute]
+size = "huge"
+
+[artifact]
+paths = ["coverage/"]
+
+[lifecycle]
+idle-stop = "5m"
+
+[devcontainer]
+container = true
+`), 0o644))
+
+	problems, err := Check(dir, "")
+	require.NoError(t, err)
+
+	var messages []string
+	for _, p := range problems {
+		messages = append(messages, p.Message)
+	}
+	assert.Contains(t, messages, "unknown key artifact.paths (did you mean artifacts.paths?)")
+	assert.Contains(t, messages, "unknown key lifecycle.idle-stop (did you mean lifecycle.idle_stop?)")
+	assert.Contains(t, messages, `invalid compute.size "huge" (must be small, medium, large, or xlarge)`)
+	assert.Equal(t, filepath.Join(dir, FileName), problems[0].File)
+
+	deprecated := Problem{
+		File:    filepath.Join(dir, FileName),
+		Line:    12,
+		Key:     "devcontainer.container",
+		Message: "devcontainer.container is deprecated: use exec.container.devcontainer",
+		Warning: true,
+	}
+	assert.Contains(t, problems, deprecated)
+}
+
+func TestCheck_TableLists(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		toml string
+		want Problem
+	}{
+		{
+			name: "array tables",
+			toml: "[[sync.mounts]]\nlocal = \"../protos\"\n\n[[sync.mounts]]\nlocal = \"../shared\"\nremote_pth = \"../lib\"\n",
+			want: Problem{
+				Line:       6,
+				Key:        "sync.mounts[1].remote_pth",
+				Message:    "unknown key sync.mounts[1].remote_pth (did you mean sync.mounts[1].remote?)",
+				Suggestion: "sync.mounts[1].remote",
+			},
+		},
+		{
+			name: "inline",
+			toml: "[sync]\nmounts = [{ local = \"../a\", exclud = [\"x\"] }]\n",
+			want: Problem{
+				Line:       2,
+				Key:        "sync.mounts[0].exclud",
+				Message:    "unknown key sync.mounts[0].exclud (did you mean sync.mounts[0].exclude?)",
+				Suggestion: "sync.mounts[0].exclude",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(tt.toml), 0o644))
+
+			problems, err := Check(dir, "")
+			require.NoError(t, err)
+			tt.want.File = filepath.Join(dir, FileName)
+			assert.Equal(t, []Problem{tt.want}, problems)
+		})
+	}
+}
+
+func TestCheck_Clean(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(Template), 0o644))
+
+	problems, err := Check(dir, "")
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	require.NoError(t, os.WriteFile(path, []byte(`[compute]
+size = "large"
+
+[artifact]
+paths = ["coverage/"]
+`), 0o644))
+
+	_, _, err := Load(dir)
+	require.Error(t, err)
+	assert.Equal(t, path+":5: unknown key artifact.paths (did you mean artifacts.paths?)", err.Error())
+
+	require.NoError(t, os.WriteFile(path, []byte(`[lifecycle]
+idle-stop = "5m"
+
+[exec]
+container = { dockerfile = "Dockerfile", image = "x" }
+`), 0o644))
+
+	_, _, err = Load(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 unknown keys:")
+	assert.Contains(t, err.Error(), path+":5: unknown key exec.container.image")
+	assert.Contains(t, err.Error(), path+":2: unknown key lifecycle.idle-stop (did you mean lifecycle.idle_stop?)")
+}
+
+func TestKeyLine(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`org_config = "x"
+
+[compute]
+size = "large"
+
+[profiles.ci]
+compute = { size = "xlarge" }
+services.db.image = "postgres"
+
+[services."db"]
+Image = "postgres"
+`)
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"org_config", 1},
+		{"compute.size", 4},
+		{"profiles.ci.compute.size", 7},
+		{"profiles.ci.services.db.image", 8},
+		{"services.db.image", 11},
+		{"compute.region", 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, keyLine(data, tt.key), tt.key)
+	}
+}
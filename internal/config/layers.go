@@ -0,0 +1,331 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// UserFileName is the user-level config file, under the OS config directory
+// (~/.config/yeager/config.toml on Linux), next to yeager's state.
+const UserFileName = "config.toml"
+
+// OrgConfigEnv points at an org-level config file (a path or an https URL)
+// when the user config doesn't set org_config.
+const OrgConfigEnv = EnvPrefix + "_ORG_CONFIG"
+
+// orgConfigKey is the user config key that references the org config.
+const orgConfigKey = "org_config"
+
+// orgFetchTimeout bounds downloading an org config from a URL.
+const orgFetchTimeout = 10 * time.Second
+
+// orgConfigTTL is how long a downloaded org config is used as is. After
+// that it's still used, and refreshed in the background for next time.
+const orgConfigTTL = time.Hour
+
+var (
+	// orgHTTPClient downloads org configs; tests swap it out.
+	orgHTTPClient = &http.Client{Timeout: orgFetchTimeout}
+	// orgRefreshes tracks background refreshes, so tests can wait for them.
+	orgRefreshes sync.WaitGroup
+)
+
+// Sources of config values, from lowest to highest precedence. CLI flags
+// come last and are applied by the caller.
+const (
+	SourceDefault = "default"
+	SourceOrg     = "org"
+	SourceUser    = "user"
+	SourceProject = "project"
+	SourceEnv     = "env"
+)
+
+// Files are the config files merged into a Config. Empty fields were not
+// found or not configured.
+type Files struct {
+	Org     string // org config as referenced: a path or URL
+	User    string
+	Project string
+}
+
+// Setting is one effective config value and where it came from.
+type Setting struct {
+	Key    string `json:"key"`    // dotted, e.g. "compute.size"
+	Value  any    `json:"value"`  // as decoded from TOML or the environment
+	Source string `json:"source"` // one of the Source constants
+	Origin string `json:"origin"` // the file, URL or env var; empty for defaults
+}
+
+// UserConfigPath returns the user config file path, whether or not it exists.
+func UserConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "yeager", UserFileName)
+}
+
+// layer is one config file's settings.
+type layer struct {
+	source   string
+	origin   string // as shown to the user: a path, or the org config URL
+	path     string // the local file read
+	settings map[string]any
+}
+
+// readLayers reads the org, user and project files, lowest precedence first.
+// With cachedOnly, an org config URL is never downloaded: its cached copy
+// is used if there is one.
+func readLayers(startDir, userPath string, cachedOnly bool) ([]layer, Files, error) {
+	var layers []layer
+	var files Files
+
+	var user map[string]any
+	if userPath != "" {
+		if _, err := os.Stat(userPath); err == nil {
+			settings, err := readFile(userPath)
+			if err != nil {
+				return nil, Files{}, err
+			}
+			user = settings
+			files.User = userPath
+		}
+	}
+
+	orgRef, _ := user[orgConfigKey].(string)
+	delete(user, orgConfigKey)
+	if orgRef == "" {
+		orgRef = os.Getenv(OrgConfigEnv)
+	}
+	if orgRef != "" {
+		path, err := resolveOrgConfig(orgRef, filepath.Dir(userPath), cachedOnly)
+		if err != nil {
+			return nil, Files{}, err
+		}
+		// Without a path, it's a URL not downloaded yet, and cachedOnly.
+		if path != "" {
+			settings, err := readFile(path)
+			if err != nil {
+				return nil, Files{}, fmt.Errorf("org config %s: %w", orgRef, err)
+			}
+			layers = append(layers, layer{SourceOrg, orgRef, path, settings})
+			files.Org = orgRef
+		}
+	}
+
+	if user != nil {
+		layers = append(layers, layer{SourceUser, userPath, userPath, user})
+	}
+
+	if projectPath := FindConfig(startDir); projectPath != "" {
+		settings, err := readFile(projectPath)
+		if err != nil {
+			return nil, Files{}, err
+		}
+		layers = append(layers, layer{SourceProject, projectPath, projectPath, settings})
+		files.Project = projectPath
+	}
+
+	return layers, files, nil
+}
+
+// readFile reads one TOML config file into a settings map.
+func readFile(path string) (map[string]any, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return v.AllSettings(), nil
+}
+
+// resolveOrgConfig returns a local path for an org config reference.
+// Relative paths are relative to the user config directory. URLs must be
+// https, since an org config can run commands on the VM as root. Each is
+// downloaded to its own cache file, used as is for orgConfigTTL and after
+// that refreshed in the background, so no command waits on the network
+// once it's cached. Offline, the cached copy is used however old. With
+// cachedOnly, a URL isn't downloaded at all, and the path is empty until
+// it has been.
+func resolveOrgConfig(ref, baseDir string, cachedOnly bool) (string, error) {
+	if strings.HasPrefix(ref, "http://") {
+		return "", fmt.Errorf("org config %s: use an https URL; the org config can run commands on the VM as root", ref)
+	}
+	if !strings.HasPrefix(ref, "https://") {
+		if strings.HasPrefix(ref, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", fmt.Errorf("expanding %s: %w", ref, err)
+			}
+			ref = filepath.Join(home, ref[2:])
+		}
+		if !filepath.IsAbs(ref) {
+			ref = filepath.Join(baseDir, ref)
+		}
+		return ref, nil
+	}
+
+	cached, err := orgCachePath(ref)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(cached); err == nil {
+		if !cachedOnly && time.Since(info.ModTime()) > orgConfigTTL {
+			orgRefreshes.Add(1)
+			go func() {
+				defer orgRefreshes.Done()
+				if err := fetchOrgConfig(ref, cached); err != nil {
+					slog.Debug("refreshing org config failed", "url", ref, "error", err)
+				}
+			}()
+		}
+		return cached, nil
+	}
+	if cachedOnly {
+		return "", nil
+	}
+	if err := fetchOrgConfig(ref, cached); err != nil {
+		return "", fmt.Errorf("fetching org config %s: %w", ref, err)
+	}
+	return cached, nil
+}
+
+// orgCachePath returns where the org config at url is cached: a file of
+// its own, so switching URLs never picks up another org's config.
+func orgCachePath(url string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("finding cache directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(cacheDir, "yeager", "org-config-"+hex.EncodeToString(sum[:8])+".toml"), nil
+}
+
+// fetchOrgConfig downloads url to path, replacing it whole.
+func fetchOrgConfig(url, path string) error {
+	resp, err := orgHTTPClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Explain returns every effective setting, sorted by key, with the layer
+// that set it: see Load for the precedence.
+func Explain(startDir, userPath string) ([]Setting, error) {
+	layers, _, err := readLayers(startDir, userPath, false)
+	if err != nil {
+		return nil, err
+	}
+	v := mergedViper(layers)
+
+	effective := map[string]any{}
+	flatten("", v.AllSettings(), effective)
+
+	keys := make([]string, 0, len(effective))
+	for key := range effective {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	settings := make([]Setting, 0, len(keys))
+	for _, key := range keys {
+		s := Setting{Key: key, Value: effective[key], Source: SourceDefault}
+		if name := envName(key); os.Getenv(name) != "" {
+			s.Source, s.Origin = SourceEnv, name
+		} else {
+			for i := len(layers) - 1; i >= 0; i-- {
+				set := map[string]any{}
+				flatten("", layers[i].settings, set)
+				if _, ok := set[key]; ok {
+					s.Source, s.Origin = layers[i].source, layers[i].origin
+					break
+				}
+			}
+		}
+		settings = append(settings, s)
+	}
+	return settings, nil
+}
+
+// Effective returns the merged settings as nested tables, keyed like
+// .yeager.toml: see Load for the precedence.
+func Effective(startDir, userPath string) (map[string]any, error) {
+	layers, _, err := readLayers(startDir, userPath, false)
+	if err != nil {
+		return nil, err
+	}
+	return mergedViper(layers).AllSettings(), nil
+}
+
+// mergedViper merges defaults, the layers and the environment.
+func mergedViper(layers []layer) *viper.Viper {
+	v := viper.New()
+	v.SetConfigType("toml")
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	setViperDefaults(v, Defaults())
+	for _, l := range layers {
+		// Merging maps of already-decoded values can't fail.
+		_ = v.MergeConfigMap(l.settings)
+	}
+	return v
+}
+
+// envName returns the environment variable that overrides a key.
+func envName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// flatten turns nested tables into dotted keys. Lists are leaves.
+func flatten(prefix string, m map[string]any, out map[string]any) {
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := v.(map[string]any); ok {
+			flatten(key, nested, out)
+			continue
+		}
+		out[key] = v
+	}
+}
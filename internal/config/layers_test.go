@@ -0,0 +1,238 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeLayers writes an org, user and project config and returns the
+// project dir and user config path.
+func writeLayers(t *testing.T, org, user, project string) (string, string) {
+	t.Helper()
+	home := t.TempDir()
+	userPath := filepath.Join(home, "yeager", UserFileName)
+	require.NoError(t, os.MkdirAll(filepath.Dir(userPath), 0o755))
+	if org != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(home, "yeager", "org.toml"), []byte(org), 0o644))
+	}
+	if user != "" {
+		require.NoError(t, os.WriteFile(userPath, []byte(user), 0o644))
+	}
+	dir := t.TempDir()
+	if project != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(project), 0o644))
+	}
+	return dir, userPath
+}
+
+func TestLoadFiles_Precedence(t *testing.T) {
+	t.Parallel()
+
+	dir, userPath := writeLayers(t,
+		`
+[compute]
+size = "small"
+region = "eu-west-1"
+
+[lifecycle]
+idle_stop = "30m"
+
+[setup]
+presets = ["awscli"]
+`,
+		`
+org_config = "org.toml"
+
+[compute]
+size = "large"
+
+[setup]
+packages = ["htop"]
+`,
+		`
+[compute]
+size = "xlarge"
+
+[setup]
+packages = ["libpq-dev"]
+`)
+
+	cfg, files, err := LoadFiles(dir, userPath)
+	require.NoError(t, err)
+	assert.Equal(t, "org.toml", files.Org)
+	assert.Equal(t, userPath, files.User)
+	assert.Equal(t, filepath.Join(dir, FileName), files.Project)
+
+	assert.Equal(t, "xlarge", cfg.Compute.Size, "project beats user and org")
+	assert.Equal(t, "eu-west-1", cfg.Compute.Region, "org beats defaults")
+	assert.Equal(t, "30m", cfg.Lifecycle.IdleStop)
+	assert.Equal(t, "7d", cfg.Lifecycle.StoppedTerminate, "defaults fill the rest")
+	assert.Equal(t, []string{"awscli"}, cfg.Setup.Presets, "tables merge key by key")
+	assert.Equal(t, []string{"libpq-dev"}, cfg.Setup.Packages, "lists replace")
+}
+
+func TestLoadFiles_NoUserConfig(t *testing.T) {
+	t.Parallel()
+
+	dir, userPath := writeLayers(t, "", "", "[compute]\nsize = \"small\"\n")
+	cfg, files, err := LoadFiles(dir, userPath)
+	require.NoError(t, err)
+	assert.Empty(t, files.User)
+	assert.Empty(t, files.Org)
+	assert.Equal(t, "small", cfg.Compute.Size)
+
+	_, _, err = LoadFiles(dir, "")
+	require.NoError(t, err)
+}
+
+func TestLoadFiles_InvalidUserConfig(t *testing.T) {
+	t.Parallel()
+
+	dir, userPath := writeLayers(t, "", "[compute]\nsize = \"giant\"\n", "")
+	_, _, err := LoadFiles(dir, userPath)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid compute.size "giant"`)
+
+	_, userPath = writeLayers(t, "", `org_config = "missing.toml"`, "")
+	_, _, err = LoadFiles(dir, userPath)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "org config missing.toml")
+}
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	dir, userPath := writeLayers(t,
+		"[lifecycle]\nidle_stop = \"30m\"\n",
+		"org_config = \"org.toml\"\n[compute]\nregion = \"eu-west-1\"\n",
+		"[compute]\nsize = \"large\"\n")
+
+	settings, err := Explain(dir, userPath)
+	require.NoError(t, err)
+
+	byKey := map[string]Setting{}
+	for _, s := range settings {
+		byKey[s.Key] = s
+	}
+	assert.Equal(t, Setting{Key: "compute.size", Value: "large", Source: SourceProject, Origin: filepath.Join(dir, FileName)}, byKey["compute.size"])
+	assert.Equal(t, Setting{Key: "compute.region", Value: "eu-west-1", Source: SourceUser, Origin: userPath}, byKey["compute.region"])
+	assert.Equal(t, Setting{Key: "lifecycle.idle_stop", Value: "30m", Source: SourceOrg, Origin: "org.toml"}, byKey["lifecycle.idle_stop"])
+	assert.Equal(t, Setting{Key: "lifecycle.grace_period", Value: "2m", Source: SourceDefault}, byKey["lifecycle.grace_period"])
+	assert.NotContains(t, byKey, orgConfigKey)
+	assert.Equal(t, "compute.region", settings[0].Key, "sorted by key")
+}
+
+func TestExplain_Env(t *testing.T) {
+	dir, userPath := writeLayers(t, "", "", "[compute]\nsize = \"large\"\n")
+	t.Setenv("YEAGER_COMPUTE_SIZE", "small")
+
+	settings, err := Explain(dir, userPath)
+	require.NoError(t, err)
+	for _, s := range settings {
+		if s.Key == "compute.size" {
+			assert.Equal(t, Setting{Key: "compute.size", Value: "small", Source: SourceEnv, Origin: "YEAGER_COMPUTE_SIZE"}, s)
+			return
+		}
+	}
+	t.Fatal("compute.size not explained")
+}
+
+func TestResolveOrgConfig_URL(t *testing.T) {
+	cacheHome := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheHome)
+	t.Setenv("HOME", cacheHome)
+
+	region, online, fetches := "ap-south-1", true, 0
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if !online {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, "[compute]\nregion = %q\n", region)
+	}))
+	defer srv.Close()
+	client := orgHTTPClient
+	orgHTTPClient = srv.Client()
+	t.Cleanup(func() { orgHTTPClient = client })
+
+	dir, userPath := writeLayers(t, "", fmt.Sprintf("org_config = %q\n", srv.URL+"/yeager.toml"), "")
+	cfg, _, err := LoadFiles(dir, userPath)
+	require.NoError(t, err)
+	assert.Equal(t, "ap-south-1", cfg.Compute.Region)
+
+	// Fresh, the cached copy is used without asking the server.
+	region = "eu-west-1"
+	cfg, _, err = LoadFiles(dir, userPath)
+	require.NoError(t, err)
+	assert.Equal(t, "ap-south-1", cfg.Compute.Region)
+	assert.Equal(t, 1, fetches)
+
+	// Stale, it's used too, and refreshed in the background for next time.
+	cached, err := orgCachePath(srv.URL + "/yeager.toml")
+	require.NoError(t, err)
+	old := time.Now().Add(-2 * orgConfigTTL)
+	require.NoError(t, os.Chtimes(cached, old, old))
+	cfg, _, err = LoadFiles(dir, userPath)
+	require.NoError(t, err)
+	assert.Equal(t, "ap-south-1", cfg.Compute.Region)
+	orgRefreshes.Wait()
+	cfg, _, err = LoadFiles(dir, userPath)
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-1", cfg.Compute.Region)
+
+	// Offline, the stale copy is all there is.
+	online = false
+	require.NoError(t, os.Chtimes(cached, old, old))
+	cfg, _, err = LoadFiles(dir, userPath)
+	require.NoError(t, err, "falls back to the cached copy")
+	orgRefreshes.Wait()
+	assert.Equal(t, "eu-west-1", cfg.Compute.Region)
+
+	// Another URL has a cache of its own, and isn't downloaded with cachedOnly.
+	other := fmt.Sprintf("org_config = %q\n", srv.URL+"/other.toml")
+	require.NoError(t, os.WriteFile(userPath, []byte(other), 0o644))
+	cfg, files, err := loadFiles(dir, userPath, true)
+	require.NoError(t, err)
+	assert.Equal(t, Defaults().Compute.Region, cfg.Compute.Region, "not the other org's config")
+	assert.Empty(t, files.Org)
+	_, _, err = LoadFiles(dir, userPath)
+	require.Error(t, err, "offline with nothing cached")
+	assert.Contains(t, err.Error(), "503")
+}
+
+func TestResolveOrgConfig_RejectsHTTP(t *testing.T) {
+	t.Parallel()
+
+	_, err := resolveOrgConfig("http://example.com/yeager.toml", t.TempDir(), false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "use an https URL")
+}
+
+func TestLoadCached_NeverFetches(t *testing.T) {
+	cacheHome := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheHome)
+	t.Setenv("HOME", cacheHome)
+	fetched := false
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched = true
+	}))
+	defer srv.Close()
+	t.Setenv(OrgConfigEnv, srv.URL+"/yeager.toml")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte("[tasks.test]\ncommand = \"go test ./...\"\n"), 0o644))
+	cfg, path, err := LoadCached(dir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, FileName), path)
+	assert.Equal(t, []string{"test"}, cfg.TaskNames())
+	assert.False(t, fetched)
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// durationPattern matches what ParseDuration accepts: "7d", "1.5d", or a
+// Go duration like "10m" or "1h30m".
+const durationPattern = `^([0-9]+(\.[0-9]+)?d|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+
+// keyDescriptions documents keys for editors. Keys under [profiles.<name>]
+// share the description of the key they overlay.
+var keyDescriptions = map[string]string{
+	"compute":                         "VM size and AWS region.",
+	"compute.size":                    "VM size: small (2cpu/4gb), medium (4cpu/8gb), large (8cpu/16gb) or xlarge (16cpu/32gb).",
+	"compute.region":                  "AWS region.",
+	"lifecycle":                       "How long before the VM stops, gets terminated, and gets deleted.",
+	"lifecycle.grace_period":          "Wait this long after a command finishes before auto-stopping the VM.",
+	"lifecycle.idle_stop":             "Stop the VM after this much idle time.",
+	"lifecycle.stopped_terminate":     "Terminate a stopped VM after this long.",
+	"lifecycle.terminated_delete_ami": "Delete the saved AMI snapshot after this long.",
+	"setup":                           "Extra system packages and commands to run when the VM is created.",
+	"setup.presets":                   "Built-in setup recipes, \"name\" or \"name@version\": awscli, docker, playwright, postgres-client, protoc.",
+	"setup.packages":                  "Extra apt packages.",
+	"setup.run":                       "Commands run after the first sync, and again when [setup] changes.",
+	"sync":                            "Override which files are synced to the VM.",
+	"sync.include":                    "Paths to sync even though .gitignore skips them.",
+	"sync.exclude":                    "Extra paths to skip.",
+	"sync.engine":                     "How files are synced: native (built in, the default) or rsync (needs rsync installed).",
+	"sync.mode":                       "How files to sync are chosen: rules (.gitignore plus default excludes, the default) or git (what git ls-files lists).",
+	"sync.large_file_bytes":           "Files this many bytes or bigger are uploaded once to the project's S3 prefix, by content, and downloaded on the VM from there instead of sent over SSH. 0 means never.",
+	"sync.max_sync_bytes":             "The most the synced files may add up to, in bytes; a bigger sync fails before sending anything. 0 means no limit.",
+	"sync.mounts":                     "Directories outside the project synced beside it on the VM, like a sibling checkout a path dependency points at.",
+	"sync.mounts.local":               "The local directory, relative to the project or absolute.",
+	"sync.mounts.remote":              "Where it goes on the VM, relative to the project there or absolute. Default: the same as local, so relative paths resolve unchanged.",
+	"sync.mounts.exclude":             "Extra paths to skip in it.",
+	"sync.preserve":                   "Paths on the VM that sync never deletes, like downloaded fixtures or local databases.",
+	"sync.pull":                       "Paths changed on the VM that are copied back after each run, like generated code or snapshots.",
+	"artifacts":                       "Paths on the VM uploaded to S3 after each run.",
+	"artifacts.paths":                 "Files or directories to upload, relative to the project.",
+	"workspace":                       "Monorepo members for language detection.",
+	"workspace.members":               "Member directories or globs, in addition to those found in workspace manifests.",
+	"devcontainer":                    "How .devcontainer/devcontainer.json is applied.",
+	"devcontainer.ignore":             "Don't read devcontainer.json.",
+	"devcontainer.container":          "Deprecated: use exec.container.devcontainer.",
+	"exec":                            "Where remote commands run.",
+	"exec.container":                  "Run commands inside a container. Set devcontainer, dockerfile or compose_service.",
+	"exec.container.devcontainer":     "Run commands inside the devcontainer image instead of on the VM.",
+	"exec.container.dockerfile":       "Dockerfile to build, relative to the project.",
+	"exec.container.context":          "Build context, relative to the project. Default \".\".",
+	"exec.container.compose_service":  "Docker compose service to run commands in.",
+	"exec.container.compose_file":     "Compose file. Default: compose.yaml, docker-compose.yml, ...",
+	"exec.container.user":             "Container user. Default: the VM user's uid:gid.",
+	"env":                             "Environment for remote commands.",
+	"env.vars":                        "\"KEY=VALUE\" entries.",
+	"env.passthrough":                 "Local variables copied as-is, e.g. \"DATABASE_URL\".",
+	"env.secrets":                     "\"KEY=ssm:/path\" or \"KEY=secretsmanager:id[#key]\" entries.",
+	"services":                        "Sidecar containers started and health-checked before every run.",
+	"services.<name>.image":           "Container image, e.g. \"postgres:16\".",
+	"services.<name>.ports":           "\"port\" or \"host:container\" mappings, bound to localhost.",
+	"services.<name>.env":             "Container env, \"KEY=VALUE\" entries.",
+	"services.<name>.export":          "Env for remote commands, \"KEY=VALUE\" entries.",
+	"services.<name>.healthcheck":     "Command run in the container to check it's ready. Default: wait for the ports.",
+	"profiles":                        "Named overlays selected with --profile or YEAGER_PROFILE, each with its own VM.",
+	"profiles.<name>":                 "Set values replace the base config's; lists are appended.",
+	"tasks":                           "Named commands, run with yg run <name> and listed by yg tasks.",
+	"tasks.<name>.command":            "The command to run.",
+	"tasks.<name>.description":        "Shown by yg tasks, yg --help and shell completion.",
+	"tasks.<name>.env":                "Env for the task, \"KEY=VALUE\" entries added to [env] vars.",
+	"tasks.<name>.profile":            "Run with this profile, unless --profile is given.",
+	"tasks.<name>.timeout":            "Kill the command after this long, e.g. \"30m\".",
+	"tasks.<name>.artifacts":          "Paths added to [artifacts] paths for the task.",
+	"tasks.<name>.depends_on":         "Tasks run first, in order. The task doesn't run if one fails.",
+}
+
+// keyConstraints adds JSON Schema constraints to keys beyond their type.
+func keyConstraints(key string) map[string]any {
+	switch key {
+	case "compute.size":
+		sizes := make([]string, 0, len(ValidSizes))
+		for size := range ValidSizes {
+			sizes = append(sizes, size)
+		}
+		sort.Strings(sizes)
+		return map[string]any{"enum": sizes}
+	case "sync.engine":
+		return map[string]any{"enum": []string{SyncEngineNative, SyncEngineRsync}}
+	case "sync.mode":
+		return map[string]any{"enum": []string{SyncModeRules, SyncModeGit}}
+	case "sync.max_sync_bytes", "sync.large_file_bytes":
+		return map[string]any{"minimum": 0}
+	case "lifecycle.grace_period", "lifecycle.idle_stop", "lifecycle.stopped_terminate", "lifecycle.terminated_delete_ami", "tasks.<name>.timeout":
+		return map[string]any{"pattern": durationPattern}
+	case "env.vars", "services.<name>.env", "services.<name>.export", "tasks.<name>.env":
+		return map[string]any{"items": map[string]any{"type": "string", "pattern": `^[A-Za-z_][A-Za-z0-9_]*=`}}
+	case "env.passthrough":
+		return map[string]any{"items": map[string]any{"type": "string", "pattern": envNameRe.String()}}
+	}
+	return nil
+}
+
+// Schema returns a JSON Schema (draft-07) for .yeager.toml, generated from
+// the Config struct, for editor completion and validation.
+func Schema() map[string]any {
+	defaults := viper.New()
+	setViperDefaults(defaults, Defaults())
+
+	s := structSchema("", reflect.TypeOf(Config{}), defaults)
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = FileName
+	s["description"] = "yeager project configuration"
+	return s
+}
+
+func structSchema(prefix string, t reflect.Type, defaults *viper.Viper) map[string]any {
+	props := map[string]any{}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		var fs map[string]any
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			fs = structSchema(key, field.Type, defaults)
+		case reflect.Map:
+			elemKey := key + "." + NamePlaceholder
+			elem := structSchema(elemKey, field.Type.Elem(), defaults)
+			if d := describe(elemKey); d != "" {
+				elem["description"] = d
+			}
+			fs = map[string]any{
+				"type":                 "object",
+				"propertyNames":        map[string]any{"pattern": serviceNameRe.String()},
+				"additionalProperties": elem,
+			}
+		case reflect.Slice:
+			items := map[string]any{"type": "string"}
+			if field.Type.Elem().Kind() == reflect.Struct {
+				items = structSchema(key, field.Type.Elem(), defaults)
+			}
+			fs = map[string]any{"type": "array", "items": items}
+		case reflect.Bool:
+			fs = map[string]any{"type": "boolean"}
+		case reflect.Int, reflect.Int64:
+			fs = map[string]any{"type": "integer"}
+		default:
+			fs = map[string]any{"type": "string"}
+		}
+
+		base := baseKey(key)
+		for k, v := range keyConstraints(base) {
+			fs[k] = v
+		}
+		if d := describe(key); d != "" {
+			fs["description"] = d
+		}
+		// Profiles have no defaults of their own.
+		if base == key && defaults.IsSet(key) && field.Type.Kind() != reflect.Struct {
+			fs["default"] = defaults.Get(key)
+		}
+		props[name] = fs
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+}
+
+// baseKey strips a profile prefix, so profiles.<name>.compute.size
+// resolves to compute.size.
+func baseKey(key string) string {
+	if rest, ok := strings.CutPrefix(key, "profiles."+NamePlaceholder+"."); ok {
+		return rest
+	}
+	return key
+}
+
+func describe(key string) string {
+	if d, ok := keyDescriptions[key]; ok {
+		return d
+	}
+	return keyDescriptions[baseKey(key)]
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaAt walks a schema to the property for a dotted key.
+func schemaAt(t *testing.T, schema map[string]any, key string) map[string]any {
+	t.Helper()
+	node := schema
+	for _, part := range strings.Split(key, ".") {
+		var next any
+		if part == NamePlaceholder {
+			next = node["additionalProperties"]
+		} else {
+			props, _ := node["properties"].(map[string]any)
+			next = props[part]
+		}
+		m, ok := next.(map[string]any)
+		require.True(t, ok, "%s: no schema for %s", key, part)
+		node = m
+	}
+	return node
+}
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	schema := Schema()
+	_, err := json.Marshal(schema)
+	require.NoError(t, err)
+
+	assert.Equal(t, false, schema["additionalProperties"], "unknown keys are flagged")
+
+	kinds := map[string]string{KindString: "string", KindList: "array", KindBool: "boolean", KindInt: "integer"}
+	for _, info := range Keys() {
+		node := schemaAt(t, schema, info.Key)
+		assert.Equal(t, kinds[info.Kind], node["type"], info.Key)
+		assert.NotEmpty(t, node["description"], "%s needs an entry in keyDescriptions", info.Key)
+	}
+
+	size := schemaAt(t, schema, "compute.size")
+	assert.ElementsMatch(t, []string{"small", "medium", "large", "xlarge"}, size["enum"])
+	assert.Equal(t, "medium", size["default"])
+	assert.Nil(t, schemaAt(t, schema, "profiles.<name>.compute.size")["default"], "profiles have no defaults")
+	assert.NotEmpty(t, schemaAt(t, schema, "profiles.<name>.compute.size")["enum"])
+}
+
+func TestDurationPattern(t *testing.T) {
+	t.Parallel()
+
+	node := schemaAt(t, Schema(), "lifecycle.idle_stop")
+	pattern, ok := node["pattern"].(string)
+	require.True(t, ok)
+	re, err := regexp.Compile(pattern)
+	require.NoError(t, err)
+	for _, d := range []string{"10m", "7d", "1.5d", "1h30m", "500ms"} {
+		_, err := ParseDuration(d)
+		require.NoError(t, err, d)
+		assert.True(t, re.MatchString(d), d)
+	}
+	for _, d := range []string{"", "ten minutes", "5", "d"} {
+		assert.False(t, re.MatchString(d), d)
+	}
+}
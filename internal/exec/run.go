@@ -1,6 +1,7 @@
 package exec
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
@@ -21,6 +22,17 @@ const (
 	markerDir    = "/tmp"
 )
 
+// TimeoutExitCode is the exit code of a command killed by RunOpts.Timeout.
+const TimeoutExitCode = 124
+
+// timeoutKillAfter is how long a timed-out command has to exit after
+// SIGTERM before it gets SIGKILL.
+const timeoutKillAfter = 10 * time.Second
+
+// envFilePrefix names per-run env files. It must match the ".yeager-env-*"
+// sync exclude.
+const envFilePrefix = ".yeager-env-"
+
 // validRunID matches exactly 8 lowercase hex characters.
 var validRunID = regexp.MustCompile(`^[0-9a-f]{8}$`)
 
@@ -72,9 +84,41 @@ func (r *RunResult) Duration() time.Duration {
 
 // RunOpts configures a remote command execution.
 type RunOpts struct {
-	Command string // the shell command to run
-	WorkDir string // working directory on the VM
-	RunID   RunID  // unique run identifier
+	Command string        // the shell command to run
+	WorkDir string        // working directory on the VM
+	RunID   RunID         // unique run identifier
+	Init    []string      // shell commands run before Command in the same shell (e.g. venv activation)
+	Wrapper string        // command prefix that runs the script elsewhere, e.g. inside a container
+	EnvFile string        // env file in WorkDir (see WriteEnvFile), loaded then deleted by the command
+	Timeout time.Duration // if set, the command is killed after this long and exits with TimeoutExitCode
+}
+
+// EnvVar is an environment variable for a remote command.
+type EnvVar struct {
+	Name  string
+	Value string
+	Shell bool // Value is a shell word expanded when the env file is loaded, e.g. "${PATH}":'/x'
+}
+
+// EnvFileName returns the name of a run's env file. It lives in the
+// project directory so wrapped environments (containers) see it at the
+// same relative path; sync never touches it (see sync.DefaultExcludes).
+func EnvFileName(runID RunID) string {
+	return envFilePrefix + string(runID)
+}
+
+// FormatEnvFile renders env as KEY='value' lines for `set -a; . file`.
+// Shell values are written as they are.
+func FormatEnvFile(env []EnvVar) []byte {
+	var b strings.Builder
+	for _, v := range env {
+		if v.Shell {
+			fmt.Fprintf(&b, "%s=%s\n", v.Name, v.Value)
+			continue
+		}
+		fmt.Fprintf(&b, "%s='%s'\n", v.Name, shellEscape(v.Value))
+	}
+	return []byte(b.String())
 }
 
 // LogPath returns the path to the tmux log file for a run.
@@ -212,6 +256,46 @@ func ReadRemoteFile(client *gossh.Client, remotePath string) ([]byte, error) {
 	return output, nil
 }
 
+// WriteEnvFile writes env to remotePath on the VM, readable only by the
+// VM user. Values travel over the session's stdin, so they never appear
+// in a command line.
+func WriteEnvFile(client *gossh.Client, remotePath string, env []EnvVar) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("creating SSH session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdin = bytes.NewReader(FormatEnvFile(env))
+	cmd := fmt.Sprintf("umask 077 && cat > '%s'", shellEscape(remotePath))
+	if err := session.Run(cmd); err != nil {
+		return fmt.Errorf("writing env file: %w", err)
+	}
+	return nil
+}
+
+// RunScript runs shell commands on the VM in a single bash session, stopping
+// at the first failure. Unlike Run, it does not use tmux: it is meant for
+// short provisioning steps the caller waits on, not for user commands.
+func RunScript(client *gossh.Client, workDir string, commands []string, stdout, stderr io.Writer) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("creating SSH session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	return session.Run(buildScriptCommand(workDir, commands))
+}
+
+// buildScriptCommand wraps commands in a fail-fast bash script rooted at workDir.
+func buildScriptCommand(workDir string, commands []string) string {
+	script := fmt.Sprintf("set -e\ncd '%s'\n%s", shellEscape(workDir), strings.Join(commands, "\n"))
+	return fmt.Sprintf("bash -c '%s'", shellEscape(script))
+}
+
 // Kill terminates a running command by killing its tmux session.
 func Kill(client *gossh.Client, runID RunID) error {
 	if err := ValidateRunID(runID.String()); err != nil {
@@ -379,21 +463,46 @@ func buildTmuxCommand(opts RunOpts) string {
 	//
 	// WorkDir is always the fixed remoteProjectDir (/home/ubuntu/project)
 	// set by the caller, not user input. RunID is validated as hex-only.
-	// Command is shell-escaped for the marker file content.
+	// Command is shell-escaped for the marker file content; Init commands
+	// run in the same bash -c so their shell state (PATH, venv) carries over.
+	script := opts.Command
+	if len(opts.Init) > 0 {
+		script = strings.Join(opts.Init, "\n") + "\n" + opts.Command
+	}
+	// The env file is exported and deleted before anything else runs, and
+	// deleted again afterwards in case the wrapper failed before loading it.
+	cleanup := marker
+	if opts.EnvFile != "" {
+		script = fmt.Sprintf("set -a; . ./%s; set +a; rm -f ./%s\n%s", opts.EnvFile, opts.EnvFile, script)
+		cleanup += " ./" + opts.EnvFile
+	}
+	// Wrapper, if set, prefixes the bash -c so Init and Command run inside
+	// it; the pipeline still captures its exit code and output.
+	wrapper := ""
+	if opts.Wrapper != "" {
+		wrapper = opts.Wrapper + " "
+	}
+	// Timeout, if set, runs outside the wrapper so a hung container exec
+	// is killed too. coreutils timeout exits with TimeoutExitCode.
+	if opts.Timeout > 0 {
+		seconds := int((opts.Timeout + time.Second - 1) / time.Second)
+		wrapper = fmt.Sprintf("timeout -k %ds %ds %s", int(timeoutKillAfter/time.Second), seconds, wrapper)
+	}
 	innerScript := fmt.Sprintf(
 		`cd %s && `+
 			`printf '%%s\n%%s\n' '%s' "$(date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ)" > %s && `+
-			`bash -c '%s' 2>&1 | tee %s; `+
+			`%sbash -c '%s' 2>&1 | tee %s; `+
 			`EC=${PIPESTATUS[0]}; `+
 			`echo $EC > %s; `+
 			`rm -f %s`,
 		opts.WorkDir,
 		shellEscape(opts.Command),
 		marker,
-		shellEscape(opts.Command),
+		wrapper,
+		shellEscape(script),
 		logFile,
 		exitFile,
-		marker,
+		cleanup,
 	)
 
 	// Start a detached tmux session that runs the inner script.
@@ -1,6 +1,7 @@
 package exec
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -192,6 +193,104 @@ func TestBuildTmuxCommand_CapturesExitCode(t *testing.T) {
 	assert.Contains(t, cmd, "echo $EC > /tmp/yg-exit-aabbccdd")
 }
 
+func TestBuildTmuxCommand_Timeout(t *testing.T) {
+	t.Parallel()
+
+	cmd := buildTmuxCommand(RunOpts{
+		Command: "make test",
+		WorkDir: "/home/ubuntu/project",
+		RunID:   "aabbccdd",
+		Wrapper: "docker exec app",
+		Timeout: 90*time.Second + time.Millisecond,
+	})
+	assert.Contains(t, cmd, "timeout -k 10s 91s docker exec app bash -c")
+
+	cmd = buildTmuxCommand(RunOpts{Command: "make test", WorkDir: "/home/ubuntu/project", RunID: "aabbccdd"})
+	assert.NotContains(t, cmd, "timeout")
+}
+
+func TestBuildTmuxCommand_InitRunsBeforeCommand(t *testing.T) {
+	t.Parallel()
+
+	cmd := buildTmuxCommand(RunOpts{
+		Command: "pytest",
+		WorkDir: "/home/ubuntu/project",
+		RunID:   "aabbccdd",
+		Init:    []string{"if [ -f .venv/bin/activate ]; then . .venv/bin/activate; fi"},
+	})
+
+	initIdx := strings.Index(cmd, ". .venv/bin/activate")
+	cmdIdx := strings.Index(cmd, "\npytest")
+	require.GreaterOrEqual(t, initIdx, 0, "init command missing")
+	require.GreaterOrEqual(t, cmdIdx, 0, "user command missing")
+	assert.Less(t, initIdx, cmdIdx, "init must run before the user command")
+
+	// The init prelude appears once (in bash -c), not in the marker file.
+	assert.Equal(t, 2, strings.Count(cmd, ".venv/bin/activate"))
+}
+
+func TestBuildTmuxCommand_WrapperPrefixesScript(t *testing.T) {
+	t.Parallel()
+
+	cmd := buildTmuxCommand(RunOpts{
+		Command: "pytest",
+		WorkDir: "/home/ubuntu/project",
+		RunID:   "aabbccdd",
+		Wrapper: `sudo env "PATH=$PATH" devcontainer exec --workspace-folder /home/ubuntu/project`,
+	})
+
+	assert.Contains(t, cmd, "devcontainer exec --workspace-folder /home/ubuntu/project bash -c")
+	// The exit code is still taken from the wrapped pipeline.
+	assert.Contains(t, cmd, "PIPESTATUS[0]")
+
+	plain := buildTmuxCommand(RunOpts{Command: "pytest", WorkDir: "/home/ubuntu/project", RunID: "aabbccdd"})
+	assert.NotContains(t, plain, "devcontainer")
+}
+
+func TestBuildTmuxCommand_EnvFile(t *testing.T) {
+	t.Parallel()
+
+	cmd := buildTmuxCommand(RunOpts{
+		Command: "npm test",
+		WorkDir: "/home/ubuntu/project",
+		RunID:   "aabbccdd",
+		Init:    []string{"export A=1"},
+		EnvFile: EnvFileName("aabbccdd"),
+	})
+
+	// Loaded and deleted first, inside the (possibly wrapped) script.
+	assert.Contains(t, cmd, "set -a; . ./.yeager-env-aabbccdd; set +a; rm -f ./.yeager-env-aabbccdd\nexport A=1\nnpm test")
+	// Deleted again once the command exits.
+	assert.Contains(t, cmd, "rm -f /tmp/yg-run-aabbccdd ./.yeager-env-aabbccdd")
+
+	plain := buildTmuxCommand(RunOpts{Command: "npm test", WorkDir: "/home/ubuntu/project", RunID: "aabbccdd"})
+	assert.NotContains(t, plain, ".yeager-env")
+}
+
+func TestFormatEnvFile(t *testing.T) {
+	t.Parallel()
+
+	got := FormatEnvFile([]EnvVar{
+		{Name: "API_KEY", Value: "s3cr3t"},
+		{Name: "QUOTED", Value: "it's $HOME"},
+		{Name: "EMPTY", Value: ""},
+		{Name: "PATH", Value: `"${PATH}"':/opt/bin'`, Shell: true},
+	})
+	assert.Equal(t, "API_KEY='s3cr3t'\nQUOTED='it'\\''s $HOME'\nEMPTY=''\nPATH=\"${PATH}\"':/opt/bin'\n", string(got))
+}
+
+func TestBuildScriptCommand(t *testing.T) {
+	t.Parallel()
+
+	cmd := buildScriptCommand("/home/ubuntu/project", []string{"npm ci", "echo 'done'"})
+
+	assert.True(t, strings.HasPrefix(cmd, "bash -c '"))
+	assert.Contains(t, cmd, "set -e")
+	assert.Contains(t, cmd, "/home/ubuntu/project")
+	assert.Contains(t, cmd, "npm ci\necho")
+	assert.Less(t, strings.Index(cmd, "set -e"), strings.Index(cmd, "npm ci"))
+}
+
 func TestLogPath(t *testing.T) {
 	t.Parallel()
 	assert.Equal(t, "/tmp/yg-log-abc12345", LogPath(RunID("abc12345")))
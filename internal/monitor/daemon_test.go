@@ -2,6 +2,7 @@ package monitor
 
 import (
 	"context"
+	"errors"
 	"os"
 	"testing"
 	"time"
@@ -125,6 +126,47 @@ func TestRunDaemonCancellation(t *testing.T) {
 	}
 }
 
+// TestHaltVM tests that regular VMs are stopped and temporary ones terminated.
+func TestHaltVM(t *testing.T) {
+	for _, temporary := range []bool{false, true} {
+		st, err := state.NewStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		projectHash := "test-halt"
+		prov := &mockProviderWithTracking{}
+		vmState := state.VMState{InstanceID: "i-halt-test", Region: "us-east-1", Temporary: temporary}
+		if err := st.SaveVM(projectHash, vmState); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.SaveIdleStart(projectHash, time.Now().UTC()); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := haltVM(context.Background(), st, prov, projectHash, vmState); err != nil {
+			t.Fatalf("haltVM failed: %v", err)
+		}
+
+		if prov.WasStopCalled() == temporary {
+			t.Errorf("temporary=%v: StopVM called = %v", temporary, prov.WasStopCalled())
+		}
+		if prov.WasTerminateCalled() != temporary {
+			t.Errorf("temporary=%v: TerminateVM called = %v", temporary, prov.WasTerminateCalled())
+		}
+		if _, err := st.LoadIdleStart(projectHash); err == nil {
+			t.Errorf("temporary=%v: idle start time not cleared", temporary)
+		}
+		_, err = st.LoadVM(projectHash)
+		if temporary && !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("temporary VM state should be deleted, got err %v", err)
+		}
+		if !temporary && err != nil {
+			t.Errorf("stopped VM state should be kept: %v", err)
+		}
+	}
+}
+
 // TestCheckShouldStopEdgeCases tests edge cases in grace period checking.
 func TestCheckShouldStopEdgeCases(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "yeager-check-edge-*")
@@ -82,6 +82,10 @@ func (f *fakeProvider) FindVM(ctx context.Context, projectHash string) (*provide
 	return nil, nil
 }
 
+func (f *fakeProvider) FindVariantVMs(ctx context.Context, projectPath, profile string) ([]provider.VMInfo, error) {
+	return nil, nil
+}
+
 func (f *fakeProvider) StartVM(ctx context.Context, instanceID string) error {
 	return nil
 }
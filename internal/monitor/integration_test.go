@@ -18,6 +18,8 @@ type mockProviderWithTracking struct {
 	stopCalled  bool
 	stopTime    time.Time
 	stopErrFunc func() error // Optional error injection
+
+	terminateCalled bool
 }
 
 func (m *mockProviderWithTracking) StopVM(ctx context.Context, instanceID string) error {
@@ -34,6 +36,19 @@ func (m *mockProviderWithTracking) StopVM(ctx context.Context, instanceID string
 	return nil
 }
 
+func (m *mockProviderWithTracking) TerminateVM(ctx context.Context, instanceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.terminateCalled = true
+	return nil
+}
+
+func (m *mockProviderWithTracking) WasTerminateCalled() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.terminateCalled
+}
+
 func (m *mockProviderWithTracking) WasStopCalled() bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
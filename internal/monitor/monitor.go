@@ -254,30 +254,44 @@ func RunDaemon(projectHash, stateDir string, gracePeriod time.Duration) error {
 			slog.Debug("grace period check", "should_stop", shouldStop)
 
 			if shouldStop {
-				slog.Info("grace period elapsed, stopping VM", "instance_id", vmState.InstanceID)
-
 				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-				defer cancel()
-
-				// Stop the VM.
-				if err := prov.StopVM(ctx, vmState.InstanceID); err != nil {
-					slog.Error("failed to stop VM", "error", err)
+				err := haltVM(ctx, st, prov, projectHash, vmState)
+				cancel()
+				if err != nil {
+					slog.Error("failed to halt VM", "error", err)
 					// Don't exit on error, keep trying.
 					continue
 				}
-
-				slog.Info("VM stopped successfully, monitor exiting")
-
-				// Clean up PID file and idle start time.
-				_ = RemovePIDFile(st, projectHash)
-				_ = st.ClearIdleStart(projectHash)
-
 				return nil
 			}
 		}
 	}
 }
 
+// haltVM stops the VM once the grace period has elapsed, or terminates it
+// if it's temporary, and cleans up the monitor's state.
+func haltVM(ctx context.Context, st *state.Store, prov provider.CloudProvider, projectHash string, vmState state.VMState) error {
+	if vmState.Temporary {
+		slog.Info("grace period elapsed, terminating temporary VM", "instance_id", vmState.InstanceID)
+		if err := prov.TerminateVM(ctx, vmState.InstanceID); err != nil {
+			return fmt.Errorf("terminating VM: %w", err)
+		}
+		_ = st.DeleteVM(projectHash)
+		slog.Info("VM terminated successfully, monitor exiting")
+	} else {
+		slog.Info("grace period elapsed, stopping VM", "instance_id", vmState.InstanceID)
+		if err := prov.StopVM(ctx, vmState.InstanceID); err != nil {
+			return fmt.Errorf("stopping VM: %w", err)
+		}
+		slog.Info("VM stopped successfully, monitor exiting")
+	}
+
+	// Clean up PID file and idle start time.
+	_ = RemovePIDFile(st, projectHash)
+	_ = st.ClearIdleStart(projectHash)
+	return nil
+}
+
 // checkShouldStop determines if the VM should be stopped based on grace period.
 func checkShouldStop(st *state.Store, projectHash string, gracePeriod time.Duration) (bool, error) {
 	// Load idle start time.
@@ -23,7 +23,10 @@ func (m *mockProvider) CreateVM(context.Context, provider.CreateVMOpts) (provide
 	return provider.VMInfo{}, nil
 }
 func (m *mockProvider) FindVM(context.Context, string) (*provider.VMInfo, error) { return nil, nil }
-func (m *mockProvider) StartVM(context.Context, string) error                    { return nil }
+func (m *mockProvider) FindVariantVMs(context.Context, string, string) ([]provider.VMInfo, error) {
+	return nil, nil
+}
+func (m *mockProvider) StartVM(context.Context, string) error { return nil }
 func (m *mockProvider) StopVM(context.Context, string) error {
 	m.stopped = true
 	return nil
@@ -71,10 +71,10 @@ func CheckAWSCredentials(lookupEnv func(string) (string, bool), fileExists func(
 	return r
 }
 
-// RunAll runs all preflight checks and returns any failures.
+// RunAll runs all preflight checks and returns any failures. CheckRsync
+// isn't among them: only the rsync sync engine needs rsync.
 func RunAll(lookupEnv func(string) (string, bool), fileExists func(string) bool, homeDir string) []Result {
 	checks := []Result{
-		CheckRsync(),
 		CheckAWSCredentials(lookupEnv, fileExists, homeDir),
 	}
 
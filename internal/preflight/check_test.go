@@ -123,7 +123,7 @@ func TestCheckAWSCredentials(t *testing.T) {
 
 func TestRunAll_NoFailures(t *testing.T) {
 	t.Parallel()
-	// With valid env and rsync available, no failures expected.
+	// With valid env, no failures expected.
 	lookupEnv := func(key string) (string, bool) {
 		if key == "AWS_ACCESS_KEY_ID" {
 			return "AKIA123", true
@@ -140,7 +140,6 @@ func TestRunAll_AWSFailure(t *testing.T) {
 	lookupEnv := func(string) (string, bool) { return "", false }
 	noFile := func(string) bool { return false }
 	failures := RunAll(lookupEnv, noFile, "/home/test")
-	// rsync is available on dev, so only AWS should fail.
 	assert.Len(t, failures, 1)
 	assert.Equal(t, "aws-credentials", failures[0].Name)
 }
@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"path/filepath"
+	"strings"
 )
 
 // Project represents a yeager project identified by its directory path.
@@ -17,6 +18,13 @@ type Project struct {
 
 	// DisplayName is a human-readable name derived from the directory basename.
 	DisplayName string
+
+	// Profile is the selected config profile, or empty for the default.
+	Profile string
+
+	// Variant names compute settings overridden for one invocation, like
+	// "xlarge spot", or is empty for the configured VM.
+	Variant string
 }
 
 // Resolve creates a Project from an absolute directory path.
@@ -40,6 +48,57 @@ func Resolve(absPath string) (Project, error) {
 	}, nil
 }
 
+// WithProfile returns the project for a config profile. Each profile gets
+// its own Hash, and so its own VM and state; the default profile ("")
+// keeps the plain path hash.
+func (p Project) WithProfile(name string) Project {
+	if name == "" {
+		return p
+	}
+	p.Profile = name
+	p.Hash = p.hash()
+	return p
+}
+
+// WithVariant returns the project for overridden compute settings. Each
+// variant gets its own Hash, so a one-off size or region doesn't replace
+// the configured VM; the empty variant keeps the current hash.
+func (p Project) WithVariant(variant string) Project {
+	if variant == "" {
+		return p
+	}
+	p.Variant = variant
+	p.Hash = p.hash()
+	return p
+}
+
+// hash derives the project's Hash from its path, profile and variant.
+func (p Project) hash() string {
+	key := p.AbsPath
+	if p.Profile != "" {
+		key += "\x00profile:" + p.Profile
+	}
+	if p.Variant != "" {
+		key += "\x00variant:" + p.Variant
+	}
+	return hashPath(key)
+}
+
+// Label names the project in messages, including any profile and variant.
+func (p Project) Label() string {
+	var extra []string
+	if p.Profile != "" {
+		extra = append(extra, "profile "+p.Profile)
+	}
+	if p.Variant != "" {
+		extra = append(extra, p.Variant)
+	}
+	if len(extra) == 0 {
+		return p.DisplayName
+	}
+	return fmt.Sprintf("%s (%s)", p.DisplayName, strings.Join(extra, ", "))
+}
+
 // hashPath produces a short, stable hash from a normalized path.
 // Uses SHA-256 truncated to 12 hex characters (48 bits of entropy).
 func hashPath(path string) string {
@@ -118,3 +118,45 @@ func TestHashUniqueness(t *testing.T) {
 	require.NoError(t, err)
 	assert.NotEqual(t, p1.Hash, p2.Hash)
 }
+
+func TestWithProfile(t *testing.T) {
+	t.Parallel()
+
+	p, err := Resolve("/Users/dev/my-project")
+	require.NoError(t, err)
+
+	assert.Equal(t, p, p.WithProfile(""), "default profile keeps the existing VM")
+	assert.Equal(t, "my-project", p.Label())
+
+	ci := p.WithProfile("ci")
+	assert.Equal(t, "ci", ci.Profile)
+	assert.Equal(t, p.AbsPath, ci.AbsPath)
+	assert.Equal(t, p.DisplayName, ci.DisplayName)
+	assert.Len(t, ci.Hash, 12)
+	assert.NotEqual(t, p.Hash, ci.Hash)
+	assert.NotEqual(t, p.WithProfile("gpu").Hash, ci.Hash)
+	assert.Equal(t, ci.Hash, p.WithProfile("ci").Hash, "stable across invocations")
+	assert.Equal(t, "my-project (profile ci)", ci.Label())
+}
+
+func TestWithVariant(t *testing.T) {
+	t.Parallel()
+
+	p, err := Resolve("/Users/dev/my-project")
+	require.NoError(t, err)
+
+	assert.Equal(t, p, p.WithVariant(""), "no overrides keeps the existing VM")
+
+	big := p.WithVariant("xlarge")
+	assert.Equal(t, "xlarge", big.Variant)
+	assert.Len(t, big.Hash, 12)
+	assert.NotEqual(t, p.Hash, big.Hash)
+	assert.Equal(t, big.Hash, p.WithVariant("xlarge").Hash, "stable across invocations")
+	assert.Equal(t, "my-project (xlarge)", big.Label())
+
+	ci := p.WithProfile("ci")
+	ciBig := ci.WithVariant("xlarge")
+	assert.NotEqual(t, ci.Hash, ciBig.Hash)
+	assert.NotEqual(t, big.Hash, ciBig.Hash, "variants are per profile")
+	assert.Equal(t, "my-project (profile ci, xlarge)", ciBig.Label())
+}
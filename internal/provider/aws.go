@@ -26,6 +26,9 @@ const (
 	managedTagValue   = "true"
 	projectHashTagKey = "yeager:project-hash"
 	projectPathTagKey = "yeager:project-path"
+	profileTagKey     = "yeager:profile"
+	variantTagKey     = "yeager:variant"
+	temporaryTagKey   = "yeager:temporary"
 	createdTagKey     = "yeager:created"
 	bucketPrefix      = "yeager-"
 	lifecycleRuleID   = "yeager-expire-30d"
@@ -345,6 +348,34 @@ func (p *AWSProvider) CreateVM(ctx context.Context, opts CreateVMOpts) (VMInfo,
 			},
 		},
 	}
+	if opts.Profile != "" {
+		input.TagSpecifications[0].Tags = append(input.TagSpecifications[0].Tags,
+			ec2types.Tag{Key: aws.String(profileTagKey), Value: aws.String(opts.Profile)})
+	}
+	if opts.Variant != "" {
+		input.TagSpecifications[0].Tags = append(input.TagSpecifications[0].Tags,
+			ec2types.Tag{Key: aws.String(variantTagKey), Value: aws.String(opts.Variant)})
+	}
+	if opts.Temporary {
+		input.TagSpecifications[0].Tags = append(input.TagSpecifications[0].Tags,
+			ec2types.Tag{Key: aws.String(temporaryTagKey), Value: aws.String("true")})
+	}
+	if opts.Temporary {
+		// A temporary VM powers itself off once idle, in case the laptop
+		// that would terminate it is asleep or gone.
+		input.InstanceInitiatedShutdownBehavior = ec2types.ShutdownBehaviorTerminate
+	}
+	if opts.Spot {
+		// One-time requests end with the instance, so terminating it
+		// leaves nothing behind to relaunch.
+		input.InstanceMarketOptions = &ec2types.InstanceMarketOptionsRequest{
+			MarketType: ec2types.MarketTypeSpot,
+			SpotOptions: &ec2types.SpotMarketOptions{
+				SpotInstanceType:             ec2types.SpotInstanceTypeOneTime,
+				InstanceInterruptionBehavior: ec2types.InstanceInterruptionBehaviorTerminate,
+			},
+		}
+	}
 	if opts.UserData != "" {
 		input.UserData = aws.String(opts.UserData)
 	}
@@ -371,15 +402,7 @@ func (p *AWSProvider) FindVM(ctx context.Context, projectHash string) (*VMInfo,
 	out, err := p.ec2.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
 		Filters: []ec2types.Filter{
 			{Name: aws.String("tag:" + projectHashTagKey), Values: []string{projectHash}},
-			{
-				Name: aws.String("instance-state-name"),
-				Values: []string{
-					string(ec2types.InstanceStateNamePending),
-					string(ec2types.InstanceStateNameRunning),
-					string(ec2types.InstanceStateNameStopping),
-					string(ec2types.InstanceStateNameStopped),
-				},
-			},
+			liveStatesFilter,
 		},
 	})
 	if err != nil {
@@ -396,12 +419,61 @@ func (p *AWSProvider) FindVM(ctx context.Context, projectHash string) (*VMInfo,
 	return nil, nil
 }
 
+// FindVariantVMs lists the project's temporary variant VMs for a profile,
+// in this provider's region. Filters out terminated instances.
+func (p *AWSProvider) FindVariantVMs(ctx context.Context, projectPath, profile string) ([]VMInfo, error) {
+	out, err := p.ec2.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("tag:" + projectPathTagKey), Values: []string{projectPath}},
+			{Name: aws.String("tag-key"), Values: []string{variantTagKey}},
+			liveStatesFilter,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describing instances: %w", err)
+	}
+
+	var vms []VMInfo
+	for _, res := range out.Reservations {
+		for _, inst := range res.Instances {
+			// The default profile has no tag, which EC2 can't filter on.
+			if tagValue(inst.Tags, profileTagKey) != profile {
+				continue
+			}
+			vms = append(vms, p.toVMInfo(inst))
+		}
+	}
+	return vms, nil
+}
+
+// liveStatesFilter matches instances that haven't been terminated.
+var liveStatesFilter = ec2types.Filter{
+	Name: aws.String("instance-state-name"),
+	Values: []string{
+		string(ec2types.InstanceStateNamePending),
+		string(ec2types.InstanceStateNameRunning),
+		string(ec2types.InstanceStateNameStopping),
+		string(ec2types.InstanceStateNameStopped),
+	},
+}
+
+// tagValue returns the value of the tag with key, or "".
+func tagValue(tags []ec2types.Tag, key string) string {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == key {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return ""
+}
+
 // toVMInfo converts an EC2 instance into a VMInfo.
 func (p *AWSProvider) toVMInfo(inst ec2types.Instance) VMInfo {
 	info := VMInfo{
 		InstanceID: aws.ToString(inst.InstanceId),
 		PublicIP:   aws.ToString(inst.PublicIpAddress),
 		Region:     p.region,
+		Variant:    tagValue(inst.Tags, variantTagKey),
 	}
 	if inst.State != nil {
 		info.State = string(inst.State.Name)
@@ -481,6 +481,10 @@ func TestCreateVM(t *testing.T) {
 				assert.Equal(t, "/home/user/myproject", tagMap["yeager:project-path"])
 				assert.Contains(t, tagMap, "yeager:created")
 				assert.Equal(t, "yeager-abc123", tagMap["Name"])
+				assert.NotContains(t, tagMap, "yeager:profile", "no tag for the default profile")
+				assert.NotContains(t, tagMap, "yeager:temporary")
+				assert.Nil(t, params.InstanceMarketOptions, "on-demand by default")
+				assert.Empty(t, params.InstanceInitiatedShutdownBehavior, "stopped on shutdown by default")
 
 				return &ec2.RunInstancesOutput{
 					Instances: []ec2types.Instance{
@@ -508,6 +512,130 @@ func TestCreateVM(t *testing.T) {
 		assert.Equal(t, "us-east-1", info.Region)
 	})
 
+	t.Run("tags the profile", func(t *testing.T) {
+		t.Parallel()
+		var tags map[string]string
+		ec2Mock := &mockEC2{
+			describeImagesFn: func(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+				return &ec2.DescribeImagesOutput{
+					Images: []ec2types.Image{
+						{ImageId: aws.String("ami-test"), CreationDate: aws.String("2024-01-01T00:00:00Z"), Name: aws.String("test")},
+					},
+				}, nil
+			},
+			runInstancesFn: func(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+				tags = make(map[string]string)
+				for _, tag := range params.TagSpecifications[0].Tags {
+					tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+				}
+				return &ec2.RunInstancesOutput{
+					Instances: []ec2types.Instance{
+						{
+							InstanceId: aws.String("i-prof001"),
+							State:      &ec2types.InstanceState{Name: ec2types.InstanceStateNamePending},
+						},
+					},
+				}, nil
+			},
+		}
+
+		p := newTestProvider(ec2Mock, nil, nil, nil)
+		_, err := p.CreateVM(context.Background(), CreateVMOpts{
+			ProjectHash:     "def456",
+			ProjectPath:     "/test",
+			Profile:         "ci",
+			Size:            "small",
+			SecurityGroupID: "sg-test",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "ci", tags["yeager:profile"])
+		assert.Equal(t, "def456", tags["yeager:project-hash"])
+	})
+
+	t.Run("terminates an ephemeral VM that shuts itself down", func(t *testing.T) {
+		t.Parallel()
+		var input *ec2.RunInstancesInput
+		ec2Mock := &mockEC2{
+			describeImagesFn: func(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+				return &ec2.DescribeImagesOutput{
+					Images: []ec2types.Image{
+						{ImageId: aws.String("ami-test"), CreationDate: aws.String("2024-01-01T00:00:00Z"), Name: aws.String("test")},
+					},
+				}, nil
+			},
+			runInstancesFn: func(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+				input = params
+				return &ec2.RunInstancesOutput{
+					Instances: []ec2types.Instance{
+						{
+							InstanceId: aws.String("i-eph001"),
+							State:      &ec2types.InstanceState{Name: ec2types.InstanceStateNamePending},
+						},
+					},
+				}, nil
+			},
+		}
+
+		p := newTestProvider(ec2Mock, nil, nil, nil)
+		_, err := p.CreateVM(context.Background(), CreateVMOpts{
+			ProjectHash:     "def456",
+			ProjectPath:     "/test",
+			Size:            "medium",
+			Temporary:       true,
+			SecurityGroupID: "sg-test",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, ec2types.ShutdownBehaviorTerminate, input.InstanceInitiatedShutdownBehavior, "no variant, but still temporary")
+		assert.Nil(t, input.InstanceMarketOptions)
+	})
+
+	t.Run("tags a temporary variant and requests spot", func(t *testing.T) {
+		t.Parallel()
+		var input *ec2.RunInstancesInput
+		ec2Mock := &mockEC2{
+			describeImagesFn: func(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+				return &ec2.DescribeImagesOutput{
+					Images: []ec2types.Image{
+						{ImageId: aws.String("ami-test"), CreationDate: aws.String("2024-01-01T00:00:00Z"), Name: aws.String("test")},
+					},
+				}, nil
+			},
+			runInstancesFn: func(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+				input = params
+				return &ec2.RunInstancesOutput{
+					Instances: []ec2types.Instance{
+						{
+							InstanceId: aws.String("i-spot001"),
+							State:      &ec2types.InstanceState{Name: ec2types.InstanceStateNamePending},
+						},
+					},
+				}, nil
+			},
+		}
+
+		p := newTestProvider(ec2Mock, nil, nil, nil)
+		_, err := p.CreateVM(context.Background(), CreateVMOpts{
+			ProjectHash:     "def456",
+			ProjectPath:     "/test",
+			Variant:         "xlarge spot",
+			Size:            "xlarge",
+			Spot:            true,
+			Temporary:       true,
+			SecurityGroupID: "sg-test",
+		})
+		require.NoError(t, err)
+		tags := make(map[string]string)
+		for _, tag := range input.TagSpecifications[0].Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+		assert.Equal(t, "xlarge spot", tags["yeager:variant"])
+		assert.Equal(t, "true", tags["yeager:temporary"])
+		assert.Equal(t, ec2types.ShutdownBehaviorTerminate, input.InstanceInitiatedShutdownBehavior, "it powers itself off once idle")
+		require.NotNil(t, input.InstanceMarketOptions)
+		assert.Equal(t, ec2types.MarketTypeSpot, input.InstanceMarketOptions.MarketType)
+		assert.Equal(t, ec2types.SpotInstanceTypeOneTime, input.InstanceMarketOptions.SpotOptions.SpotInstanceType)
+	})
+
 	t.Run("passes UserData when provided", func(t *testing.T) {
 		t.Parallel()
 		var capturedUserData *string
@@ -783,6 +911,51 @@ func TestFindVM(t *testing.T) {
 	})
 }
 
+func TestFindVariantVMs(t *testing.T) {
+	t.Parallel()
+
+	instance := func(id, profile, variant string) ec2types.Instance {
+		tags := []ec2types.Tag{{Key: aws.String("yeager:variant"), Value: aws.String(variant)}}
+		if profile != "" {
+			tags = append(tags, ec2types.Tag{Key: aws.String("yeager:profile"), Value: aws.String(profile)})
+		}
+		return ec2types.Instance{
+			InstanceId: aws.String(id),
+			State:      &ec2types.InstanceState{Name: ec2types.InstanceStateNameRunning},
+			Tags:       tags,
+		}
+	}
+	ec2Mock := &mockEC2{
+		describeInstancesFn: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+			filters := map[string][]string{}
+			for _, f := range params.Filters {
+				filters[aws.ToString(f.Name)] = f.Values
+			}
+			assert.Equal(t, []string{"/test"}, filters["tag:yeager:project-path"])
+			assert.Equal(t, []string{"yeager:variant"}, filters["tag-key"])
+			assert.NotContains(t, filters["instance-state-name"], "terminated")
+			return &ec2.DescribeInstancesOutput{
+				Reservations: []ec2types.Reservation{{Instances: []ec2types.Instance{
+					instance("i-big001", "", "xlarge"),
+					instance("i-ci001", "ci", "xlarge spot"),
+				}}},
+			}, nil
+		},
+	}
+	p := newTestProvider(ec2Mock, nil, nil, nil)
+
+	vms, err := p.FindVariantVMs(context.Background(), "/test", "")
+	require.NoError(t, err)
+	require.Len(t, vms, 1, "only the default profile's")
+	assert.Equal(t, "i-big001", vms[0].InstanceID)
+	assert.Equal(t, "xlarge", vms[0].Variant)
+
+	vms, err = p.FindVariantVMs(context.Background(), "/test", "ci")
+	require.NoError(t, err)
+	require.Len(t, vms, 1)
+	assert.Equal(t, "xlarge spot", vms[0].Variant)
+}
+
 func TestStartVM(t *testing.T) {
 	t.Parallel()
 
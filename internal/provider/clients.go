@@ -7,7 +7,10 @@ import (
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
 
+	"github.com/gridlhq/yeager/internal/secrets"
 	fkssh "github.com/gridlhq/yeager/internal/ssh"
 	fkstorage "github.com/gridlhq/yeager/internal/storage"
 )
@@ -37,3 +40,30 @@ func NewS3ObjectClient(ctx context.Context, region string) (fkstorage.S3API, err
 	}
 	return s3.NewFromConfig(cfg), nil
 }
+
+// NewS3BlobClients creates a real S3 client and presigner for storage.BlobStore.
+func NewS3BlobClients(ctx context.Context, region string) (fkstorage.BlobS3API, fkstorage.PresignAPI, error) {
+	opts := []func(*awsconfig.LoadOptions) error{}
+	if region != "" {
+		opts = append(opts, awsconfig.WithRegion(region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+	client := s3.NewFromConfig(cfg)
+	return client, s3.NewPresignClient(client), nil
+}
+
+// NewSecretsResolver creates a resolver backed by real SSM and Secrets Manager clients.
+func NewSecretsResolver(ctx context.Context, region string) (*secrets.Resolver, error) {
+	opts := []func(*awsconfig.LoadOptions) error{}
+	if region != "" {
+		opts = append(opts, awsconfig.WithRegion(region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+	return secrets.NewResolver(ssm.NewFromConfig(cfg), secretsmanager.NewFromConfig(cfg)), nil
+}
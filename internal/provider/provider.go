@@ -10,6 +10,7 @@ type VMInfo struct {
 	Region           string
 	AvailabilityZone string
 	InstanceType     string // e.g. "t4g.medium"
+	Variant          string // overridden compute settings it was launched with, e.g. "xlarge spot"
 }
 
 // CloudProvider defines the interface for cloud infrastructure operations.
@@ -33,6 +34,12 @@ type CloudProvider interface {
 	// Returns nil if no VM exists (not an error).
 	FindVM(ctx context.Context, projectHash string) (*VMInfo, error)
 
+	// FindVariantVMs lists the temporary VMs launched for a project with
+	// overridden compute settings (--size, --region, --spot), for a config
+	// profile ("" for the default). Each has its own hash, so FindVM with
+	// the project's hash doesn't see them.
+	FindVariantVMs(ctx context.Context, projectPath, profile string) ([]VMInfo, error)
+
 	// StartVM starts a stopped instance.
 	StartVM(ctx context.Context, instanceID string) error
 
@@ -60,7 +67,11 @@ type CloudProvider interface {
 type CreateVMOpts struct {
 	ProjectHash     string
 	ProjectPath     string
+	Profile         string // config profile, tagged for visibility; empty for the default
+	Variant         string // overridden compute settings, e.g. "xlarge spot"; tagged like Profile
 	Size            string // "small", "medium", "large", "xlarge"
+	Spot            bool   // launch a one-time spot instance, which can't be stopped
+	Temporary       bool   // terminated rather than stopped once idle, or if it shuts itself down; tagged for visibility
 	SecurityGroupID string
 	UserData        string // base64-encoded cloud-init document (optional)
 }
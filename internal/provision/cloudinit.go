@@ -4,14 +4,23 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/gridlhq/yeager/internal/config"
+	"gopkg.in/yaml.v3"
 )
 
 // CloudInitVersion is incremented when cloud-init changes in a breaking way.
 // Used to detect outdated VMs that need recreation.
-const CloudInitVersion = 1
+const CloudInitVersion = 2
+
+// projectDir is the rsync target on the VM.
+const projectDir = "/home/ubuntu/project"
 
 // basePackages are always installed on every VM.
 var basePackages = []string{
@@ -25,10 +34,67 @@ var basePackages = []string{
 	"tmux",
 }
 
-// CloudInit represents a generated cloud-init document.
+// CloudInit is a cloud-config document. Fields marshal in declaration
+// order, which is also the order cloud-init's modules run in.
 type CloudInit struct {
-	packages []string
-	runcmd   []string
+	Bootcmd    []string    `yaml:"bootcmd,omitempty"` // every boot, before packages
+	Apt        *AptConfig  `yaml:"apt,omitempty"`
+	Packages   []string    `yaml:"packages,omitempty"`
+	Users      []User      `yaml:"users,omitempty"`
+	WriteFiles []WriteFile `yaml:"write_files,omitempty"`
+	Runcmd     []string    `yaml:"runcmd,omitempty"` // first boot only, after packages
+}
+
+// AptConfig adds apt sources before packages are installed.
+type AptConfig struct {
+	Sources map[string]AptSource `yaml:"sources"`
+}
+
+// AptSource is one apt source. Source may use $RELEASE for the Ubuntu
+// codename; the signing key is given inline or fetched by ID.
+type AptSource struct {
+	Source string `yaml:"source"`
+	Key    string `yaml:"key,omitempty"`
+	KeyID  string `yaml:"keyid,omitempty"`
+}
+
+// User is a cloud-init user. A User named "default" with no other fields
+// keeps the image's default user (ubuntu), which listing users otherwise
+// removes.
+type User struct {
+	Name              string   `yaml:"name"`
+	Groups            string   `yaml:"groups,omitempty"`
+	Shell             string   `yaml:"shell,omitempty"`
+	Sudo              string   `yaml:"sudo,omitempty"`
+	SSHAuthorizedKeys []string `yaml:"ssh_authorized_keys,omitempty"`
+}
+
+// MarshalYAML renders the default user as the bare "default" entry.
+func (u User) MarshalYAML() (any, error) {
+	if u.Name == "default" && u.Groups == "" && u.Shell == "" && u.Sudo == "" && len(u.SSHAuthorizedKeys) == 0 {
+		return "default", nil
+	}
+	type plain User
+	return plain(u), nil
+}
+
+// WriteFile is a file written by cloud-init. Defer writes it after users
+// and packages exist, so Owner may name a user created by cloud-init.
+type WriteFile struct {
+	Path        string `yaml:"path"`
+	Content     string `yaml:"content"`
+	Owner       string `yaml:"owner,omitempty"`
+	Permissions string `yaml:"permissions,omitempty"` // octal string, e.g. "0644"
+	Append      bool   `yaml:"append,omitempty"`
+	Defer       bool   `yaml:"defer,omitempty"`
+}
+
+// AddAptSource adds an apt source under the given name.
+func (ci *CloudInit) AddAptSource(name string, src AptSource) {
+	if ci.Apt == nil {
+		ci.Apt = &AptConfig{Sources: map[string]AptSource{}}
+	}
+	ci.Apt.Sources[name] = src
 }
 
 // GenerateCloudInit creates a cloud-init document for a VM.
@@ -38,18 +104,24 @@ type CloudInit struct {
 // Cloud-init runs at first boot BEFORE project files are synced, so it only
 // includes runtime installs (rustup, nvm, go) and system packages — NOT
 // dependency installs (cargo fetch, npm ci) which need project files.
-// Dep install and [setup] run commands are executed post-sync via SSH (Phase 4).
+// Dep install and [setup] run commands are executed post-sync via SSH
+// (see PostSyncCommands).
 func GenerateCloudInit(langs []Language, setup config.SetupConfig) *CloudInit {
 	ci := &CloudInit{}
 
 	// 1. Base packages.
-	ci.packages = append(ci.packages, basePackages...)
+	ci.Packages = append(ci.Packages, basePackages...)
 
 	// 2. Setup packages from .yeager.toml (apt packages are fine in cloud-init).
-	ci.packages = append(ci.packages, setup.Packages...)
+	presets := setupPresets(setup)
+	// cloud-config packages must be unique.
+	for _, p := range presets {
+		ci.Packages = appendMissing(ci.Packages, p.Packages...)
+	}
+	ci.Packages = appendMissing(ci.Packages, setup.Packages...)
 
 	// 3. Configure sshd on both port 22 and 443.
-	ci.runcmd = append(ci.runcmd,
+	ci.Runcmd = append(ci.Runcmd,
 		// Ensure Port 22 is explicitly set (uncomment if commented, add if absent).
 		`grep -q '^Port 22' /etc/ssh/sshd_config || sed -i 's/^#Port 22$/Port 22/' /etc/ssh/sshd_config`,
 		`grep -q '^Port 22' /etc/ssh/sshd_config || echo 'Port 22' >> /etc/ssh/sshd_config`,
@@ -58,45 +130,184 @@ func GenerateCloudInit(langs []Language, setup config.SetupConfig) *CloudInit {
 	)
 
 	// 4. Per-language runtime installation (doesn't need project files).
+	// Runtimes are managed by mise, so install it first.
+	if usesMise(langs) {
+		ci.Runcmd = append(ci.Runcmd, miseSetup...)
+	}
 	for _, lang := range langs {
-		ci.runcmd = append(ci.runcmd, lang.RuntimeInstall...)
+		ci.Runcmd = append(ci.Runcmd, lang.RuntimeInstall...)
+	}
+	for _, p := range presets {
+		ci.Runcmd = append(ci.Runcmd, p.RuntimeInstall...)
 	}
 
 	// 5. Create project directory for rsync target.
-	ci.runcmd = append(ci.runcmd, "mkdir -p /home/ubuntu/project && chown ubuntu:ubuntu /home/ubuntu/project")
+	ci.Runcmd = append(ci.Runcmd, fmt.Sprintf("mkdir -p %s && chown ubuntu:ubuntu %s", projectDir, projectDir))
 
 	// NOTE: Dependency installs (DepInstall) and [setup] run commands are NOT
 	// included here. They require project files which aren't available until
-	// after the first rsync. Phase 4 runs them post-sync via SSH.
+	// after the first rsync. PostSyncCommands runs them over SSH instead.
 
 	return ci
 }
 
-// Render returns the cloud-init document as a string.
+// idleShutdownPath is where AddIdleShutdown installs its check.
+const idleShutdownPath = "/usr/local/sbin/yeager-idle-shutdown"
+
+// idleShutdownScript powers the VM off once it has had no SSH connection
+// and no yeager run (ubuntu's tmux) for the given minutes. The activity
+// stamp is in /run, so a reboot starts the count again.
+const idleShutdownScript = `#!/bin/sh
+stamp=/run/yeager-active
+if [ ! -e "$stamp" ] || pgrep -u ubuntu tmux >/dev/null ||
+  [ -n "$(ss -Htn state established '( sport = :22 or sport = :443 )')" ]; then
+  touch "$stamp"
+elif [ -n "$(find "$stamp" -mmin +%d)" ]; then
+  shutdown -h now
+fi
+`
+
+// AddIdleShutdown makes the VM power itself off once it has been idle for
+// after, checked every minute by cron. It's for temporary VMs launched to
+// terminate on shutdown: the laptop that would terminate them otherwise
+// may be asleep or gone.
+func (ci *CloudInit) AddIdleShutdown(after time.Duration) {
+	minutes := max(int(after.Minutes()), 1)
+	ci.WriteFiles = append(ci.WriteFiles,
+		WriteFile{Path: idleShutdownPath, Content: fmt.Sprintf(idleShutdownScript, minutes), Permissions: "0755"},
+		WriteFile{Path: "/etc/cron.d/yeager-idle-shutdown", Content: "* * * * * root " + idleShutdownPath + "\n", Permissions: "0644"},
+	)
+}
+
+// waitForCloudInit blocks until first-boot provisioning has finished, so
+// post-sync commands can rely on the runtimes cloud-init installs.
+const waitForCloudInit = "cloud-init status --wait >/dev/null 2>&1 || true"
+
+// PostSyncCommands returns the commands run over SSH after the first sync:
+// per-language dependency installs, then preset and [setup] run commands.
+// Returns nil if there is nothing to run.
+func PostSyncCommands(langs []Language, setup config.SetupConfig) []string {
+	var cmds []string
+	for _, lang := range langs {
+		cmds = append(cmds, lang.DepInstall...)
+	}
+	for _, p := range setupPresets(setup) {
+		cmds = append(cmds, p.Run...)
+	}
+	cmds = append(cmds, setup.Run...)
+	if len(cmds) == 0 {
+		return nil
+	}
+	// Shell init gives installs the same PATH and env as user commands.
+	prelude := append([]string{waitForCloudInit}, ShellInit(langs)...)
+	return append(prelude, cmds...)
+}
+
+// Render returns the cloud-init document as a string. Values are quoted
+// by the YAML encoder, so commands may contain any characters.
 func (ci *CloudInit) Render() string {
 	var b strings.Builder
-
 	b.WriteString("#cloud-config\n")
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	// Encoding plain structs of strings can't fail.
+	_ = enc.Encode(ci)
+	_ = enc.Close()
+	return b.String()
+}
 
-	// packages section.
-	b.WriteString("packages:\n")
-	for _, pkg := range ci.packages {
-		fmt.Fprintf(&b, "  - %s\n", pkg)
+// maxUserData is EC2's limit on raw (pre-base64) user data.
+const maxUserData = 16 * 1024
+
+var (
+	// aptPackageSpecRe matches a package with an optional :arch and =version.
+	aptPackageSpecRe = regexp.MustCompile(`^[a-z0-9][a-z0-9+.\-]*(:[a-z0-9]+)?(=[A-Za-z0-9.+~:\-]+)?$`)
+	permissionsRe    = regexp.MustCompile(`^0?[0-7]{3,4}$`)
+)
+
+// Validate checks the rendered document against the cloud-config schema
+// (see cloudConfigSchema), then the values the schema leaves open:
+// malformed apt package specs, empty commands, apt sources without a
+// source, users without a name, relative or non-octal write_files, and
+// user data over the EC2 size limit. cloud-init skips invalid modules at boot without failing,
+// so problems must be caught before launch.
+func (ci *CloudInit) Validate() error {
+	raw := ci.Render()
+	if len(raw) > maxUserData {
+		return fmt.Errorf("cloud-init is %d bytes, over the %d byte EC2 user data limit", len(raw), maxUserData)
+	}
+	if err := validateCloudConfig([]byte(raw)); err != nil {
+		return err
 	}
 
-	// runcmd section.
-	if len(ci.runcmd) > 0 {
-		b.WriteString("runcmd:\n")
-		for _, cmd := range ci.runcmd {
-			fmt.Fprintf(&b, "  - |\n    %s\n", cmd)
+	for _, pkg := range ci.Packages {
+		if !aptPackageSpecRe.MatchString(pkg) {
+			return fmt.Errorf("cloud-init packages: invalid apt package %q", pkg)
 		}
 	}
+	for _, list := range []struct {
+		key  string
+		cmds []string
+	}{{"bootcmd", ci.Bootcmd}, {"runcmd", ci.Runcmd}} {
+		for i, cmd := range list.cmds {
+			if strings.TrimSpace(cmd) == "" {
+				return fmt.Errorf("cloud-init %s[%d]: empty command", list.key, i)
+			}
+		}
+	}
+	if ci.Apt != nil {
+		for name, src := range ci.Apt.Sources {
+			if src.Source == "" {
+				return fmt.Errorf("cloud-init apt source %q: source is required", name)
+			}
+		}
+	}
+	for i, u := range ci.Users {
+		if u.Name == "" {
+			return fmt.Errorf("cloud-init users[%d]: name is required", i)
+		}
+	}
+	for _, f := range ci.WriteFiles {
+		if !path.IsAbs(f.Path) {
+			return fmt.Errorf("cloud-init write_files %q: path must be absolute", f.Path)
+		}
+		if f.Permissions != "" && !permissionsRe.MatchString(f.Permissions) {
+			return fmt.Errorf("cloud-init write_files %q: permissions %q must be octal, e.g. \"0644\"", f.Path, f.Permissions)
+		}
+	}
+	return nil
+}
 
-	return b.String()
+// DependencyHash computes a stable hash of what PostSyncCommands installs
+// for the project in dir: the commands themselves and the lockfiles of the
+// languages. Used to reinstall dependencies when either changes, and to
+// retry an install that failed.
+func DependencyHash(dir string, langs []Language, setup config.SetupConfig) string {
+	h := sha256.New()
+	for _, cmd := range PostSyncCommands(langs, setup) {
+		h.Write([]byte(cmd))
+		h.Write([]byte{0})
+	}
+	for _, lang := range langs {
+		lockfile := LockfileForLanguage(lang.Name, dir)
+		if lockfile == "" {
+			continue
+		}
+		data, err := os.ReadFile(lockfile)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(h, "lockfile:%s:%d:", filepath.Base(lockfile), len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
 }
 
 // SetupHash computes a stable hash of the setup config.
 // Used to detect when the [setup] section has changed.
+// Presets are hashed by what they install, so a new default version
+// counts as a change too. Without presets the hash is unchanged from
+// before presets existed.
 func SetupHash(setup config.SetupConfig) string {
 	h := sha256.New()
 	h.Write([]byte("packages:"))
@@ -109,5 +320,15 @@ func SetupHash(setup config.SetupConfig) string {
 		h.Write([]byte(r))
 		h.Write([]byte{0})
 	}
+	for _, p := range setupPresets(setup) {
+		fmt.Fprintf(h, "preset:%s@%s", p.Name, p.Version)
+		for _, part := range [][]string{p.Packages, p.RuntimeInstall, p.Run} {
+			for _, cmd := range part {
+				h.Write([]byte(cmd))
+				h.Write([]byte{0})
+			}
+			h.Write([]byte{1})
+		}
+	}
 	return hex.EncodeToString(h.Sum(nil))[:16]
 }
@@ -1,8 +1,12 @@
 package provision
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gridlhq/yeager/internal/config"
 	"github.com/stretchr/testify/assert"
@@ -230,6 +234,21 @@ func TestCloudInitValidYAML(t *testing.T) {
 	assert.Contains(t, pkgs, "pkg2")
 }
 
+func TestDependencyHash(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	langs := []Language{{Name: Node, DepInstall: []string{"npm ci"}}}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "package-lock.json"), []byte(`{"v":1}`), 0o644))
+	base := DependencyHash(dir, langs, config.SetupConfig{})
+	assert.Equal(t, base, DependencyHash(dir, langs, config.SetupConfig{}), "stable")
+
+	assert.NotEqual(t, base, DependencyHash(dir, langs, config.SetupConfig{Run: []string{"make deps"}}), "setup run changed")
+	assert.NotEqual(t, base, DependencyHash(dir, []Language{{Name: Node, DepInstall: []string{"npm install"}}}, config.SetupConfig{}), "install command changed")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "package-lock.json"), []byte(`{"v":2}`), 0o644))
+	assert.NotEqual(t, base, DependencyHash(dir, langs, config.SetupConfig{}), "lockfile changed")
+}
+
 func TestSetupHash(t *testing.T) {
 	t.Parallel()
 
@@ -284,6 +303,24 @@ func TestSetupHash(t *testing.T) {
 			b:         config.SetupConfig{},
 			wantEqual: false,
 		},
+		{
+			name:      "preset added",
+			a:         config.SetupConfig{Presets: []string{"docker"}},
+			b:         config.SetupConfig{},
+			wantEqual: false,
+		},
+		{
+			name:      "preset default version is the same as pinning it",
+			a:         config.SetupConfig{Presets: []string{"protoc"}},
+			b:         config.SetupConfig{Presets: []string{"protoc@" + presetCatalog["protoc"].DefaultVersion}},
+			wantEqual: true,
+		},
+		{
+			name:      "preset version bump",
+			a:         config.SetupConfig{Presets: []string{"protoc@28.3"}},
+			b:         config.SetupConfig{Presets: []string{"protoc@29.0"}},
+			wantEqual: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -310,3 +347,152 @@ func toStringSlice(vals []any) []string {
 	}
 	return out
 }
+
+func TestPostSyncCommands(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nothing to run", func(t *testing.T) {
+		t.Parallel()
+		assert.Nil(t, PostSyncCommands([]Language{{Name: Ruby}}, config.SetupConfig{}))
+	})
+
+	t.Run("deps then setup after cloud-init finishes", func(t *testing.T) {
+		t.Parallel()
+		langs := []Language{
+			{Name: Rust, DepInstall: []string{"cargo fetch"}},
+			{Name: Node, DepInstall: []string{"npm ci"}},
+		}
+		setup := config.SetupConfig{Run: []string{"cargo install cargo-nextest"}}
+
+		got := PostSyncCommands(langs, setup)
+		assert.Equal(t, []string{
+			waitForCloudInit,
+			"cargo fetch",
+			"npm ci",
+			"cargo install cargo-nextest",
+		}, got)
+	})
+}
+
+func TestCloudInitRenderQuotesValues(t *testing.T) {
+	t.Parallel()
+
+	tricky := []string{
+		`echo "key: value" # not a comment`,
+		"- starts with a dash",
+		"printf 'a\nb'",
+		"{ braces; }",
+	}
+	ci := GenerateCloudInit([]Language{{Name: Rust, RuntimeInstall: tricky}}, config.SetupConfig{})
+	ci.WriteFiles = []WriteFile{{Path: "/etc/yeager.conf", Content: "a: b\n'quoted'\n", Permissions: "0600"}}
+	ci.Users = []User{{Name: "default"}, {Name: "ci", Groups: "docker"}}
+	ci.Bootcmd = []string{"echo boot: $(date)"}
+	ci.AddAptSource("pgdg", AptSource{Source: "deb http://apt.postgresql.org/pub/repos/apt $RELEASE-pgdg main", KeyID: "B97B0AFCAA1A47F044F244A07FCC7D46ACCC4CF8"})
+
+	var doc struct {
+		Bootcmd    []string    `yaml:"bootcmd"`
+		Apt        AptConfig   `yaml:"apt"`
+		Runcmd     []string    `yaml:"runcmd"`
+		WriteFiles []WriteFile `yaml:"write_files"`
+		Users      []any       `yaml:"users"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(ci.Render()), &doc))
+	assert.Subset(t, doc.Runcmd, tricky, "commands round-trip unchanged")
+	assert.Equal(t, ci.WriteFiles, doc.WriteFiles)
+	assert.Equal(t, ci.Bootcmd, doc.Bootcmd)
+	assert.Equal(t, *ci.Apt, doc.Apt)
+	assert.Equal(t, "default", doc.Users[0])
+	assert.Equal(t, map[string]any{"name": "ci", "groups": "docker"}, doc.Users[1])
+	assert.NoError(t, ci.Validate())
+}
+
+func TestCloudInitValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		modify  func(ci *CloudInit)
+		wantErr string
+	}{
+		{"bad package", func(ci *CloudInit) { ci.Packages = append(ci.Packages, "libpq-dev curl") }, `invalid apt package "libpq-dev curl"`},
+		{"pinned package", func(ci *CloudInit) { ci.Packages = append(ci.Packages, "nginx=1.24.0-2ubuntu7") }, ""},
+		{"empty runcmd", func(ci *CloudInit) { ci.Runcmd = append(ci.Runcmd, " ") }, "runcmd[5]: empty command"},
+		{"relative file", func(ci *CloudInit) { ci.WriteFiles = []WriteFile{{Path: "etc/x"}} }, "path must be absolute"},
+		{"bad permissions", func(ci *CloudInit) { ci.WriteFiles = []WriteFile{{Path: "/x", Permissions: "644x"}} }, "must be octal"},
+		{"empty bootcmd", func(ci *CloudInit) { ci.Bootcmd = []string{""} }, "bootcmd[0]: empty command"},
+		{"nameless user", func(ci *CloudInit) { ci.Users = []User{{Groups: "sudo"}} }, "users[0]: name is required"},
+		{"apt source", func(ci *CloudInit) { ci.AddAptSource("x", AptSource{KeyID: "ABC"}) }, `apt source "x": source is required`},
+		{"duplicate package", func(ci *CloudInit) { ci.Packages = append(ci.Packages, "git") }, "cloud-init packages: git is listed twice"},
+		{"too large", func(ci *CloudInit) {
+			ci.WriteFiles = []WriteFile{{Path: "/big", Content: strings.Repeat("x", maxUserData)}}
+		}, "over the 16384 byte EC2 user data limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ci := GenerateCloudInit(nil, config.SetupConfig{})
+			tt.modify(ci)
+			err := ci.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestValidateCloudConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{"valid", "packages: [git, [nginx, 1.24.0]]\nusers: [default, {name: ci, sudo: false}]\nruncmd: [[ls, -l], echo hi]\n", ""},
+		{"unknown module", "packges: [git]\n", "cloud-init packges: unknown key"},
+		{"wrong type", "runcmd: echo hi\n", "cloud-init runcmd: want array, got string"},
+		{"empty list", "write_files: []\n", "cloud-init write_files: want at least 1 items"},
+		{"missing path", "write_files: [{content: x}]\n", "cloud-init write_files[0]: path is required"},
+		{"unknown field", "write_files: [{path: /x, mode: '0644'}]\n", "cloud-init write_files[0].mode: unknown key"},
+		{"bad encoding", "write_files: [{path: /x, encoding: zip}]\n", "cloud-init write_files[0].encoding: zip is not one of"},
+		{"apt source field", "apt: {sources: {x: {source: deb, signed: y}}}\n", "cloud-init apt.sources.x.signed: unknown key"},
+		{"not yaml", "runcmd: [\n", "not valid YAML"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateCloudConfig([]byte("#cloud-config\n" + tt.doc))
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestCloudInitAddIdleShutdown(t *testing.T) {
+	t.Parallel()
+
+	ci := GenerateCloudInit(nil, config.SetupConfig{})
+	ci.AddIdleShutdown(15 * time.Minute)
+	require.NoError(t, ci.Validate())
+
+	require.Len(t, ci.WriteFiles, 2)
+	script, cron := ci.WriteFiles[0], ci.WriteFiles[1]
+	assert.Equal(t, "/usr/local/sbin/yeager-idle-shutdown", script.Path)
+	assert.Equal(t, "0755", script.Permissions)
+	assert.Contains(t, script.Content, `-mmin +15`)
+	assert.Contains(t, script.Content, "shutdown -h now")
+	assert.Equal(t, "/etc/cron.d/yeager-idle-shutdown", cron.Path)
+	assert.Equal(t, "* * * * * root /usr/local/sbin/yeager-idle-shutdown\n", cron.Content)
+
+	if sh, err := exec.LookPath("sh"); err == nil {
+		out, err := exec.Command(sh, "-n", "-c", script.Content).CombinedOutput()
+		assert.NoError(t, err, "script doesn't parse: %s", out)
+	}
+}
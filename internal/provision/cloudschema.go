@@ -0,0 +1,221 @@
+package provision
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// cloudConfigSchema is the JSON Schema rendered documents are validated
+// against: the part of cloud-init's schema-cloud-config-v1.json for the
+// modules CloudInit emits.
+//
+//go:embed cloudconfig.schema.json
+var cloudConfigSchema []byte
+
+// cloudConfigRoot is cloudConfigSchema, parsed.
+var cloudConfigRoot = func() map[string]any {
+	var root map[string]any
+	if err := json.Unmarshal(cloudConfigSchema, &root); err != nil {
+		panic("provision: invalid cloud-config schema: " + err.Error())
+	}
+	return root
+}()
+
+// validateCloudConfig checks a rendered #cloud-config document against
+// cloudConfigSchema.
+func validateCloudConfig(raw []byte) error {
+	var doc any
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("cloud-init is not valid YAML: %w", err)
+	}
+	if err := validateSchema(cloudConfigRoot, cloudConfigRoot, doc, ""); err != nil {
+		return fmt.Errorf("cloud-init %w", err)
+	}
+	return nil
+}
+
+// validateSchema checks v against schema, with the JSON Schema keywords
+// cloudConfigSchema uses. $refs resolve against root. at is where v is in
+// the document, e.g. "users[1].name".
+func validateSchema(root, schema map[string]any, v any, at string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/$defs/")
+		def, ok := root["$defs"].(map[string]any)[name].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: unknown schema $ref %q", where(at), ref)
+		}
+		return validateSchema(root, def, v, at)
+	}
+
+	if t, ok := schema["type"]; ok && !hasType(t, v) {
+		return fmt.Errorf("%s: want %s, got %s", where(at), typeNames(t), jsonType(v))
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			found = found || reflect.DeepEqual(e, v)
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", where(at), v, enum)
+		}
+	}
+	if alts, ok := schema["oneOf"].([]any); ok {
+		matched := 0
+		for _, alt := range alts {
+			if validateSchema(root, alt.(map[string]any), v, at) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fmt.Errorf("%s: %s matches %d of the allowed forms, want exactly 1", where(at), jsonType(v), matched)
+		}
+	}
+	if alts, ok := schema["anyOf"].([]any); ok {
+		matched := false
+		for _, alt := range alts {
+			matched = matched || validateSchema(root, alt.(map[string]any), v, at) == nil
+		}
+		if !matched {
+			return fmt.Errorf("%s: %s matches none of the allowed forms", where(at), jsonType(v))
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		return validateObject(root, schema, v, at)
+	case []any:
+		return validateArray(root, schema, v, at)
+	}
+	return nil
+}
+
+func validateObject(root, schema map[string]any, obj map[string]any, at string) error {
+	if n, ok := schema["minProperties"].(float64); ok && len(obj) < int(n) {
+		return fmt.Errorf("%s: want at least %d keys", where(at), int(n))
+	}
+	required, _ := schema["required"].([]any)
+	for _, name := range required {
+		if _, ok := obj[name.(string)]; !ok {
+			return fmt.Errorf("%s: %s is required", where(at), name)
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	props, _ := schema["properties"].(map[string]any)
+	for _, key := range keys {
+		sub := subPath(at, key)
+		if prop, ok := props[key].(map[string]any); ok {
+			if err := validateSchema(root, prop, obj[key], sub); err != nil {
+				return err
+			}
+			continue
+		}
+		switch extra := schema["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				return fmt.Errorf("%s: unknown key", sub)
+			}
+		case map[string]any:
+			if err := validateSchema(root, extra, obj[key], sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateArray(root, schema map[string]any, list []any, at string) error {
+	if n, ok := schema["minItems"].(float64); ok && len(list) < int(n) {
+		return fmt.Errorf("%s: want at least %d items", where(at), int(n))
+	}
+	if n, ok := schema["maxItems"].(float64); ok && len(list) > int(n) {
+		return fmt.Errorf("%s: want at most %d items", where(at), int(n))
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range list {
+			for j := range i {
+				if reflect.DeepEqual(list[i], list[j]) {
+					return fmt.Errorf("%s: %v is listed twice", where(at), list[i])
+				}
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		for i, item := range list {
+			if err := validateSchema(root, items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hasType reports whether v has the schema type t, a name or a list.
+func hasType(t, v any) bool {
+	if names, ok := t.([]any); ok {
+		for _, name := range names {
+			if hasType(name, v) {
+				return true
+			}
+		}
+		return false
+	}
+	got := jsonType(v)
+	return got == t || (t == "number" && got == "integer")
+}
+
+func typeNames(t any) string {
+	if names, ok := t.([]any); ok {
+		parts := make([]string, len(names))
+		for i, name := range names {
+			parts[i] = fmt.Sprint(name)
+		}
+		return strings.Join(parts, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+// jsonType names the JSON type of a decoded YAML value.
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func subPath(at, key string) string {
+	if at == "" {
+		return key
+	}
+	return at + "." + key
+}
+
+func where(at string) string {
+	if at == "" {
+		return "document"
+	}
+	return at
+}
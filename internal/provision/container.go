@@ -0,0 +1,148 @@
+package provision
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/gridlhq/yeager/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Container is the pseudo-language for [exec] container: the VM only gets
+// Docker, and remote commands run inside an image built from the project's
+// Dockerfile or a docker compose service, with the project bind-mounted.
+const Container LanguageName = "container"
+
+// composeFiles are the files docker compose reads by default, in lookup order.
+var composeFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// ExecContainer returns the container exec environment configured in
+// [exec] container. Its Version hashes the image inputs, so editing the
+// Dockerfile or compose file rebuilds the image on the next run.
+func ExecContainer(dir string, cfg config.ExecContainerConfig) (Language, error) {
+	if cfg.ComposeService != "" {
+		return composeContainer(dir, cfg)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(cfg.Dockerfile)))
+	if err != nil {
+		return Language{}, fmt.Errorf("reading exec.container.dockerfile: %w", err)
+	}
+	buildContext := cfg.Context
+	if buildContext == "" {
+		buildContext = "."
+	}
+	version := commandsHash([]string{string(content)}, []string{buildContext})
+	tag := "yeager-exec:" + version
+	build := fmt.Sprintf("sudo docker build -t %s -f %s %s", tag, shellQuote(cfg.Dockerfile), shellQuote(buildContext))
+
+	return Language{
+		Name:           Container,
+		DisplayName:    fmt.Sprintf("container (%s)", cfg.Dockerfile),
+		Version:        version,
+		RuntimeInstall: append([]string{}, dockerInstall...),
+		DepInstall:     []string{build},
+		// The image is tagged by its inputs, so this only builds when the
+		// Dockerfile changed or the image was pruned.
+		Prepare: []string{fmt.Sprintf("sudo docker image inspect %s >/dev/null 2>&1 || %s", tag, build)},
+		Wrapper: fmt.Sprintf("sudo docker run --rm --init --network host %s %s", containerRunFlags(cfg), tag),
+	}, nil
+}
+
+// composeContainer runs commands in a one-off container of a compose
+// service, which also starts the services it depends on.
+func composeContainer(dir string, cfg config.ExecContainerConfig) (Language, error) {
+	file := cfg.ComposeFile
+	if file == "" {
+		for _, name := range composeFiles {
+			if fileExists(dir, name) {
+				file = name
+				break
+			}
+		}
+	}
+	if file == "" {
+		return Language{}, fmt.Errorf("exec.container.compose_service is set but no compose file was found (set exec.container.compose_file)")
+	}
+	content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file)))
+	if err != nil {
+		return Language{}, fmt.Errorf("reading compose file: %w", err)
+	}
+	dockerfile, err := composeServiceDockerfile(content, cfg.ComposeService)
+	if err != nil {
+		return Language{}, fmt.Errorf("%s: %w", file, err)
+	}
+
+	inputs := []string{string(content)}
+	if dockerfile != "" {
+		// Best-effort: a missing Dockerfile fails the build with a clearer error.
+		df, _ := os.ReadFile(filepath.Join(dir, filepath.Dir(filepath.FromSlash(file)), filepath.FromSlash(dockerfile)))
+		inputs = append(inputs, string(df))
+	}
+
+	compose := "sudo docker compose -f " + shellQuote(file)
+	return Language{
+		Name:           Container,
+		DisplayName:    fmt.Sprintf("container (compose service %s)", cfg.ComposeService),
+		Version:        commandsHash(inputs, []string{cfg.ComposeService}),
+		RuntimeInstall: append([]string{}, dockerInstall...),
+		DepInstall:     []string{fmt.Sprintf("%s build %s", compose, shellQuote(cfg.ComposeService))},
+		// compose run builds or pulls a missing image and starts the
+		// service's dependencies itself, so nothing needs preparing.
+		Wrapper: fmt.Sprintf("%s run --rm -T %s %s", compose, containerRunFlags(cfg), shellQuote(cfg.ComposeService)),
+	}, nil
+}
+
+// containerRunFlags bind-mounts the synced project at the same path and
+// runs as the VM user by default, so files the command writes stay
+// owned by it. The image's entrypoint is kept, so it must exec its
+// arguments (as docker-entrypoint scripts do).
+func containerRunFlags(cfg config.ExecContainerConfig) string {
+	user := `"$(id -u):$(id -g)"`
+	if cfg.User != "" {
+		user = shellQuote(cfg.User)
+	}
+	return fmt.Sprintf("-v %s:%s -w %s -u %s", projectDir, projectDir, projectDir, user)
+}
+
+// composeServiceDockerfile returns the Dockerfile a compose service builds
+// from, relative to the compose file, or empty string if it uses a
+// prebuilt image.
+func composeServiceDockerfile(content []byte, service string) (string, error) {
+	var doc struct {
+		Services map[string]struct {
+			Build yaml.Node `yaml:"build"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return "", fmt.Errorf("parsing compose file: %w", err)
+	}
+	svc, ok := doc.Services[service]
+	if !ok {
+		return "", fmt.Errorf("compose service %q not found", service)
+	}
+
+	var build struct {
+		Context    string `yaml:"context"`
+		Dockerfile string `yaml:"dockerfile"`
+	}
+	switch svc.Build.Kind {
+	case 0:
+		return "", nil
+	case yaml.ScalarNode:
+		build.Context = svc.Build.Value
+	default:
+		if err := svc.Build.Decode(&build); err != nil {
+			return "", fmt.Errorf("parsing build of compose service %q: %w", service, err)
+		}
+	}
+	if build.Context == "" {
+		build.Context = "."
+	}
+	if build.Dockerfile == "" {
+		build.Dockerfile = "Dockerfile"
+	}
+	return path.Join(build.Context, build.Dockerfile), nil
+}
@@ -0,0 +1,129 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/gridlhq/yeager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecContainer_Dockerfile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"Dockerfile.ci": "FROM golang:1.22\n", "go.mod": "module m\n"})
+
+	lang, err := ExecContainer(dir, config.ExecContainerConfig{Dockerfile: "Dockerfile.ci"})
+	require.NoError(t, err)
+	assert.Equal(t, Container, lang.Name)
+	assert.Equal(t, "container (Dockerfile.ci)", lang.DisplayName)
+	assert.Contains(t, lang.RuntimeInstall, "apt-get install -y docker.io docker-compose-v2")
+	assert.Empty(t, lang.Tool)
+
+	tag := "yeager-exec:" + lang.Version
+	assert.Equal(t, []string{"sudo docker build -t " + tag + " -f 'Dockerfile.ci' '.'"}, lang.DepInstall)
+	require.Len(t, lang.Prepare, 1)
+	assert.Contains(t, lang.Prepare[0], "sudo docker image inspect "+tag+" >/dev/null 2>&1 || sudo docker build")
+	assert.Equal(t,
+		`sudo docker run --rm --init --network host -v /home/ubuntu/project:/home/ubuntu/project -w /home/ubuntu/project -u "$(id -u):$(id -g)" `+tag,
+		lang.Wrapper)
+	assert.Empty(t, ShellInit([]Language{lang}))
+}
+
+func TestExecContainer_VersionTracksDockerfile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfg := config.ExecContainerConfig{Dockerfile: "Dockerfile"}
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM ubuntu:22.04\n"})
+	a, err := ExecContainer(dir, cfg)
+	require.NoError(t, err)
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM ubuntu:24.04\n"})
+	b, err := ExecContainer(dir, cfg)
+	require.NoError(t, err)
+	assert.NotEqual(t, a.Version, b.Version)
+}
+
+func TestExecContainer_MissingDockerfile(t *testing.T) {
+	t.Parallel()
+
+	_, err := ExecContainer(t.TempDir(), config.ExecContainerConfig{Dockerfile: "Dockerfile"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exec.container.dockerfile")
+}
+
+func TestExecContainer_Compose(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"docker-compose.yml": `services:
+  app:
+    build:
+      context: .
+      dockerfile: docker/app.Dockerfile
+    depends_on: [db]
+  db:
+    image: postgres:16
+`,
+		"docker/app.Dockerfile": "FROM node:20\n",
+	})
+
+	cfg := config.ExecContainerConfig{ComposeService: "app", User: "root"}
+	lang, err := ExecContainer(dir, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "container (compose service app)", lang.DisplayName)
+	assert.Equal(t, []string{"sudo docker compose -f 'docker-compose.yml' build 'app'"}, lang.DepInstall)
+	assert.Empty(t, lang.Prepare)
+	assert.Equal(t,
+		"sudo docker compose -f 'docker-compose.yml' run --rm -T -v /home/ubuntu/project:/home/ubuntu/project -w /home/ubuntu/project -u 'root' 'app'",
+		lang.Wrapper)
+
+	writeFiles(t, dir, map[string]string{"docker/app.Dockerfile": "FROM node:22\n"})
+	changed, err := ExecContainer(dir, cfg)
+	require.NoError(t, err)
+	assert.NotEqual(t, lang.Version, changed.Version, "the service's Dockerfile is an image input")
+}
+
+func TestExecContainer_ComposeErrors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no compose file", func(t *testing.T) {
+		t.Parallel()
+		_, err := ExecContainer(t.TempDir(), config.ExecContainerConfig{ComposeService: "app"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no compose file")
+	})
+
+	t.Run("unknown service", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{"compose.yaml": "services:\n  web:\n    image: nginx\n"})
+		_, err := ExecContainer(dir, config.ExecContainerConfig{ComposeService: "app"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `compose service "app" not found`)
+	})
+}
+
+func TestComposeServiceDockerfile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		build string
+		want  string
+	}{
+		{"prebuilt image", "    image: ubuntu\n", ""},
+		{"context string", "    build: ./app\n", "app/Dockerfile"},
+		{"context and dockerfile", "    build:\n      context: api\n      dockerfile: Dockerfile.dev\n", "api/Dockerfile.dev"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := composeServiceDockerfile([]byte("services:\n  app:\n"+tt.build), "app")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
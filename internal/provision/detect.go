@@ -1,10 +1,12 @@
 package provision
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -17,142 +19,178 @@ const (
 	Go     LanguageName = "go"
 	Python LanguageName = "python"
 	Ruby   LanguageName = "ruby"
+	DotNet LanguageName = "dotnet"
 )
 
 const (
 	defaultGoVersion = "1.22.0"
-	nvmVersion       = "v0.40.1"
+	dotnetInstallDir = "/usr/local/dotnet"
 )
 
 // Language represents a detected language with its provisioning commands.
 type Language struct {
 	Name           LanguageName
 	DisplayName    string   // e.g. "Rust (Cargo.toml)"
+	Version        string   // resolved runtime version, e.g. "20", "1.22.0", "stable"
+	Tool           string   // mise tool that installs the runtime; empty if not managed by mise
 	RuntimeInstall []string // shell commands to install the runtime
 	DepInstall     []string // shell commands to install dependencies
+	ShellInit      []string // shell commands run before every remote command (e.g. venv activation)
+	Env            []string // "NAME=word" env for remote commands, word expanded on the VM; sent through the run's env file
+	Prepare        []string // shell commands run before each remote command on an existing VM (e.g. start a container)
+	Wrapper        string   // command prefix that runs remote commands inside this environment (e.g. a container)
 }
 
-// DetectLanguages scans a project directory for known manifest files
-// and returns the detected languages in a stable order.
-// Returns nil if no languages are detected.
-func DetectLanguages(dir string) []Language {
-	var langs []Language
-
-	// Detection order is stable: Rust, Node, Go, Python, Ruby.
-	// This matches the priority table in FEATURES.md.
-
-	if fileExists(dir, "Cargo.toml") {
-		langs = append(langs, detectRust(dir))
-	}
-
-	if fileExists(dir, "package.json") {
-		langs = append(langs, detectNode(dir))
-	}
-
-	if fileExists(dir, "go.mod") {
-		langs = append(langs, detectGo(dir))
-	}
-
-	// Python: prefer pyproject.toml over requirements.txt.
-	if fileExists(dir, "pyproject.toml") {
-		langs = append(langs, detectPython(dir, "pyproject.toml"))
-	} else if fileExists(dir, "requirements.txt") {
-		langs = append(langs, detectPython(dir, "requirements.txt"))
-	}
-
-	if fileExists(dir, "Gemfile") {
-		langs = append(langs, detectRuby(dir))
-	}
-
-	return langs
-}
-
-func detectRust(_ string) Language {
+func detectRust(dir string, pins versionPins) Language {
+	version := resolveRustVersion(dir, pins)
 	return Language{
-		Name:        Rust,
-		DisplayName: "Rust (Cargo.toml)",
-		RuntimeInstall: []string{
-			"curl --proto '=https' --tlsv1.2 -sSf https://sh.rustup.rs | sh -s -- -y",
-			`. "$HOME/.cargo/env"`,
-		},
-		DepInstall: []string{"cargo fetch"},
+		Name:           Rust,
+		DisplayName:    "Rust (Cargo.toml)",
+		Version:        version,
+		Tool:           "rust",
+		RuntimeInstall: []string{miseUse("rust", version)},
+		DepInstall:     []string{"cargo fetch"},
 	}
 }
 
-func detectNode(dir string) Language {
-	// Detect Node version from .nvmrc if present.
-	nodeVersion := "--lts"
-	if content, err := os.ReadFile(filepath.Join(dir, ".nvmrc")); err == nil {
-		nodeVersion = parseNvmrc(string(content))
-	}
-
-	nvmInstall := fmt.Sprintf("curl -o- https://raw.githubusercontent.com/nvm-sh/nvm/%s/install.sh | bash", nvmVersion)
-	nvmLoad := `export NVM_DIR="$HOME/.nvm" && . "$NVM_DIR/nvm.sh"`
-	nodeInstall := fmt.Sprintf("%s && nvm install %s", nvmLoad, nodeVersion)
+func detectNode(dir string, pins versionPins) Language {
+	version := resolveNodeVersion(dir, pins)
 
 	// Detect package manager from lockfile.
 	// npm ci requires package-lock.json — fall back to npm install if absent.
-	depCmd := fmt.Sprintf("%s && npm install", nvmLoad)
+	depCmd := "npm install"
 	if fileExists(dir, "package-lock.json") {
-		depCmd = fmt.Sprintf("%s && npm ci", nvmLoad)
+		depCmd = "npm ci"
 	} else if fileExists(dir, "yarn.lock") {
-		depCmd = fmt.Sprintf("%s && yarn install --frozen-lockfile", nvmLoad)
+		depCmd = "yarn install --frozen-lockfile"
 	} else if fileExists(dir, "pnpm-lock.yaml") {
-		depCmd = fmt.Sprintf("%s && npm install -g pnpm && pnpm install --frozen-lockfile", nvmLoad)
+		depCmd = "npm install -g pnpm && pnpm install --frozen-lockfile"
 	}
 
 	return Language{
 		Name:           Node,
 		DisplayName:    "Node (package.json)",
-		RuntimeInstall: []string{nvmInstall, nodeInstall},
+		Version:        version,
+		Tool:           "node",
+		RuntimeInstall: []string{miseUse("node", version)},
 		DepInstall:     []string{depCmd},
 	}
 }
 
-func detectGo(dir string) Language {
-	version := defaultGoVersion
-	if content, err := os.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
-		version = parseGoVersion(string(content))
+func detectGo(dir string, pins versionPins) Language {
+	version := resolveGoVersion(dir, pins)
+	return Language{
+		Name:           Go,
+		DisplayName:    "Go (go.mod)",
+		Version:        version,
+		Tool:           "go",
+		RuntimeInstall: []string{miseUse("go", version)},
+		DepInstall:     []string{"go mod download"},
 	}
+}
 
+func detectRuby(dir string, pins versionPins) Language {
+	version := resolveRubyVersion(dir, pins)
 	return Language{
-		Name:        Go,
-		DisplayName: "Go (go.mod)",
+		Name:        Ruby,
+		DisplayName: "Ruby (Gemfile)",
+		Version:     version,
+		Tool:        "ruby",
 		RuntimeInstall: []string{
-			fmt.Sprintf("curl -fsSL https://go.dev/dl/go%s.linux-arm64.tar.gz | tar -C /usr/local -xz", version),
-			"echo 'export PATH=$PATH:/usr/local/go/bin:$HOME/go/bin' >> $HOME/.bashrc",
-		},
-		DepInstall: []string{
-			"export PATH=$PATH:/usr/local/go/bin && go mod download",
+			// mise builds Ruby from source.
+			"apt-get install -y libssl-dev libyaml-dev zlib1g-dev libffi-dev libreadline-dev",
+			miseUse("ruby", version),
 		},
+		DepInstall: []string{"bundle install"},
 	}
 }
 
-func detectPython(_ string, manifest string) Language {
-	depCmd := "python3 -m pip install -e ."
-	if manifest == "requirements.txt" {
-		depCmd = "python3 -m pip install -r requirements.txt"
+func detectDotNet(dir, manifest string, pins versionPins) Language {
+	version := resolveDotNetVersion(dir, pins)
+
+	// dotnet restore needs an explicit target when a directory holds
+	// more than one solution/project file. A bare global.json has nothing
+	// to restore at the root.
+	var depInstall []string
+	if manifest != "global.json" {
+		depInstall = []string{fmt.Sprintf("dotnet restore '%s'", manifest)}
 	}
 
 	return Language{
-		Name:        Python,
-		DisplayName: fmt.Sprintf("Python (%s)", manifest),
-		RuntimeInstall: []string{
-			"apt-get install -y python3 python3-pip python3-venv",
-		},
-		DepInstall: []string{depCmd},
+		Name:           DotNet,
+		DisplayName:    fmt.Sprintf(".NET (%s)", manifest),
+		Version:        version,
+		RuntimeInstall: dotnetRuntimeInstall(version),
+		DepInstall:     depInstall,
 	}
 }
 
-func detectRuby(_ string) Language {
-	return Language{
-		Name:        Ruby,
-		DisplayName: "Ruby (Gemfile)",
-		RuntimeInstall: []string{
-			"apt-get install -y ruby-full",
-		},
-		DepInstall: []string{"bundle install"},
+// dotnetRuntimeInstall installs the given SDK version, or the current LTS
+// channel if version is empty. mise has no first-class .NET support, so the
+// SDK comes from dotnet-install.sh.
+func dotnetRuntimeInstall(version string) []string {
+	versionArg := "--channel LTS"
+	if version != "" {
+		versionArg = "--version " + version
+	}
+	return []string{
+		"curl -fsSL https://dot.net/v1/dotnet-install.sh -o /tmp/dotnet-install.sh",
+		fmt.Sprintf("bash /tmp/dotnet-install.sh %s --architecture arm64 --install-dir %s", versionArg, dotnetInstallDir),
+		fmt.Sprintf("ln -sf %s/dotnet /usr/local/bin/dotnet", dotnetInstallDir),
+		fmt.Sprintf("grep -q '^DOTNET_ROOT=' /etc/environment || echo 'DOTNET_ROOT=%s' >> /etc/environment", dotnetInstallDir),
+	}
+}
+
+// dotnetManifest returns the .NET manifest that identifies the project:
+// the first *.sln, then the first *.csproj/*.fsproj, then global.json.
+// Returns empty string if the directory is not a .NET project.
+func dotnetManifest(dir string) string {
+	for _, pattern := range []string{"*.sln", "*.csproj", "*.fsproj"} {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		if len(matches) > 0 {
+			sort.Strings(matches)
+			return filepath.Base(matches[0])
+		}
+	}
+	if fileExists(dir, "global.json") {
+		return "global.json"
+	}
+	return ""
+}
+
+// ShellInit returns the commands to run before every remote command
+// for the given languages, in detection order. mise shims aren't
+// exported into a wrapped environment such as a container.
+func ShellInit(langs []Language) []string {
+	var cmds []string
+	if usesMise(langs) && ExecWrapper(langs) == "" {
+		cmds = append(cmds, miseShellInit)
 	}
+	for _, lang := range langs {
+		cmds = append(cmds, lang.ShellInit...)
+	}
+	return cmds
+}
+
+// ExecWrapper returns the wrapper remote commands run through, or empty
+// string if they run directly on the VM.
+func ExecWrapper(langs []Language) string {
+	for _, lang := range langs {
+		if lang.Wrapper != "" {
+			return lang.Wrapper
+		}
+	}
+	return ""
+}
+
+// PrepareCommands returns the commands that ready the exec environment
+// on an existing VM, in detection order.
+func PrepareCommands(langs []Language) []string {
+	var cmds []string
+	for _, lang := range langs {
+		cmds = append(cmds, lang.Prepare...)
+	}
+	return cmds
 }
 
 // LockfileForLanguage returns the path to the lockfile for a language,
@@ -172,8 +210,9 @@ var lockfileCandidates = map[LanguageName][]string{
 	Rust:   {"Cargo.lock"},
 	Node:   {"package-lock.json", "yarn.lock", "pnpm-lock.yaml"},
 	Go:     {"go.sum"},
-	Python: {"poetry.lock", "Pipfile.lock", "requirements.txt"},
+	Python: {"uv.lock", "poetry.lock", "Pipfile.lock", "pdm.lock", "requirements.txt"},
 	Ruby:   {"Gemfile.lock"},
+	DotNet: {"packages.lock.json"},
 }
 
 // parseGoVersion extracts the Go version from go.mod content.
@@ -193,14 +232,29 @@ func parseGoVersion(content string) string {
 	return v
 }
 
-// parseNvmrc trims whitespace from .nvmrc content.
-// Returns "--lts" if the file is empty or whitespace-only.
+// parseGlobalJSON extracts sdk.version from global.json content.
+// Returns empty string if the file is malformed or does not pin a version.
+func parseGlobalJSON(content []byte) string {
+	var g struct {
+		SDK struct {
+			Version string `json:"version"`
+		} `json:"sdk"`
+	}
+	if err := json.Unmarshal(content, &g); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(g.SDK.Version)
+}
+
+// parseNvmrc trims whitespace and a leading "v" from .nvmrc/.node-version content.
+// nvm aliases (lts/*, lts/iron) map to mise's "lts".
+// Returns empty string if the file is empty or whitespace-only.
 func parseNvmrc(content string) string {
 	v := strings.TrimSpace(content)
-	if v == "" {
-		return "--lts"
+	if strings.HasPrefix(v, "lts/") {
+		return defaultNodeVersion
 	}
-	return v
+	return strings.TrimPrefix(v, "v")
 }
 
 func fileExists(dir, name string) bool {
@@ -74,6 +74,34 @@ gem "rails"
 			},
 			wantLangs: []LanguageName{Ruby},
 		},
+		{
+			name: "dotnet solution",
+			files: map[string]string{
+				"MyApp.sln": "",
+			},
+			wantLangs: []LanguageName{DotNet},
+		},
+		{
+			name: "dotnet csproj",
+			files: map[string]string{
+				"Api.csproj": "<Project Sdk=\"Microsoft.NET.Sdk\"></Project>",
+			},
+			wantLangs: []LanguageName{DotNet},
+		},
+		{
+			name: "dotnet fsproj",
+			files: map[string]string{
+				"Lib.fsproj": "<Project Sdk=\"Microsoft.NET.Sdk\"></Project>",
+			},
+			wantLangs: []LanguageName{DotNet},
+		},
+		{
+			name: "dotnet global.json only",
+			files: map[string]string{
+				"global.json": `{"sdk": {"version": "8.0.100"}}`,
+			},
+			wantLangs: []LanguageName{DotNet},
+		},
 		{
 			name: "multi-language project",
 			files: map[string]string{
@@ -93,6 +121,13 @@ gem "rails"
 			},
 			wantLangs: []LanguageName{Rust, Node, Go, Python, Ruby},
 		},
+		{
+			name: "python project with Pipfile",
+			files: map[string]string{
+				"Pipfile": "[packages]\nrequests = \"*\"\n",
+			},
+			wantLangs: []LanguageName{Python},
+		},
 		{
 			name: "python prefers pyproject.toml over requirements.txt",
 			files: map[string]string{
@@ -143,7 +178,16 @@ func TestDetectLanguage_RuntimeCommands(t *testing.T) {
 			files: map[string]string{
 				"Cargo.toml": "[package]\nname = \"app\"\n",
 			},
-			wantRuntime:    []string{"curl --proto '=https' --tlsv1.2 -sSf https://sh.rustup.rs | sh -s -- -y", ". \"$HOME/.cargo/env\""},
+			wantRuntime:    []string{"su - ubuntu -c 'mise use --global --yes rust@stable'"},
+			wantDepInstall: []string{"cargo fetch"},
+		},
+		{
+			name: "rust with rust-toolchain.toml",
+			files: map[string]string{
+				"Cargo.toml":          "[package]\nname = \"app\"\n",
+				"rust-toolchain.toml": "[toolchain]\nchannel = \"1.78.0\"\ncomponents = [\"clippy\"]\n",
+			},
+			wantRuntime:    []string{"su - ubuntu -c 'mise use --global --yes rust@1.78.0'"},
 			wantDepInstall: []string{"cargo fetch"},
 		},
 		{
@@ -151,8 +195,8 @@ func TestDetectLanguage_RuntimeCommands(t *testing.T) {
 			files: map[string]string{
 				"package.json": "{}",
 			},
-			wantRuntime:    []string{"curl -o- https://raw.githubusercontent.com/nvm-sh/nvm/v0.40.1/install.sh | bash", "export NVM_DIR=\"$HOME/.nvm\" && . \"$NVM_DIR/nvm.sh\" && nvm install --lts"},
-			wantDepInstall: []string{"export NVM_DIR=\"$HOME/.nvm\" && . \"$NVM_DIR/nvm.sh\" && npm install"},
+			wantRuntime:    []string{"su - ubuntu -c 'mise use --global --yes node@lts'"},
+			wantDepInstall: []string{"npm install"},
 		},
 		{
 			name: "node with nvmrc (no lockfile)",
@@ -160,8 +204,8 @@ func TestDetectLanguage_RuntimeCommands(t *testing.T) {
 				"package.json": "{}",
 				".nvmrc":       "20",
 			},
-			wantRuntime:    []string{"curl -o- https://raw.githubusercontent.com/nvm-sh/nvm/v0.40.1/install.sh | bash", "export NVM_DIR=\"$HOME/.nvm\" && . \"$NVM_DIR/nvm.sh\" && nvm install 20"},
-			wantDepInstall: []string{"export NVM_DIR=\"$HOME/.nvm\" && . \"$NVM_DIR/nvm.sh\" && npm install"},
+			wantRuntime:    []string{"su - ubuntu -c 'mise use --global --yes node@20'"},
+			wantDepInstall: []string{"npm install"},
 		},
 		{
 			name: "node with package-lock",
@@ -169,8 +213,8 @@ func TestDetectLanguage_RuntimeCommands(t *testing.T) {
 				"package.json":      "{}",
 				"package-lock.json": "{}",
 			},
-			wantRuntime:    []string{"curl -o- https://raw.githubusercontent.com/nvm-sh/nvm/v0.40.1/install.sh | bash", "export NVM_DIR=\"$HOME/.nvm\" && . \"$NVM_DIR/nvm.sh\" && nvm install --lts"},
-			wantDepInstall: []string{"export NVM_DIR=\"$HOME/.nvm\" && . \"$NVM_DIR/nvm.sh\" && npm ci"},
+			wantRuntime:    []string{"su - ubuntu -c 'mise use --global --yes node@lts'"},
+			wantDepInstall: []string{"npm ci"},
 		},
 		{
 			name: "node with yarn.lock",
@@ -178,50 +222,139 @@ func TestDetectLanguage_RuntimeCommands(t *testing.T) {
 				"package.json": "{}",
 				"yarn.lock":    "",
 			},
-			wantRuntime:    []string{"curl -o- https://raw.githubusercontent.com/nvm-sh/nvm/v0.40.1/install.sh | bash", "export NVM_DIR=\"$HOME/.nvm\" && . \"$NVM_DIR/nvm.sh\" && nvm install --lts"},
-			wantDepInstall: []string{"export NVM_DIR=\"$HOME/.nvm\" && . \"$NVM_DIR/nvm.sh\" && yarn install --frozen-lockfile"},
+			wantRuntime:    []string{"su - ubuntu -c 'mise use --global --yes node@lts'"},
+			wantDepInstall: []string{"yarn install --frozen-lockfile"},
 		},
 		{
 			name: "node with pnpm-lock",
 			files: map[string]string{
-				"package.json":    "{}",
+				"package.json":   "{}",
 				"pnpm-lock.yaml": "",
 			},
-			wantRuntime:    []string{"curl -o- https://raw.githubusercontent.com/nvm-sh/nvm/v0.40.1/install.sh | bash", "export NVM_DIR=\"$HOME/.nvm\" && . \"$NVM_DIR/nvm.sh\" && nvm install --lts"},
-			wantDepInstall: []string{"export NVM_DIR=\"$HOME/.nvm\" && . \"$NVM_DIR/nvm.sh\" && npm install -g pnpm && pnpm install --frozen-lockfile"},
+			wantRuntime:    []string{"su - ubuntu -c 'mise use --global --yes node@lts'"},
+			wantDepInstall: []string{"npm install -g pnpm && pnpm install --frozen-lockfile"},
 		},
 		{
 			name: "go project",
 			files: map[string]string{
 				"go.mod": "module m\n\ngo 1.22.0\n",
 			},
-			wantRuntime:    []string{"curl -fsSL https://go.dev/dl/go1.22.0.linux-arm64.tar.gz | tar -C /usr/local -xz", "echo 'export PATH=$PATH:/usr/local/go/bin:$HOME/go/bin' >> $HOME/.bashrc"},
-			wantDepInstall: []string{"export PATH=$PATH:/usr/local/go/bin && go mod download"},
+			wantRuntime:    []string{"su - ubuntu -c 'mise use --global --yes go@1.22.0'"},
+			wantDepInstall: []string{"go mod download"},
+		},
+		{
+			name: "go toolchain directive wins over go directive",
+			files: map[string]string{
+				"go.mod": "module m\n\ngo 1.23.0\n\ntoolchain go1.23.5\n",
+			},
+			wantRuntime:    []string{"su - ubuntu -c 'mise use --global --yes go@1.23.5'"},
+			wantDepInstall: []string{"go mod download"},
 		},
 		{
 			name: "python with pyproject.toml",
 			files: map[string]string{
 				"pyproject.toml": "[project]\nname = \"app\"\n",
 			},
-			wantRuntime:    []string{"apt-get install -y python3 python3-pip python3-venv"},
-			wantDepInstall: []string{"python3 -m pip install -e ."},
+			wantRuntime: []string{
+				"su - ubuntu -c 'mise use --global --yes python@3'",
+				"curl -LsSf https://astral.sh/uv/install.sh | env UV_INSTALL_DIR=/usr/local/bin UV_NO_MODIFY_PATH=1 sh",
+			},
+			wantDepInstall: []string{
+				"[ -d .venv ] || uv venv --python 3 .venv",
+				". .venv/bin/activate && uv pip install -e .",
+			},
 		},
 		{
 			name: "python with requirements.txt",
 			files: map[string]string{
 				"requirements.txt": "flask\n",
 			},
-			wantRuntime:    []string{"apt-get install -y python3 python3-pip python3-venv"},
-			wantDepInstall: []string{"python3 -m pip install -r requirements.txt"},
+			wantRuntime: []string{
+				"su - ubuntu -c 'mise use --global --yes python@3'",
+				"curl -LsSf https://astral.sh/uv/install.sh | env UV_INSTALL_DIR=/usr/local/bin UV_NO_MODIFY_PATH=1 sh",
+			},
+			wantDepInstall: []string{
+				"[ -d .venv ] || uv venv --python 3 .venv",
+				". .venv/bin/activate && uv pip install -r requirements.txt",
+			},
 		},
 		{
 			name: "ruby",
 			files: map[string]string{
 				"Gemfile": "source 'https://rubygems.org'\n",
 			},
-			wantRuntime:    []string{"apt-get install -y ruby-full"},
+			wantRuntime: []string{
+				"apt-get install -y libssl-dev libyaml-dev zlib1g-dev libffi-dev libreadline-dev",
+				"su - ubuntu -c 'mise use --global --yes ruby@3'",
+			},
+			wantDepInstall: []string{"bundle install"},
+		},
+		{
+			name: "ruby with .ruby-version",
+			files: map[string]string{
+				"Gemfile":       "source 'https://rubygems.org'\n",
+				".ruby-version": "ruby-3.3.0\n",
+			},
+			wantRuntime: []string{
+				"apt-get install -y libssl-dev libyaml-dev zlib1g-dev libffi-dev libreadline-dev",
+				"su - ubuntu -c 'mise use --global --yes ruby@3.3.0'",
+			},
 			wantDepInstall: []string{"bundle install"},
 		},
+		{
+			name: "dotnet without global.json uses LTS channel",
+			files: map[string]string{
+				"MyApp.sln": "",
+			},
+			wantRuntime: []string{
+				"curl -fsSL https://dot.net/v1/dotnet-install.sh -o /tmp/dotnet-install.sh",
+				"bash /tmp/dotnet-install.sh --channel LTS --architecture arm64 --install-dir /usr/local/dotnet",
+				"ln -sf /usr/local/dotnet/dotnet /usr/local/bin/dotnet",
+				"grep -q '^DOTNET_ROOT=' /etc/environment || echo 'DOTNET_ROOT=/usr/local/dotnet' >> /etc/environment",
+			},
+			wantDepInstall: []string{"dotnet restore 'MyApp.sln'"},
+		},
+		{
+			name: "dotnet with global.json pins SDK version",
+			files: map[string]string{
+				"global.json": `{"sdk": {"version": "8.0.204", "rollForward": "latestFeature"}}`,
+				"Api.csproj":  "<Project></Project>",
+			},
+			wantRuntime: []string{
+				"curl -fsSL https://dot.net/v1/dotnet-install.sh -o /tmp/dotnet-install.sh",
+				"bash /tmp/dotnet-install.sh --version 8.0.204 --architecture arm64 --install-dir /usr/local/dotnet",
+				"ln -sf /usr/local/dotnet/dotnet /usr/local/bin/dotnet",
+				"grep -q '^DOTNET_ROOT=' /etc/environment || echo 'DOTNET_ROOT=/usr/local/dotnet' >> /etc/environment",
+			},
+			wantDepInstall: []string{"dotnet restore 'Api.csproj'"},
+		},
+		{
+			name: "dotnet prefers solution over project file",
+			files: map[string]string{
+				"Api.csproj": "<Project></Project>",
+				"All.sln":    "",
+			},
+			wantRuntime: []string{
+				"curl -fsSL https://dot.net/v1/dotnet-install.sh -o /tmp/dotnet-install.sh",
+				"bash /tmp/dotnet-install.sh --channel LTS --architecture arm64 --install-dir /usr/local/dotnet",
+				"ln -sf /usr/local/dotnet/dotnet /usr/local/bin/dotnet",
+				"grep -q '^DOTNET_ROOT=' /etc/environment || echo 'DOTNET_ROOT=/usr/local/dotnet' >> /etc/environment",
+			},
+			wantDepInstall: []string{"dotnet restore 'All.sln'"},
+		},
+		{
+			name: "dotnet global.json only has no restore",
+			files: map[string]string{
+				"global.json": `{"sdk": {"version": "9.0.100"}}`,
+			},
+			wantRuntime: []string{
+				"curl -fsSL https://dot.net/v1/dotnet-install.sh -o /tmp/dotnet-install.sh",
+				"bash /tmp/dotnet-install.sh --version 9.0.100 --architecture arm64 --install-dir /usr/local/dotnet",
+				"ln -sf /usr/local/dotnet/dotnet /usr/local/bin/dotnet",
+				"grep -q '^DOTNET_ROOT=' /etc/environment || echo 'DOTNET_ROOT=/usr/local/dotnet' >> /etc/environment",
+			},
+			wantDepInstall: nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -293,11 +426,12 @@ func TestParseNvmrc(t *testing.T) {
 	}{
 		{name: "major version", content: "20\n", want: "20"},
 		{name: "minor version", content: "20.11\n", want: "20.11"},
-		{name: "full version", content: "v20.11.1\n", want: "v20.11.1"},
-		{name: "lts", content: "lts/*\n", want: "lts/*"},
+		{name: "full version strips v", content: "v20.11.1\n", want: "20.11.1"},
+		{name: "lts alias", content: "lts/*\n", want: "lts"},
+		{name: "named lts alias", content: "lts/iron\n", want: "lts"},
 		{name: "with whitespace", content: "  18  \n", want: "18"},
-		{name: "empty file", content: "", want: "--lts"},
-		{name: "whitespace only", content: "  \n  \n", want: "--lts"},
+		{name: "empty file", content: "", want: ""},
+		{name: "whitespace only", content: "  \n  \n", want: ""},
 	}
 
 	for _, tt := range tests {
@@ -309,6 +443,30 @@ func TestParseNvmrc(t *testing.T) {
 	}
 }
 
+func TestParseGlobalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "pinned version", content: `{"sdk": {"version": "8.0.100"}}`, want: "8.0.100"},
+		{name: "with rollForward", content: `{"sdk": {"version": "6.0.420", "rollForward": "latestPatch"}}`, want: "6.0.420"},
+		{name: "no sdk section", content: `{"msbuild-sdks": {}}`, want: ""},
+		{name: "malformed", content: `{"sdk": `, want: ""},
+		{name: "empty", content: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := parseGlobalJSON([]byte(tt.content))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func TestLockfileForLanguage(t *testing.T) {
 	t.Parallel()
 
@@ -348,6 +506,12 @@ func TestLockfileForLanguage(t *testing.T) {
 			files:    map[string]string{"go.sum": "checksums"},
 			wantFile: "go.sum",
 		},
+		{
+			name:     "python uv.lock",
+			lang:     Python,
+			files:    map[string]string{"uv.lock": "version = 1"},
+			wantFile: "uv.lock",
+		},
 		{
 			name:     "python no lockfile",
 			lang:     Python,
@@ -360,6 +524,12 @@ func TestLockfileForLanguage(t *testing.T) {
 			files:    map[string]string{"Gemfile.lock": "lock"},
 			wantFile: "Gemfile.lock",
 		},
+		{
+			name:     "dotnet packages.lock.json",
+			lang:     DotNet,
+			files:    map[string]string{"packages.lock.json": "{}"},
+			wantFile: "packages.lock.json",
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,521 @@
+package provision
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Devcontainer is the pseudo-language for .devcontainer/devcontainer.json.
+// Its features become runtime installs, its lifecycle commands become
+// dependency installs and its env becomes shell init.
+const Devcontainer LanguageName = "devcontainer"
+
+// devcontainerCLI runs the devcontainer CLI (a mise-managed npm package)
+// as root: the ubuntu user's SSH session may predate its docker group
+// membership, and may predate mise shims on PATH.
+var devcontainerCLI = fmt.Sprintf(`sudo env "PATH=%s:$PATH" devcontainer`, miseShims)
+
+// devcontainerPaths are the devcontainer.json locations, in lookup order.
+var devcontainerPaths = []string{
+	".devcontainer/devcontainer.json",
+	".devcontainer.json",
+}
+
+// DevcontainerConfig is the subset of devcontainer.json that yeager applies.
+type DevcontainerConfig struct {
+	Path          string                     `json:"-"` // relative to the project dir
+	Features      map[string]json.RawMessage `json:"features"`
+	OnCreate      json.RawMessage            `json:"onCreateCommand"`
+	UpdateContent json.RawMessage            `json:"updateContentCommand"`
+	PostCreate    json.RawMessage            `json:"postCreateCommand"`
+	ContainerEnv  map[string]*string         `json:"containerEnv"`
+	RemoteEnv     map[string]*string         `json:"remoteEnv"`
+
+	dir string
+}
+
+// LoadDevcontainer reads devcontainer.json from dir.
+// Returns nil, nil if the project has none.
+func LoadDevcontainer(dir string) (*DevcontainerConfig, error) {
+	for _, rel := range devcontainerPaths {
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			continue
+		}
+		var dc DevcontainerConfig
+		if err := json.Unmarshal(stripJSONC(content), &dc); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", rel, err)
+		}
+		dc.Path = rel
+		dc.dir = dir
+		return &dc, nil
+	}
+	return nil, nil
+}
+
+// devcontainerFeature is a parsed entry of the "features" object.
+type devcontainerFeature struct {
+	ID      string // short feature name, e.g. "node" for ghcr.io/devcontainers/features/node:1
+	Ref     string // the full reference as written
+	Version string // the "version" option, or the value itself in the legacy string form
+	Options map[string]any
+}
+
+// features returns the configured features sorted by reference.
+func (dc *DevcontainerConfig) features() []devcontainerFeature {
+	var feats []devcontainerFeature
+	for ref, raw := range dc.Features {
+		f := devcontainerFeature{Ref: ref, ID: featureID(ref)}
+		var legacy string
+		if json.Unmarshal(raw, &legacy) == nil {
+			f.Version = legacy
+		} else if json.Unmarshal(raw, &f.Options) == nil {
+			f.Version, _ = f.Options["version"].(string)
+		}
+		feats = append(feats, f)
+	}
+	sort.Slice(feats, func(i, j int) bool { return feats[i].Ref < feats[j].Ref })
+	return feats
+}
+
+// featureID reduces a feature reference to its name:
+// "ghcr.io/devcontainers/features/node:1" → "node".
+func featureID(ref string) string {
+	id := ref[strings.LastIndex(ref, "/")+1:]
+	if i := strings.IndexAny(id, ":@"); i >= 0 {
+		id = id[:i]
+	}
+	return id
+}
+
+// featureLanguages maps runtime features to the language they install.
+var featureLanguages = map[string]LanguageName{
+	"node":   Node,
+	"go":     Go,
+	"python": Python,
+	"ruby":   Ruby,
+	"rust":   Rust,
+	"dotnet": DotNet,
+}
+
+// featureTools maps tool features to the mise tools that install them.
+var featureTools = map[string][]string{
+	"github-cli":            {"gh"},
+	"aws-cli":               {"aws-cli"},
+	"terraform":             {"terraform"},
+	"java":                  {"java"},
+	"kubectl-helm-minikube": {"kubectl", "helm"},
+}
+
+// featureNoops are features the base image already covers.
+var featureNoops = map[string]bool{
+	"common-utils": true,
+	"git":          true,
+	"sshd":         true,
+}
+
+// dockerInstall installs Docker and lets the ubuntu user use it.
+var dockerInstall = []string{
+	"apt-get install -y docker.io docker-compose-v2",
+	"usermod -aG docker ubuntu",
+	"systemctl enable --now docker",
+}
+
+// aptPackageRe matches apt package names that are safe to interpolate.
+var aptPackageRe = regexp.MustCompile(`^[a-z0-9][a-z0-9.+-]*$`)
+
+// featureVersion returns a mise-usable version for a feature's version option.
+// Unpinned or OS-provided versions fall back to def.
+func featureVersion(v, def string) string {
+	switch v {
+	case "", "os-provided", "current":
+		return def
+	}
+	if !validVersion(v) {
+		return def
+	}
+	return v
+}
+
+// devcontainerPins returns the runtime versions pinned by devcontainer
+// features in dir. Floating versions ("latest", "lts") don't pin.
+func devcontainerPins(dir string) versionPins {
+	dc, err := LoadDevcontainer(dir)
+	if err != nil || dc == nil {
+		return nil
+	}
+	pins := versionPins{}
+	for _, f := range dc.features() {
+		lang, ok := featureLanguages[f.ID]
+		if !ok {
+			continue
+		}
+		switch f.Version {
+		case "", "latest", "lts", "os-provided", "current", "none":
+			continue
+		}
+		if validVersion(f.Version) {
+			pins[lang] = f.Version
+		}
+	}
+	return pins
+}
+
+// Language translates the devcontainer into VM provisioning. Features for
+// runtimes already in detected are skipped (their version is pinned through
+// readVersionPins instead). Features with no translation are returned as
+// unsupported.
+func (dc *DevcontainerConfig) Language(detected []Language) (Language, []string) {
+	have := make(map[LanguageName]bool, len(detected))
+	for _, lang := range detected {
+		have[lang.Name] = true
+	}
+
+	var runtime, unsupported []string
+	var tool string
+	use := func(t, version string) {
+		runtime = append(runtime, miseUse(t, version))
+		if tool == "" {
+			tool = t
+		}
+	}
+
+	for _, f := range dc.features() {
+		if f.Version == "none" {
+			continue
+		}
+		if lang, ok := featureLanguages[f.ID]; ok {
+			if have[lang] {
+				continue
+			}
+			switch lang {
+			case Node:
+				use("node", featureVersion(f.Version, defaultNodeVersion))
+			case Go:
+				use("go", featureVersion(f.Version, "latest"))
+			case Python:
+				use("python", featureVersion(f.Version, defaultPythonVersion))
+			case Ruby:
+				runtime = append(runtime, "apt-get install -y libssl-dev libyaml-dev zlib1g-dev libffi-dev libreadline-dev")
+				use("ruby", featureVersion(f.Version, defaultRubyVersion))
+			case Rust:
+				use("rust", featureVersion(f.Version, defaultRustVersion))
+			case DotNet:
+				v := featureVersion(f.Version, "")
+				if v == "latest" || v == "lts" {
+					v = ""
+				}
+				runtime = append(runtime, dotnetRuntimeInstall(v)...)
+			}
+			continue
+		}
+		if tools, ok := featureTools[f.ID]; ok {
+			// The version option applies to the primary tool only.
+			for i, t := range tools {
+				v := "latest"
+				if i == 0 {
+					v = featureVersion(f.Version, "latest")
+				}
+				use(t, v)
+			}
+			continue
+		}
+		switch {
+		case featureNoops[f.ID]:
+		case f.ID == "docker-in-docker" || f.ID == "docker-outside-of-docker":
+			runtime = append(runtime, dockerInstall...)
+		case f.ID == "git-lfs":
+			runtime = append(runtime, "apt-get install -y git-lfs")
+		case f.ID == "apt-packages":
+			pkgs, ok := aptFeaturePackages(f.Options)
+			if !ok {
+				unsupported = append(unsupported, f.Ref)
+				continue
+			}
+			runtime = append(runtime, "apt-get install -y "+strings.Join(pkgs, " "))
+		default:
+			unsupported = append(unsupported, f.Ref)
+		}
+	}
+
+	var deps []string
+	for _, raw := range []json.RawMessage{dc.OnCreate, dc.UpdateContent, dc.PostCreate} {
+		deps = append(deps, lifecycleCommands(raw)...)
+	}
+
+	return Language{
+		Name:           Devcontainer,
+		DisplayName:    fmt.Sprintf("devcontainer (%s)", dc.Path),
+		Version:        commandsHash(runtime, deps),
+		Tool:           tool,
+		RuntimeInstall: runtime,
+		DepInstall:     deps,
+		Env:            dc.env(),
+	}, unsupported
+}
+
+// ContainerLanguage provisions the VM to build the devcontainer image and
+// run commands inside it with the devcontainer CLI, which applies features,
+// lifecycle commands and env itself.
+func (dc *DevcontainerConfig) ContainerLanguage() Language {
+	content, _ := os.ReadFile(filepath.Join(dc.dir, filepath.FromSlash(dc.Path)))
+
+	runtime := append([]string{}, dockerInstall...)
+	runtime = append(runtime,
+		miseUse("node", defaultNodeVersion),
+		miseUse("npm:@devcontainers/cli", "latest"),
+	)
+
+	return Language{
+		Name:           Devcontainer,
+		DisplayName:    fmt.Sprintf("devcontainer (%s, container)", dc.Path),
+		Version:        commandsHash([]string{string(content)}, nil),
+		Tool:           "node",
+		RuntimeInstall: runtime,
+		// Rebuild on reprovision so devcontainer.json edits take effect.
+		DepInstall: []string{fmt.Sprintf("%s up --workspace-folder %s --remove-existing-container", devcontainerCLI, projectDir)},
+		// Start the container if it isn't running (e.g. after the VM was
+		// stopped). Cheap when it already is.
+		Prepare: []string{fmt.Sprintf("%s up --workspace-folder %s", devcontainerCLI, projectDir)},
+		Wrapper: fmt.Sprintf("%s exec --workspace-folder %s", devcontainerCLI, projectDir),
+	}
+}
+
+// aptFeaturePackages returns the validated packages option of the
+// apt-packages feature ("packages": "curl,jq").
+func aptFeaturePackages(opts map[string]any) ([]string, bool) {
+	list, _ := opts["packages"].(string)
+	var pkgs []string
+	for _, p := range strings.Split(list, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !aptPackageRe.MatchString(p) {
+			return nil, false
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs, len(pkgs) > 0
+}
+
+// lifecycleCommands converts a lifecycle command to shell commands.
+// A string runs in a shell, an array is an exec-style argv, and an object
+// holds named commands (run in name order rather than in parallel).
+func lifecycleCommands(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		if s == "" {
+			return nil
+		}
+		return []string{s}
+	}
+	var argv []string
+	if json.Unmarshal(raw, &argv) == nil {
+		if len(argv) == 0 {
+			return nil
+		}
+		quoted := make([]string, len(argv))
+		for i, a := range argv {
+			quoted[i] = shellQuote(a)
+		}
+		return []string{strings.Join(quoted, " ")}
+	}
+	var named map[string]json.RawMessage
+	if json.Unmarshal(raw, &named) == nil {
+		names := make([]string, 0, len(named))
+		for name := range named {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var cmds []string
+		for _, name := range names {
+			cmds = append(cmds, lifecycleCommands(named[name])...)
+		}
+		return cmds
+	}
+	return nil
+}
+
+// envNameRe matches valid environment variable names.
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// env returns "NAME=word" assignments for containerEnv then remoteEnv,
+// so remoteEnv wins. Null values (which unset a variable) are skipped.
+// Values may hold secrets resolved from ${localEnv:X}, so they go through
+// the run's env file rather than the command line.
+func (dc *DevcontainerConfig) env() []string {
+	var cmds []string
+	for _, env := range []map[string]*string{dc.ContainerEnv, dc.RemoteEnv} {
+		names := make([]string, 0, len(env))
+		for name, v := range env {
+			if v != nil && envNameRe.MatchString(name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			cmds = append(cmds, name+"="+dc.envValue(*env[name]))
+		}
+	}
+	return cmds
+}
+
+// envVarRe matches devcontainer variable references, e.g. ${localEnv:HOME}.
+var envVarRe = regexp.MustCompile(`\$\{([A-Za-z]+)(?::([A-Za-z_][A-Za-z0-9_]*))?(?::([^}]*))?\}`)
+
+// envValue converts a devcontainer env value to a shell word.
+// ${localEnv:X} is resolved on this machine, ${containerEnv:X} is expanded
+// on the VM, and workspace folder references point at the synced project.
+// Everything else is quoted literally.
+func (dc *DevcontainerConfig) envValue(v string) string {
+	var b, lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			b.WriteString(shellQuote(lit.String()))
+			lit.Reset()
+		}
+	}
+
+	last := 0
+	for _, m := range envVarRe.FindAllStringSubmatchIndex(v, -1) {
+		lit.WriteString(v[last:m[0]])
+		last = m[1]
+
+		kind := v[m[2]:m[3]]
+		var name, def string
+		if m[4] >= 0 {
+			name = v[m[4]:m[5]]
+		}
+		if m[6] >= 0 {
+			def = v[m[6]:m[7]]
+		}
+		switch kind {
+		case "localEnv":
+			val, ok := os.LookupEnv(name)
+			if !ok {
+				val = def
+			}
+			lit.WriteString(val)
+		case "containerEnv":
+			flush()
+			if m[6] >= 0 {
+				fmt.Fprintf(&b, `"${%s:-%s}"`, name, dquoteEscape(def))
+			} else {
+				fmt.Fprintf(&b, `"${%s}"`, name)
+			}
+		case "containerWorkspaceFolder", "localWorkspaceFolder":
+			lit.WriteString(projectDir)
+		case "containerWorkspaceFolderBasename", "localWorkspaceFolderBasename":
+			lit.WriteString(filepath.Base(dc.dir))
+		default:
+			lit.WriteString(v[m[0]:m[1]])
+		}
+	}
+	lit.WriteString(v[last:])
+	flush()
+	if b.Len() == 0 {
+		return "''"
+	}
+	return b.String()
+}
+
+// dquoteEscape escapes s for use inside double quotes.
+func dquoteEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`").Replace(s)
+}
+
+// commandsHash fingerprints provisioning commands so changes can be
+// detected like a runtime version bump.
+func commandsHash(runtime, deps []string) string {
+	h := sha256.New()
+	for _, cmds := range [][]string{runtime, deps} {
+		for _, c := range cmds {
+			h.Write([]byte(c))
+			h.Write([]byte{0})
+		}
+		h.Write([]byte{1})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// stripJSONC removes // and /* */ comments and trailing commas from
+// JSON-with-comments, as used by devcontainer.json.
+func stripJSONC(src []byte) []byte {
+	return stripTrailingCommas(stripJSONComments(src))
+}
+
+func stripJSONComments(src []byte) []byte {
+	var out bytes.Buffer
+	inString := false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case inString:
+			out.WriteByte(c)
+			if c == '\\' && i+1 < len(src) {
+				i++
+				out.WriteByte(src[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			out.WriteByte(c)
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i+1 < len(src) && src[i+1] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			i += 2
+			for i+1 < len(src) && !(src[i] == '*' && src[i+1] == '/') {
+				i++
+			}
+			i++
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.Bytes()
+}
+
+func stripTrailingCommas(src []byte) []byte {
+	var out bytes.Buffer
+	inString := false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case inString:
+			out.WriteByte(c)
+			if c == '\\' && i+1 < len(src) {
+				i++
+				out.WriteByte(src[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			out.WriteByte(c)
+		case c == ',':
+			rest := bytes.TrimLeft(src[i+1:], " \t\r\n")
+			if len(rest) > 0 && (rest[0] == '}' || rest[0] == ']') {
+				continue
+			}
+			out.WriteByte(c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.Bytes()
+}
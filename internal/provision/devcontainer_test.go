@@ -0,0 +1,237 @@
+package provision
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDevcontainer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("none", func(t *testing.T) {
+		t.Parallel()
+		dc, err := LoadDevcontainer(t.TempDir())
+		require.NoError(t, err)
+		assert.Nil(t, dc)
+	})
+
+	t.Run("jsonc with comments and trailing commas", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			".devcontainer/devcontainer.json": `{
+	// Base image
+	"image": "mcr.microsoft.com/devcontainers/base:ubuntu",
+	/* tools */
+	"features": {
+		"ghcr.io/devcontainers/features/node:1": { "version": "20" },
+	},
+	"remoteEnv": { "URL": "http://localhost//api" },
+}`,
+		})
+		dc, err := LoadDevcontainer(dir)
+		require.NoError(t, err)
+		require.NotNil(t, dc)
+		assert.Equal(t, ".devcontainer/devcontainer.json", dc.Path)
+		assert.Len(t, dc.Features, 1)
+		require.NotNil(t, dc.RemoteEnv["URL"])
+		assert.Equal(t, "http://localhost//api", *dc.RemoteEnv["URL"])
+	})
+
+	t.Run("root devcontainer.json", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{".devcontainer.json": `{"postCreateCommand": "make"}`})
+		dc, err := LoadDevcontainer(dir)
+		require.NoError(t, err)
+		require.NotNil(t, dc)
+		assert.Equal(t, ".devcontainer.json", dc.Path)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{".devcontainer/devcontainer.json": `{"features": `})
+		_, err := LoadDevcontainer(dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), ".devcontainer/devcontainer.json")
+	})
+}
+
+func TestFeatureID(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "node", featureID("ghcr.io/devcontainers/features/node:1"))
+	assert.Equal(t, "apt-packages", featureID("ghcr.io/devcontainers-extra/features/apt-packages@sha256:abc"))
+	assert.Equal(t, "go", featureID("go"))
+}
+
+func TestDevcontainerLanguage_Features(t *testing.T) {
+	t.Parallel()
+
+	dc := &DevcontainerConfig{
+		Path: ".devcontainer/devcontainer.json",
+		Features: map[string]json.RawMessage{
+			"ghcr.io/devcontainers/features/node:1":               json.RawMessage(`{"version": "20"}`),
+			"ghcr.io/devcontainers/features/go:1":                 json.RawMessage(`{}`),
+			"ghcr.io/devcontainers/features/python:1":             json.RawMessage(`{"version": "none"}`),
+			"ghcr.io/devcontainers/features/terraform:1":          json.RawMessage(`{"version": "1.7.5"}`),
+			"ghcr.io/devcontainers/features/docker-in-docker:2":   json.RawMessage(`{}`),
+			"ghcr.io/devcontainers/features/common-utils:2":       json.RawMessage(`{}`),
+			"ghcr.io/devcontainers-extra/features/apt-packages:1": json.RawMessage(`{"packages": "libpq-dev, redis-tools"}`),
+			"ghcr.io/devcontainers/features/desktop-lite:1":       json.RawMessage(`{}`),
+		},
+	}
+
+	lang, unsupported := dc.Language([]Language{{Name: Go}})
+	assert.Equal(t, Devcontainer, lang.Name)
+	assert.Equal(t, "devcontainer (.devcontainer/devcontainer.json)", lang.DisplayName)
+	assert.Equal(t, []string{"ghcr.io/devcontainers/features/desktop-lite:1"}, unsupported)
+
+	runtime := strings.Join(lang.RuntimeInstall, "\n")
+	assert.Contains(t, runtime, miseUse("node", "20"))
+	assert.Contains(t, runtime, miseUse("terraform", "1.7.5"))
+	assert.Contains(t, runtime, "apt-get install -y docker.io")
+	assert.Contains(t, runtime, "apt-get install -y libpq-dev redis-tools")
+	assert.NotContains(t, runtime, "go@", "detected runtimes are installed by their language")
+	assert.NotContains(t, runtime, "python", "version none disables a feature")
+	assert.NotEmpty(t, lang.Tool, "mise is set up for feature tools")
+}
+
+func TestDevcontainerLanguage_RejectsUnsafeAptPackages(t *testing.T) {
+	t.Parallel()
+
+	dc := &DevcontainerConfig{Features: map[string]json.RawMessage{
+		"ghcr.io/devcontainers-extra/features/apt-packages:1": json.RawMessage(`{"packages": "curl; rm -rf /"}`),
+	}}
+	lang, unsupported := dc.Language(nil)
+	assert.Empty(t, lang.RuntimeInstall)
+	assert.Len(t, unsupported, 1)
+}
+
+func TestDevcontainerLanguage_LifecycleAndEnv(t *testing.T) {
+	t.Parallel()
+
+	dc := &DevcontainerConfig{
+		Path:          ".devcontainer/devcontainer.json",
+		OnCreate:      json.RawMessage(`"make deps"`),
+		UpdateContent: json.RawMessage(`["npm", "run", "build it"]`),
+		PostCreate:    json.RawMessage(`{"b": "echo b", "a": ["echo", "a"]}`),
+		ContainerEnv:  map[string]*string{"APP_ENV": strPtr("dev")},
+		RemoteEnv: map[string]*string{
+			"APP_ENV": strPtr("test"),
+			"PATH":    strPtr("${containerEnv:PATH}:${containerWorkspaceFolder}/bin"),
+			"UNSET":   nil,
+			"BAD-KEY": strPtr("x"),
+		},
+		dir: "/home/user/myproject",
+	}
+
+	lang, _ := dc.Language(nil)
+	assert.Equal(t, []string{"make deps", "'npm' 'run' 'build it'", "'echo' 'a'", "echo b"}, lang.DepInstall)
+	assert.Equal(t, []string{
+		"APP_ENV='dev'",
+		"APP_ENV='test'",
+		`PATH="${PATH}"':/home/ubuntu/project/bin'`,
+	}, lang.Env)
+	assert.Empty(t, lang.ShellInit, "env stays out of the command line")
+}
+
+func TestDevcontainerLanguage_VersionTracksChanges(t *testing.T) {
+	t.Parallel()
+
+	a := &DevcontainerConfig{PostCreate: json.RawMessage(`"make"`)}
+	b := &DevcontainerConfig{PostCreate: json.RawMessage(`"make all"`)}
+	la, _ := a.Language(nil)
+	lb, _ := b.Language(nil)
+	la2, _ := a.Language(nil)
+	assert.NotEqual(t, la.Version, lb.Version)
+	assert.Equal(t, la.Version, la2.Version)
+}
+
+func TestDevcontainerEnvValue(t *testing.T) {
+	t.Setenv("YG_TEST_TOKEN", "s3cr3t")
+
+	dc := &DevcontainerConfig{dir: "/home/user/myproject"}
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "'plain'"},
+		{"", "''"},
+		{"it's", `'it'\''s'`},
+		{"${localEnv:YG_TEST_TOKEN}", "'s3cr3t'"},
+		{"${localEnv:YG_TEST_MISSING:fallback}", "'fallback'"},
+		{"${containerEnv:HOME:/root}", `"${HOME:-/root}"`},
+		{"${containerWorkspaceFolderBasename}", "'myproject'"},
+		{"$(whoami)", "'$(whoami)'"},
+		{"${unknownVar}", "'${unknownVar}'"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, dc.envValue(tt.in), tt.in)
+	}
+}
+
+func TestDevcontainerPins(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"package.json": "{}",
+		".nvmrc":       "18\n",
+		".devcontainer/devcontainer.json": `{"features": {
+			"ghcr.io/devcontainers/features/node:1": {"version": "20"},
+			"ghcr.io/devcontainers/features/go:1": {"version": "latest"}
+		}}`,
+	})
+
+	assert.Equal(t, versionPins{Node: "20"}, readVersionPins(dir, false))
+	assert.Empty(t, readVersionPins(dir, true), "devcontainer.ignore")
+
+	node := findLanguage(DetectLanguages(dir), Node)
+	require.NotNil(t, node)
+	assert.Equal(t, "20", node.Version)
+}
+
+func TestDevcontainerContainerLanguage(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".devcontainer/devcontainer.json": `{"image": "ubuntu"}`})
+	dc, err := LoadDevcontainer(dir)
+	require.NoError(t, err)
+
+	lang := dc.ContainerLanguage()
+	assert.Contains(t, lang.RuntimeInstall, "apt-get install -y docker.io docker-compose-v2")
+	assert.Contains(t, lang.RuntimeInstall, miseUse("npm:@devcontainers/cli", "latest"))
+	require.Len(t, lang.DepInstall, 1)
+	assert.Contains(t, lang.DepInstall[0], "devcontainer up --workspace-folder /home/ubuntu/project --remove-existing-container")
+	assert.Empty(t, lang.ShellInit)
+
+	require.Len(t, lang.Prepare, 1)
+	assert.Contains(t, lang.Prepare[0], "devcontainer up --workspace-folder /home/ubuntu/project")
+	assert.Contains(t, lang.Wrapper, "devcontainer exec --workspace-folder /home/ubuntu/project")
+	assert.Contains(t, lang.Wrapper, miseShims)
+	assert.Equal(t, lang.Wrapper, ExecWrapper([]Language{lang}))
+}
+
+func TestStripJSONC(t *testing.T) {
+	t.Parallel()
+
+	in := `{
+  "a": "keep // this", // drop
+  "b": "and /* this */", /* drop
+  too */ "c": [1, 2,],
+}`
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(stripJSONC([]byte(in)), &got))
+	assert.Equal(t, "keep // this", got["a"])
+	assert.Equal(t, "and /* this */", got["b"])
+	assert.Equal(t, []any{1.0, 2.0}, got["c"])
+}
+
+func strPtr(s string) *string { return &s }
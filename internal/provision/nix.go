@@ -0,0 +1,103 @@
+package provision
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Nix is the pseudo-language for projects that pin their toolchain with
+// Nix: every remote command runs inside the project's Nix dev shell, which
+// provides the runtimes, so none are installed on the VM itself.
+const Nix LanguageName = "nix"
+
+const (
+	// nixBin holds the nix commands of a multi-user install. Non-login SSH
+	// shells don't source the installer's profile script.
+	nixBin = "/nix/var/nix/profiles/default/bin"
+
+	// nixEnvCache holds the evaluated dev shells, keyed by the hash of
+	// the Nix files. They're GC roots, so the store keeps their inputs.
+	nixEnvCache = "/home/ubuntu/.cache/yeager/nix"
+
+	// nixShellHelper runs a command inside a cached nix-shell derivation.
+	// nix-shell --run takes a single string, so the helper re-quotes its
+	// arguments; this lets it prefix `bash -c '...'` like any wrapper.
+	nixShellHelper = "/usr/local/bin/yeager-nix-shell"
+)
+
+// nixFiles are the Nix entry points, in lookup order. A flake wins.
+var nixFiles = []string{"flake.nix", "shell.nix", "default.nix"}
+
+// nixInstall installs multi-user Nix with flakes enabled. The store lives
+// on the root volume, so it survives VM stops.
+var nixInstall = []string{
+	"[ -d /nix/store ] || (curl -fsSL https://install.determinate.systems/nix | sh -s -- install --no-confirm)",
+	`grep -qs '^trusted-users' /etc/nix/nix.custom.conf || echo 'trusted-users = root ubuntu' >> /etc/nix/nix.custom.conf`,
+	"systemctl restart nix-daemon || true",
+}
+
+// nixShellHelperInstall writes nixShellHelper.
+var nixShellHelperInstall = fmt.Sprintf(
+	`printf '%%s\n' '#!/bin/bash' 'drv=$(readlink -f "$1"); shift' 'exec %s/nix-shell "$drv" --run "$(printf "%%q " "$@")"' > %s && chmod +x %s`,
+	nixBin, nixShellHelper, nixShellHelper)
+
+// nixFile returns the Nix entry point in dir, or empty string if none.
+func nixFile(dir string) string {
+	for _, name := range nixFiles {
+		if fileExists(dir, name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// detectNix returns the Nix environment for a project with a flake.nix,
+// shell.nix or default.nix at its root. The other detected languages
+// aren't installed on the VM: the dev shell provides their runtimes, and
+// their dependency installs and shell init run inside it.
+//
+// Evaluating a dev shell is slow, so it's done once per version of the
+// Nix files (flake.lock included) and cached as a GC root: a flake's as
+// a `nix develop --profile`, a nix-shell's as its instantiated derivation.
+func detectNix(dir, file string, langs []Language) Language {
+	inputs := []string{file}
+	for _, name := range []string{file, "flake.lock"} {
+		content, _ := os.ReadFile(filepath.Join(dir, name))
+		inputs = append(inputs, string(content))
+	}
+	version := commandsHash(inputs, nil)
+	cached := fmt.Sprintf("%s/env-%s", nixEnvCache, version)
+
+	runtime := append([]string{}, nixInstall...)
+	var build, wrapper string
+	if file == "flake.nix" {
+		build = fmt.Sprintf("%s/nix develop --profile %s %s -c true", nixBin, cached, projectDir)
+		wrapper = fmt.Sprintf("%s/nix develop %s -c", nixBin, cached)
+	} else {
+		runtime = append(runtime, nixShellHelperInstall)
+		// <nixpkgs> resolves through the flake registry when there are no channels.
+		build = fmt.Sprintf(`NIX_PATH="${NIX_PATH:-nixpkgs=flake:nixpkgs}" %s/nix-instantiate %s/%s --add-root %s --indirect >/dev/null`,
+			nixBin, projectDir, file, cached)
+		wrapper = fmt.Sprintf("%s %s", nixShellHelper, cached)
+	}
+	build = fmt.Sprintf("mkdir -p %s && %s", nixEnvCache, build)
+
+	lang := Language{
+		Name:           Nix,
+		DisplayName:    fmt.Sprintf("Nix (%s)", file),
+		Version:        version,
+		RuntimeInstall: runtime,
+		DepInstall:     []string{build},
+		// Rebuild the cache if it's missing, e.g. after a garbage collection.
+		Prepare: []string{fmt.Sprintf("[ -e %s ] || (%s)", cached, build)},
+		Wrapper: wrapper,
+	}
+	for _, l := range langs {
+		for _, cmd := range l.DepInstall {
+			lang.DepInstall = append(lang.DepInstall, fmt.Sprintf("%s bash -c %s", wrapper, shellQuote(cmd)))
+		}
+		lang.ShellInit = append(lang.ShellInit, l.ShellInit...)
+	}
+	return lang
+}
@@ -0,0 +1,79 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectNix_Flake(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"flake.nix":    "{ outputs = { self }: { }; }\n",
+		"flake.lock":   `{"nodes": {}}`,
+		"shell.nix":    "{ }\n",
+		"package.json": "{}",
+	})
+
+	langs := DetectLanguages(dir)
+	require.Len(t, langs, 1, "the dev shell provides the runtimes")
+	lang := langs[0]
+	assert.Equal(t, Nix, lang.Name)
+	assert.Equal(t, "Nix (flake.nix)", lang.DisplayName)
+	assert.Empty(t, lang.Tool)
+	assert.Contains(t, strings.Join(lang.RuntimeInstall, "\n"), "install.determinate.systems/nix")
+
+	cached := nixEnvCache + "/env-" + lang.Version
+	assert.Equal(t, nixBin+"/nix develop "+cached+" -c", lang.Wrapper)
+	require.Len(t, lang.DepInstall, 2)
+	assert.Contains(t, lang.DepInstall[0], "nix develop --profile "+cached+" /home/ubuntu/project -c true")
+	assert.Equal(t, lang.Wrapper+" bash -c 'npm install'", lang.DepInstall[1], "dependency installs run in the dev shell")
+	require.Len(t, lang.Prepare, 1)
+	assert.True(t, strings.HasPrefix(lang.Prepare[0], "[ -e "+cached+" ] || "))
+	assert.Empty(t, ShellInit(langs))
+}
+
+func TestDetectNix_Shell(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"shell.nix": "{ pkgs ? import <nixpkgs> {} }: pkgs.mkShell { }\n"})
+
+	langs := DetectLanguages(dir)
+	require.Len(t, langs, 1)
+	lang := langs[0]
+	assert.Equal(t, "Nix (shell.nix)", lang.DisplayName)
+	assert.Contains(t, lang.RuntimeInstall, nixShellHelperInstall)
+	assert.Equal(t, nixShellHelper+" "+nixEnvCache+"/env-"+lang.Version, lang.Wrapper)
+	require.Len(t, lang.DepInstall, 1)
+	assert.Contains(t, lang.DepInstall[0], "nix-instantiate /home/ubuntu/project/shell.nix --add-root")
+	assert.Contains(t, lang.DepInstall[0], "nixpkgs=flake:nixpkgs")
+}
+
+func TestDetectNix_VersionTracksLockfile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"flake.nix": "{ }\n", "flake.lock": `{"version": 1}`})
+	a := DetectLanguages(dir)[0]
+	writeFiles(t, dir, map[string]string{"flake.lock": `{"version": 2}`})
+	b := DetectLanguages(dir)[0]
+	assert.NotEqual(t, a.Version, b.Version)
+	assert.NotEqual(t, a.Wrapper, b.Wrapper, "each lock gets its own cached dev shell")
+}
+
+func TestDetectNix_KeepsShellInit(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"default.nix": "{ }\n", "pyproject.toml": "[project]\nname = \"x\"\n"})
+
+	langs := DetectLanguages(dir)
+	require.Len(t, langs, 1)
+	assert.Equal(t, "Nix (default.nix)", langs[0].DisplayName)
+	assert.Equal(t, []string{"if [ -f .venv/bin/activate ]; then . .venv/bin/activate; fi"}, ShellInit(langs))
+}
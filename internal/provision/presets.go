@@ -0,0 +1,180 @@
+package provision
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gridlhq/yeager/internal/config"
+)
+
+// Preset is a named setup recipe enabled with [setup] presets = ["name"]
+// or ["name@version"]. It contributes to the same places [setup] and the
+// detected languages do.
+type Preset struct {
+	Name           string
+	Description    string
+	DefaultVersion string // empty if the preset can't be pinned
+	// Each func receives the resolved version.
+	Packages       func(version string) []string // apt packages, installed by cloud-init
+	RuntimeInstall func(version string) []string // cloud-init commands, run as root before the first sync
+	Run            func(version string) []string // post-sync commands, run like [setup] run
+	SyncExclude    []string                      // added to [sync] exclude
+	Artifacts      []string                      // added to [artifacts] paths
+}
+
+// ResolvedPreset is a preset with its version and commands filled in.
+type ResolvedPreset struct {
+	Name           string
+	Version        string
+	Packages       []string
+	RuntimeInstall []string
+	Run            []string
+	SyncExclude    []string
+	Artifacts      []string
+}
+
+// presetCatalog lists the built-in presets. The VMs are arm64 (Graviton),
+// so downloaded binaries use arm64 builds.
+var presetCatalog = map[string]Preset{
+	"playwright": {
+		Name:           "playwright",
+		Description:    "Playwright browsers and their system dependencies (needs Node)",
+		DefaultVersion: "1.48.2",
+		Run: func(v string) []string {
+			return []string{fmt.Sprintf("npx -y playwright@%s install --with-deps", v)}
+		},
+		SyncExclude: []string{"playwright-report/", "test-results/"},
+		Artifacts:   []string{"playwright-report/index.html"},
+	},
+	"docker": {
+		Name:        "docker",
+		Description: "Docker engine and compose, usable without sudo",
+		Packages:    func(string) []string { return []string{"docker.io", "docker-compose-v2"} },
+		RuntimeInstall: func(string) []string {
+			return []string{"usermod -aG docker ubuntu"}
+		},
+	},
+	"postgres-client": {
+		Name:        "postgres-client",
+		Description: "psql, pg_dump and pg_isready",
+		Packages:    func(string) []string { return []string{"postgresql-client"} },
+	},
+	"protoc": {
+		Name:           "protoc",
+		Description:    "Protocol Buffers compiler",
+		DefaultVersion: "28.3",
+		RuntimeInstall: func(v string) []string {
+			return []string{
+				fmt.Sprintf("curl -fsSL -o /tmp/protoc.zip https://github.com/protocolbuffers/protobuf/releases/download/v%s/protoc-%s-linux-aarch_64.zip", v, v),
+				"unzip -o /tmp/protoc.zip -d /usr/local bin/protoc 'include/*' && chmod 755 /usr/local/bin/protoc && rm /tmp/protoc.zip",
+			}
+		},
+	},
+	"awscli": {
+		Name:           "awscli",
+		Description:    "AWS CLI v2",
+		DefaultVersion: "2.18.10",
+		RuntimeInstall: func(v string) []string {
+			return []string{
+				fmt.Sprintf("curl -fsSL -o /tmp/awscliv2.zip https://awscli.amazonaws.com/awscli-exe-linux-aarch64-%s.zip", v),
+				"unzip -q -o /tmp/awscliv2.zip -d /tmp && /tmp/aws/install --update && rm -rf /tmp/awscliv2.zip /tmp/aws",
+			}
+		},
+	},
+}
+
+// presetVersionRe matches pinned versions, which end up in shell commands.
+var presetVersionRe = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._-]*$`)
+
+// PresetNames returns the names of the built-in presets, sorted.
+func PresetNames() []string {
+	names := make([]string, 0, len(presetCatalog))
+	for name := range presetCatalog {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupPreset returns the built-in preset with the given name.
+func LookupPreset(name string) (Preset, bool) {
+	p, ok := presetCatalog[name]
+	return p, ok
+}
+
+// ResolvePresets resolves "name" or "name@version" entries from
+// [setup] presets, in order.
+func ResolvePresets(specs []string) ([]ResolvedPreset, error) {
+	var resolved []ResolvedPreset
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		name, version, pinned := strings.Cut(spec, "@")
+		p, ok := presetCatalog[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(PresetNames(), ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("preset %q listed more than once", name)
+		}
+		seen[name] = true
+		switch {
+		case pinned && p.DefaultVersion == "":
+			return nil, fmt.Errorf("preset %q can't be pinned to a version", name)
+		case pinned && !presetVersionRe.MatchString(version):
+			return nil, fmt.Errorf("preset %q: invalid version %q", name, version)
+		case !pinned:
+			version = p.DefaultVersion
+		}
+		resolved = append(resolved, ResolvedPreset{
+			Name:           name,
+			Version:        version,
+			Packages:       presetCommands(p.Packages, version),
+			RuntimeInstall: presetCommands(p.RuntimeInstall, version),
+			Run:            presetCommands(p.Run, version),
+			SyncExclude:    p.SyncExclude,
+			Artifacts:      p.Artifacts,
+		})
+	}
+	return resolved, nil
+}
+
+func presetCommands(fn func(string) []string, version string) []string {
+	if fn == nil {
+		return nil
+	}
+	return fn(version)
+}
+
+// ApplyPresets validates [setup] presets and merges their sync excludes and
+// artifact paths into cfg. Packages and commands are read from
+// cfg.Setup by GenerateCloudInit, PostSyncCommands and SetupHash.
+func ApplyPresets(cfg *config.Config) error {
+	presets, err := ResolvePresets(cfg.Setup.Presets)
+	if err != nil {
+		return fmt.Errorf("invalid setup.presets: %w", err)
+	}
+	for _, p := range presets {
+		cfg.Sync.Exclude = appendMissing(cfg.Sync.Exclude, p.SyncExclude...)
+		cfg.Artifacts.Paths = appendMissing(cfg.Artifacts.Paths, p.Artifacts...)
+	}
+	return nil
+}
+
+// setupPresets resolves presets for provisioning. Invalid entries were
+// already rejected by ApplyPresets when the config was loaded.
+func setupPresets(setup config.SetupConfig) []ResolvedPreset {
+	presets, _ := ResolvePresets(setup.Presets)
+	return presets
+}
+
+func appendMissing(list []string, items ...string) []string {
+	for _, item := range items {
+		if !slices.Contains(list, item) {
+			list = append(list, item)
+		}
+	}
+	return list
+}
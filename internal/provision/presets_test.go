@@ -0,0 +1,82 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/gridlhq/yeager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvePresets(t *testing.T) {
+	t.Parallel()
+
+	presets, err := ResolvePresets([]string{"docker", "protoc@27.1", "playwright"})
+	require.NoError(t, err)
+	require.Len(t, presets, 3)
+
+	assert.Equal(t, []string{"docker.io", "docker-compose-v2"}, presets[0].Packages)
+	assert.Empty(t, presets[0].Version)
+
+	assert.Equal(t, "27.1", presets[1].Version)
+	assert.Contains(t, presets[1].RuntimeInstall[0], "/v27.1/protoc-27.1-linux-aarch_64.zip")
+
+	assert.Equal(t, presetCatalog["playwright"].DefaultVersion, presets[2].Version)
+	assert.Equal(t, []string{"npx -y playwright@" + presets[2].Version + " install --with-deps"}, presets[2].Run)
+}
+
+func TestResolvePresets_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		specs   []string
+		wantErr string
+	}{
+		{[]string{"kubectl"}, `unknown preset "kubectl" (available: awscli, docker, playwright, postgres-client, protoc)`},
+		{[]string{"docker@24"}, `preset "docker" can't be pinned`},
+		{[]string{"protoc@"}, `invalid version ""`},
+		{[]string{"protoc@1;rm -rf /"}, "invalid version"},
+		{[]string{"awscli", "awscli@2.0.0"}, "listed more than once"},
+	}
+	for _, tt := range tests {
+		_, err := ResolvePresets(tt.specs)
+		require.Error(t, err, tt.specs)
+		assert.Contains(t, err.Error(), tt.wantErr)
+	}
+}
+
+func TestApplyPresets(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Defaults()
+	cfg.Setup.Presets = []string{"playwright"}
+	cfg.Sync.Exclude = []string{"test-results/"}
+	require.NoError(t, ApplyPresets(&cfg))
+	assert.Equal(t, []string{"test-results/", "playwright-report/"}, cfg.Sync.Exclude, "no duplicates")
+	assert.Equal(t, []string{"playwright-report/index.html"}, cfg.Artifacts.Paths)
+
+	cfg.Setup.Presets = []string{"nope"}
+	err := ApplyPresets(&cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid setup.presets")
+}
+
+func TestPresetsInProvisioning(t *testing.T) {
+	t.Parallel()
+
+	setup := config.SetupConfig{
+		Presets:  []string{"docker", "protoc", "playwright"},
+		Packages: []string{"libpq-dev"},
+		Run:      []string{"make setup"},
+	}
+
+	ci := GenerateCloudInit(nil, setup)
+	assert.Subset(t, ci.Packages, []string{"docker.io", "docker-compose-v2", "libpq-dev"})
+	assert.Contains(t, ci.Runcmd, "usermod -aG docker ubuntu")
+	assert.Contains(t, ci.Render(), "protoc-"+presetCatalog["protoc"].DefaultVersion+"-linux-aarch_64.zip")
+
+	cmds := PostSyncCommands(nil, setup)
+	require.Len(t, cmds, 3)
+	assert.Contains(t, cmds[1], "npx -y playwright@")
+	assert.Equal(t, "make setup", cmds[2], "[setup] run comes after presets")
+}
@@ -0,0 +1,163 @@
+package provision
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// PythonTool identifies the Python project/dependency manager.
+type PythonTool string
+
+const (
+	Pip    PythonTool = "pip"
+	UV     PythonTool = "uv"
+	Poetry PythonTool = "poetry"
+	Pipenv PythonTool = "pipenv"
+	PDM    PythonTool = "pdm"
+)
+
+const (
+	// pythonVenvDir is the project venv on the VM, relative to the project dir.
+	// .venv/ is in the sync default excludes, so rsync --delete leaves it alone.
+	pythonVenvDir = ".venv"
+
+	// uvToolDir is a shared store so tools installed by cloud-init (as root)
+	// are usable by the ubuntu user.
+	uvToolDir = "/opt/uv/tools"
+)
+
+var (
+	pyToolTableRe      = regexp.MustCompile(`(?m)^\s*\[tool\.(poetry|pdm|uv)[\].]`)
+	pyRequiresPythonRe = regexp.MustCompile(`(?m)^\s*requires-python\s*=\s*["']([^"']+)["']`)
+	pyVersionRe        = regexp.MustCompile(`\d+\.\d+(?:\.\d+)?`)
+)
+
+// detectPythonTool picks the dependency manager for a Python project.
+// Lockfiles win over pyproject [tool.*] tables; pip is the fallback.
+func detectPythonTool(dir string) PythonTool {
+	switch {
+	case fileExists(dir, "uv.lock"):
+		return UV
+	case fileExists(dir, "poetry.lock"):
+		return Poetry
+	case fileExists(dir, "Pipfile.lock"), fileExists(dir, "Pipfile"):
+		return Pipenv
+	case fileExists(dir, "pdm.lock"):
+		return PDM
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "pyproject.toml"))
+	if err != nil {
+		return Pip
+	}
+	if m := pyToolTableRe.FindStringSubmatch(string(content)); m != nil {
+		return PythonTool(m[1])
+	}
+	return Pip
+}
+
+// pythonVersion returns the interpreter version pinned by .python-version
+// or pyproject.toml requires-python. Returns empty string if unpinned.
+func pythonVersion(dir string) string {
+	if content, err := os.ReadFile(filepath.Join(dir, ".python-version")); err == nil {
+		if v := parsePythonVersionFile(string(content)); v != "" {
+			return v
+		}
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "pyproject.toml")); err == nil {
+		return parseRequiresPython(string(content))
+	}
+	return ""
+}
+
+// parsePythonVersionFile returns the first version listed in a .python-version file.
+// pyenv allows several lines (one per interpreter) and # comments.
+func parsePythonVersionFile(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return line
+	}
+	return ""
+}
+
+// parseRequiresPython extracts the lowest version from a requires-python
+// specifier, e.g. ">=3.11,<4" → "3.11". Returns empty string if absent.
+func parseRequiresPython(content string) string {
+	m := pyRequiresPythonRe.FindStringSubmatch(content)
+	if m == nil {
+		return ""
+	}
+	return pyVersionRe.FindString(m[1])
+}
+
+// detectPython installs the interpreter through mise; uv creates the venv
+// and installs the project's dependency manager. Plain pip projects are
+// installed with uv pip too, since a uv venv has no pip in it.
+func detectPython(dir string, manifest string, pins versionPins) Language {
+	tool := detectPythonTool(dir)
+	version := resolvePythonVersion(dir, pins)
+
+	runtime := []string{
+		miseUse("python", version),
+		"curl -LsSf https://astral.sh/uv/install.sh | env UV_INSTALL_DIR=/usr/local/bin UV_NO_MODIFY_PATH=1 sh",
+	}
+	switch tool {
+	case Poetry, Pipenv, PDM:
+		runtime = append(runtime, fmt.Sprintf("UV_TOOL_DIR=%s UV_TOOL_BIN_DIR=/usr/local/bin uv tool install %s", uvToolDir, tool))
+	}
+
+	venvCmd := fmt.Sprintf("[ -d %s ] || uv venv --python %s %s", pythonVenvDir, version, pythonVenvDir)
+	activate := fmt.Sprintf(". %s/bin/activate", pythonVenvDir)
+
+	var installCmd string
+	switch tool {
+	case UV:
+		installCmd = "uv sync"
+		if fileExists(dir, "uv.lock") {
+			installCmd = "uv sync --frozen"
+		}
+	case Poetry:
+		installCmd = "poetry install --no-interaction"
+	case Pipenv:
+		installCmd = "pipenv install --dev"
+		if fileExists(dir, "Pipfile.lock") {
+			installCmd = "pipenv install --dev --deploy"
+		}
+	case PDM:
+		installCmd = "pdm install"
+		if fileExists(dir, "pdm.lock") {
+			installCmd = "pdm install --frozen-lockfile"
+		}
+	default:
+		installCmd = "uv pip install -e ."
+		if manifest == "requirements.txt" {
+			installCmd = "uv pip install -r requirements.txt"
+		}
+	}
+
+	displayName := fmt.Sprintf("Python (%s)", manifest)
+	if tool != Pip {
+		displayName = fmt.Sprintf("Python (%s, %s)", tool, manifest)
+	}
+
+	return Language{
+		Name:           Python,
+		DisplayName:    displayName,
+		Version:        version,
+		Tool:           "python",
+		RuntimeInstall: runtime,
+		DepInstall: []string{
+			venvCmd,
+			fmt.Sprintf("%s && %s", activate, installCmd),
+		},
+		ShellInit: []string{
+			fmt.Sprintf("if [ -f %s/bin/activate ]; then %s; fi", pythonVenvDir, activate),
+		},
+	}
+}
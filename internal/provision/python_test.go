@@ -0,0 +1,251 @@
+package provision
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectPythonTool(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  PythonTool
+	}{
+		{
+			name:  "plain pyproject uses pip",
+			files: map[string]string{"pyproject.toml": "[project]\nname = \"app\"\n"},
+			want:  Pip,
+		},
+		{
+			name:  "requirements.txt uses pip",
+			files: map[string]string{"requirements.txt": "flask\n"},
+			want:  Pip,
+		},
+		{
+			name:  "uv.lock",
+			files: map[string]string{"pyproject.toml": "[project]\n", "uv.lock": ""},
+			want:  UV,
+		},
+		{
+			name:  "poetry.lock",
+			files: map[string]string{"pyproject.toml": "[project]\n", "poetry.lock": ""},
+			want:  Poetry,
+		},
+		{
+			name:  "Pipfile.lock",
+			files: map[string]string{"Pipfile": "", "Pipfile.lock": "{}"},
+			want:  Pipenv,
+		},
+		{
+			name:  "Pipfile without lock",
+			files: map[string]string{"Pipfile": ""},
+			want:  Pipenv,
+		},
+		{
+			name:  "pdm.lock",
+			files: map[string]string{"pyproject.toml": "[project]\n", "pdm.lock": ""},
+			want:  PDM,
+		},
+		{
+			name:  "tool.poetry table without lockfile",
+			files: map[string]string{"pyproject.toml": "[tool.poetry]\nname = \"app\"\n"},
+			want:  Poetry,
+		},
+		{
+			name:  "tool.pdm subtable",
+			files: map[string]string{"pyproject.toml": "[project]\n\n[tool.pdm.dev-dependencies]\ntest = []\n"},
+			want:  PDM,
+		},
+		{
+			name:  "tool.uv table",
+			files: map[string]string{"pyproject.toml": "[project]\n\n[tool.uv]\ndev-dependencies = []\n"},
+			want:  UV,
+		},
+		{
+			name:  "unrelated tool table",
+			files: map[string]string{"pyproject.toml": "[tool.ruff]\nline-length = 100\n"},
+			want:  Pip,
+		},
+		{
+			name:  "lockfile wins over tool table",
+			files: map[string]string{"pyproject.toml": "[tool.poetry]\n", "uv.lock": ""},
+			want:  UV,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+			}
+			assert.Equal(t, tt.want, detectPythonTool(dir))
+		})
+	}
+}
+
+func TestPythonVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "unpinned",
+			files: map[string]string{"pyproject.toml": "[project]\nname = \"app\"\n"},
+			want:  "",
+		},
+		{
+			name:  "python-version file",
+			files: map[string]string{".python-version": "3.12.1\n"},
+			want:  "3.12.1",
+		},
+		{
+			name:  "python-version with multiple interpreters and comments",
+			files: map[string]string{".python-version": "# pinned\n3.11\n3.10\n"},
+			want:  "3.11",
+		},
+		{
+			name:  "requires-python lower bound",
+			files: map[string]string{"pyproject.toml": "[project]\nrequires-python = \">=3.11,<4\"\n"},
+			want:  "3.11",
+		},
+		{
+			name:  "requires-python compatible release",
+			files: map[string]string{"pyproject.toml": "[project]\nrequires-python = '~=3.10.4'\n"},
+			want:  "3.10.4",
+		},
+		{
+			name: "python-version wins over requires-python",
+			files: map[string]string{
+				".python-version": "3.13\n",
+				"pyproject.toml":  "[project]\nrequires-python = \">=3.9\"\n",
+			},
+			want: "3.13",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+			}
+			assert.Equal(t, tt.want, pythonVersion(dir))
+		})
+	}
+}
+
+func TestDetectPython_Commands(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		files          map[string]string
+		wantDisplay    string
+		wantRuntimeHas []string
+		wantDepInstall []string
+	}{
+		{
+			name: "uv with pinned version",
+			files: map[string]string{
+				"pyproject.toml":  "[project]\nname = \"app\"\n",
+				"uv.lock":         "",
+				".python-version": "3.12\n",
+			},
+			wantDisplay:    "Python (uv, pyproject.toml)",
+			wantRuntimeHas: []string{"su - ubuntu -c 'mise use --global --yes python@3.12'"},
+			wantDepInstall: []string{
+				"[ -d .venv ] || uv venv --python 3.12 .venv",
+				". .venv/bin/activate && uv sync --frozen",
+			},
+		},
+		{
+			name: "poetry installs the tool",
+			files: map[string]string{
+				"pyproject.toml": "[tool.poetry]\nname = \"app\"\n",
+				"poetry.lock":    "",
+			},
+			wantDisplay:    "Python (poetry, pyproject.toml)",
+			wantRuntimeHas: []string{"UV_TOOL_DIR=/opt/uv/tools UV_TOOL_BIN_DIR=/usr/local/bin uv tool install poetry"},
+			wantDepInstall: []string{
+				"[ -d .venv ] || uv venv --python 3 .venv",
+				". .venv/bin/activate && poetry install --no-interaction",
+			},
+		},
+		{
+			name: "pipenv with lockfile deploys",
+			files: map[string]string{
+				"Pipfile":      "",
+				"Pipfile.lock": "{}",
+			},
+			wantDisplay:    "Python (pipenv, Pipfile)",
+			wantRuntimeHas: []string{"UV_TOOL_DIR=/opt/uv/tools UV_TOOL_BIN_DIR=/usr/local/bin uv tool install pipenv"},
+			wantDepInstall: []string{
+				"[ -d .venv ] || uv venv --python 3 .venv",
+				". .venv/bin/activate && pipenv install --dev --deploy",
+			},
+		},
+		{
+			name: "pdm with lockfile",
+			files: map[string]string{
+				"pyproject.toml": "[project]\nrequires-python = \">=3.11\"\n",
+				"pdm.lock":       "",
+			},
+			wantDisplay:    "Python (pdm, pyproject.toml)",
+			wantRuntimeHas: []string{"su - ubuntu -c 'mise use --global --yes python@3.11'"},
+			wantDepInstall: []string{
+				"[ -d .venv ] || uv venv --python 3.11 .venv",
+				". .venv/bin/activate && pdm install --frozen-lockfile",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+			}
+
+			langs := DetectLanguages(dir)
+			require.Len(t, langs, 1)
+			lang := langs[0]
+
+			assert.Equal(t, Python, lang.Name)
+			assert.Equal(t, tt.wantDisplay, lang.DisplayName)
+			for _, want := range tt.wantRuntimeHas {
+				assert.Contains(t, lang.RuntimeInstall, want)
+			}
+			assert.Equal(t, tt.wantDepInstall, lang.DepInstall)
+			assert.Equal(t, []string{"if [ -f .venv/bin/activate ]; then . .venv/bin/activate; fi"}, lang.ShellInit)
+			assert.Equal(t, "python", lang.Tool)
+		})
+	}
+}
+
+func TestShellInit(t *testing.T) {
+	t.Parallel()
+
+	langs := []Language{
+		{Name: DotNet},
+		{Name: Python, ShellInit: []string{"activate venv"}},
+	}
+	assert.Equal(t, []string{"activate venv"}, ShellInit(langs))
+	assert.Empty(t, ShellInit(nil))
+
+	// mise shims go first so later init commands see the managed runtimes.
+	langs = append(langs, Language{Name: Node, Tool: "node"})
+	assert.Equal(t, []string{miseShellInit, "activate venv"}, ShellInit(langs))
+}
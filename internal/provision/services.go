@@ -0,0 +1,204 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gridlhq/yeager/internal/config"
+)
+
+// Services is the pseudo-language for the sidecar containers declared in
+// [services.<name>]: they're started and health-checked before every
+// run, and their connection env is exported to remote commands.
+const Services LanguageName = "services"
+
+const (
+	// serviceLabel marks yeager's service containers with their name.
+	serviceLabel = "yeager.service"
+
+	// serviceConfigLabel records the config a container was created
+	// from, so a config change recreates it.
+	serviceConfigLabel = "yeager.config"
+
+	// serviceHealthTimeout is how many seconds a service gets to become
+	// healthy before the run fails.
+	serviceHealthTimeout = 120
+)
+
+// ServiceContainer returns the docker container name of a service.
+func ServiceContainer(name string) string {
+	return "yeager-svc-" + name
+}
+
+// ServicesLanguage returns the services environment, or false if no
+// services are configured. Version hashes the whole config, so editing
+// a service recreates it on the next run. It doesn't start them: a
+// service that won't start fails the run, so the caller runs
+// ServicesUpCommands itself instead of as a best-effort install.
+func ServicesLanguage(cfg config.Config) (Language, bool) {
+	names := cfg.ServiceNames()
+	if len(names) == 0 {
+		return Language{}, false
+	}
+
+	var hashInputs []string
+	for _, name := range names {
+		hashInputs = append(hashInputs, name, serviceHash(cfg.Services[name]))
+	}
+	return Language{
+		Name:           Services,
+		DisplayName:    fmt.Sprintf("services (%s)", strings.Join(names, ", ")),
+		Version:        commandsHash(hashInputs, nil),
+		RuntimeInstall: append([]string{}, dockerInstall...),
+		ShellInit:      serviceExports(cfg, names),
+	}, true
+}
+
+// ServicesUpCommands starts every configured service and waits until each
+// one is healthy, before each run: they restart with the VM (--restart
+// unless-stopped) but may still be starting up, and on a new VM docker
+// is installed by cloud-init first. Fails, after printing the service's
+// logs, if one doesn't start or become healthy.
+func ServicesUpCommands(cfg config.Config) []string {
+	names := cfg.ServiceNames()
+	if len(names) == 0 {
+		return nil
+	}
+	return append([]string{waitForCloudInit}, StartServicesCommands(cfg, names)...)
+}
+
+// StartServicesCommands starts the named services, recreating any whose
+// config changed, then waits until each one is healthy.
+func StartServicesCommands(cfg config.Config, names []string) []string {
+	var cmds []string
+	for _, name := range names {
+		cmds = append(cmds, startServiceCommand(name, cfg.Services[name]))
+	}
+	for _, name := range names {
+		cmds = append(cmds, waitForService(name, serviceHealthCheck(name, cfg.Services[name])))
+	}
+	return cmds
+}
+
+// RestartServicesCommands recreates the named services from their
+// current config and waits until each one is healthy.
+func RestartServicesCommands(cfg config.Config, names []string) []string {
+	var cmds []string
+	for _, name := range names {
+		cmds = append(cmds, fmt.Sprintf("sudo docker rm -f %s >/dev/null 2>&1 || true", ServiceContainer(name)))
+	}
+	return append(cmds, StartServicesCommands(cfg, names)...)
+}
+
+// ServiceLogsCommand prints a service's logs, following them if follow is set.
+func ServiceLogsCommand(name string, tail int, follow bool) string {
+	cmd := "sudo docker logs"
+	if tail > 0 {
+		cmd += fmt.Sprintf(" --tail %d", tail)
+	}
+	if follow {
+		cmd += " --follow"
+	}
+	return fmt.Sprintf("%s %s 2>&1", cmd, ServiceContainer(name))
+}
+
+// ServiceStatusCommand lists yeager's service containers, one per line:
+// the service name, a tab, and docker's status (e.g. "Up 5 minutes (healthy)").
+func ServiceStatusCommand() string {
+	return fmt.Sprintf(`sudo docker ps -a --filter label=%s --format '{{.Label "%s"}}\t{{.Status}}' 2>/dev/null || true`,
+		serviceLabel, serviceLabel)
+}
+
+// startServiceCommand starts a service's container, creating it if it
+// doesn't exist or was created from a different config.
+func startServiceCommand(name string, svc config.ServiceConfig) string {
+	container := ServiceContainer(name)
+	hash := serviceHash(svc)
+
+	run := []string{"sudo docker run -d", "--name " + container, "--restart unless-stopped",
+		fmt.Sprintf("--label %s=%s", serviceLabel, name),
+		fmt.Sprintf("--label %s=%s", serviceConfigLabel, hash)}
+	for _, port := range svc.Ports {
+		host, containerPort, _ := config.ParsePort(port)
+		run = append(run, fmt.Sprintf("-p 127.0.0.1:%d:%d", host, containerPort))
+	}
+	for _, kv := range svc.Env {
+		run = append(run, "-e "+shellQuote(kv))
+	}
+	run = append(run, shellQuote(svc.Image), ">/dev/null")
+
+	return fmt.Sprintf(`if [ "$(sudo docker inspect -f '{{index .Config.Labels "%s"}}' %s 2>/dev/null)" = %s ]; then
+  sudo docker start %s >/dev/null
+else
+  sudo docker rm -f %s >/dev/null 2>&1 || true
+  %s
+fi`, serviceConfigLabel, container, hash, container, container, strings.Join(run, " "))
+}
+
+// serviceHealthCheck returns the shell condition that holds once a
+// service is healthy: its healthcheck succeeds, or else all its ports
+// accept connections. Empty if there's nothing to check.
+func serviceHealthCheck(name string, svc config.ServiceConfig) string {
+	if svc.Healthcheck != "" {
+		return fmt.Sprintf("sudo docker exec %s sh -c %s", ServiceContainer(name), shellQuote(svc.Healthcheck))
+	}
+	var checks []string
+	for _, port := range svc.Ports {
+		host, _, _ := config.ParsePort(port)
+		checks = append(checks, fmt.Sprintf("(echo > /dev/tcp/127.0.0.1/%d)", host))
+	}
+	return strings.Join(checks, " && ")
+}
+
+// serviceLogTail is how many lines of a failed service's logs are shown.
+const serviceLogTail = 30
+
+// waitForService polls check every second until it succeeds, failing
+// after serviceHealthTimeout seconds, or as soon as the container stops.
+// Without a check, it only makes sure the container is running. On
+// failure it prints the end of the service's logs.
+func waitForService(name, check string) string {
+	container := ServiceContainer(name)
+	fail := func(reason string) string {
+		return fmt.Sprintf(`echo "service %s %s; its last logs:" >&2; sudo docker logs --tail %d %s >&2 2>&1; exit 1`,
+			name, reason, serviceLogTail, container)
+	}
+	running := fmt.Sprintf(`[ "$(sudo docker inspect -f '{{.State.Running}}' %s 2>/dev/null)" = true ]`, container)
+	if check == "" {
+		return fmt.Sprintf("sleep 1; %s || { %s; }", running, fail("exited"))
+	}
+	return fmt.Sprintf(`for i in $(seq %d); do
+  if { %s; } >/dev/null 2>&1; then break; fi
+  %s || { %s; }
+  if [ "$i" = %d ]; then %s; fi
+  sleep 1
+done`, serviceHealthTimeout, check, running, fail("exited"), serviceHealthTimeout,
+		fail(fmt.Sprintf("is not healthy after %ds", serviceHealthTimeout)))
+}
+
+// serviceExports returns the env exported to remote commands: NAME_HOST
+// and NAME_PORT (the first host port) for each service, then its export
+// entries.
+func serviceExports(cfg config.Config, names []string) []string {
+	var exports []string
+	for _, name := range names {
+		svc := cfg.Services[name]
+		prefix := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		if len(svc.Ports) > 0 {
+			host, _, _ := config.ParsePort(svc.Ports[0])
+			exports = append(exports,
+				fmt.Sprintf("export %s_HOST=127.0.0.1", prefix),
+				fmt.Sprintf("export %s_PORT=%d", prefix, host))
+		}
+		for _, kv := range svc.Export {
+			k, v, _ := strings.Cut(kv, "=")
+			exports = append(exports, fmt.Sprintf("export %s=%s", k, shellQuote(v)))
+		}
+	}
+	return exports
+}
+
+// serviceHash identifies a service's container config.
+func serviceHash(svc config.ServiceConfig) string {
+	return commandsHash(append([]string{svc.Image}, svc.Ports...), svc.Env)
+}
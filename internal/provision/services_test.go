@@ -0,0 +1,109 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gridlhq/yeager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testServicesConfig() config.Config {
+	cfg := config.Defaults()
+	cfg.Services = map[string]config.ServiceConfig{
+		"redis": {Image: "redis:7", Ports: []string{"16379:6379"}},
+		"postgres": {
+			Image:       "postgres:16",
+			Ports:       []string{"5432"},
+			Env:         []string{"POSTGRES_PASSWORD=it's"},
+			Export:      []string{"DATABASE_URL=postgres://postgres@localhost:5432/db"},
+			Healthcheck: "pg_isready -U postgres",
+		},
+		"mail-hog": {Image: "mailhog/mailhog"},
+	}
+	return cfg
+}
+
+func TestServicesLanguage(t *testing.T) {
+	t.Parallel()
+
+	_, ok := ServicesLanguage(config.Defaults())
+	assert.False(t, ok)
+
+	lang, ok := ServicesLanguage(testServicesConfig())
+	require.True(t, ok)
+	assert.Equal(t, Services, lang.Name)
+	assert.Equal(t, "services (mail-hog, postgres, redis)", lang.DisplayName)
+	assert.Contains(t, lang.RuntimeInstall, "apt-get install -y docker.io docker-compose-v2")
+	assert.Empty(t, lang.DepInstall, "started by ServicesUpCommands, which fails the run, not as a best-effort install")
+	assert.Empty(t, lang.Prepare)
+	assert.Empty(t, lang.Wrapper)
+
+	assert.Equal(t, []string{
+		"export POSTGRES_HOST=127.0.0.1",
+		"export POSTGRES_PORT=5432",
+		"export DATABASE_URL='postgres://postgres@localhost:5432/db'",
+		"export REDIS_HOST=127.0.0.1",
+		"export REDIS_PORT=16379",
+	}, lang.ShellInit)
+}
+
+func TestStartServicesCommands(t *testing.T) {
+	t.Parallel()
+
+	cfg := testServicesConfig()
+	cmds := StartServicesCommands(cfg, cfg.ServiceNames())
+	require.Len(t, cmds, 6, "three starts, then a wait for each")
+
+	pg := cmds[1]
+	assert.Contains(t, pg, "sudo docker run -d --name yeager-svc-postgres --restart unless-stopped --label yeager.service=postgres")
+	assert.Contains(t, pg, "-p 127.0.0.1:5432:5432 -e 'POSTGRES_PASSWORD=it'\\''s' 'postgres:16'")
+	assert.Contains(t, pg, "sudo docker start yeager-svc-postgres")
+	assert.Contains(t, cmds[2], "-p 127.0.0.1:16379:6379")
+
+	assert.NotContains(t, cmds[3], "for i in", "nothing to check but that it's running")
+	assert.Contains(t, cmds[3], "service mail-hog exited; its last logs:")
+	assert.Contains(t, cmds[4], "sudo docker exec yeager-svc-postgres sh -c 'pg_isready -U postgres'")
+	assert.Contains(t, cmds[4], "service postgres is not healthy after 120s; its last logs:")
+	assert.Contains(t, cmds[4], "sudo docker logs --tail 30 yeager-svc-postgres >&2")
+	assert.Contains(t, cmds[4], "{{.State.Running}}", "a container that stopped fails at once")
+	assert.Contains(t, cmds[5], "(echo > /dev/tcp/127.0.0.1/16379)", "without a healthcheck, wait for the ports")
+}
+
+func TestServicesVersionTracksConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg := testServicesConfig()
+	a, _ := ServicesLanguage(cfg)
+
+	redis := cfg.Services["redis"]
+	redis.Image = "redis:8"
+	cfg.Services["redis"] = redis
+	b, _ := ServicesLanguage(cfg)
+	assert.NotEqual(t, a.Version, b.Version)
+
+	// A config change recreates only the changed container.
+	before, after := ServicesUpCommands(testServicesConfig()), ServicesUpCommands(cfg)
+	assert.Equal(t, "cloud-init status --wait >/dev/null 2>&1 || true", after[0], "docker may still be installing")
+	assert.NotEqual(t, before[3], after[3])
+	assert.Equal(t, before[2], after[2])
+	assert.Empty(t, ServicesUpCommands(config.Defaults()))
+}
+
+func TestRestartServicesCommands(t *testing.T) {
+	t.Parallel()
+
+	cmds := RestartServicesCommands(testServicesConfig(), []string{"redis"})
+	require.Len(t, cmds, 3)
+	assert.Equal(t, "sudo docker rm -f yeager-svc-redis >/dev/null 2>&1 || true", cmds[0])
+	assert.Contains(t, cmds[1], "--name yeager-svc-redis")
+	assert.True(t, strings.HasPrefix(cmds[2], "for i in"))
+}
+
+func TestServiceLogsCommand(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "sudo docker logs yeager-svc-pg 2>&1", ServiceLogsCommand("pg", 0, false))
+	assert.Equal(t, "sudo docker logs --tail 20 --follow yeager-svc-pg 2>&1", ServiceLogsCommand("pg", 20, true))
+}
@@ -0,0 +1,342 @@
+package provision
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	// miseShims is where mise exposes the runtimes it manages for the ubuntu user.
+	miseShims = "/home/ubuntu/.local/share/mise/shims"
+
+	// Default versions when nothing pins a runtime.
+	defaultNodeVersion   = "lts"
+	defaultPythonVersion = "3"
+	defaultRubyVersion   = "3"
+	defaultRustVersion   = "stable"
+)
+
+// miseSetup installs mise and puts its shims on PATH for SSH sessions.
+// Idempotent so it can be replayed when reprovisioning an existing VM.
+var miseSetup = []string{
+	"command -v mise >/dev/null || curl -fsSL https://mise.run | MISE_INSTALL_PATH=/usr/local/bin/mise sh",
+	fmt.Sprintf(`grep -q '%s' /etc/environment || sed -i 's#^PATH="#PATH="%s:#' /etc/environment`, miseShims, miseShims),
+	"grep -q '^MISE_TRUSTED_CONFIG_PATHS=' /etc/environment || echo 'MISE_TRUSTED_CONFIG_PATHS=/home/ubuntu/project' >> /etc/environment",
+}
+
+// miseShellInit puts mise shims on PATH for the current shell. SSH sessions
+// opened before cloud-init rewrote /etc/environment don't have them yet.
+var miseShellInit = fmt.Sprintf(`export PATH="%s:$PATH"`, miseShims)
+
+// miseUse installs a runtime version for the ubuntu user and makes it the default.
+// Runs as root (cloud-init) or via sudo, hence the su.
+func miseUse(tool, version string) string {
+	return fmt.Sprintf("su - ubuntu -c 'mise use --global --yes %s@%s'", tool, version)
+}
+
+// toolLanguages maps .tool-versions / mise.toml tool names to languages.
+var toolLanguages = map[string]LanguageName{
+	"node":        Node,
+	"nodejs":      Node,
+	"go":          Go,
+	"golang":      Go,
+	"python":      Python,
+	"ruby":        Ruby,
+	"rust":        Rust,
+	"dotnet":      DotNet,
+	"dotnet-core": DotNet,
+}
+
+// versionPins holds runtime versions pinned by version-manager files
+// (mise.toml, .tool-versions). Native pin files are resolved per language.
+type versionPins map[LanguageName]string
+
+// readVersionPins loads pins from mise config, .tool-versions and, unless
+// ignoreDevcontainer is set, devcontainer features in dir. mise.toml takes
+// precedence over .tool-versions, matching mise itself; devcontainer
+// features come last.
+func readVersionPins(dir string, ignoreDevcontainer bool) versionPins {
+	pins := versionPins{}
+	if !ignoreDevcontainer {
+		for lang, v := range devcontainerPins(dir) {
+			pins[lang] = v
+		}
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, ".tool-versions")); err == nil {
+		for lang, v := range parseToolVersions(string(content)) {
+			if validVersion(v) {
+				pins[lang] = v
+			}
+		}
+	}
+	for _, name := range []string{".mise.toml", "mise.toml"} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		for lang, v := range parseMiseToml(content) {
+			if validVersion(v) {
+				pins[lang] = v
+			}
+		}
+	}
+	return pins
+}
+
+// parseToolVersions parses asdf-style .tool-versions content ("nodejs 20.11.0").
+// When a line lists several versions, the first is the default.
+func parseToolVersions(content string) map[LanguageName]string {
+	pins := make(map[LanguageName]string)
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if lang, ok := toolLanguages[fields[0]]; ok {
+			pins[lang] = fields[1]
+		}
+	}
+	return pins
+}
+
+// parseMiseToml extracts the [tools] table from mise.toml content.
+// Tool values may be a string, a list (first entry wins) or a table with "version".
+// Returns nil if the file is malformed.
+func parseMiseToml(content []byte) map[LanguageName]string {
+	var doc struct {
+		Tools map[string]any `toml:"tools"`
+	}
+	if err := toml.Unmarshal(content, &doc); err != nil {
+		return nil
+	}
+
+	pins := make(map[LanguageName]string)
+	for tool, raw := range doc.Tools {
+		lang, ok := toolLanguages[tool]
+		if !ok {
+			continue
+		}
+		var v string
+		switch val := raw.(type) {
+		case string:
+			v = val
+		case []any:
+			if len(val) > 0 {
+				v, _ = val[0].(string)
+			}
+		case map[string]any:
+			v, _ = val["version"].(string)
+		}
+		if v != "" {
+			pins[lang] = v
+		}
+	}
+	return pins
+}
+
+// goToolchainRe matches the go.mod toolchain directive ("toolchain go1.23.5").
+var goToolchainRe = regexp.MustCompile(`(?m)^toolchain\s+go(\d+\.\d+(?:\.\d+)?)\s*$`)
+
+// parseGoToolchain returns the version from a go.mod toolchain directive,
+// or empty string if there is none.
+func parseGoToolchain(content string) string {
+	m := goToolchainRe.FindStringSubmatch(content)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// parseRustToolchain returns the channel from rust-toolchain.toml, or from a
+// legacy rust-toolchain file holding either TOML or a bare channel name.
+func parseRustToolchain(content []byte) string {
+	var doc struct {
+		Toolchain struct {
+			Channel string `toml:"channel"`
+		} `toml:"toolchain"`
+	}
+	if err := toml.Unmarshal(content, &doc); err == nil && doc.Toolchain.Channel != "" {
+		return doc.Toolchain.Channel
+	}
+	v := strings.TrimSpace(string(content))
+	if v == "" || strings.ContainsAny(v, "[=\n") {
+		return ""
+	}
+	return v
+}
+
+// parseRubyVersion trims a .ruby-version file, dropping the optional "ruby-" prefix.
+func parseRubyVersion(content string) string {
+	return strings.TrimPrefix(strings.TrimSpace(content), "ruby-")
+}
+
+// versionRe matches version specs that are safe to interpolate into shell
+// commands: "20", "3.12.1", "lts", "nightly-2024-05-01", "pypy3.10".
+var versionRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+
+// validVersion reports whether v is a usable version spec.
+func validVersion(v string) bool {
+	return versionRe.MatchString(v)
+}
+
+// readPin returns the result of parse applied to dir/name, or empty string
+// if the file doesn't exist or doesn't hold a valid version.
+func readPin(dir, name string, parse func(string) string) string {
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	if v := parse(string(content)); validVersion(v) {
+		return v
+	}
+	return ""
+}
+
+func resolveNodeVersion(dir string, pins versionPins) string {
+	if v := pins[Node]; v != "" {
+		return v
+	}
+	for _, name := range []string{".nvmrc", ".node-version"} {
+		if v := readPin(dir, name, parseNvmrc); v != "" {
+			return v
+		}
+	}
+	return defaultNodeVersion
+}
+
+func resolveGoVersion(dir string, pins versionPins) string {
+	if v := pins[Go]; v != "" {
+		return v
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return defaultGoVersion
+	}
+	if v := parseGoToolchain(string(content)); v != "" {
+		return v
+	}
+	return parseGoVersion(string(content))
+}
+
+func resolveRustVersion(dir string, pins versionPins) string {
+	if v := pins[Rust]; v != "" {
+		return v
+	}
+	for _, name := range []string{"rust-toolchain.toml", "rust-toolchain"} {
+		if content, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			if v := parseRustToolchain(content); validVersion(v) {
+				return v
+			}
+		}
+	}
+	return defaultRustVersion
+}
+
+func resolvePythonVersion(dir string, pins versionPins) string {
+	if v := pins[Python]; v != "" {
+		return v
+	}
+	if v := pythonVersion(dir); validVersion(v) {
+		return v
+	}
+	return defaultPythonVersion
+}
+
+func resolveRubyVersion(dir string, pins versionPins) string {
+	if v := pins[Ruby]; v != "" {
+		return v
+	}
+	if v := readPin(dir, ".ruby-version", parseRubyVersion); v != "" {
+		return v
+	}
+	return defaultRubyVersion
+}
+
+// resolveDotNetVersion returns the pinned SDK version, or empty string
+// to install the current LTS channel.
+func resolveDotNetVersion(dir string, pins versionPins) string {
+	if v := pins[DotNet]; v != "" {
+		return v
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "global.json")); err == nil {
+		if v := parseGlobalJSON(content); validVersion(v) {
+			return v
+		}
+	}
+	return ""
+}
+
+// RuntimeVersions returns the resolved runtime version per language,
+// keyed by language name. Recorded in VM state to detect version bumps.
+func RuntimeVersions(langs []Language) map[string]string {
+	if len(langs) == 0 {
+		return nil
+	}
+	versions := make(map[string]string, len(langs))
+	for _, lang := range langs {
+		versions[string(lang.Name)] = lang.Version
+	}
+	return versions
+}
+
+// ChangedRuntimes returns the languages whose resolved version differs from
+// the recorded versions, including languages that weren't recorded at all.
+func ChangedRuntimes(recorded map[string]string, langs []Language) []Language {
+	var changed []Language
+	for _, lang := range langs {
+		if v, ok := recorded[string(lang.Name)]; !ok || v != lang.Version {
+			changed = append(changed, lang)
+		}
+	}
+	return changed
+}
+
+// ReprovisionCommands returns the SSH commands that install changed runtimes
+// on an existing VM and reinstall their dependencies. Runtime installs are
+// written for cloud-init (root), so they run under sudo.
+func ReprovisionCommands(changed []Language) []string {
+	if len(changed) == 0 {
+		return nil
+	}
+
+	var root []string
+	if usesMise(changed) {
+		root = append(root, miseSetup...)
+	}
+	for _, lang := range changed {
+		root = append(root, lang.RuntimeInstall...)
+	}
+
+	script := "set -e\n" + strings.Join(root, "\n")
+	cmds := []string{fmt.Sprintf("sudo bash -c %s", shellQuote(script))}
+	if usesMise(changed) {
+		cmds = append(cmds, miseShellInit)
+	}
+	for _, lang := range changed {
+		cmds = append(cmds, lang.DepInstall...)
+	}
+	return cmds
+}
+
+// usesMise reports whether any language is installed through mise.
+func usesMise(langs []Language) bool {
+	for _, lang := range langs {
+		if lang.Tool != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// shellQuote wraps s in single quotes for bash, escaping embedded single quotes.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
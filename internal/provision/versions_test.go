@@ -0,0 +1,282 @@
+package provision
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseToolVersions(t *testing.T) {
+	t.Parallel()
+
+	content := `# runtimes
+nodejs 20.11.0
+python 3.12.1 3.11.7
+golang 1.22.1 # toolchain
+terraform 1.7.0
+ruby
+`
+	assert.Equal(t, map[LanguageName]string{
+		Node:   "20.11.0",
+		Python: "3.12.1",
+		Go:     "1.22.1",
+	}, parseToolVersions(content))
+	assert.Empty(t, parseToolVersions(""))
+}
+
+func TestParseMiseToml(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		want    map[LanguageName]string
+	}{
+		{
+			name:    "string values",
+			content: "[tools]\nnode = \"22\"\nrust = \"1.78.0\"\n",
+			want:    map[LanguageName]string{Node: "22", Rust: "1.78.0"},
+		},
+		{
+			name:    "list takes first entry",
+			content: "[tools]\npython = [\"3.12\", \"3.11\"]\n",
+			want:    map[LanguageName]string{Python: "3.12"},
+		},
+		{
+			name:    "table with version",
+			content: "[tools]\nruby = { version = \"3.3.0\" }\n",
+			want:    map[LanguageName]string{Ruby: "3.3.0"},
+		},
+		{
+			name:    "unknown tools ignored",
+			content: "[tools]\nterraform = \"1.7\"\n",
+			want:    map[LanguageName]string{},
+		},
+		{
+			name:    "no tools table",
+			content: "[env]\nFOO = \"bar\"\n",
+			want:    map[LanguageName]string{},
+		},
+		{
+			name:    "malformed",
+			content: "[tools\nnode = ",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, parseMiseToml([]byte(tt.content)))
+		})
+	}
+}
+
+func TestParseGoToolchain(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "1.23.5", parseGoToolchain("module m\n\ngo 1.23.0\n\ntoolchain go1.23.5\n"))
+	assert.Empty(t, parseGoToolchain("module m\n\ngo 1.23.0\n"))
+}
+
+func TestParseRustToolchain(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"toml channel", "[toolchain]\nchannel = \"1.78.0\"\n", "1.78.0"},
+		{"legacy bare channel", "nightly-2024-05-01\n", "nightly-2024-05-01"},
+		{"toml without channel", "[toolchain]\ncomponents = [\"rustfmt\"]\n", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, parseRustToolchain([]byte(tt.content)))
+		})
+	}
+}
+
+func TestParseRubyVersion(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "3.3.0", parseRubyVersion("ruby-3.3.0\n"))
+	assert.Equal(t, "3.2.2", parseRubyVersion("  3.2.2  \n"))
+}
+
+func TestValidVersion(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{"20", "3.12.1", "lts", "stable", "nightly-2024-05-01", "pypy3.10", "8.0.100"} {
+		assert.True(t, validVersion(v), v)
+	}
+	for _, v := range []string{"", "20; rm -rf /", "$(whoami)", "3 4", "'3'", "-v"} {
+		assert.False(t, validVersion(v), v)
+	}
+}
+
+func TestReadVersionPins_MiseWinsOverToolVersions(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".tool-versions"), []byte("nodejs 18.19.0\nruby 3.2.0\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "mise.toml"), []byte("[tools]\nnode = \"22\"\n"), 0o644))
+
+	assert.Equal(t, versionPins{Node: "22", Ruby: "3.2.0"}, readVersionPins(dir, false))
+}
+
+func TestReadVersionPins_SkipsUnsafeVersions(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "mise.toml"), []byte("[tools]\nnode = \"20; curl evil\"\n"), 0o644))
+
+	assert.Empty(t, readVersionPins(dir, false))
+}
+
+func TestDetectLanguages_VersionPrecedence(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		lang  LanguageName
+		want  string
+	}{
+		{
+			name:  "tool-versions beats nvmrc",
+			files: map[string]string{"package.json": "{}", ".nvmrc": "18", ".tool-versions": "nodejs 20.11.0\n"},
+			lang:  Node,
+			want:  "20.11.0",
+		},
+		{
+			name:  "node-version file",
+			files: map[string]string{"package.json": "{}", ".node-version": "v21.6.0\n"},
+			lang:  Node,
+			want:  "21.6.0",
+		},
+		{
+			name:  "mise beats go.mod",
+			files: map[string]string{"go.mod": "module m\n\ngo 1.21\n", "mise.toml": "[tools]\ngo = \"1.23.2\"\n"},
+			lang:  Go,
+			want:  "1.23.2",
+		},
+		{
+			name:  "tool-versions beats python-version",
+			files: map[string]string{"requirements.txt": "", ".python-version": "3.11\n", ".tool-versions": "python 3.12.2\n"},
+			lang:  Python,
+			want:  "3.12.2",
+		},
+		{
+			name:  "tool-versions beats rust-toolchain",
+			files: map[string]string{"Cargo.toml": "", "rust-toolchain": "nightly\n", ".tool-versions": "rust 1.77.0\n"},
+			lang:  Rust,
+			want:  "1.77.0",
+		},
+		{
+			name:  "tool-versions beats global.json",
+			files: map[string]string{"global.json": `{"sdk":{"version":"8.0.100"}}`, ".tool-versions": "dotnet 9.0.100\n"},
+			lang:  DotNet,
+			want:  "9.0.100",
+		},
+		{
+			name:  "invalid nvmrc falls back to default",
+			files: map[string]string{"package.json": "{}", ".nvmrc": "$(whoami)\n"},
+			lang:  Node,
+			want:  defaultNodeVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+			}
+
+			var got *Language
+			langs := DetectLanguages(dir)
+			for i := range langs {
+				if langs[i].Name == tt.lang {
+					got = &langs[i]
+				}
+			}
+			require.NotNil(t, got, "language %s not detected", tt.lang)
+			assert.Equal(t, tt.want, got.Version)
+		})
+	}
+}
+
+func TestRuntimeVersions(t *testing.T) {
+	t.Parallel()
+
+	langs := []Language{{Name: Node, Version: "20"}, {Name: Go, Version: "1.22.0"}}
+	assert.Equal(t, map[string]string{"node": "20", "go": "1.22.0"}, RuntimeVersions(langs))
+	assert.Nil(t, RuntimeVersions(nil))
+}
+
+func TestChangedRuntimes(t *testing.T) {
+	t.Parallel()
+
+	recorded := map[string]string{"node": "20", "go": "1.22.0"}
+	langs := []Language{
+		{Name: Node, Version: "22"},
+		{Name: Go, Version: "1.22.0"},
+		{Name: Python, Version: "3"},
+	}
+
+	changed := ChangedRuntimes(recorded, langs)
+	require.Len(t, changed, 2)
+	assert.Equal(t, Node, changed[0].Name)
+	assert.Equal(t, Python, changed[1].Name)
+
+	assert.Empty(t, ChangedRuntimes(RuntimeVersions(langs), langs))
+}
+
+func TestReprovisionCommands(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, ReprovisionCommands(nil))
+
+	changed := []Language{{
+		Name:           Node,
+		Version:        "22",
+		Tool:           "node",
+		RuntimeInstall: []string{miseUse("node", "22")},
+		DepInstall:     []string{"npm ci"},
+	}}
+	cmds := ReprovisionCommands(changed)
+	require.Len(t, cmds, 3)
+
+	assert.True(t, strings.HasPrefix(cmds[0], "sudo bash -c 'set -e\n"), cmds[0])
+	assert.Contains(t, cmds[0], "mise.run")
+	// The embedded su -c quotes must be escaped for the outer single quotes.
+	assert.Contains(t, cmds[0], `su - ubuntu -c '\''mise use --global --yes node@22'\''`)
+	assert.Equal(t, miseShellInit, cmds[1])
+	assert.Equal(t, "npm ci", cmds[2])
+}
+
+func TestReprovisionCommands_WithoutMise(t *testing.T) {
+	t.Parallel()
+
+	changed := []Language{{
+		Name:           DotNet,
+		Version:        "9.0.100",
+		RuntimeInstall: []string{"install dotnet"},
+		DepInstall:     []string{"dotnet restore 'App.sln'"},
+	}}
+	cmds := ReprovisionCommands(changed)
+	assert.Equal(t, []string{
+		"sudo bash -c 'set -e\ninstall dotnet'",
+		"dotnet restore 'App.sln'",
+	}, cmds)
+}
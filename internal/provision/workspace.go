@@ -0,0 +1,431 @@
+package provision
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// maxWorkspaceDepth limits how many directories below the project root
+// are scanned for nested manifests. Workspace globs are not limited.
+const maxWorkspaceDepth = 3
+
+// workspaceSkipDirs are never scanned for nested manifests: dependency
+// caches, build output and fixtures hold manifests that aren't projects.
+var workspaceSkipDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"target":       true,
+	"dist":         true,
+	"build":        true,
+	"bin":          true,
+	"obj":          true,
+	"venv":         true,
+	"__pycache__":  true,
+	"testdata":     true,
+}
+
+// ExcludeFunc reports whether a path, slash-separated and relative to the
+// project root, is left out of sync, so it isn't on the VM.
+type ExcludeFunc func(rel string) bool
+
+// workspace is the set of member directories found below a project root.
+type workspace struct {
+	// members are slash-separated paths relative to the root, sorted.
+	members []string
+
+	// covered marks members whose dependencies the root install already
+	// handles (npm/yarn/pnpm workspaces, Cargo workspaces).
+	covered map[LanguageName]map[string]bool
+}
+
+// DetectLanguages scans a project directory and its workspace members
+// for known manifest files. See DetectWorkspaceLanguages.
+func DetectLanguages(dir string) []Language {
+	return DetectWorkspaceLanguages(dir, nil, false, nil)
+}
+
+// DetectWorkspaceLanguages detects languages at the project root and in
+// every workspace member: directories listed by npm/yarn/pnpm workspaces,
+// Cargo workspaces and go.work, directories matching the configured member
+// globs, and nested manifests up to maxWorkspaceDepth levels deep. Nested
+// manifests that excluded reports, like git-ignored build output, are
+// skipped; excluded may be nil.
+//
+// The result holds one Language per runtime. The root's version wins;
+// dependency installs run in each member directory unless the root's
+// package manager already installs that member. Runtime versions are
+// resolved from mise.toml, then .tool-versions, then each ecosystem's
+// native pin file (.nvmrc, rust-toolchain.toml, ...), and devcontainer
+// features unless ignoreDevcontainer is set (devcontainer.ignore).
+// A Nix project (flake.nix, shell.nix or default.nix at the root) gets a
+// single Nix language instead, whose dev shell provides the runtimes.
+// Returns nil if no languages are detected.
+func DetectWorkspaceLanguages(dir string, members []string, ignoreDevcontainer bool, excluded ExcludeFunc) []Language {
+	pins := readVersionPins(dir, ignoreDevcontainer)
+	langs := detectDir(dir, pins)
+
+	dirs := make(map[LanguageName][]string)
+	for _, lang := range langs {
+		dirs[lang.Name] = []string{"."}
+	}
+
+	ws := discoverWorkspace(dir, members, excluded)
+	for _, rel := range ws.members {
+		memberDir := filepath.Join(dir, filepath.FromSlash(rel))
+		memberPins := make(versionPins, len(pins))
+		for lang, v := range pins {
+			memberPins[lang] = v
+		}
+		for lang, v := range readVersionPins(memberDir, ignoreDevcontainer) {
+			memberPins[lang] = v
+		}
+
+		for _, lang := range detectDir(memberDir, memberPins) {
+			if ws.covered[lang.Name][rel] {
+				lang.DepInstall = nil
+			} else {
+				lang.DepInstall = memberDepInstall(rel, lang.DepInstall)
+			}
+			langs = mergeMember(langs, lang, rel)
+			dirs[lang.Name] = append(dirs[lang.Name], rel)
+		}
+	}
+
+	for i := range langs {
+		langs[i].DisplayName = workspaceDisplayName(langs[i].DisplayName, dirs[langs[i].Name])
+	}
+	if file := nixFile(dir); file != "" {
+		return []Language{detectNix(dir, file, langs)}
+	}
+	return langs
+}
+
+// detectDir returns the languages whose manifests are in dir itself,
+// in a stable order: Rust, Node, Go, Python, Ruby, .NET.
+// This matches the priority table in FEATURES.md.
+func detectDir(dir string, pins versionPins) []Language {
+	var langs []Language
+
+	if fileExists(dir, "Cargo.toml") {
+		langs = append(langs, detectRust(dir, pins))
+	}
+
+	if fileExists(dir, "package.json") {
+		langs = append(langs, detectNode(dir, pins))
+	}
+
+	if fileExists(dir, "go.mod") {
+		langs = append(langs, detectGo(dir, pins))
+	}
+
+	// Python: prefer pyproject.toml over requirements.txt over Pipfile.
+	if fileExists(dir, "pyproject.toml") {
+		langs = append(langs, detectPython(dir, "pyproject.toml", pins))
+	} else if fileExists(dir, "requirements.txt") {
+		langs = append(langs, detectPython(dir, "requirements.txt", pins))
+	} else if fileExists(dir, "Pipfile") {
+		langs = append(langs, detectPython(dir, "Pipfile", pins))
+	}
+
+	if fileExists(dir, "Gemfile") {
+		langs = append(langs, detectRuby(dir, pins))
+	}
+
+	if manifest := dotnetManifest(dir); manifest != "" {
+		langs = append(langs, detectDotNet(dir, manifest, pins))
+	}
+
+	return langs
+}
+
+// mergeMember folds the language of the member at rel into langs. A new
+// runtime is appended, with its shell init (venv activation) run in the
+// member's directory; an already-detected one keeps its version and shell
+// init, and gains the member's dependency install plus any runtime
+// commands it doesn't have (e.g. Poetry in a member of a pip project).
+func mergeMember(langs []Language, lang Language, rel string) []Language {
+	lang.ShellInit = memberShellInit(rel, lang.ShellInit)
+
+	for i := range langs {
+		if langs[i].Name != lang.Name {
+			continue
+		}
+		// Only one runtime version is the VM-wide default.
+		skip := ""
+		if lang.Tool != "" {
+			skip = miseUse(lang.Tool, lang.Version)
+		}
+		for _, cmd := range lang.RuntimeInstall {
+			if cmd != skip && !containsString(langs[i].RuntimeInstall, cmd) {
+				langs[i].RuntimeInstall = append(langs[i].RuntimeInstall, cmd)
+			}
+		}
+		langs[i].DepInstall = append(langs[i].DepInstall, lang.DepInstall...)
+		return langs
+	}
+	return append(langs, lang)
+}
+
+// memberDepInstall runs a member's dependency install commands in its
+// directory. The subshell keeps the cd from leaking into later commands.
+func memberDepInstall(rel string, cmds []string) []string {
+	if len(cmds) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("(set -e; cd %s; %s)", shellQuote(rel), strings.Join(cmds, "; "))}
+}
+
+// memberShellInit runs a member's shell init in its directory, so relative
+// paths like a venv's resolve, then returns to the project root. Unlike
+// installs it can't use a subshell: its exports must reach the command.
+func memberShellInit(rel string, cmds []string) []string {
+	if len(cmds) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("if cd %s 2>/dev/null; then %s; cd - >/dev/null; fi", shellQuote(rel), strings.Join(cmds, "; "))}
+}
+
+// workspaceDisplayName describes where a runtime was detected, e.g.
+// "Node (package.json) + 3 workspace members" or "Go (go.mod) in services/api".
+func workspaceDisplayName(base string, dirs []string) string {
+	if len(dirs) == 0 {
+		return base
+	}
+	name := base
+	rest := len(dirs) - 1
+	if dirs[0] != "." {
+		name = fmt.Sprintf("%s in %s", base, dirs[0])
+	}
+	switch {
+	case rest == 0:
+		return name
+	case dirs[0] != ".":
+		return fmt.Sprintf("%s + %d more", name, rest)
+	case rest == 1:
+		return fmt.Sprintf("%s + 1 workspace member", name)
+	default:
+		return fmt.Sprintf("%s + %d workspace members", name, rest)
+	}
+}
+
+// discoverWorkspace finds workspace member directories below root.
+func discoverWorkspace(root string, configured []string, excluded ExcludeFunc) workspace {
+	ws := workspace{covered: make(map[LanguageName]map[string]bool)}
+	found := make(map[string]bool)
+	add := func(rels []string, covers LanguageName) {
+		for _, rel := range rels {
+			found[rel] = true
+			if covers != "" {
+				if ws.covered[covers] == nil {
+					ws.covered[covers] = make(map[string]bool)
+				}
+				ws.covered[covers][rel] = true
+			}
+		}
+	}
+
+	add(expandMembers(root, nodeWorkspacePatterns(root)), Node)
+	add(expandMembers(root, cargoWorkspacePatterns(root)), Rust)
+	add(expandMembers(root, goWorkUses(root)), "")
+	add(expandMembers(root, configured), "")
+	add(nestedManifestDirs(root, excluded), "")
+
+	for rel := range found {
+		ws.members = append(ws.members, rel)
+	}
+	sort.Strings(ws.members)
+	return ws
+}
+
+// nodeWorkspacePatterns returns member globs from package.json "workspaces"
+// (npm, yarn) or pnpm-workspace.yaml.
+func nodeWorkspacePatterns(root string) []string {
+	var patterns []string
+	if content, err := os.ReadFile(filepath.Join(root, "package.json")); err == nil {
+		var pkg struct {
+			Workspaces json.RawMessage `json:"workspaces"`
+		}
+		if json.Unmarshal(content, &pkg) == nil && len(pkg.Workspaces) > 0 {
+			// Either a list of globs or {"packages": [...]} (yarn classic).
+			var list []string
+			var obj struct {
+				Packages []string `json:"packages"`
+			}
+			if json.Unmarshal(pkg.Workspaces, &list) == nil {
+				patterns = append(patterns, list...)
+			} else if json.Unmarshal(pkg.Workspaces, &obj) == nil {
+				patterns = append(patterns, obj.Packages...)
+			}
+		}
+	}
+	if content, err := os.ReadFile(filepath.Join(root, "pnpm-workspace.yaml")); err == nil {
+		var doc struct {
+			Packages []string `yaml:"packages"`
+		}
+		if yaml.Unmarshal(content, &doc) == nil {
+			patterns = append(patterns, doc.Packages...)
+		}
+	}
+	return patterns
+}
+
+// cargoWorkspacePatterns returns [workspace] members from the root Cargo.toml,
+// with excluded paths as negated patterns.
+func cargoWorkspacePatterns(root string) []string {
+	content, err := os.ReadFile(filepath.Join(root, "Cargo.toml"))
+	if err != nil {
+		return nil
+	}
+	var doc struct {
+		Workspace struct {
+			Members []string `toml:"members"`
+			Exclude []string `toml:"exclude"`
+		} `toml:"workspace"`
+	}
+	if toml.Unmarshal(content, &doc) != nil {
+		return nil
+	}
+	patterns := doc.Workspace.Members
+	for _, ex := range doc.Workspace.Exclude {
+		patterns = append(patterns, "!"+ex)
+	}
+	return patterns
+}
+
+var (
+	goWorkUseLineRe  = regexp.MustCompile(`(?m)^use\s+([^\s(]+)\s*$`)
+	goWorkUseBlockRe = regexp.MustCompile(`(?s)\buse\s*\((.*?)\)`)
+)
+
+// goWorkUses returns the module directories listed by go.work use directives.
+func goWorkUses(root string) []string {
+	content, err := os.ReadFile(filepath.Join(root, "go.work"))
+	if err != nil {
+		return nil
+	}
+	var uses []string
+	for _, m := range goWorkUseLineRe.FindAllStringSubmatch(string(content), -1) {
+		uses = append(uses, m[1])
+	}
+	for _, m := range goWorkUseBlockRe.FindAllStringSubmatch(string(content), -1) {
+		for _, line := range strings.Split(m[1], "\n") {
+			if i := strings.Index(line, "//"); i >= 0 {
+				line = line[:i]
+			}
+			if line = strings.TrimSpace(line); line != "" {
+				uses = append(uses, line)
+			}
+		}
+	}
+	return uses
+}
+
+// expandMembers resolves member globs to directories below root, as
+// slash-separated relative paths. Patterns prefixed with "!" exclude.
+// Paths outside root and the root itself are dropped.
+func expandMembers(root string, patterns []string) []string {
+	included := make(map[string]bool)
+	excluded := make(map[string]bool)
+	for _, pattern := range patterns {
+		target := included
+		if strings.HasPrefix(pattern, "!") {
+			target = excluded
+			pattern = pattern[1:]
+		}
+		// filepath.Glob has no "**"; one level covers the usual "packages/**".
+		pattern = strings.ReplaceAll(strings.TrimSpace(pattern), "**", "*")
+		if pattern == "" {
+			continue
+		}
+		matches, _ := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern)))
+		for _, m := range matches {
+			rel, err := filepath.Rel(root, m)
+			if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+			if info, err := os.Stat(m); err != nil || !info.IsDir() {
+				continue
+			}
+			target[filepath.ToSlash(rel)] = true
+		}
+	}
+
+	var rels []string
+	for rel := range included {
+		if !excluded[rel] {
+			rels = append(rels, rel)
+		}
+	}
+	sort.Strings(rels)
+	return rels
+}
+
+// nestedManifestDirs returns directories up to maxWorkspaceDepth below root
+// that hold a known manifest. Hidden and dependency/build directories are
+// skipped, as are directories and manifests excluded reports, if not nil.
+func nestedManifestDirs(root string, excluded ExcludeFunc) []string {
+	if excluded == nil {
+		excluded = func(string) bool { return false }
+	}
+	var rels []string
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() || path == root {
+			return nil
+		}
+		name := d.Name()
+		if strings.HasPrefix(name, ".") || workspaceSkipDirs[name] {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return filepath.SkipDir
+		}
+		rel = filepath.ToSlash(rel)
+		if excluded(rel) {
+			return filepath.SkipDir
+		}
+		for _, name := range manifests(path) {
+			if !excluded(rel + "/" + name) {
+				rels = append(rels, rel)
+				break
+			}
+		}
+		if strings.Count(rel, "/")+1 >= maxWorkspaceDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return rels
+}
+
+// manifests returns the manifests in dir that detectDir recognizes.
+func manifests(dir string) []string {
+	var names []string
+	for _, name := range []string{"Cargo.toml", "package.json", "go.mod", "pyproject.toml", "requirements.txt", "Pipfile", "Gemfile"} {
+		if fileExists(dir, name) {
+			names = append(names, name)
+		}
+	}
+	if name := dotnetManifest(dir); name != "" {
+		names = append(names, name)
+	}
+	return names
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+package provision
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+func findLanguage(langs []Language, name LanguageName) *Language {
+	for i := range langs {
+		if langs[i].Name == name {
+			return &langs[i]
+		}
+	}
+	return nil
+}
+
+func TestDetectWorkspaceLanguages_Monorepo(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"package.json":         "{}",
+		"package-lock.json":    "{}",
+		"services/api/go.mod":  "module api\n\ngo 1.23.0\n",
+		"tools/cli/Cargo.toml": "[package]\nname = \"cli\"\n",
+	})
+
+	langs := DetectWorkspaceLanguages(dir, nil, false, nil)
+	require.Len(t, langs, 3)
+	assert.Equal(t, []LanguageName{Node, Go, Rust}, []LanguageName{langs[0].Name, langs[1].Name, langs[2].Name})
+
+	assert.Equal(t, []string{"npm ci"}, langs[0].DepInstall)
+	assert.Equal(t, "Node (package.json)", langs[0].DisplayName)
+
+	assert.Equal(t, "1.23.0", langs[1].Version)
+	assert.Equal(t, []string{"(set -e; cd 'services/api'; go mod download)"}, langs[1].DepInstall)
+	assert.Equal(t, "Go (go.mod) in services/api", langs[1].DisplayName)
+
+	assert.Equal(t, []string{"(set -e; cd 'tools/cli'; cargo fetch)"}, langs[2].DepInstall)
+}
+
+func TestDetectWorkspaceLanguages_NodeWorkspacesInstallFromRoot(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"package.json":                  `{"workspaces": ["packages/*"]}`,
+		"package-lock.json":             "{}",
+		"packages/web/package.json":     "{}",
+		"packages/api/package.json":     "{}",
+		"packages/api/requirements.txt": "flask\n",
+	})
+
+	langs := DetectWorkspaceLanguages(dir, nil, false, nil)
+	node := findLanguage(langs, Node)
+	require.NotNil(t, node)
+	assert.Equal(t, []string{"npm ci"}, node.DepInstall, "npm installs workspace members from the root")
+	assert.Equal(t, "Node (package.json) + 2 workspace members", node.DisplayName)
+
+	py := findLanguage(langs, Python)
+	require.NotNil(t, py)
+	require.Len(t, py.DepInstall, 1)
+	assert.Contains(t, py.DepInstall[0], "(set -e; cd 'packages/api'; [ -d .venv ] || uv venv")
+	require.Len(t, py.ShellInit, 1, "the only Python project's venv is activated")
+	assert.Equal(t, "if cd 'packages/api' 2>/dev/null; then if [ -f .venv/bin/activate ]; then . .venv/bin/activate; fi; cd - >/dev/null; fi", py.ShellInit[0])
+
+	// It's activated from the project root, which commands still run in.
+	writeFiles(t, dir, map[string]string{"packages/api/.venv/bin/activate": `export VIRTUAL_ENV="$PWD/.venv"`})
+	script := py.ShellInit[0] + "\necho \"$PWD $VIRTUAL_ENV\"\n"
+	cmd := exec.Command("bash", "-ec", script)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.Equal(t, dir+" "+filepath.Join(dir, "packages/api/.venv")+"\n", string(out))
+}
+
+func TestDetectWorkspaceLanguages_CargoWorkspace(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Cargo.toml":                        "[workspace]\nmembers = [\"crates/*\"]\nexclude = [\"crates/legacy\"]\n",
+		"crates/core/Cargo.toml":            "[package]\nname = \"core\"\n",
+		"crates/deep/nested/x/y/Cargo.toml": "[package]\nname = \"deep\"\n",
+	})
+
+	langs := DetectWorkspaceLanguages(dir, nil, false, nil)
+	require.Len(t, langs, 1)
+	assert.Equal(t, []string{"cargo fetch"}, langs[0].DepInstall)
+}
+
+func TestDetectWorkspaceLanguages_GoWork(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.work":           "go 1.22\n\nuse (\n\t./a/b/c/mod1 // deep\n\t./mod2\n)\n",
+		"a/b/c/mod1/go.mod": "module mod1\n\ngo 1.22.1\n",
+		"mod2/go.mod":       "module mod2\n\ngo 1.22.1\n",
+	})
+
+	langs := DetectWorkspaceLanguages(dir, nil, false, nil)
+	require.Len(t, langs, 1)
+	assert.Equal(t, []string{
+		"(set -e; cd 'a/b/c/mod1'; go mod download)",
+		"(set -e; cd 'mod2'; go mod download)",
+	}, langs[0].DepInstall)
+	assert.Equal(t, "Go (go.mod) in a/b/c/mod1 + 1 more", langs[0].DisplayName)
+}
+
+func TestDetectWorkspaceLanguages_ConfiguredMembers(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"deep/er/than/limit/Gemfile": "source 'https://rubygems.org'\n",
+	})
+
+	assert.Empty(t, DetectWorkspaceLanguages(dir, nil, false, nil), "beyond the scan depth")
+
+	langs := DetectWorkspaceLanguages(dir, []string{"deep/er/than/*", "../outside"}, false, nil)
+	require.Len(t, langs, 1)
+	assert.Equal(t, Ruby, langs[0].Name)
+	assert.Equal(t, []string{"(set -e; cd 'deep/er/than/limit'; bundle install)"}, langs[0].DepInstall)
+}
+
+func TestDetectWorkspaceLanguages_RootVersionWins(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"package.json":          "{}",
+		".nvmrc":                "20\n",
+		"apps/web/package.json": "{}",
+		"apps/web/.nvmrc":       "22\n",
+	})
+
+	node := findLanguage(DetectWorkspaceLanguages(dir, nil, false, nil), Node)
+	require.NotNil(t, node)
+	assert.Equal(t, "20", node.Version)
+	assert.Equal(t, []string{miseUse("node", "20")}, node.RuntimeInstall)
+	assert.Equal(t, []string{"npm install", "(set -e; cd 'apps/web'; npm install)"}, node.DepInstall)
+}
+
+func TestDetectWorkspaceLanguages_MemberAddsToolInstall(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"requirements.txt":   "flask\n",
+		"svc/pyproject.toml": "[tool.poetry]\nname = \"svc\"\n",
+		"svc/poetry.lock":    "",
+	})
+
+	py := findLanguage(DetectWorkspaceLanguages(dir, nil, false, nil), Python)
+	require.NotNil(t, py)
+	assert.Contains(t, py.RuntimeInstall, "UV_TOOL_DIR=/opt/uv/tools UV_TOOL_BIN_DIR=/usr/local/bin uv tool install poetry")
+	assert.Len(t, py.ShellInit, 1, "root venv is still activated")
+}
+
+func TestNestedManifestDirs_SkipsDependencyDirs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"node_modules/left-pad/package.json": "{}",
+		".cache/x/go.mod":                    "module x\n",
+		"vendor/lib/go.mod":                  "module lib\n",
+		"testdata/fixture/Cargo.toml":        "",
+		"web/package.json":                   "{}",
+	})
+
+	assert.Equal(t, []string{"web"}, nestedManifestDirs(dir, nil))
+}
+
+func TestNestedManifestDirs_SkipsExcluded(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"out/app/package.json":    "{}",
+		"fixtures/pyproject.toml": "[project]\nname = \"x\"\n",
+		"web/package.json":        "{}",
+		"api/go.mod":              "module api\n",
+	})
+	excluded := func(rel string) bool {
+		return rel == "out" || rel == "fixtures/pyproject.toml"
+	}
+
+	assert.Equal(t, []string{"api", "web"}, nestedManifestDirs(dir, excluded))
+}
+
+func TestNodeWorkspacePatterns(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{"npm list", map[string]string{"package.json": `{"workspaces": ["packages/*", "apps/web"]}`}, []string{"packages/*", "apps/web"}},
+		{"yarn object", map[string]string{"package.json": `{"workspaces": {"packages": ["libs/*"]}}`}, []string{"libs/*"}},
+		{"pnpm", map[string]string{"pnpm-workspace.yaml": "packages:\n  - 'packages/*'\n  - '!**/test/**'\n"}, []string{"packages/*", "!**/test/**"}},
+		{"no workspaces", map[string]string{"package.json": `{"name": "app"}`}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			assert.Equal(t, tt.want, nodeWorkspacePatterns(dir))
+		})
+	}
+}
+
+func TestWorkspaceDisplayName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "Go (go.mod)", workspaceDisplayName("Go (go.mod)", []string{"."}))
+	assert.Equal(t, "Go (go.mod) + 1 workspace member", workspaceDisplayName("Go (go.mod)", []string{".", "a"}))
+	assert.Equal(t, "Go (go.mod) in a", workspaceDisplayName("Go (go.mod)", []string{"a"}))
+	assert.Equal(t, "Go (go.mod) in a + 2 more", workspaceDisplayName("Go (go.mod)", []string{"a", "b", "c"}))
+}
@@ -0,0 +1,104 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// Reference prefixes for secret values in .yeager.toml.
+const (
+	SSMPrefix            = "ssm:"
+	SecretsManagerPrefix = "secretsmanager:"
+)
+
+// SSMAPI is the subset of the SSM client used to read parameters.
+type SSMAPI interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+// SecretsManagerAPI is the subset of the Secrets Manager client used to read secrets.
+type SecretsManagerAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// Resolver fetches secret values from SSM Parameter Store and Secrets Manager.
+type Resolver struct {
+	ssm SSMAPI
+	sm  SecretsManagerAPI
+}
+
+// NewResolver creates a Resolver with the given clients.
+func NewResolver(ssmAPI SSMAPI, smAPI SecretsManagerAPI) *Resolver {
+	return &Resolver{ssm: ssmAPI, sm: smAPI}
+}
+
+// ValidateRef checks a secret reference's syntax without fetching it:
+//
+//	ssm:/path/to/parameter        a String or SecureString parameter
+//	secretsmanager:name           a secret's string value
+//	secretsmanager:name#key       one key of a JSON secret
+func ValidateRef(ref string) error {
+	switch {
+	case strings.HasPrefix(ref, SSMPrefix) && len(ref) > len(SSMPrefix):
+		return nil
+	case strings.HasPrefix(ref, SecretsManagerPrefix):
+		id, key, hasKey := strings.Cut(strings.TrimPrefix(ref, SecretsManagerPrefix), "#")
+		if id != "" && (!hasKey || key != "") {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid secret reference %q: want ssm:<parameter> or secretsmanager:<secret>[#key]", ref)
+}
+
+// Resolve returns the value a secret reference points at.
+func (r *Resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	if err := ValidateRef(ref); err != nil {
+		return "", err
+	}
+
+	if name, ok := strings.CutPrefix(ref, SSMPrefix); ok {
+		out, err := r.ssm.GetParameter(ctx, &ssm.GetParameterInput{
+			Name:           aws.String(name),
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			return "", fmt.Errorf("reading SSM parameter %s: %w", name, err)
+		}
+		if out.Parameter == nil {
+			return "", fmt.Errorf("reading SSM parameter %s: no value", name)
+		}
+		return aws.ToString(out.Parameter.Value), nil
+	}
+
+	id, key, hasKey := strings.Cut(strings.TrimPrefix(ref, SecretsManagerPrefix), "#")
+	out, err := r.sm.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(id)})
+	if err != nil {
+		return "", fmt.Errorf("reading secret %s: %w", id, err)
+	}
+	if out.SecretString == nil {
+		return "", fmt.Errorf("reading secret %s: binary secrets are not supported", id)
+	}
+	if !hasKey {
+		return *out.SecretString, nil
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(*out.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("reading secret %s: not a JSON object, so it has no key %q", id, key)
+	}
+	value, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("reading secret %s: no key %q", id, key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded), nil
+}
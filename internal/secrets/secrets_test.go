@@ -0,0 +1,107 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- Mocks ---
+
+type mockSSM struct {
+	params map[string]string
+	calls  []ssm.GetParameterInput
+}
+
+func (m *mockSSM) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	m.calls = append(m.calls, *params)
+	v, ok := m.params[aws.ToString(params.Name)]
+	if !ok {
+		return nil, fmt.Errorf("ParameterNotFound")
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssmtypes.Parameter{Value: aws.String(v)}}, nil
+}
+
+type mockSecretsManager struct {
+	secrets map[string]string
+}
+
+func (m *mockSecretsManager) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	v, ok := m.secrets[aws.ToString(params.SecretId)]
+	if !ok {
+		return nil, fmt.Errorf("ResourceNotFoundException")
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(v)}, nil
+}
+
+// --- Tests ---
+
+func TestValidateRef(t *testing.T) {
+	t.Parallel()
+
+	for _, ref := range []string{"ssm:/app/key", "ssm:key", "secretsmanager:prod/db", "secretsmanager:prod/db#password"} {
+		assert.NoError(t, ValidateRef(ref), ref)
+	}
+	for _, ref := range []string{"", "plain", "ssm:", "secretsmanager:", "secretsmanager:#key", "secretsmanager:db#", "vault:x"} {
+		assert.Error(t, ValidateRef(ref), ref)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	ssmAPI := &mockSSM{params: map[string]string{"/app/api-key": "s3cr3t"}}
+	r := NewResolver(ssmAPI, &mockSecretsManager{secrets: map[string]string{
+		"prod/token": "tok",
+		"prod/db":    `{"password": "pw", "port": 5432}`,
+	}})
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"ssm:/app/api-key", "s3cr3t"},
+		{"secretsmanager:prod/token", "tok"},
+		{"secretsmanager:prod/db#password", "pw"},
+		{"secretsmanager:prod/db#port", "5432"},
+	}
+	for _, tt := range tests {
+		got, err := r.Resolve(context.Background(), tt.ref)
+		require.NoError(t, err, tt.ref)
+		assert.Equal(t, tt.want, got, tt.ref)
+	}
+	require.NotEmpty(t, ssmAPI.calls)
+	assert.True(t, aws.ToBool(ssmAPI.calls[0].WithDecryption), "SecureString parameters are decrypted")
+}
+
+func TestResolve_Errors(t *testing.T) {
+	t.Parallel()
+
+	r := NewResolver(&mockSSM{}, &mockSecretsManager{secrets: map[string]string{
+		"plain": "not json",
+		"json":  `{"a": "b"}`,
+	}})
+
+	tests := []struct {
+		ref     string
+		wantErr string
+	}{
+		{"ssm:/missing", "reading SSM parameter /missing"},
+		{"secretsmanager:missing", "reading secret missing"},
+		{"secretsmanager:plain#key", "not a JSON object"},
+		{"secretsmanager:json#c", `no key "c"`},
+		{"env:HOME", "invalid secret reference"},
+	}
+	for _, tt := range tests {
+		_, err := r.Resolve(context.Background(), tt.ref)
+		require.Error(t, err, tt.ref)
+		assert.Contains(t, err.Error(), tt.wantErr, tt.ref)
+	}
+}
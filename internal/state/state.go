@@ -19,12 +19,22 @@ const (
 
 // VMState represents the persisted state for a project's VM.
 type VMState struct {
-	InstanceID        string    `json:"instance_id"`
-	Region            string    `json:"region"`
-	Created           time.Time `json:"created"`
-	ProjectDir        string    `json:"project_dir"`
-	SetupHash         string    `json:"setup_hash,omitempty"`
-	CloudInitVersion  int       `json:"cloud_init_version,omitempty"`
+	InstanceID       string    `json:"instance_id"`
+	Region           string    `json:"region"`
+	Created          time.Time `json:"created"`
+	ProjectDir       string    `json:"project_dir"`
+	SetupHash        string    `json:"setup_hash,omitempty"`
+	CloudInitVersion int       `json:"cloud_init_version,omitempty"`
+	// RuntimeVersions records the runtime version installed per language
+	// so version bumps can be applied without recreating the VM.
+	RuntimeVersions map[string]string `json:"runtime_versions,omitempty"`
+	// DepsHash is provision.DependencyHash as of the last dependency
+	// install that succeeded; empty until one has. Dependencies are
+	// installed again whenever it's missing or stale.
+	DepsHash string `json:"deps_hash,omitempty"`
+	// Temporary VMs are terminated instead of stopped once idle: those for
+	// one-off --size, --region or --spot overrides, and --ephemeral runs.
+	Temporary bool `json:"temporary,omitempty"`
 }
 
 // Store manages yeager state on the local filesystem.
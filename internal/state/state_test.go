@@ -150,6 +150,24 @@ func TestSaveAndLoadVMWithSetupHash(t *testing.T) {
 	assert.Equal(t, want.InstanceID, got.InstanceID)
 }
 
+func TestSaveAndLoadVMWithRuntimeVersions(t *testing.T) {
+	t.Parallel()
+
+	store, err := NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	want := VMState{
+		InstanceID:      "i-runtime001",
+		Region:          "us-east-1",
+		RuntimeVersions: map[string]string{"node": "20", "python": "3.12"},
+	}
+	require.NoError(t, store.SaveVM("runtimes", want))
+
+	got, err := store.LoadVM("runtimes")
+	require.NoError(t, err)
+	assert.Equal(t, want.RuntimeVersions, got.RuntimeVersions)
+}
+
 func TestLoadVMCorruptFile(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// BlobS3API is the subset of the S3 client used to store blobs.
+type BlobS3API interface {
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// PresignAPI presigns S3 requests, like s3.PresignClient.
+type PresignAPI interface {
+	PresignGetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+}
+
+const (
+	// blobPartSize is the size of each part of a multipart upload; S3
+	// needs at least 5 MiB for all but the last.
+	blobPartSize = 16 << 20
+	// blobConcurrency is how many parts are uploaded at once.
+	blobConcurrency = 8
+	// blobURLExpiry is how long a download URL for the VM stays valid.
+	blobURLExpiry = time.Hour
+)
+
+// BlobStore keeps file contents in S3 under the project's prefix, keyed
+// by SHA-256, so a file is uploaded once for every run, VM and teammate
+// sharing the bucket. The VM downloads them with presigned URLs.
+type BlobStore struct {
+	s3      BlobS3API
+	presign PresignAPI
+	bucket  string
+	project string
+}
+
+// NewBlobStore creates a BlobStore for a project's blobs in bucket.
+func NewBlobStore(s3api BlobS3API, presign PresignAPI, bucket, projectName string) *BlobStore {
+	return &BlobStore{s3: s3api, presign: presign, bucket: bucket, project: projectName}
+}
+
+// BlobKey returns the S3 key of a project's blob.
+func BlobKey(projectName, hash string) string {
+	return fmt.Sprintf("%s/blobs/%s", projectName, hash)
+}
+
+func (b *BlobStore) key(hash string) string {
+	return BlobKey(b.project, hash)
+}
+
+// Has reports whether the blob for hash is stored.
+func (b *BlobStore) Has(ctx context.Context, hash string) (bool, error) {
+	_, err := b.s3.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key(hash)),
+	})
+	var notFound *s3types.NotFound
+	if errors.As(err, &notFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("checking s3://%s/%s: %w", b.bucket, b.key(hash), err)
+	}
+	return true, nil
+}
+
+// Put stores size bytes of r as the blob for hash. Anything over one part
+// is uploaded in parts, several at once.
+func (b *BlobStore) Put(ctx context.Context, hash string, r io.ReaderAt, size int64) error {
+	key := b.key(hash)
+	if size <= blobPartSize {
+		_, err := b.s3.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:        aws.String(b.bucket),
+			Key:           aws.String(key),
+			Body:          io.NewSectionReader(r, 0, size),
+			ContentLength: aws.Int64(size),
+			ContentType:   aws.String("application/octet-stream"),
+		})
+		if err != nil {
+			return fmt.Errorf("putting object s3://%s/%s: %w", b.bucket, key, err)
+		}
+		return nil
+	}
+
+	created, err := b.s3.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(b.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String("application/octet-stream"),
+	})
+	if err != nil {
+		return fmt.Errorf("starting upload to s3://%s/%s: %w", b.bucket, key, err)
+	}
+	parts, err := b.uploadParts(ctx, key, created.UploadId, r, size)
+	if err == nil {
+		_, err = b.s3.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(b.bucket),
+			Key:             aws.String(key),
+			UploadId:        created.UploadId,
+			MultipartUpload: &s3types.CompletedMultipartUpload{Parts: parts},
+		})
+	}
+	if err != nil {
+		// The bucket's lifecycle rule cleans up after this too, a day later.
+		if _, abortErr := b.s3.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(b.bucket),
+			Key:      aws.String(key),
+			UploadId: created.UploadId,
+		}); abortErr != nil {
+			slog.Debug("aborting multipart upload failed", "key", key, "error", abortErr)
+		}
+		return fmt.Errorf("uploading s3://%s/%s: %w", b.bucket, key, err)
+	}
+	return nil
+}
+
+// uploadParts uploads r in blobPartSize parts, blobConcurrency at a time,
+// and returns them in order.
+func (b *BlobStore) uploadParts(ctx context.Context, key string, uploadID *string, r io.ReaderAt, size int64) ([]s3types.CompletedPart, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		parts    []s3types.CompletedPart
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	sem := make(chan struct{}, blobConcurrency)
+	for n, off := int32(1), int64(0); off < size; n, off = n+1, off+blobPartSize {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		length := min(int64(blobPartSize), size-off)
+		wg.Add(1)
+		go func(n int32, off, length int64) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			out, err := b.s3.UploadPart(ctx, &s3.UploadPartInput{
+				Bucket:        aws.String(b.bucket),
+				Key:           aws.String(key),
+				UploadId:      uploadID,
+				PartNumber:    aws.Int32(n),
+				Body:          io.NewSectionReader(r, off, length),
+				ContentLength: aws.Int64(length),
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("part %d: %w", n, err)
+					cancel()
+				}
+				return
+			}
+			parts = append(parts, s3types.CompletedPart{ETag: out.ETag, PartNumber: aws.Int32(n)})
+		}(n, off, length)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(parts, func(i, j int) bool { return *parts[i].PartNumber < *parts[j].PartNumber })
+	return parts, nil
+}
+
+// URL returns a presigned URL the VM can download the blob for hash from,
+// valid for an hour.
+func (b *BlobStore) URL(ctx context.Context, hash string) (string, error) {
+	req, err := b.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key(hash)),
+	}, s3.WithPresignExpires(blobURLExpiry))
+	if err != nil {
+		return "", fmt.Errorf("presigning s3://%s/%s: %w", b.bucket, b.key(hash), err)
+	}
+	return req.URL, nil
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- Mock blob S3 ---
+
+// mockBlobS3 keeps objects in memory. Parts are uploaded concurrently, so
+// everything is behind a mutex.
+type mockBlobS3 struct {
+	mu        sync.Mutex
+	objects   map[string][]byte
+	parts     map[int32][]byte
+	partErr   error
+	headErr   error
+	puts      int
+	completed []s3types.CompletedPart
+	aborted   bool
+}
+
+func newMockBlobS3() *mockBlobS3 {
+	return &mockBlobS3{objects: map[string][]byte{}, parts: map[int32][]byte{}}
+}
+
+func (m *mockBlobS3) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.headErr != nil {
+		return nil, m.headErr
+	}
+	if _, ok := m.objects[*params.Key]; !ok {
+		return nil, &s3types.NotFound{}
+	}
+	return &s3.HeadObjectOutput{}, nil
+}
+
+func (m *mockBlobS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.puts++
+	m.objects[*params.Key] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *mockBlobS3) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+}
+
+func (m *mockBlobS3) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.partErr != nil && *params.PartNumber == 2 {
+		return nil, m.partErr
+	}
+	m.parts[*params.PartNumber] = data
+	return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf("etag-%d", *params.PartNumber))}, nil
+}
+
+func (m *mockBlobS3) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.completed = params.MultipartUpload.Parts
+	var data []byte
+	for _, p := range m.completed {
+		data = append(data, m.parts[*p.PartNumber]...)
+	}
+	m.objects[*params.Key] = data
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (m *mockBlobS3) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.aborted = true
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+type mockPresign struct{}
+
+func (mockPresign) PresignGetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	return &v4.PresignedHTTPRequest{URL: fmt.Sprintf("https://%s.s3.amazonaws.com/%s?X-Amz-Signature=x", *params.Bucket, *params.Key)}, nil
+}
+
+// --- Tests ---
+
+func TestBlobKey(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "my-app/blobs/abc123", BlobKey("my-app", "abc123"))
+}
+
+func TestBlobStoreHas(t *testing.T) {
+	t.Parallel()
+
+	mock := newMockBlobS3()
+	mock.objects["my-app/blobs/abc"] = []byte("x")
+	store := NewBlobStore(mock, mockPresign{}, "bucket", "my-app")
+
+	has, err := store.Has(context.Background(), "abc")
+	require.NoError(t, err)
+	assert.True(t, has)
+
+	has, err = store.Has(context.Background(), "def")
+	require.NoError(t, err)
+	assert.False(t, has)
+
+	mock.headErr = errors.New("access denied")
+	_, err = store.Has(context.Background(), "abc")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "s3://bucket/my-app/blobs/abc")
+}
+
+func TestBlobStorePut(t *testing.T) {
+	t.Parallel()
+
+	t.Run("small file in one request", func(t *testing.T) {
+		t.Parallel()
+		mock := newMockBlobS3()
+		store := NewBlobStore(mock, mockPresign{}, "bucket", "my-app")
+
+		require.NoError(t, store.Put(context.Background(), "abc", bytes.NewReader([]byte("hello")), 5))
+		assert.Equal(t, 1, mock.puts)
+		assert.Equal(t, []byte("hello"), mock.objects["my-app/blobs/abc"])
+	})
+
+	t.Run("large file in parts", func(t *testing.T) {
+		t.Parallel()
+		mock := newMockBlobS3()
+		store := NewBlobStore(mock, mockPresign{}, "bucket", "my-app")
+
+		data := make([]byte, 2*blobPartSize+1000)
+		for i := range data {
+			data[i] = byte(i % 251)
+		}
+		require.NoError(t, store.Put(context.Background(), "big", bytes.NewReader(data), int64(len(data))))
+		assert.Zero(t, mock.puts)
+		require.Len(t, mock.completed, 3)
+		for i, p := range mock.completed {
+			assert.Equal(t, int32(i+1), *p.PartNumber, "parts in order")
+		}
+		assert.Equal(t, data, mock.objects["my-app/blobs/big"])
+		assert.False(t, mock.aborted)
+	})
+
+	t.Run("failed part aborts the upload", func(t *testing.T) {
+		t.Parallel()
+		mock := newMockBlobS3()
+		mock.partErr = errors.New("connection reset")
+		store := NewBlobStore(mock, mockPresign{}, "bucket", "my-app")
+
+		data := make([]byte, 3*blobPartSize)
+		err := store.Put(context.Background(), "big", bytes.NewReader(data), int64(len(data)))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "part 2: connection reset")
+		assert.True(t, mock.aborted)
+		assert.NotContains(t, mock.objects, "my-app/blobs/big")
+	})
+}
+
+func TestBlobStoreURL(t *testing.T) {
+	t.Parallel()
+
+	store := NewBlobStore(newMockBlobS3(), mockPresign{}, "bucket", "my-app")
+	url, err := store.URL(context.Background(), "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://bucket.s3.amazonaws.com/my-app/blobs/abc?X-Amz-Signature=x", url)
+}
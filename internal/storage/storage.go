@@ -30,6 +30,17 @@ type RunMeta struct {
 	StartTime time.Time `json:"start_time"`
 	EndTime   time.Time `json:"end_time"`
 	Duration  string    `json:"duration"`
+	Task      *TaskMeta `json:"task,omitempty"`
+}
+
+// TaskMeta describes the [tasks] entry a run came from.
+type TaskMeta struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Profile     string   `json:"profile,omitempty"`
+	Timeout     string   `json:"timeout,omitempty"`
+	DependsOn   []string `json:"depends_on,omitempty"`
+	Target      string   `json:"target,omitempty"` // the task that was asked for, if this one ran as its dependency
 }
 
 // Store handles S3 output storage operations.
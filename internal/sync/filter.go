@@ -0,0 +1,532 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gridlhq/yeager/internal/config"
+	"github.com/gridlhq/yeager/internal/provision"
+)
+
+const (
+	// GitignoreFile is read in every synced directory, like rsync's
+	// "--filter ':- .gitignore'".
+	GitignoreFile = ".gitignore"
+	// YeagerignoreFile is read in every synced directory too. It has
+	// gitignore syntax, only affects sync, and wins over .gitignore.
+	YeagerignoreFile = ".yeagerignore"
+)
+
+// rule is one include or exclude pattern. A trailing "/" matches only
+// directories. A leading "/" anchors the pattern to the directory it's
+// relative to; so does any other "/" in a .gitignore pattern. Unanchored
+// patterns match the end of the path at a "/" boundary, so "*.log" matches
+// at any depth. "*" and "?" don't cross "/"; "**" does.
+type rule struct {
+	pattern string // as written, for Explain
+	source  string // where it's from, for Explain
+	include bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// newRule parses a pattern. ok is false for an empty pattern.
+func newRule(pattern string, include, gitignore bool) (r rule, ok bool) {
+	r.pattern = pattern
+	r.include = include
+	if strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	anchored := strings.HasPrefix(pattern, "/")
+	pattern = strings.TrimLeft(pattern, "/")
+	if pattern == "" {
+		return rule{}, false
+	}
+	if gitignore && strings.Contains(pattern, "/") {
+		anchored = true
+	}
+
+	prefix := "(^|/)"
+	if anchored {
+		prefix = "^"
+	}
+	re, err := regexp.Compile(prefix + globToRegexp(pattern) + "$")
+	if err != nil {
+		return rule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+func (r rule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	return r.re.MatchString(rel)
+}
+
+// parseRules parses patterns like [sync] pull's, skipping empty ones.
+func parseRules(patterns []string) []rule {
+	var rules []rule
+	for _, p := range patterns {
+		if r, ok := newRule(p, true, false); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// matchUnder reports whether the file rel or one of its directories
+// matches a rule.
+func matchUnder(rules []rule, rel string) bool {
+	for _, r := range rules {
+		if r.match(rel, false) {
+			return true
+		}
+		for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+			if r.match(dir, true) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// globToRegexp translates a glob to a regular expression body.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					b.WriteString("(.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// Filter decides which files under a project root are synced. The first
+// rule that matches a path decides:
+//
+//  1. [sync] include
+//  2. .yeagerignore files, the deepest first
+//  3. .gitignore files, the deepest first, then [sync] exclude, language
+//     excludes and DefaultExcludes: the precedence BuildArgs gives rsync
+//
+// In git mode (sync.mode = "git"), step 3 is [sync] exclude, then the files
+// "git ls-files --cached --others --exclude-standard" lists, so all of
+// git's rules apply (.git/info/exclude, core.excludesFile) and nothing is
+// excluded by default. Otherwise, paths no rule matches are synced. An
+// excluded directory hides everything in it.
+type Filter struct {
+	root          string
+	head          []rule
+	tail          []rule
+	ignores       map[string][]rule // .gitignore rules by directory, "" for the root
+	yeagerignores map[string][]rule // .yeagerignore rules, likewise
+
+	git         bool
+	tracked     map[string]bool // git mode: the files git lists
+	trackedDirs map[string]bool // git mode: their directories
+	includeDirs []string        // git mode: directories [sync] include looks in
+}
+
+// NewFilter returns the filter for the project at root, given its sync
+// config and languages. In git mode it runs git to list the files.
+func NewFilter(root string, syncCfg config.SyncConfig, langs []provision.LanguageName) (*Filter, error) {
+	f := &Filter{
+		root:          root,
+		ignores:       map[string][]rule{},
+		yeagerignores: map[string][]rule{},
+		git:           syncCfg.Mode == config.SyncModeGit,
+	}
+	for _, inc := range syncCfg.Include {
+		if r, ok := newRule(inc, true, false); ok {
+			r.source = "[sync] include"
+			f.head = append(f.head, r)
+			if dir := literalDir(inc); dir != "" {
+				f.includeDirs = append(f.includeDirs, dir)
+			}
+		}
+	}
+	f.addTail(syncCfg.Exclude, "[sync] exclude")
+	if f.git {
+		if err := f.ListGitFiles(); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	f.addTail(LanguageExcludes(langs), "language excludes")
+	f.addTail(DefaultExcludes, "default excludes")
+	return f, nil
+}
+
+func (f *Filter) addTail(patterns []string, source string) {
+	for _, p := range patterns {
+		if r, ok := newRule(p, false, false); ok {
+			r.source = source
+			f.tail = append(f.tail, r)
+		}
+	}
+}
+
+// literalDir returns the directories a pattern starts with, up to its
+// first wildcard: "fixtures/**" gives "fixtures".
+func literalDir(pattern string) string {
+	parts := strings.Split(strings.Trim(pattern, "/"), "/")
+	if !strings.HasSuffix(pattern, "/") {
+		parts = parts[:len(parts)-1]
+	}
+	for i, p := range parts {
+		if strings.ContainsAny(p, `*?[\`) {
+			parts = parts[:i]
+			break
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// ListGitFiles asks git again which files it tracks or would, in git mode,
+// including those of checked-out submodules. It does nothing otherwise.
+func (f *Filter) ListGitFiles() error {
+	if !f.git {
+		return nil
+	}
+	files, err := gitFiles(f.root, "")
+	if err != nil {
+		return fmt.Errorf("listing files with git (sync.mode = %q): %w", config.SyncModeGit, err)
+	}
+	f.tracked = map[string]bool{}
+	f.trackedDirs = map[string]bool{}
+	for _, p := range files {
+		f.tracked[p] = true
+		for dir := path.Dir(p); dir != "." && !f.trackedDirs[dir]; dir = path.Dir(dir) {
+			f.trackedDirs[dir] = true
+		}
+	}
+	return nil
+}
+
+// gitFiles lists the files git tracks or would in the repository at dir,
+// prefixed with prefix. A submodule is listed as its files, not the
+// gitlink git lists it as; one that isn't checked out has none.
+func gitFiles(dir, prefix string) ([]string, error) {
+	out, err := git(dir, "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	submodules, err := gitlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, p := range strings.Split(out, "\x00") {
+		if p == "" {
+			continue
+		}
+		if !submodules[p] {
+			files = append(files, prefix+p)
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(p), ".git")); err != nil {
+			continue // not checked out
+		}
+		sub, err := gitFiles(filepath.Join(dir, filepath.FromSlash(p)), prefix+p+"/")
+		if err != nil {
+			return nil, fmt.Errorf("submodule %s: %w", prefix+p, err)
+		}
+		files = append(files, sub...)
+	}
+	return files, nil
+}
+
+// gitlinks returns the submodule paths of the repository at dir. Only a
+// repository with a .gitmodules file is asked.
+func gitlinks(dir string) (map[string]bool, error) {
+	if _, err := os.Stat(filepath.Join(dir, ".gitmodules")); err != nil {
+		return nil, nil
+	}
+	out, err := git(dir, "ls-files", "-z", "--stage")
+	if err != nil {
+		return nil, err
+	}
+	links := map[string]bool{}
+	for _, entry := range strings.Split(out, "\x00") {
+		// "<mode> <object> <stage>\t<path>"; gitlinks have mode 160000.
+		if meta, p, ok := strings.Cut(entry, "\t"); ok && strings.HasPrefix(meta, "160000 ") {
+			links[p] = true
+		}
+	}
+	return links, nil
+}
+
+// git runs git in dir and returns its output. The error is git's message.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = errors.New(msg)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+// Decision is why a path is synced or not.
+type Decision struct {
+	Excluded bool
+	Pattern  string // the pattern that decided, "" if none did
+	Source   string // where it's from, e.g. "pkg/.gitignore", or "git"
+	Dir      string // the excluded directory the path is in, if that decided
+}
+
+// Reason says in a few words why the path is synced or not.
+func (d Decision) Reason() string {
+	var why string
+	switch {
+	case d.Source == "git" && d.Excluded:
+		why = "git doesn't list it (ignored, or not in the repository)"
+	case d.Source == "git":
+		why = "git lists it"
+	case d.Pattern != "":
+		why = fmt.Sprintf("matches %q in %s", d.Pattern, d.Source)
+	default:
+		why = "no rule matches it"
+	}
+	if d.Dir != "" {
+		return fmt.Sprintf("it's in %s/, which %s", d.Dir, why)
+	}
+	return why
+}
+
+// Excluded reports whether rel, a slash-separated path relative to the
+// project root, is left out. The ignore files of its directories must have
+// been loaded, as Walk does.
+func (f *Filter) Excluded(rel string, isDir bool) bool {
+	return f.decide(rel, isDir).Excluded
+}
+
+func (f *Filter) decide(rel string, isDir bool) Decision {
+	for _, r := range f.head {
+		if r.match(rel, isDir) {
+			return ruleDecision(r)
+		}
+	}
+	if r, ok := matchIgnores(f.yeagerignores, rel, isDir); ok {
+		return ruleDecision(r)
+	}
+	if !f.git {
+		if r, ok := matchIgnores(f.ignores, rel, isDir); ok {
+			return ruleDecision(r)
+		}
+	}
+	for _, r := range f.tail {
+		if r.match(rel, isDir) {
+			return ruleDecision(r)
+		}
+	}
+	if !f.git {
+		return Decision{}
+	}
+	if isDir {
+		// A gitlink (submodule) is listed like a file.
+		listed := f.trackedDirs[rel] || f.tracked[rel] || f.underInclude(rel)
+		return Decision{Excluded: !listed, Source: "git"}
+	}
+	return Decision{Excluded: !f.tracked[rel], Source: "git"}
+}
+
+func ruleDecision(r rule) Decision {
+	return Decision{Excluded: !r.include, Pattern: r.pattern, Source: r.source}
+}
+
+// matchIgnores matches rel against the ignore files of its directories,
+// the deepest first. Within a file the last matching line wins, as in git.
+func matchIgnores(byDir map[string][]rule, rel string, isDir bool) (rule, bool) {
+	for dir := path.Dir(rel); ; dir = path.Dir(dir) {
+		if dir == "." {
+			dir = ""
+		}
+		local := rel
+		if dir != "" {
+			local = strings.TrimPrefix(rel, dir+"/")
+		}
+		rules := byDir[dir]
+		for i := len(rules) - 1; i >= 0; i-- {
+			if rules[i].match(local, isDir) {
+				return rules[i], true
+			}
+		}
+		if dir == "" {
+			return rule{}, false
+		}
+	}
+}
+
+// underInclude reports whether dir is, leads to or is inside a directory
+// [sync] include looks in, so git mode walks it even if git doesn't.
+func (f *Filter) underInclude(dir string) bool {
+	for _, inc := range f.includeDirs {
+		if dir == inc || strings.HasPrefix(dir, inc+"/") || strings.HasPrefix(inc, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Explain reports why rel, a path relative to the project root, is synced
+// or not. Its directories are checked first, as Walk would.
+func (f *Filter) Explain(rel string) (Decision, error) {
+	rel = path.Clean(filepath.ToSlash(rel))
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+		return Decision{}, fmt.Errorf("%s is not inside the project", rel)
+	}
+	info, err := os.Lstat(filepath.Join(f.root, filepath.FromSlash(rel)))
+	isDir := err == nil && info.IsDir()
+
+	if err := f.loadIgnores(""); err != nil {
+		return Decision{}, err
+	}
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/")
+		if d := f.decide(dir, true); d.Excluded {
+			d.Dir = dir
+			return d, nil
+		}
+		if err := f.loadIgnores(dir); err != nil {
+			return Decision{}, err
+		}
+	}
+	return f.decide(rel, isDir), nil
+}
+
+// loadIgnores reads the .yeagerignore and, outside git mode, the .gitignore
+// in dir (relative to the root), replacing what was read from them before.
+func (f *Filter) loadIgnores(dir string) error {
+	if err := loadIgnoreFile(f.yeagerignores, f.root, dir, YeagerignoreFile); err != nil {
+		return err
+	}
+	if f.git {
+		return nil
+	}
+	return loadIgnoreFile(f.ignores, f.root, dir, GitignoreFile)
+}
+
+func loadIgnoreFile(byDir map[string][]rule, root, dir, name string) error {
+	delete(byDir, dir)
+	file, err := os.Open(filepath.Join(root, filepath.FromSlash(dir), name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	source := path.Join(dir, name)
+	var rules []rule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern, include := line, false
+		if strings.HasPrefix(pattern, "!") {
+			include, pattern = true, pattern[1:]
+		}
+		if r, ok := newRule(pattern, include, true); ok {
+			r.pattern, r.source = line, source
+			rules = append(rules, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(rules) > 0 {
+		byDir[dir] = rules
+	}
+	return nil
+}
+
+// Walk calls fn for every regular file and symlink under the root the
+// filter keeps, in lexical order, with slash-separated paths relative to
+// the root. Symlinks are not followed.
+func (f *Filter) Walk(fn func(rel string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(f.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(f.root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel == "." {
+				return f.loadIgnores("")
+			}
+			if f.Excluded(rel, true) {
+				return filepath.SkipDir
+			}
+			return f.loadIgnores(rel)
+		}
+		if !d.Type().IsRegular() && d.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+		if f.Excluded(rel, false) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(rel, info)
+	})
+}
@@ -0,0 +1,285 @@
+package sync
+
+import (
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/gridlhq/yeager/internal/config"
+	"github.com/gridlhq/yeager/internal/provision"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleMatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern   string
+		gitignore bool
+		path      string
+		isDir     bool
+		want      bool
+	}{
+		{pattern: "*.pyc", path: "a.pyc", want: true},
+		{pattern: "*.pyc", path: "pkg/deep/a.pyc", want: true},
+		{pattern: "*.pyc", path: "a.pyc.txt", want: false},
+		{pattern: "build/", path: "build", isDir: true, want: true},
+		{pattern: "build/", path: "build", isDir: false, want: false},
+		{pattern: "build/", path: "src/build", isDir: true, want: true},
+		{pattern: "/build/", path: "src/build", isDir: true, want: false},
+		{pattern: "/build/", path: "build", isDir: true, want: true},
+		{pattern: "docs/*.md", path: "docs/a.md", want: true},
+		{pattern: "docs/*.md", path: "x/docs/a.md", want: true, gitignore: false},
+		{pattern: "docs/*.md", path: "x/docs/a.md", want: false, gitignore: true},
+		{pattern: "docs/*.md", path: "docs/sub/a.md", want: false},
+		{pattern: "**/fixtures", path: "a/b/fixtures", isDir: true, want: true, gitignore: true},
+		{pattern: "**/fixtures", path: "fixtures", isDir: true, want: true, gitignore: true},
+		{pattern: "logs/**", path: "logs/a/b.txt", want: true, gitignore: true},
+		{pattern: "a/**/z", path: "a/z", want: true, gitignore: true},
+		{pattern: "a/**/z", path: "a/b/c/z", want: true, gitignore: true},
+		{pattern: "file?.txt", path: "file1.txt", want: true},
+		{pattern: "file?.txt", path: "file10.txt", want: false},
+		{pattern: "[ab].txt", path: "b.txt", want: true},
+		{pattern: "[!ab].txt", path: "b.txt", want: false},
+		{pattern: "[!ab].txt", path: "c.txt", want: true},
+		{pattern: `\#notes`, path: "#notes", want: true},
+		{pattern: "a+b.txt", path: "a+b.txt", want: true},
+	}
+	for _, tt := range tests {
+		r, ok := newRule(tt.pattern, false, tt.gitignore)
+		require.True(t, ok, tt.pattern)
+		assert.Equal(t, tt.want, r.match(tt.path, tt.isDir), "%q gitignore=%v vs %q", tt.pattern, tt.gitignore, tt.path)
+	}
+
+	_, ok := newRule("/", false, false)
+	assert.False(t, ok, "empty pattern")
+}
+
+// writeTree creates files (with their content) under root.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+}
+
+// walked returns the paths f.Walk keeps.
+func walked(t *testing.T, f *Filter) []string {
+	t.Helper()
+	var paths []string
+	require.NoError(t, f.Walk(func(rel string, _ fs.FileInfo) error {
+		paths = append(paths, rel)
+		return nil
+	}))
+	return paths
+}
+
+func TestFilterWalk(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":              "*.log\n/out/\n!keep.log\n# comment\n\n",
+		"main.go":                 "package main",
+		"debug.log":               "",
+		"keep.log":                "",
+		"out/bin":                 "",
+		"pkg/out/gen.go":          "",
+		"pkg/.gitignore":          "secret.txt\n",
+		"pkg/secret.txt":          "",
+		"secret.txt":              "",
+		"node_modules/x/index.js": "",
+		"vendor/mod.go":           "",
+		"data/big.csv":            "",
+		".git/HEAD":               "",
+	})
+	require.NoError(t, os.Symlink("main.go", filepath.Join(root, "link.go")))
+
+	f, err := NewFilter(root, config.SyncConfig{Exclude: []string{"data/"}}, []provision.LanguageName{provision.Go})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		".gitignore",
+		"keep.log",
+		"link.go",
+		"main.go",
+		"pkg/.gitignore",
+		"pkg/out/gen.go",
+		"secret.txt",
+	}, walked(t, f))
+}
+
+func TestFilterIncludeOverridesGitignore(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":       "*.bin\n",
+		"model.bin":        "",
+		"other.bin":        "",
+		"fixtures/set.bin": "",
+	})
+
+	f, err := NewFilter(root, config.SyncConfig{Include: []string{"model.bin", "fixtures/**"}}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{".gitignore", "fixtures/set.bin", "model.bin"}, walked(t, f))
+}
+
+func TestFilterYeagerignore(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":        "*.bin\n",
+		".yeagerignore":     "docs/\n!weights.bin\n",
+		"main.go":           "",
+		"weights.bin":       "",
+		"other.bin":         "",
+		"docs/guide.md":     "",
+		"pkg/.yeagerignore": "*.golden\n",
+		"pkg/a.golden":      "",
+		"a.golden":          "",
+	})
+
+	f, err := NewFilter(root, config.SyncConfig{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		".gitignore",
+		".yeagerignore",
+		"a.golden",
+		"main.go",
+		"pkg/.yeagerignore",
+		"weights.bin",
+	}, walked(t, f))
+}
+
+// gitRepo makes root a git repository with files committed, skipping the
+// test when git isn't installed.
+func gitRepo(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	writeTree(t, root, files)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		out, err := exec.Command("git", append([]string{"-C", root}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+}
+
+func TestFilterGitMode(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	gitRepo(t, root, map[string]string{
+		".gitignore":      "*.log\n",
+		"main.go":         "",
+		"build/gen.go":    "", // DefaultExcludes would drop it
+		"docs/guide.md":   "",
+		".yeagerignore":   "docs/\n",
+		"fixtures/a.json": "",
+	})
+	writeTree(t, root, map[string]string{
+		"new.go":            "", // untracked but not ignored
+		"debug.log":         "",
+		"data/big.bin":      "",
+		"models/w.bin":      "",
+		".git/info/exclude": "data/\nmodels/\n",
+	})
+
+	f, err := NewFilter(root, config.SyncConfig{
+		Mode:    config.SyncModeGit,
+		Include: []string{"models/*.bin"},
+		Exclude: []string{"fixtures/"},
+	}, []provision.LanguageName{provision.Go})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		".gitignore",
+		".yeagerignore",
+		"build/gen.go",
+		"main.go",
+		"models/w.bin",
+		"new.go",
+	}, walked(t, f))
+
+	_, err = NewFilter(t.TempDir(), config.SyncConfig{Mode: config.SyncModeGit}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `listing files with git (sync.mode = "git")`)
+}
+
+func TestFilterGitModeSubmodules(t *testing.T) {
+	t.Parallel()
+
+	lib := t.TempDir()
+	gitRepo(t, lib, map[string]string{"lib.go": "", "internal/x.go": "", ".gitignore": "*.tmp\n"})
+	root := t.TempDir()
+	gitRepo(t, root, map[string]string{"main.go": ""})
+	out, err := exec.Command("git", "-C", root, "-c", "protocol.file.allow=always", "submodule", "add", "-q", lib, "vendored").CombinedOutput()
+	require.NoError(t, err, string(out))
+	writeTree(t, root, map[string]string{"vendored/new.go": "", "vendored/scratch.tmp": ""})
+
+	f, err := NewFilter(root, config.SyncConfig{Mode: config.SyncModeGit}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		".gitmodules",
+		"main.go",
+		"vendored/.gitignore",
+		"vendored/internal/x.go",
+		"vendored/lib.go",
+		"vendored/new.go",
+	}, walked(t, f))
+}
+
+func TestFilterExplain(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":       "*.log\n!keep.log\n",
+		"pkg/.gitignore":   "/gen/\n",
+		"pkg/gen/a.go":     "",
+		".yeagerignore":    "docs/\n",
+		"docs/guide.md":    "",
+		"main.go":          "",
+		"keep.log":         "",
+		"node_modules/x":   "",
+		"fixtures/big.bin": "",
+	})
+	f, err := NewFilter(root, config.SyncConfig{Include: []string{"*.bin"}}, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		path string
+		want Decision
+	}{
+		{"main.go", Decision{}},
+		{"debug.log", Decision{Excluded: true, Pattern: "*.log", Source: ".gitignore"}},
+		{"keep.log", Decision{Pattern: "!keep.log", Source: ".gitignore"}},
+		{"pkg/gen/a.go", Decision{Excluded: true, Pattern: "/gen/", Source: "pkg/.gitignore", Dir: "pkg/gen"}},
+		{"docs/guide.md", Decision{Excluded: true, Pattern: "docs/", Source: ".yeagerignore", Dir: "docs"}},
+		{"node_modules/x", Decision{Excluded: true, Pattern: "node_modules/", Source: "default excludes", Dir: "node_modules"}},
+		{"fixtures/big.bin", Decision{Pattern: "*.bin", Source: "[sync] include"}},
+		{"./pkg/../main.go", Decision{}},
+	}
+	for _, tt := range tests {
+		got, err := f.Explain(tt.path)
+		require.NoError(t, err, tt.path)
+		assert.Equal(t, tt.want, got, tt.path)
+	}
+
+	_, err = f.Explain("../elsewhere")
+	assert.Error(t, err)
+
+	d, _ := f.Explain("pkg/gen/a.go")
+	assert.Equal(t, `it's in pkg/gen/, which matches "/gen/" in pkg/.gitignore`, d.Reason())
+	assert.Equal(t, "no rule matches it", Decision{}.Reason())
+	assert.Equal(t, "git lists it", Decision{Source: "git"}.Reason())
+}
@@ -0,0 +1,156 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// ManifestVersion is bumped when the manifest format changes. A manifest
+// of another version is ignored, so the next sync sends everything.
+const ManifestVersion = 1
+
+// Manifest lists the synced files of a directory by slash-separated path.
+type Manifest struct {
+	Version int                  `json:"version"`
+	Files   map[string]FileEntry `json:"files"`
+}
+
+// FileEntry describes one synced file. Size, Mode and ModTime let the next
+// sync skip hashing files that haven't changed.
+type FileEntry struct {
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`  // permission bits, plus fs.ModeSymlink for links
+	ModTime int64       `json:"mtime"` // Unix nanoseconds
+	Hash    string      `json:"hash"`  // SHA-256 of the content, or of the target for links
+}
+
+// ParseManifest decodes a manifest. Anything unreadable, including an old
+// version, is an empty manifest.
+func ParseManifest(data []byte) Manifest {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil || m.Version != ManifestVersion || m.Files == nil {
+		return Manifest{Version: ManifestVersion, Files: map[string]FileEntry{}}
+	}
+	return m
+}
+
+// BuildManifest hashes the files under root that filter keeps; the filter
+// must be for root. Entries of prev whose size, mode and mtime still match
+// are reused without hashing.
+func BuildManifest(root string, filter *Filter, prev Manifest) (Manifest, error) {
+	return buildManifest(root, filter, prev, nil)
+}
+
+// buildManifest is BuildManifest for the files keep, if set, accepts.
+func buildManifest(root string, filter *Filter, prev Manifest, keep func(fs.FileInfo) bool) (Manifest, error) {
+	m := Manifest{Version: ManifestVersion, Files: map[string]FileEntry{}}
+	err := filter.Walk(func(rel string, info fs.FileInfo) error {
+		if keep != nil && !keep(info) {
+			return nil
+		}
+		entry := FileEntry{
+			Size:    info.Size(),
+			Mode:    info.Mode() & (fs.ModePerm | fs.ModeSymlink),
+			ModTime: info.ModTime().UnixNano(),
+		}
+		if old, ok := prev.Files[rel]; ok && old.Size == entry.Size && old.Mode == entry.Mode && old.ModTime == entry.ModTime {
+			entry.Hash = old.Hash
+		} else {
+			hash, err := hashFile(filepath.Join(root, filepath.FromSlash(rel)), entry.Mode)
+			if err != nil {
+				return err
+			}
+			entry.Hash = hash
+		}
+		m.Files[rel] = entry
+		return nil
+	})
+	if err != nil {
+		return Manifest{}, fmt.Errorf("scanning %s: %w", root, err)
+	}
+	return m, nil
+}
+
+func hashFile(p string, mode fs.FileMode) (string, error) {
+	h := sha256.New()
+	if mode&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(p)
+		if err != nil {
+			return "", err
+		}
+		io.WriteString(h, target)
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Diff returns the paths of next that are new or changed since prev, and
+// the paths of prev that next no longer has, both sorted.
+func Diff(prev, next Manifest) (changed, deleted []string) {
+	for p, entry := range next.Files {
+		old, ok := prev.Files[p]
+		if !ok || old.Hash != entry.Hash || old.Mode != entry.Mode {
+			changed = append(changed, p)
+		}
+	}
+	for p := range prev.Files {
+		if _, ok := next.Files[p]; !ok {
+			deleted = append(deleted, p)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(deleted)
+	return changed, deleted
+}
+
+// emptiedDirs returns the directories of deleted files that next no longer
+// has any files in, deepest first, so they can be removed in order.
+func emptiedDirs(deleted []string, next Manifest) []string {
+	kept := map[string]bool{}
+	for p := range next.Files {
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			kept[dir] = true
+		}
+	}
+	seen := map[string]bool{}
+	var dirs []string
+	for _, p := range deleted {
+		for dir := path.Dir(p); dir != "." && !kept[dir] && !seen[dir]; dir = path.Dir(dir) {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if di, dj := depth(dirs[i]), depth(dirs[j]); di != dj {
+			return di > dj
+		}
+		return dirs[i] < dirs[j]
+	})
+	return dirs
+}
+
+func depth(p string) int {
+	n := 0
+	for i := range len(p) {
+		if p[i] == '/' {
+			n++
+		}
+	}
+	return n
+}
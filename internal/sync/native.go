@@ -0,0 +1,418 @@
+package sync
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Remote runs shell commands on the VM for the native engine.
+type Remote interface {
+	// Run runs cmd with stdin (which may be nil) and returns its stdout.
+	Run(ctx context.Context, cmd string, stdin io.Reader) ([]byte, error)
+}
+
+// SSHRemote runs commands over an open SSH connection, one session each.
+type SSHRemote struct {
+	Client *gossh.Client
+}
+
+// Run implements Remote. Cancelling ctx closes the session.
+func (r SSHRemote) Run(ctx context.Context, cmd string, stdin io.Reader) ([]byte, error) {
+	session, err := r.Client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("creating SSH session: %w", err)
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdin = stdin
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	done := make(chan error, 1)
+	go func() { done <- session.Run(cmd) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, remoteError(err, stderr.String())
+		}
+		return stdout.Bytes(), nil
+	case <-ctx.Done():
+		session.Close()
+		return nil, ctx.Err()
+	}
+}
+
+func remoteError(err error, stderr string) error {
+	if msg := strings.TrimSpace(stderr); msg != "" {
+		return fmt.Errorf("%w: %s", err, msg)
+	}
+	return err
+}
+
+// Push syncs opts.SourceDir to opts.RemoteDir without rsync. It hashes the
+// local files into a manifest, compares it with the one the last sync left
+// at opts.ManifestPath on the VM, deletes the files that are gone and
+// streams the new and changed ones as a gzipped tar. Files whose size or
+// mtime on the VM no longer matches that manifest, because something there
+// changed or deleted them, are sent again too. Files on the VM that
+// were never synced are left alone, and so are those [sync] preserve
+// matches. Nothing is sent if the files add up to
+// more than [sync] max_sync_bytes: the error is a *TooLargeError. With
+// opts.Blobs, changed files of [sync] large_file_bytes or more are staged
+// through it instead of streamed.
+func Push(ctx context.Context, remote Remote, opts Options) (SyncResult, error) {
+	if opts.ManifestPath == "" {
+		return SyncResult{}, fmt.Errorf("no remote manifest path")
+	}
+	start := time.Now()
+	remoteDir := strings.TrimSuffix(opts.RemoteDir, "/")
+
+	filter, err := NewFilter(opts.SourceDir, opts.SyncConfig, opts.Languages)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	if err := CheckSize(filter, opts.SyncConfig.MaxSyncBytes); err != nil {
+		return SyncResult{}, err
+	}
+	prev, err := readRemoteManifest(ctx, remote, remoteDir, opts.ManifestPath)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	next, err := BuildManifest(opts.SourceDir, filter, prev)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	changed, deleted := Diff(prev, next)
+	deleted = unpreserved(deleted, parseRules(opts.SyncConfig.Preserve))
+	drifted, err := remoteDrift(ctx, remote, remoteDir, prev, unchanged(next, changed))
+	if err != nil {
+		return SyncResult{}, err
+	}
+	if len(drifted) > 0 {
+		changed = append(changed, drifted...)
+		sort.Strings(changed)
+	}
+
+	if len(deleted) > 0 {
+		if err := deleteRemote(ctx, remote, remoteDir, deleted, emptiedDirs(deleted, next)); err != nil {
+			return SyncResult{}, err
+		}
+	}
+	small := changed
+	var staged StageResult
+	if opts.Blobs != nil {
+		var big map[string]FileEntry
+		small, big = large(changed, next, opts.SyncConfig.LargeFileBytes)
+		if staged, err = Stage(ctx, remote, opts.Blobs, opts.SourceDir, remoteDir, big, opts.Progress); err != nil {
+			return SyncResult{}, err
+		}
+	}
+	var sent, compressed int64
+	if len(small) > 0 {
+		var total int64
+		for _, p := range small {
+			total += next.Files[p].Size
+		}
+		if sent, compressed, err = sendFiles(ctx, remote, opts.SourceDir, remoteDir, small, total, opts.Progress); err != nil {
+			return SyncResult{}, err
+		}
+	}
+	if retimed := touchedFiles(prev, next, drifted); len(retimed) > 0 {
+		// Keep the VM's mtimes those of the manifest, for remoteDrift.
+		if err := touchRemote(ctx, remote, remoteDir, retimed, next); err != nil {
+			return SyncResult{}, err
+		}
+	}
+	if len(changed) > 0 || len(deleted) > 0 || len(prev.Files) == 0 || touched(prev, next) {
+		if err := writeRemoteManifest(ctx, remote, opts.ManifestPath, next); err != nil {
+			return SyncResult{}, err
+		}
+	}
+
+	var totalBytes int64
+	for _, entry := range next.Files {
+		if entry.Mode&fs.ModeSymlink == 0 {
+			totalBytes += entry.Size
+		}
+	}
+	return SyncResult{
+		FilesTransferred: len(changed),
+		TotalFiles:       len(next.Files),
+		BytesTransferred: sent + staged.Bytes,
+		FilesDeleted:     len(deleted),
+		TotalBytes:       totalBytes,
+		BytesSent:        compressed,
+		Duration:         time.Since(start),
+		FilesStaged:      staged.Files,
+		BytesUploaded:    staged.BytesUploaded,
+		Deleted:          deleted,
+	}, nil
+}
+
+// unpreserved returns the deleted files no preserve rule matches.
+func unpreserved(deleted []string, preserve []rule) []string {
+	if len(preserve) == 0 {
+		return deleted
+	}
+	var keep []string
+	for _, p := range deleted {
+		if !matchUnder(preserve, p) {
+			keep = append(keep, p)
+		}
+	}
+	return keep
+}
+
+// touched reports whether any file's mtime changed without its content,
+// so the manifest is saved and the file isn't hashed again next time.
+func touched(prev, next Manifest) bool {
+	for p, entry := range next.Files {
+		if prev.Files[p].ModTime != entry.ModTime {
+			return true
+		}
+	}
+	return false
+}
+
+// unchanged returns the files of next that aren't in changed.
+func unchanged(next Manifest, changed []string) []string {
+	skip := make(map[string]bool, len(changed))
+	for _, p := range changed {
+		skip[p] = true
+	}
+	var files []string
+	for p := range next.Files {
+		if !skip[p] {
+			files = append(files, p)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// touchedFiles returns the files of next whose mtime changed without their
+// content since prev, except those in skip, which are sent again anyway.
+func touchedFiles(prev, next Manifest, skip []string) []string {
+	sent := make(map[string]bool, len(skip))
+	for _, p := range skip {
+		sent[p] = true
+	}
+	var files []string
+	for p, entry := range next.Files {
+		if old, ok := prev.Files[p]; ok && old.ModTime != entry.ModTime && !sent[p] {
+			files = append(files, p)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// statScript prints the size and mtime, in seconds, of each NUL-separated
+// file on stdin that exists in the directory $1, as NUL-separated triples.
+const statScript = `cd "$1" || exit 0
+xargs -0 -r stat --printf '%n\0%s\0%Y\0' -- 2>/dev/null || true`
+
+// remoteDrift returns the files, of those the manifest prev says the VM
+// has, that are missing on the VM or whose size or mtime differs there:
+// changed by a formatter, a git checkout or anything else on the VM.
+func remoteDrift(ctx context.Context, remote Remote, remoteDir string, prev Manifest, files []string) ([]string, error) {
+	var stdin bytes.Buffer
+	for _, p := range files {
+		if _, ok := prev.Files[p]; ok {
+			stdin.WriteString(p + "\x00")
+		}
+	}
+	if stdin.Len() == 0 {
+		return nil, nil
+	}
+	cmd := fmt.Sprintf("bash -c %s yg-stat %s", quote(statScript), quote(remoteDir))
+	out, err := remote.Run(ctx, cmd, &stdin)
+	if err != nil {
+		return nil, fmt.Errorf("checking synced files on the VM: %w", err)
+	}
+
+	type stat struct{ size, mtime int64 }
+	stats := map[string]stat{}
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		size, err1 := strconv.ParseInt(fields[i+1], 10, 64)
+		mtime, err2 := strconv.ParseInt(fields[i+2], 10, 64)
+		if err1 == nil && err2 == nil {
+			stats[fields[i]] = stat{size, mtime}
+		}
+	}
+	var drifted []string
+	for _, p := range files {
+		entry, ok := prev.Files[p]
+		if !ok {
+			continue
+		}
+		st, ok := stats[p]
+		// Links get the time they were made; only their target matters.
+		if !ok || st.size != entry.Size || (entry.Mode&fs.ModeSymlink == 0 && st.mtime != entry.ModTime/int64(time.Second)) {
+			drifted = append(drifted, p)
+		}
+	}
+	return drifted, nil
+}
+
+// touchScript sets the mtime of NUL-separated path and mtime pairs on
+// stdin, in the directory $1.
+const touchScript = `cd "$1" || exit 0
+while IFS= read -r -d '' p && IFS= read -r -d '' t; do touch -h -m -d "@$t" -- "$p" 2>/dev/null || true; done`
+
+func touchRemote(ctx context.Context, remote Remote, remoteDir string, files []string, next Manifest) error {
+	var stdin bytes.Buffer
+	for _, p := range files {
+		mtime := next.Files[p].ModTime
+		fmt.Fprintf(&stdin, "%s\x00%d.%09d\x00", p, mtime/int64(time.Second), mtime%int64(time.Second))
+	}
+	cmd := fmt.Sprintf("bash -c %s yg-touch %s", quote(touchScript), quote(remoteDir))
+	if _, err := remote.Run(ctx, cmd, &stdin); err != nil {
+		return fmt.Errorf("updating mtimes on the VM: %w", err)
+	}
+	return nil
+}
+
+func readRemoteManifest(ctx context.Context, remote Remote, remoteDir, manifestPath string) (Manifest, error) {
+	// Without the directory the manifest is stale: sync everything.
+	cmd := fmt.Sprintf("if [ -d %s ] && [ -f %s ]; then cat %s; fi", quote(remoteDir), quote(manifestPath), quote(manifestPath))
+	out, err := remote.Run(ctx, cmd, nil)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("reading sync manifest: %w", err)
+	}
+	return ParseManifest(out), nil
+}
+
+func writeRemoteManifest(ctx context.Context, remote Remote, manifestPath string, m Manifest) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	tmp := manifestPath + ".tmp"
+	cmd := fmt.Sprintf("mkdir -p %s && cat > %s && mv %s %s", quote(path.Dir(manifestPath)), quote(tmp), quote(tmp), quote(manifestPath))
+	if _, err := remote.Run(ctx, cmd, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("writing sync manifest: %w", err)
+	}
+	return nil
+}
+
+// deleteScript removes NUL-separated files read from stdin, up to an empty
+// entry, then the NUL-separated directories after it that are now empty.
+const deleteScript = `cd "$1" || exit 0
+while IFS= read -r -d '' p && [ -n "$p" ]; do rm -f -- "$p"; done
+while IFS= read -r -d '' p; do rmdir -- "$p" 2>/dev/null || true; done`
+
+func deleteRemote(ctx context.Context, remote Remote, remoteDir string, files, dirs []string) error {
+	var stdin bytes.Buffer
+	for _, p := range files {
+		stdin.WriteString(p + "\x00")
+	}
+	stdin.WriteString("\x00")
+	for _, d := range dirs {
+		stdin.WriteString(d + "\x00")
+	}
+	cmd := fmt.Sprintf("bash -c %s yg-sync %s", quote(deleteScript), quote(remoteDir))
+	if _, err := remote.Run(ctx, cmd, &stdin); err != nil {
+		return fmt.Errorf("deleting removed files: %w", err)
+	}
+	return nil
+}
+
+// sendFiles streams files, total bytes in all, as a gzipped tar into
+// remoteDir, which is created if needed. Returns the bytes sent before and
+// after compression. progress, if set, is told how it's going.
+func sendFiles(ctx context.Context, remote Remote, sourceDir, remoteDir string, files []string, total int64, progress func(Progress)) (sent, compressed int64, err error) {
+	pr, pw := io.Pipe()
+	counted := &countingWriter{w: pw}
+	done := make(chan int64, 1)
+	go func() {
+		n, err := writeTar(counted, sourceDir, files, total, progress)
+		done <- n
+		pw.CloseWithError(err)
+	}()
+
+	cmd := fmt.Sprintf("mkdir -p %s && tar -xzpf - -C %s", quote(remoteDir), quote(remoteDir))
+	_, err = remote.Run(ctx, cmd, pr)
+	// Unblock the writer if the remote side stopped reading early.
+	pr.CloseWithError(io.ErrClosedPipe)
+	sent = <-done
+	if err != nil {
+		return 0, 0, fmt.Errorf("sending files: %w", err)
+	}
+	return sent, counted.n, nil
+}
+
+func writeTar(w io.Writer, sourceDir string, files []string, size int64, progress func(Progress)) (int64, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(newProgressWriter(gz, size, progress))
+	var total int64
+	for _, rel := range files {
+		n, err := addToTar(tw, filepath.Join(sourceDir, filepath.FromSlash(rel)), rel)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	if err := tw.Close(); err != nil {
+		return total, err
+	}
+	return total, gz.Close()
+}
+
+func addToTar(tw *tar.Writer, p, rel string) (int64, error) {
+	info, err := os.Lstat(p)
+	if err != nil {
+		return 0, err
+	}
+	hdr := &tar.Header{
+		Name:    rel,
+		Mode:    int64(info.Mode().Perm()),
+		ModTime: info.ModTime(),
+		Format:  tar.FormatPAX,
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(p)
+		if err != nil {
+			return 0, err
+		}
+		hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, target
+		return 0, tw.WriteHeader(hdr)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	hdr.Typeflag, hdr.Size = tar.TypeReg, info.Size()
+	if err := tw.WriteHeader(hdr); err != nil {
+		return 0, err
+	}
+	n, err := io.CopyN(tw, f, hdr.Size)
+	if err != nil {
+		return n, fmt.Errorf("%s changed while syncing: %w", rel, err)
+	}
+	return n, nil
+}
+
+// quote single-quotes s for the remote shell.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,317 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gridlhq/yeager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// localRemote runs remote commands with the local bash, so Push can be
+// tested end to end against a directory standing in for the VM.
+type localRemote struct {
+	cmds []string
+}
+
+func (r *localRemote) Run(ctx context.Context, cmd string, stdin io.Reader) ([]byte, error) {
+	r.cmds = append(r.cmds, cmd)
+	c := exec.CommandContext(ctx, "bash", "-c", cmd)
+	c.Stdin = stdin
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, stderr.String())
+	}
+	return out, nil
+}
+
+// remoteTree returns the files under dir with their content.
+func remoteTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	require.NoError(t, filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		require.NoError(t, err)
+		if info.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, _ := os.Readlink(p)
+			files[rel] = "-> " + target
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		require.NoError(t, err)
+		files[rel] = string(data)
+		return nil
+	}))
+	return files
+}
+
+func TestPush(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar not available")
+	}
+
+	src, vm := t.TempDir(), t.TempDir()
+	remoteDir := filepath.Join(vm, "project")
+	opts := Options{
+		SourceDir:    src + "/",
+		RemoteDir:    remoteDir + "/",
+		ManifestPath: filepath.Join(vm, ".yeager", "manifests", "project.json"),
+	}
+	writeTree(t, src, map[string]string{
+		".gitignore":       "*.log\n",
+		"main.go":          "package main",
+		"it's here/a.txt":  "quoted",
+		"pkg/util/util.go": "package util",
+		"debug.log":        "skipped",
+		"node_modules/x":   "skipped",
+	})
+	require.NoError(t, os.Chmod(filepath.Join(src, "main.go"), 0o755))
+	require.NoError(t, os.Symlink("main.go", filepath.Join(src, "link.go")))
+	remote := &localRemote{}
+
+	// First sync sends everything.
+	result, err := Push(context.Background(), remote, opts)
+	require.NoError(t, err)
+	assert.Equal(t, SyncResult{FilesTransferred: 5, TotalFiles: 5, BytesTransferred: 36, TotalBytes: 36}, counts(result))
+	assert.Positive(t, result.BytesSent)
+	assert.Positive(t, result.Duration)
+	assert.Equal(t, map[string]string{
+		".gitignore":       "*.log\n",
+		"main.go":          "package main",
+		"it's here/a.txt":  "quoted",
+		"pkg/util/util.go": "package util",
+		"link.go":          "-> main.go",
+	}, remoteTree(t, remoteDir))
+	info, err := os.Stat(filepath.Join(remoteDir, "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+	// Nothing changed: nothing is sent or deleted.
+	remote.cmds = nil
+	result, err = Push(context.Background(), remote, opts)
+	require.NoError(t, err)
+	assert.Equal(t, SyncResult{TotalFiles: 5, TotalBytes: 36}, counts(result))
+	assert.Len(t, remote.cmds, 2, "the manifest is read and checked against the VM's files, no more")
+
+	// Touching a file without changing it sends nothing.
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(src, "main.go"), future, future))
+	result, err = Push(context.Background(), remote, opts)
+	require.NoError(t, err)
+	assert.Zero(t, result.FilesTransferred)
+
+	// Edits are sent, deletions applied, and emptied directories removed.
+	// Files made on the VM are left alone.
+	writeTree(t, src, map[string]string{"main.go": "package main // v2", "new.txt": "new"})
+	require.NoError(t, os.RemoveAll(filepath.Join(src, "pkg")))
+	writeTree(t, remoteDir, map[string]string{"generated.txt": "from the VM"})
+	result, err = Push(context.Background(), remote, opts)
+	require.NoError(t, err)
+	assert.Equal(t, SyncResult{FilesTransferred: 2, TotalFiles: 5, BytesTransferred: 21, FilesDeleted: 1, TotalBytes: 33, Deleted: []string{"pkg/util/util.go"}}, counts(result))
+	assert.Equal(t, map[string]string{
+		".gitignore":      "*.log\n",
+		"main.go":         "package main // v2",
+		"new.txt":         "new",
+		"it's here/a.txt": "quoted",
+		"link.go":         "-> main.go",
+		"generated.txt":   "from the VM",
+	}, remoteTree(t, remoteDir))
+	assert.NoDirExists(t, filepath.Join(remoteDir, "pkg"))
+
+	// A missing remote directory means the manifest is stale.
+	require.NoError(t, os.RemoveAll(remoteDir))
+	result, err = Push(context.Background(), remote, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 5, result.FilesTransferred)
+	assert.FileExists(t, filepath.Join(remoteDir, "main.go"))
+}
+
+func TestPushRestoresFilesChangedOnTheVM(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar not available")
+	}
+
+	src, vm := t.TempDir(), t.TempDir()
+	remoteDir := filepath.Join(vm, "project")
+	opts := Options{
+		SourceDir:    src + "/",
+		RemoteDir:    remoteDir + "/",
+		ManifestPath: filepath.Join(vm, "m.json"),
+	}
+	files := map[string]string{
+		"main.go":    "package main",
+		"fmt.go":     "package main\n\nfunc f() {}",
+		"touched.go": "package main // same",
+		"deleted.go": "package main",
+	}
+	writeTree(t, src, files)
+	require.NoError(t, os.Symlink("main.go", filepath.Join(src, "link.go")))
+	_, err := Push(context.Background(), &localRemote{}, opts)
+	require.NoError(t, err)
+
+	// A formatter rewrites one file, a checkout another with the same
+	// size, and something deletes a third.
+	writeTree(t, remoteDir, map[string]string{
+		"fmt.go":     "package main\n\nfunc f() {\n}\n",
+		"touched.go": "package main // SAME",
+	})
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(remoteDir, "touched.go"), later, later))
+	require.NoError(t, os.Remove(filepath.Join(remoteDir, "deleted.go")))
+
+	result, err := Push(context.Background(), &localRemote{}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.FilesTransferred)
+	files["link.go"] = "-> main.go"
+	assert.Equal(t, files, remoteTree(t, remoteDir))
+
+	// Touching a file locally updates its mtime on the VM, so it isn't
+	// taken for changed there next time.
+	require.NoError(t, os.Chtimes(filepath.Join(src, "main.go"), later, later))
+	result, err = Push(context.Background(), &localRemote{}, opts)
+	require.NoError(t, err)
+	assert.Zero(t, result.FilesTransferred)
+	info, err := os.Stat(filepath.Join(remoteDir, "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, later.Unix(), info.ModTime().Unix())
+	result, err = Push(context.Background(), &localRemote{}, opts)
+	require.NoError(t, err)
+	assert.Zero(t, result.FilesTransferred)
+}
+
+// counts drops the parts of a SyncResult that vary from run to run.
+func counts(r SyncResult) SyncResult {
+	r.BytesSent, r.Duration = 0, 0
+	return r
+}
+
+func TestPushTooLarge(t *testing.T) {
+	t.Parallel()
+
+	src, vm := t.TempDir(), t.TempDir()
+	writeTree(t, src, map[string]string{
+		"main.go":     "package main",
+		"data/a.csv":  strings.Repeat("x", 600),
+		"data/b.csv":  strings.Repeat("x", 500),
+		"notes/1.txt": "hello",
+	})
+	remote := &localRemote{}
+	_, err := Push(context.Background(), remote, Options{
+		SourceDir:    src,
+		RemoteDir:    filepath.Join(vm, "project"),
+		ManifestPath: filepath.Join(vm, "m.json"),
+		SyncConfig:   config.SyncConfig{MaxSyncBytes: 1000},
+	})
+	var tooLarge *TooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, int64(1117), tooLarge.Report.Bytes)
+	assert.Equal(t, PathSize{Path: "data/", Bytes: 1100}, tooLarge.Biggest())
+	assert.Equal(t, "the files to sync add up to 1.1 KB, over max_sync_bytes (1000 B); data/ is 1.1 KB", err.Error())
+	assert.Empty(t, remote.cmds, "nothing is sent")
+}
+
+func TestPushPreserve(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar not available")
+	}
+
+	src, vm := t.TempDir(), t.TempDir()
+	remoteDir := filepath.Join(vm, "project")
+	opts := Options{
+		SourceDir:    src,
+		RemoteDir:    remoteDir,
+		ManifestPath: filepath.Join(vm, "m.json"),
+		SyncConfig:   config.SyncConfig{Preserve: []string{"fixtures/downloaded/", "*.sqlite"}},
+	}
+	writeTree(t, src, map[string]string{
+		"main.go":                    "package main",
+		"old.txt":                    "old",
+		"test.sqlite":                "db",
+		"fixtures/downloaded/a.json": "{}",
+	})
+	_, err := Push(context.Background(), &localRemote{}, opts)
+	require.NoError(t, err)
+
+	for _, p := range []string{"old.txt", "test.sqlite", "fixtures"} {
+		require.NoError(t, os.RemoveAll(filepath.Join(src, p)))
+	}
+	result, err := Push(context.Background(), &localRemote{}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.FilesDeleted)
+	assert.Equal(t, []string{"old.txt"}, result.Deleted)
+	assert.Equal(t, map[string]string{
+		"main.go":                    "package main",
+		"test.sqlite":                "db",
+		"fixtures/downloaded/a.json": "{}",
+	}, remoteTree(t, remoteDir))
+}
+
+func TestPushRemoteError(t *testing.T) {
+	t.Parallel()
+
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"a.txt": "a"})
+	remote := &localRemote{}
+	_, err := Push(context.Background(), remote, Options{
+		SourceDir: src,
+		// A file where the directory should be makes mkdir fail.
+		RemoteDir:    filepath.Join(src, "a.txt", "project"),
+		ManifestPath: filepath.Join(t.TempDir(), "m.json"),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sending files")
+	assert.True(t, strings.Contains(err.Error(), "Not a directory") || strings.Contains(err.Error(), "File exists"), err.Error())
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	prev := Manifest{Version: ManifestVersion, Files: map[string]FileEntry{
+		"same":     {Hash: "1", Mode: 0o644},
+		"edited":   {Hash: "1", Mode: 0o644},
+		"chmodded": {Hash: "1", Mode: 0o644},
+		"a/b/gone": {Hash: "1"},
+		"a/kept":   {Hash: "1"},
+	}}
+	next := Manifest{Version: ManifestVersion, Files: map[string]FileEntry{
+		"same":     {Hash: "1", Mode: 0o644, ModTime: 5},
+		"edited":   {Hash: "2", Mode: 0o644},
+		"chmodded": {Hash: "1", Mode: 0o755},
+		"a/kept":   {Hash: "1"},
+		"added":    {Hash: "3"},
+	}}
+	changed, deleted := Diff(prev, next)
+	assert.Equal(t, []string{"added", "chmodded", "edited"}, changed)
+	assert.Equal(t, []string{"a/b/gone"}, deleted)
+	assert.Equal(t, []string{"a/b"}, emptiedDirs(deleted, next), "a still has a file")
+	assert.Equal(t, []string{"x/y/z", "x/y", "x"}, emptiedDirs([]string{"x/y/z/f"}, next))
+}
+
+func TestParseManifest(t *testing.T) {
+	t.Parallel()
+
+	for _, data := range []string{"", "not json", `{"version": 99, "files": {"a": {}}}`} {
+		m := ParseManifest([]byte(data))
+		assert.Equal(t, ManifestVersion, m.Version)
+		assert.Empty(t, m.Files, data)
+	}
+	m := ParseManifest([]byte(`{"version": 1, "files": {"a": {"size": 3, "hash": "h"}}}`))
+	assert.Equal(t, FileEntry{Size: 3, Hash: "h"}, m.Files["a"])
+}
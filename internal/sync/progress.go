@@ -0,0 +1,145 @@
+package sync
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress is how far a transfer has got.
+type Progress struct {
+	Bytes int64   // sent so far, before compression
+	Total int64   // to send in all, 0 if not known yet
+	Rate  float64 // bytes per second
+}
+
+// progressInterval is the least time between two progress reports from the
+// native engine.
+const progressInterval = 200 * time.Millisecond
+
+// progressWriter counts the bytes written through it and reports them at
+// most every progressInterval.
+type progressWriter struct {
+	w      io.Writer
+	total  int64
+	report func(Progress)
+
+	start time.Time
+	last  time.Time
+	n     int64
+}
+
+func newProgressWriter(w io.Writer, total int64, report func(Progress)) *progressWriter {
+	now := time.Now()
+	return &progressWriter{w: w, total: total, report: report, start: now, last: now}
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.add(n)
+	return n, err
+}
+
+// add counts n more bytes written.
+func (p *progressWriter) add(n int) {
+	p.n += int64(n)
+	if now := time.Now(); p.report != nil && now.Sub(p.last) >= progressInterval {
+		p.last = now
+		p.report(Progress{Bytes: p.n, Total: p.total, Rate: float64(p.n) / now.Sub(p.start).Seconds()})
+	}
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// reProgress2 matches an rsync --info=progress2 line:
+// "  12,345,678  45%   11.77MB/s    0:00:03 (xfr#12, to-chk=34/100)".
+var reProgress2 = regexp.MustCompile(`^\s*([\d,]+)\s+(\d+)%\s+([\d.]+)([kMGT]?B)/s`)
+
+// RsyncOutput collects rsync's stdout for ParseStats and reports the
+// --info=progress2 lines in it as they arrive.
+type RsyncOutput struct {
+	report func(Progress)
+
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	line []byte
+}
+
+// NewRsyncOutput returns an RsyncOutput that calls report, which may be
+// nil, for each progress line.
+func NewRsyncOutput(report func(Progress)) *RsyncOutput {
+	return &RsyncOutput{report: report}
+}
+
+// Write implements io.Writer. rsync ends progress lines with "\r".
+func (o *RsyncOutput) Write(b []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf.Write(b)
+	for _, c := range b {
+		if c != '\r' && c != '\n' {
+			o.line = append(o.line, c)
+			continue
+		}
+		if p, ok := ParseProgress(string(o.line)); ok && o.report != nil {
+			o.report(p)
+		}
+		o.line = o.line[:0]
+	}
+	return len(b), nil
+}
+
+// String returns everything rsync wrote.
+func (o *RsyncOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+// ParseProgress parses an rsync --info=progress2 line.
+func ParseProgress(line string) (Progress, bool) {
+	m := reProgress2.FindStringSubmatch(line)
+	if m == nil {
+		return Progress{}, false
+	}
+	p := Progress{Bytes: parseCommaInt64(m[1])}
+	if pct, _ := strconv.Atoi(m[2]); pct > 0 {
+		p.Total = p.Bytes * 100 / int64(pct)
+	}
+	rate, _ := strconv.ParseFloat(m[3], 64)
+	// rsync's units are powers of 1024, like FormatBytes.
+	p.Rate = rate * float64(int64(1)<<(10*strings.Index("BkMGT", m[4][:1])))
+	return p, true
+}
+
+var reRsyncVersion = regexp.MustCompile(`rsync\s+version\s+v?(\d+)\.(\d+)`)
+
+// RsyncHasProgress2 reports whether the rsync that printed versionOutput
+// (rsync --version) has --info=progress2, which came in rsync 3.1. The
+// openrsync macOS ships doesn't.
+func RsyncHasProgress2(versionOutput string) bool {
+	if strings.Contains(versionOutput, "openrsync") {
+		return false
+	}
+	m := reRsyncVersion.FindStringSubmatch(versionOutput)
+	if m == nil {
+		return false
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	return major > 3 || major == 3 && minor >= 1
+}
@@ -0,0 +1,103 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProgress(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		line string
+		want Progress
+		ok   bool
+	}{
+		{
+			line: "     12,345,678  45%   11.50MB/s    0:00:03 (xfr#12, to-chk=34/100)",
+			want: Progress{Bytes: 12_345_678, Total: 27_434_840, Rate: 11.5 * (1 << 20)},
+			ok:   true,
+		},
+		{
+			line: "          1,024   0%    1.00kB/s    0:00:00",
+			want: Progress{Bytes: 1024, Rate: 1024},
+			ok:   true,
+		},
+		{
+			line: "            512 100%  512.00B/s    0:00:00 (xfr#1, to-chk=0/1)",
+			want: Progress{Bytes: 512, Total: 512, Rate: 512},
+			ok:   true,
+		},
+		{line: "Number of files: 847"},
+		{line: "sending incremental file list"},
+		{line: ""},
+	}
+	for _, tt := range tests {
+		got, ok := ParseProgress(tt.line)
+		assert.Equal(t, tt.ok, ok, tt.line)
+		assert.Equal(t, tt.want, got, tt.line)
+	}
+}
+
+func TestRsyncOutput(t *testing.T) {
+	t.Parallel()
+
+	var got []Progress
+	out := NewRsyncOutput(func(p Progress) { got = append(got, p) })
+	stream := "\r      1,000  10%    1.00kB/s    0:00:09\r      5,000  50%    2.00kB/s    0:00:02" +
+		"\r     10,000 100%    2.00kB/s    0:00:00 (xfr#3, to-chk=0/3)\n\nNumber of files: 3\n"
+	// rsync's writes don't line up with its lines.
+	for i := 0; i < len(stream); i += 7 {
+		_, _ = fmt.Fprint(out, stream[i:min(i+7, len(stream))])
+	}
+
+	assert.Equal(t, []Progress{
+		{Bytes: 1000, Total: 10_000, Rate: 1024},
+		{Bytes: 5000, Total: 10_000, Rate: 2048},
+		{Bytes: 10_000, Total: 10_000, Rate: 2048},
+	}, got)
+	assert.Equal(t, stream, out.String(), "everything is kept for ParseStats")
+	assert.Equal(t, 3, ParseStats(out.String()).TotalFiles)
+}
+
+func TestRsyncHasProgress2(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"rsync  version 3.2.7  protocol version 31", true},
+		{"rsync  version v3.3.0  protocol version 32", true},
+		{"rsync  version 3.1.0  protocol version 31", true},
+		{"rsync  version 3.0.9  protocol version 30", false},
+		{"rsync  version 2.6.9  protocol version 29", false},
+		{"openrsync: protocol version 29\nrsync version 2.6.9 compatible", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, RsyncHasProgress2(tt.version), tt.version)
+	}
+}
+
+func TestProgressWriter(t *testing.T) {
+	t.Parallel()
+
+	var got []Progress
+	var buf bytes.Buffer
+	w := newProgressWriter(&buf, 100, func(p Progress) { got = append(got, p) })
+	_, _ = w.Write([]byte("early"))
+	assert.Empty(t, got, "reports are spaced out")
+
+	w.last = w.last.Add(-progressInterval)
+	_, _ = w.Write([]byte("later"))
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, int64(10), got[0].Bytes)
+		assert.Equal(t, int64(100), got[0].Total)
+		assert.Positive(t, got[0].Rate)
+	}
+	assert.Equal(t, "earlylater", buf.String())
+}
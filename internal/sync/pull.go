@@ -0,0 +1,258 @@
+package sync
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PullResult reports what Pull copied back from the VM.
+type PullResult struct {
+	Pulled    []string // files written to the local project
+	Conflicts []string // files changed both here and on the VM, left alone
+	Bytes     int64    // bytes written locally
+}
+
+// Pull copies the files under opts.RemoteDir that match opts.SyncConfig.Pull
+// back into opts.SourceDir, if they changed on the VM since the last Push.
+// The manifest Push left on the VM says what the VM had before the run, so a
+// local file that no longer matches it was edited here in the meantime and
+// is reported as a conflict instead of overwritten, unless force is set.
+// Files deleted on the VM are not deleted locally.
+func Pull(ctx context.Context, remote Remote, opts Options, force bool) (PullResult, error) {
+	rules := parseRules(opts.SyncConfig.Pull)
+	if len(rules) == 0 {
+		return PullResult{}, nil
+	}
+	remoteDir := strings.TrimSuffix(opts.RemoteDir, "/")
+
+	listed, err := listRemote(ctx, remote, remoteDir)
+	if err != nil {
+		return PullResult{}, err
+	}
+	var candidates []string
+	for _, rel := range listed {
+		if matchUnder(rules, rel) {
+			candidates = append(candidates, rel)
+		}
+	}
+	if len(candidates) == 0 {
+		return PullResult{}, nil
+	}
+
+	hashes, err := hashRemote(ctx, remote, remoteDir, candidates)
+	if err != nil {
+		return PullResult{}, err
+	}
+	var base Manifest
+	if opts.ManifestPath != "" {
+		if base, err = readRemoteManifest(ctx, remote, remoteDir, opts.ManifestPath); err != nil {
+			return PullResult{}, err
+		}
+	}
+
+	var result PullResult
+	var fetch []string
+	for _, rel := range candidates {
+		remoteHash, ok := hashes[rel]
+		if !ok {
+			continue // gone since it was listed
+		}
+		old, synced := base.Files[rel]
+		if synced && old.Hash == remoteHash {
+			continue // unchanged on the VM
+		}
+		localHash, err := localHash(filepath.Join(opts.SourceDir, filepath.FromSlash(rel)))
+		if err != nil {
+			return PullResult{}, err
+		}
+		if localHash == remoteHash {
+			continue
+		}
+		// Without a manifest entry, only a missing local file is safe to write.
+		editedHere := (synced && localHash != old.Hash) || (!synced && localHash != "")
+		if editedHere && !force {
+			result.Conflicts = append(result.Conflicts, rel)
+			continue
+		}
+		fetch = append(fetch, rel)
+	}
+	if len(fetch) == 0 {
+		return result, nil
+	}
+
+	if result.Bytes, err = fetchFiles(ctx, remote, opts.SourceDir, remoteDir, fetch); err != nil {
+		return result, err
+	}
+	result.Pulled = fetch
+
+	// Record the pulled files as synced, so the next Push doesn't send them
+	// straight back. Best left alone when there's no manifest to update.
+	if opts.ManifestPath != "" && len(base.Files) > 0 {
+		filter, err := NewFilter(opts.SourceDir, opts.SyncConfig, opts.Languages)
+		if err != nil {
+			return result, err
+		}
+		for _, rel := range fetch {
+			d, err := filter.Explain(rel)
+			if err != nil || d.Excluded {
+				continue
+			}
+			if entry, err := localEntry(opts.SourceDir, rel); err == nil {
+				base.Files[rel] = entry
+			}
+		}
+		if err := writeRemoteManifest(ctx, remote, opts.ManifestPath, base); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// listRemote returns the regular files under remoteDir, sorted.
+func listRemote(ctx context.Context, remote Remote, remoteDir string) ([]string, error) {
+	cmd := fmt.Sprintf("cd %s 2>/dev/null || exit 0; find . -type f -print0", quote(remoteDir))
+	out, err := remote.Run(ctx, cmd, nil)
+	if err != nil {
+		return nil, fmt.Errorf("listing files on the VM: %w", err)
+	}
+	var files []string
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p = strings.TrimPrefix(p, "./"); p != "" {
+			files = append(files, p)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// hashRemote returns the SHA-256 of files under remoteDir by path.
+func hashRemote(ctx context.Context, remote Remote, remoteDir string, files []string) (map[string]string, error) {
+	var stdin bytes.Buffer
+	for _, p := range files {
+		stdin.WriteString(p + "\x00")
+	}
+	// -z keeps unusual file names unescaped; missing files are skipped.
+	cmd := fmt.Sprintf("cd %s && xargs -0 -r sha256sum -z -- 2>/dev/null; true", quote(remoteDir))
+	out, err := remote.Run(ctx, cmd, &stdin)
+	if err != nil {
+		return nil, fmt.Errorf("hashing files on the VM: %w", err)
+	}
+	hashes := map[string]string{}
+	for _, line := range strings.Split(string(out), "\x00") {
+		hash, name, ok := strings.Cut(line, "  ")
+		if ok {
+			hashes[name] = hash
+		}
+	}
+	return hashes, nil
+}
+
+// localHash returns the SHA-256 of the regular file at p, "" if there's
+// nothing there, and "-" for anything else (a directory or symlink), which
+// never matches a remote hash.
+func localHash(p string) (string, error) {
+	info, err := os.Lstat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "-", nil
+	}
+	return hashFile(p, info.Mode())
+}
+
+func localEntry(root, rel string) (FileEntry, error) {
+	p := filepath.Join(root, filepath.FromSlash(rel))
+	info, err := os.Lstat(p)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	entry := FileEntry{
+		Size:    info.Size(),
+		Mode:    info.Mode() & (fs.ModePerm | fs.ModeSymlink),
+		ModTime: info.ModTime().UnixNano(),
+	}
+	entry.Hash, err = hashFile(p, entry.Mode)
+	return entry, err
+}
+
+// fetchFiles copies files from remoteDir into localDir as a gzipped tar,
+// replacing each one atomically. Returns the bytes written.
+func fetchFiles(ctx context.Context, remote Remote, localDir, remoteDir string, files []string) (int64, error) {
+	var stdin bytes.Buffer
+	wanted := map[string]bool{}
+	for _, p := range files {
+		stdin.WriteString(p + "\x00")
+		wanted[p] = true
+	}
+	cmd := fmt.Sprintf("cd %s && tar -czf - --null -T -", quote(remoteDir))
+	out, err := remote.Run(ctx, cmd, &stdin)
+	if err != nil {
+		return 0, fmt.Errorf("fetching files: %w", err)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(out))
+	if err != nil {
+		return 0, fmt.Errorf("fetching files: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return total, fmt.Errorf("fetching files: %w", err)
+		}
+		// Only write what was asked for, so a bad archive can't escape localDir.
+		if hdr.Typeflag != tar.TypeReg || !wanted[hdr.Name] {
+			continue
+		}
+		n, err := writeLocal(filepath.Join(localDir, filepath.FromSlash(hdr.Name)), hdr, tr)
+		if err != nil {
+			return total, fmt.Errorf("writing %s: %w", hdr.Name, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func writeLocal(p string, hdr *tar.Header, r io.Reader) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".yg-pull-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return n, err
+	}
+	if err := os.Chmod(tmp.Name(), fs.FileMode(hdr.Mode).Perm()); err != nil {
+		return n, err
+	}
+	if err := os.Chtimes(tmp.Name(), hdr.ModTime, hdr.ModTime); err != nil {
+		return n, err
+	}
+	return n, os.Rename(tmp.Name(), p)
+}
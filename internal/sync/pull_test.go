@@ -0,0 +1,128 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/gridlhq/yeager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPull(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar not available")
+	}
+
+	src, vm := t.TempDir(), t.TempDir()
+	remoteDir := filepath.Join(vm, "project")
+	opts := Options{
+		SourceDir:    src,
+		RemoteDir:    remoteDir,
+		ManifestPath: filepath.Join(vm, "manifest.json"),
+		SyncConfig:   config.SyncConfig{Pull: []string{"gen/", "**/__snapshots__/", "Cargo.lock"}},
+	}
+	writeTree(t, src, map[string]string{
+		"main.go":                 "package main",
+		"gen/api.go":              "v1",
+		"gen/types.go":            "v1",
+		"ui/__snapshots__/a.snap": "v1",
+		"Cargo.lock":              "v1",
+	})
+	remote := &localRemote{}
+	_, err := Push(context.Background(), remote, opts)
+	require.NoError(t, err)
+
+	// The run regenerates code, updates a snapshot and the lockfile, and
+	// touches a file nobody asked to pull. Meanwhile gen/types.go and the
+	// lockfile are edited locally.
+	writeTree(t, remoteDir, map[string]string{
+		"gen/api.go":              "v2",
+		"gen/new.go":              "new",
+		"gen/types.go":            "v2 from the VM",
+		"ui/__snapshots__/a.snap": "v2",
+		"Cargo.lock":              "v2 from the VM",
+		"main.go":                 "changed on the VM",
+	})
+	require.NoError(t, os.Chmod(filepath.Join(remoteDir, "gen", "new.go"), 0o755))
+	writeTree(t, src, map[string]string{"gen/types.go": "v2 edited here", "Cargo.lock": "v2 from the VM"})
+
+	result, err := Pull(context.Background(), remote, opts, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gen/api.go", "gen/new.go", "ui/__snapshots__/a.snap"}, result.Pulled)
+	assert.Equal(t, []string{"gen/types.go"}, result.Conflicts)
+	assert.Equal(t, int64(7), result.Bytes)
+	assert.Equal(t, map[string]string{
+		"main.go":                 "package main",
+		"gen/api.go":              "v2",
+		"gen/new.go":              "new",
+		"gen/types.go":            "v2 edited here",
+		"ui/__snapshots__/a.snap": "v2",
+		"Cargo.lock":              "v2 from the VM",
+	}, remoteTree(t, src))
+	info, err := os.Stat(filepath.Join(src, "gen", "new.go"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+	// Pulled files are recorded as synced: the next push sends only the
+	// local edits and what changed on the VM but isn't pulled, and pulling
+	// again finds nothing new.
+	pushed, err := Push(context.Background(), remote, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 3, pushed.FilesTransferred, "gen/types.go, Cargo.lock and main.go")
+	assert.Equal(t, "package main", remoteTree(t, remoteDir)["main.go"])
+	result, err = Pull(context.Background(), remote, opts, false)
+	require.NoError(t, err)
+	assert.Empty(t, result.Pulled)
+	assert.Empty(t, result.Conflicts)
+
+	// force takes the VM's copy over a local edit.
+	writeTree(t, remoteDir, map[string]string{"gen/api.go": "v3"})
+	writeTree(t, src, map[string]string{"gen/api.go": "v3 edited here"})
+	result, err = Pull(context.Background(), remote, opts, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gen/api.go"}, result.Pulled)
+	assert.Equal(t, "v3", remoteTree(t, src)["gen/api.go"])
+}
+
+func TestPullWithoutManifest(t *testing.T) {
+	t.Parallel()
+
+	src, remoteDir := t.TempDir(), t.TempDir()
+	writeTree(t, src, map[string]string{"out/same.txt": "same", "out/local.txt": "mine"})
+	writeTree(t, remoteDir, map[string]string{"out/same.txt": "same", "out/local.txt": "theirs", "out/new.txt": "new"})
+
+	// Without a manifest (the rsync engine), only missing files are safe.
+	result, err := Pull(context.Background(), &localRemote{}, Options{
+		SourceDir:  src,
+		RemoteDir:  remoteDir,
+		SyncConfig: config.SyncConfig{Pull: []string{"out/"}},
+	}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"out/new.txt"}, result.Pulled)
+	assert.Equal(t, []string{"out/local.txt"}, result.Conflicts)
+}
+
+func TestMatchUnder(t *testing.T) {
+	t.Parallel()
+
+	rules := parseRules([]string{"gen/", "**/__snapshots__/", "*.lock", ""})
+	require.Len(t, rules, 3)
+	for path, want := range map[string]bool{
+		"gen/a.go":                       true,
+		"pkg/gen/a.go":                   true,
+		"gen":                            false, // a file, not the directory
+		"ui/deep/__snapshots__/x.snap":   true,
+		"Cargo.lock":                     true,
+		"sub/yarn.lock":                  true,
+		"src/main.rs":                    false,
+		"__snapshots__.txt":              false,
+		"ui/__snapshots__/nested/y.snap": true,
+	} {
+		assert.Equal(t, want, matchUnder(rules, path), path)
+	}
+}
@@ -0,0 +1,112 @@
+package sync
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// PathSize is a synced file or directory and its size.
+type PathSize struct {
+	Path  string `json:"path"` // directories end in "/"
+	Bytes int64  `json:"bytes"`
+}
+
+// Report sizes up the files a filter keeps: what a sync to a new VM sends.
+type Report struct {
+	Files        int        `json:"files"`
+	Bytes        int64      `json:"bytes"`
+	LargestDirs  []PathSize `json:"largest_dirs"`  // top-level directories, largest first
+	LargestFiles []PathSize `json:"largest_files"` // largest first
+}
+
+// Scan walks the files the filter keeps, without hashing them, and reports
+// the top largest files and top-level directories.
+func Scan(filter *Filter, top int) (Report, error) {
+	var r Report
+	var files []PathSize
+	dirs := map[string]int64{}
+	err := filter.Walk(func(rel string, info fs.FileInfo) error {
+		size := int64(0)
+		if info.Mode().IsRegular() {
+			size = info.Size()
+		}
+		r.Files++
+		r.Bytes += size
+		files = append(files, PathSize{Path: rel, Bytes: size})
+		if dir, _, ok := strings.Cut(rel, "/"); ok {
+			dirs[dir+"/"] += size
+		}
+		return nil
+	})
+	if err != nil {
+		return Report{}, err
+	}
+
+	r.LargestFiles = largest(files, top)
+	dirSizes := make([]PathSize, 0, len(dirs))
+	for dir, size := range dirs {
+		dirSizes = append(dirSizes, PathSize{Path: dir, Bytes: size})
+	}
+	r.LargestDirs = largest(dirSizes, top)
+	return r, nil
+}
+
+// largest returns the top entries by size, ties by path.
+func largest(entries []PathSize, top int) []PathSize {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Bytes != entries[j].Bytes {
+			return entries[i].Bytes > entries[j].Bytes
+		}
+		return entries[i].Path < entries[j].Path
+	})
+	if len(entries) > top {
+		entries = entries[:top]
+	}
+	return entries
+}
+
+// TooLargeError is returned when the files to sync add up to more than
+// [sync] max_sync_bytes. Nothing was sent.
+type TooLargeError struct {
+	Max    int64
+	Report Report
+}
+
+func (e *TooLargeError) Error() string {
+	msg := fmt.Sprintf("the files to sync add up to %s, over max_sync_bytes (%s)", FormatBytes(e.Report.Bytes), FormatBytes(e.Max))
+	if big := e.Biggest(); big.Path != "" {
+		msg += fmt.Sprintf("; %s is %s", big.Path, FormatBytes(big.Bytes))
+	}
+	return msg
+}
+
+// Biggest returns the largest top-level directory, or the largest file if
+// that's bigger: the likeliest thing to exclude.
+func (e *TooLargeError) Biggest() PathSize {
+	var big PathSize
+	if len(e.Report.LargestDirs) > 0 {
+		big = e.Report.LargestDirs[0]
+	}
+	if len(e.Report.LargestFiles) > 0 && e.Report.LargestFiles[0].Bytes > big.Bytes {
+		big = e.Report.LargestFiles[0]
+	}
+	return big
+}
+
+// CheckSize returns a *TooLargeError if the files the filter keeps add up
+// to more than limit bytes. A limit of 0 means none.
+func CheckSize(filter *Filter, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+	r, err := Scan(filter, 5)
+	if err != nil {
+		return err
+	}
+	if r.Bytes > limit {
+		return &TooLargeError{Max: limit, Report: r}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gridlhq/yeager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScan(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":         "*.log\n",
+		"main.go":            strings.Repeat("x", 10),
+		"debug.log":          strings.Repeat("x", 5000),
+		"data/raw/a.csv":     strings.Repeat("x", 400),
+		"data/b.csv":         strings.Repeat("x", 300),
+		"src/app.go":         strings.Repeat("x", 200),
+		"src/util.go":        strings.Repeat("x", 50),
+		"docs/guide.md":      strings.Repeat("x", 100),
+		"node_modules/x/big": strings.Repeat("x", 9000),
+	})
+	require.NoError(t, os.Symlink("main.go", filepath.Join(root, "link.go")))
+
+	f, err := NewFilter(root, config.SyncConfig{}, nil)
+	require.NoError(t, err)
+	r, err := Scan(f, 2)
+	require.NoError(t, err)
+	assert.Equal(t, Report{
+		Files:        8,
+		Bytes:        1066,
+		LargestDirs:  []PathSize{{"data/", 700}, {"src/", 250}},
+		LargestFiles: []PathSize{{"data/raw/a.csv", 400}, {"data/b.csv", 300}},
+	}, r)
+
+	require.NoError(t, CheckSize(f, 0), "0 is no limit")
+	require.NoError(t, CheckSize(f, 1066))
+	var tooLarge *TooLargeError
+	require.ErrorAs(t, CheckSize(f, 1065), &tooLarge)
+	assert.Equal(t, int64(1065), tooLarge.Max)
+}
+
+func TestTooLargeErrorBiggest(t *testing.T) {
+	t.Parallel()
+
+	e := &TooLargeError{Report: Report{
+		LargestDirs:  []PathSize{{"src/", 100}},
+		LargestFiles: []PathSize{{"model.bin", 5000}},
+	}}
+	assert.Equal(t, PathSize{"model.bin", 5000}, e.Biggest(), "a file at the top level can be the biggest")
+	assert.Contains(t, e.Error(), "model.bin is 4.9 KB")
+
+	assert.Equal(t, PathSize{}, (&TooLargeError{}).Biggest())
+}
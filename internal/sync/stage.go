@@ -0,0 +1,224 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlobStore keeps file contents by SHA-256 where the VM can download them,
+// for staging large files. storage.BlobStore keeps them in S3.
+type BlobStore interface {
+	// Has reports whether the blob for hash is stored.
+	Has(ctx context.Context, hash string) (bool, error)
+	// Put stores size bytes of r as the blob for hash.
+	Put(ctx context.Context, hash string, r io.ReaderAt, size int64) error
+	// URL returns a URL the VM can download the blob for hash from.
+	URL(ctx context.Context, hash string) (string, error)
+}
+
+// StageResult holds statistics from staging large files.
+type StageResult struct {
+	Files         int   // files the VM downloaded
+	Bytes         int64 // their total size
+	Uploaded      int   // blobs uploaded; the store had the rest already
+	BytesUploaded int64
+}
+
+// fetchScript downloads the files described by NUL-separated hash, path,
+// mode, mtime and URL quintuples on stdin into the directory $1, eight at
+// a time, checking each against its hash before moving it into place.
+const fetchScript = `mkdir -p "$1" && cd "$1" || exit 1
+xargs -0 -r -n 5 -P 8 sh -c '
+tmp="$2.yg-fetch"
+mkdir -p -- "$(dirname -- "$2")" &&
+curl -fsS --retry 3 -o "$tmp" "$5" &&
+[ "$(sha256sum < "$tmp" | cut -d" " -f1)" = "$1" ] &&
+chmod "$3" "$tmp" && touch -m -d "@$4" "$tmp" && mv -f -- "$tmp" "$2" ||
+{ echo "downloading $2 failed" >&2; rm -f -- "$tmp"; exit 255; }' yg-fetch`
+
+// Stage sends files, entries of a manifest of sourceDir, to remoteDir
+// through blobs. Each distinct content is uploaded unless blobs has it
+// already, then the VM downloads every file. progress, if set, is told
+// how the uploads are going.
+func Stage(ctx context.Context, remote Remote, blobs BlobStore, sourceDir, remoteDir string, files map[string]FileEntry, progress func(Progress)) (StageResult, error) {
+	var result StageResult
+	if len(files) == 0 {
+		return result, nil
+	}
+
+	paths := make([]string, 0, len(files))
+	byHash := map[string]string{} // a path for each distinct content
+	for p, entry := range files {
+		paths = append(paths, p)
+		result.Bytes += entry.Size
+		if q, ok := byHash[entry.Hash]; !ok || p < q {
+			byHash[entry.Hash] = p
+		}
+	}
+	sort.Strings(paths)
+	result.Files = len(paths)
+
+	var upload []string
+	var uploadBytes int64
+	for hash, p := range byHash {
+		has, err := blobs.Has(ctx, hash)
+		if err != nil {
+			return StageResult{}, err
+		}
+		if !has {
+			upload = append(upload, p)
+			uploadBytes += files[p].Size
+		}
+	}
+	sort.Strings(upload)
+	counter := newProgressCounter(uploadBytes, progress)
+	for _, p := range upload {
+		entry := files[p]
+		if err := putFile(ctx, blobs, filepath.Join(sourceDir, filepath.FromSlash(p)), entry, counter); err != nil {
+			return StageResult{}, fmt.Errorf("uploading %s: %w", p, err)
+		}
+		result.Uploaded++
+		result.BytesUploaded += entry.Size
+	}
+
+	var stdin bytes.Buffer
+	urls := map[string]string{}
+	for _, p := range paths {
+		entry := files[p]
+		url, ok := urls[entry.Hash]
+		if !ok {
+			var err error
+			if url, err = blobs.URL(ctx, entry.Hash); err != nil {
+				return StageResult{}, err
+			}
+			urls[entry.Hash] = url
+		}
+		for _, field := range []string{
+			entry.Hash,
+			p,
+			strconv.FormatUint(uint64(entry.Mode.Perm()), 8),
+			fmt.Sprintf("%d.%09d", entry.ModTime/int64(time.Second), entry.ModTime%int64(time.Second)),
+			url,
+		} {
+			stdin.WriteString(field + "\x00")
+		}
+	}
+	cmd := fmt.Sprintf("bash -c %s yg-stage %s", quote(fetchScript), quote(remoteDir))
+	if _, err := remote.Run(ctx, cmd, &stdin); err != nil {
+		return StageResult{}, fmt.Errorf("downloading large files on the VM: %w", err)
+	}
+	return result, nil
+}
+
+func putFile(ctx context.Context, blobs BlobStore, p string, entry FileEntry, counter *progressCounter) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return blobs.Put(ctx, entry.Hash, &countingReaderAt{r: f, counter: counter}, entry.Size)
+}
+
+// StageLarge is the rsync engine's part of staging: it stages the files
+// the filter keeps of [sync] large_file_bytes or more that changed since
+// the last time, keeping a manifest of them at opts.StageManifestPath on
+// the VM.
+// It returns all of them, for Options.Staged. Nothing is staged without
+// opts.Blobs or a large_file_bytes.
+func StageLarge(ctx context.Context, remote Remote, filter *Filter, opts Options) ([]string, StageResult, error) {
+	limit := opts.SyncConfig.LargeFileBytes
+	if opts.Blobs == nil || limit <= 0 {
+		return nil, StageResult{}, nil
+	}
+	if opts.StageManifestPath == "" {
+		return nil, StageResult{}, fmt.Errorf("no remote manifest path for staged files")
+	}
+	remoteDir := strings.TrimSuffix(opts.RemoteDir, "/")
+
+	prev, err := readRemoteManifest(ctx, remote, remoteDir, opts.StageManifestPath)
+	if err != nil {
+		return nil, StageResult{}, err
+	}
+	next, err := buildManifest(opts.SourceDir, filter, prev, func(info fs.FileInfo) bool {
+		return info.Mode().IsRegular() && info.Size() >= limit
+	})
+	if err != nil {
+		return nil, StageResult{}, err
+	}
+	changed, deleted := Diff(prev, next)
+
+	files := map[string]FileEntry{}
+	for _, p := range changed {
+		files[p] = next.Files[p]
+	}
+	result, err := Stage(ctx, remote, opts.Blobs, opts.SourceDir, remoteDir, files, opts.Progress)
+	if err != nil {
+		return nil, StageResult{}, err
+	}
+	if len(changed) > 0 || len(deleted) > 0 || touched(prev, next) {
+		if err := writeRemoteManifest(ctx, remote, opts.StageManifestPath, next); err != nil {
+			return nil, StageResult{}, err
+		}
+	}
+
+	staged := make([]string, 0, len(next.Files))
+	for p := range next.Files {
+		staged = append(staged, p)
+	}
+	sort.Strings(staged)
+	return staged, result, nil
+}
+
+// large splits files, paths of next, into those Push sends itself and
+// those it stages: regular files of at least limit bytes.
+func large(files []string, next Manifest, limit int64) (small []string, big map[string]FileEntry) {
+	big = map[string]FileEntry{}
+	for _, p := range files {
+		entry := next.Files[p]
+		if limit > 0 && entry.Mode&fs.ModeSymlink == 0 && entry.Size >= limit {
+			big[p] = entry
+		} else {
+			small = append(small, p)
+		}
+	}
+	return small, big
+}
+
+// progressCounter adds up bytes read by several goroutines and reports
+// them at most every progressInterval.
+type progressCounter struct {
+	mu sync.Mutex
+	w  *progressWriter
+}
+
+func newProgressCounter(total int64, report func(Progress)) *progressCounter {
+	return &progressCounter{w: newProgressWriter(io.Discard, total, report)}
+}
+
+func (c *progressCounter) add(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.w.add(n)
+}
+
+// countingReaderAt counts the bytes read through it into a progressCounter.
+type countingReaderAt struct {
+	r       io.ReaderAt
+	counter *progressCounter
+}
+
+func (c *countingReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(b, off)
+	c.counter.add(n)
+	return n, err
+}
@@ -0,0 +1,176 @@
+package sync
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gridlhq/yeager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dirBlobs keeps blobs in a local directory and hands out file:// URLs,
+// standing in for S3.
+type dirBlobs struct {
+	dir  string
+	puts int
+}
+
+func (b *dirBlobs) Has(ctx context.Context, hash string) (bool, error) {
+	_, err := os.Stat(filepath.Join(b.dir, hash))
+	return err == nil, nil
+}
+
+func (b *dirBlobs) Put(ctx context.Context, hash string, r io.ReaderAt, size int64) error {
+	b.puts++
+	f, err := os.Create(filepath.Join(b.dir, hash))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, io.NewSectionReader(r, 0, size))
+	return err
+}
+
+func (b *dirBlobs) URL(ctx context.Context, hash string) (string, error) {
+	return "file://" + filepath.Join(b.dir, hash), nil
+}
+
+func requireStageTools(t *testing.T) {
+	t.Helper()
+	for _, tool := range []string{"tar", "curl", "sha256sum", "xargs"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+}
+
+func TestPushStagesLargeFiles(t *testing.T) {
+	t.Parallel()
+	requireStageTools(t)
+
+	src, vm := t.TempDir(), t.TempDir()
+	blobs := &dirBlobs{dir: t.TempDir()}
+	remoteDir := filepath.Join(vm, "project")
+	opts := Options{
+		SourceDir:    src,
+		RemoteDir:    remoteDir,
+		ManifestPath: filepath.Join(vm, "m.json"),
+		SyncConfig:   config.SyncConfig{LargeFileBytes: 100},
+		Blobs:        blobs,
+	}
+	model := strings.Repeat("weights ", 20)
+	writeTree(t, src, map[string]string{
+		"main.go":              "package main",
+		"models/a.bin":         model,
+		"models/copy of a.bin": model,
+	})
+	require.NoError(t, os.Chmod(filepath.Join(src, "models", "a.bin"), 0o600))
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(src, "models", "a.bin"), mtime, mtime))
+
+	result, err := Push(context.Background(), &localRemote{}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.FilesTransferred)
+	assert.Equal(t, 2, result.FilesStaged)
+	assert.Equal(t, int64(len(model)), result.BytesUploaded, "the same content is uploaded once")
+	assert.Equal(t, 1, blobs.puts)
+	assert.Equal(t, map[string]string{
+		"main.go":              "package main",
+		"models/a.bin":         model,
+		"models/copy of a.bin": model,
+	}, remoteTree(t, remoteDir))
+	info, err := os.Stat(filepath.Join(remoteDir, "models", "a.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	assert.True(t, mtime.Equal(info.ModTime()), info.ModTime())
+
+	// Unchanged files aren't staged again.
+	result, err = Push(context.Background(), &localRemote{}, opts)
+	require.NoError(t, err)
+	assert.Zero(t, result.FilesStaged)
+
+	// A new VM, or a teammate's, downloads what's stored already.
+	opts.RemoteDir = filepath.Join(t.TempDir(), "project")
+	opts.ManifestPath = filepath.Join(t.TempDir(), "m.json")
+	result, err = Push(context.Background(), &localRemote{}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.FilesStaged)
+	assert.Zero(t, result.BytesUploaded)
+	assert.Equal(t, 1, blobs.puts)
+	assert.Len(t, remoteTree(t, opts.RemoteDir), 3)
+}
+
+func TestStageCorruptBlob(t *testing.T) {
+	t.Parallel()
+	requireStageTools(t)
+
+	src, vm := t.TempDir(), t.TempDir()
+	blobs := &dirBlobs{dir: t.TempDir()}
+	writeTree(t, src, map[string]string{"data.bin": "the real content"})
+	entry, err := localEntry(src, "data.bin")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(blobs.dir, entry.Hash), []byte("something else"), 0o644))
+
+	_, err = Stage(context.Background(), &localRemote{}, blobs, src, vm, map[string]FileEntry{"data.bin": entry}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "downloading data.bin failed")
+	assert.NoFileExists(t, filepath.Join(vm, "data.bin"))
+	assert.NoFileExists(t, filepath.Join(vm, "data.bin.yg-fetch"))
+}
+
+func TestStageLarge(t *testing.T) {
+	t.Parallel()
+	requireStageTools(t)
+
+	src, vm := t.TempDir(), t.TempDir()
+	blobs := &dirBlobs{dir: t.TempDir()}
+	opts := Options{
+		SourceDir:         src + "/",
+		RemoteDir:         filepath.Join(vm, "project") + "/",
+		StageManifestPath: filepath.Join(vm, "staged.json"),
+		SyncConfig:        config.SyncConfig{LargeFileBytes: 10},
+		Blobs:             blobs,
+	}
+	writeTree(t, src, map[string]string{
+		"small.txt":         "small",
+		"data/[big].csv":    strings.Repeat("x", 50),
+		"node_modules/big":  strings.Repeat("x", 50),
+		"fixtures/big.json": strings.Repeat("y", 20),
+	})
+	filter, err := NewFilter(opts.SourceDir, opts.SyncConfig, nil)
+	require.NoError(t, err)
+
+	staged, result, err := StageLarge(context.Background(), &localRemote{}, filter, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data/[big].csv", "fixtures/big.json"}, staged)
+	assert.Equal(t, StageResult{Files: 2, Bytes: 70, Uploaded: 2, BytesUploaded: 70}, result)
+	assert.FileExists(t, filepath.Join(vm, "project", "data", "[big].csv"))
+
+	staged, result, err = StageLarge(context.Background(), &localRemote{}, filter, opts)
+	require.NoError(t, err)
+	assert.Len(t, staged, 2, "staged files are still left to the VM")
+	assert.Zero(t, result.Files)
+
+	opts.Staged = staged
+	args := strings.Join(BuildArgs(opts), " ")
+	assert.Contains(t, args, `--exclude /data/\[big].csv --exclude /fixtures/big.json`)
+
+	opts.Blobs = nil
+	staged, _, err = StageLarge(context.Background(), &localRemote{}, filter, opts)
+	require.NoError(t, err)
+	assert.Empty(t, staged, "no store, no staging")
+}
+
+func TestRsyncLiteral(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "data/model.bin", rsyncLiteral("data/model.bin"))
+	assert.Equal(t, `data\\x/\[1]\?\*`, rsyncLiteral(`data\x/[1]?*`))
+}
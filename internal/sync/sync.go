@@ -2,19 +2,31 @@ package sync
 
 import (
 	"fmt"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gridlhq/yeager/internal/config"
 	"github.com/gridlhq/yeager/internal/provision"
 )
 
-// SyncResult holds statistics from an rsync invocation.
+// SyncResult holds statistics from an rsync invocation or a native Push.
 type SyncResult struct {
 	FilesTransferred int   // number of files actually sent
 	TotalFiles       int   // total number of files considered
 	BytesTransferred int64 // total transferred file size in bytes
+	FilesDeleted     int   // files removed from the VM
+	TotalBytes       int64 // total size of the files considered
+	BytesSent        int64 // bytes that went over the wire, after compression
+	Duration         time.Duration
+	FilesStaged      int   // of those sent, files the VM downloaded from S3
+	BytesUploaded    int64 // bytes uploaded to S3 for them
+
+	// Deleted is what was removed from the VM, relative to the project;
+	// directories end in "/", and what was inside them isn't listed.
+	Deleted []string
 }
 
 // DefaultExcludes are always excluded from rsync regardless of language.
@@ -34,15 +46,17 @@ var DefaultExcludes = []string{
 	".gradle/",
 	"*.pyc",
 	".DS_Store",
+	".yeager-env-*", // per-run env files on the VM (see exec.EnvFileName)
 }
 
 // languageExtraExcludes are additional excludes per language
 // (only things NOT already in DefaultExcludes).
 var languageExtraExcludes = map[provision.LanguageName][]string{
-	provision.Go: {"vendor/"},
+	provision.Go:     {"vendor/"},
+	provision.DotNet: {"bin/", "obj/"},
 }
 
-// Options configures an rsync invocation.
+// Options configures an rsync invocation or a native Push.
 type Options struct {
 	SourceDir  string // local directory (must end with /)
 	RemoteDir  string // remote directory (must end with /)
@@ -52,6 +66,22 @@ type Options struct {
 	SSHKeyPath string // path to SSH private key (optional)
 	SyncConfig config.SyncConfig
 	Languages  []provision.LanguageName // detected project languages
+
+	// ManifestPath is where Push keeps its manifest on the VM; rsync
+	// doesn't use it.
+	ManifestPath string
+
+	// Progress, if set, is called as files are sent. For rsync it adds
+	// --info=progress2, which needs rsync 3.1 or later.
+	Progress func(Progress)
+
+	// Blobs, if set, is where files of [sync] large_file_bytes or more
+	// are staged; see Stage. StageManifestPath is where StageLarge keeps
+	// its manifest of them for rsync, and Staged is what it returned:
+	// files rsync leaves alone.
+	Blobs             BlobStore
+	StageManifestPath string
+	Staged            []string
 }
 
 // BuildArgs constructs the rsync argument list.
@@ -61,6 +91,11 @@ func BuildArgs(opts Options) []string {
 		"-az",
 		"--delete",
 		"--stats",
+		"--itemize-changes", // lists what --delete removes, for ParseStats
+	}
+	if opts.Progress != nil {
+		// Without incremental recursion, the percentage covers every file.
+		args = append(args, "--info=progress2", "--no-inc-recursive")
 	}
 
 	// SSH transport.
@@ -70,13 +105,25 @@ func BuildArgs(opts Options) []string {
 	}
 	args = append(args, "-e", sshCmd)
 
-	// Includes first (rsync evaluates rules in order).
+	// Protect filters first: they only apply on the VM, where rsync would
+	// otherwise delete files it doesn't see locally.
+	for _, p := range opts.SyncConfig.Preserve {
+		args = append(args, "--filter", "P "+p)
+	}
+
+	// Staged files are on the VM already.
+	for _, p := range opts.Staged {
+		args = append(args, "--exclude", "/"+rsyncLiteral(p))
+	}
+
+	// Includes next (rsync evaluates rules in order).
 	for _, inc := range opts.SyncConfig.Include {
 		args = append(args, "--include", inc)
 	}
 
-	// .gitignore filter (before explicit excludes so it takes effect).
-	args = append(args, "--filter", ":- .gitignore")
+	// .yeagerignore, then .gitignore filters (before explicit excludes so
+	// they take effect).
+	args = append(args, "--filter", ":- "+YeagerignoreFile, "--filter", ":- "+GitignoreFile)
 
 	// User-configured excludes from .yeager.toml [sync] section.
 	for _, exc := range opts.SyncConfig.Exclude {
@@ -100,6 +147,22 @@ func BuildArgs(opts Options) []string {
 	return args
 }
 
+// rsyncLiteral escapes a path for an rsync filter pattern. rsync only
+// treats a backslash as an escape in patterns with wildcards.
+func rsyncLiteral(p string) string {
+	if !strings.ContainsAny(p, "*?[") {
+		return p
+	}
+	var b strings.Builder
+	for _, c := range p {
+		if strings.ContainsRune(`*?[\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
 // LanguageExcludes returns extra exclude patterns for the given languages.
 func LanguageExcludes(langs []provision.LanguageName) []string {
 	var extras []string
@@ -117,14 +180,21 @@ func LanguageExcludes(langs []provision.LanguageName) []string {
 
 var (
 	reNumFiles     = regexp.MustCompile(`Number of files: ([\d,]+)`)
-	reTransferred  = regexp.MustCompile(`Number of files transferred: ([\d,]+)`)
+	reTransferred  = regexp.MustCompile(`Number of (?:regular )?files transferred: ([\d,]+)`)
 	reTotalSize    = regexp.MustCompile(`Total transferred file size: ([\d,]+)`)
+	reDeleted      = regexp.MustCompile(`Number of deleted files: ([\d,]+)`)
+	reAllFilesSize = regexp.MustCompile(`Total file size: ([\d,]+)`)
+	reBytesSent    = regexp.MustCompile(`Total bytes sent: ([\d,]+)`)
+	reDeleting     = regexp.MustCompile(`^\*deleting\s+(.+)$`)
 )
 
-// ParseStats extracts sync statistics from rsync --stats output.
+// ParseStats extracts sync statistics from rsync --stats and
+// --itemize-changes output.
 // Returns a zero SyncResult if parsing fails (best-effort).
 func ParseStats(output string) SyncResult {
 	var r SyncResult
+	r.Deleted = parseDeleted(output)
+	r.FilesDeleted = len(r.Deleted)
 	if m := reNumFiles.FindStringSubmatch(output); len(m) > 1 {
 		r.TotalFiles = parseCommaInt(m[1])
 	}
@@ -134,9 +204,50 @@ func ParseStats(output string) SyncResult {
 	if m := reTotalSize.FindStringSubmatch(output); len(m) > 1 {
 		r.BytesTransferred = parseCommaInt64(m[1])
 	}
+	if m := reDeleted.FindStringSubmatch(output); len(m) > 1 {
+		r.FilesDeleted = parseCommaInt(m[1])
+	}
+	if m := reAllFilesSize.FindStringSubmatch(output); len(m) > 1 {
+		r.TotalBytes = parseCommaInt64(m[1])
+	}
+	if m := reBytesSent.FindStringSubmatch(output); len(m) > 1 {
+		r.BytesSent = parseCommaInt64(m[1])
+	}
 	return r
 }
 
+// parseDeleted returns the paths rsync's itemized changes say it deleted,
+// leaving out those inside a deleted directory.
+func parseDeleted(output string) []string {
+	var paths []string
+	dirs := map[string]bool{}
+	for _, line := range strings.FieldsFunc(output, func(c rune) bool { return c == '\n' || c == '\r' }) {
+		if m := reDeleting.FindStringSubmatch(line); m != nil {
+			paths = append(paths, m[1])
+			if strings.HasSuffix(m[1], "/") {
+				dirs[m[1]] = true
+			}
+		}
+	}
+	var deleted []string
+	for _, p := range paths {
+		if !insideAny(dirs, p) {
+			deleted = append(deleted, p)
+		}
+	}
+	return deleted
+}
+
+// insideAny reports whether p is inside one of dirs.
+func insideAny(dirs map[string]bool, p string) bool {
+	for dir := path.Dir(strings.TrimSuffix(p, "/")); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if dirs[dir+"/"] {
+			return true
+		}
+	}
+	return false
+}
+
 // FormatBytes formats bytes into a human-readable string.
 func FormatBytes(b int64) string {
 	switch {
@@ -64,7 +64,7 @@ func TestBuildArgs(t *testing.T) {
 			},
 		},
 		{
-			name: "with gitignore filter",
+			name: "with ignore file filters",
 			opts: Options{
 				SourceDir: "/src/",
 				RemoteDir: "/dst/",
@@ -74,6 +74,7 @@ func TestBuildArgs(t *testing.T) {
 			},
 			wantContain: []string{
 				"--filter", ":- .gitignore",
+				":- .yeagerignore",
 			},
 		},
 		{
@@ -109,6 +110,33 @@ func TestBuildArgs(t *testing.T) {
 				"--include", "fixtures/large-dataset.bin",
 			},
 		},
+		{
+			name: "with progress",
+			opts: Options{
+				SourceDir: "/src/",
+				RemoteDir: "/dst/",
+				Host:      "1.2.3.4",
+				User:      "ubuntu",
+				SSHPort:   22,
+				Progress:  func(Progress) {},
+			},
+			wantContain: []string{"--info=progress2", "--no-inc-recursive"},
+		},
+		{
+			name: "with preserve",
+			opts: Options{
+				SourceDir: "/src/",
+				RemoteDir: "/dst/",
+				Host:      "1.2.3.4",
+				User:      "ubuntu",
+				SSHPort:   22,
+				SyncConfig: config.SyncConfig{
+					Preserve: []string{"fixtures/downloaded/", "*.sqlite"},
+				},
+			},
+			wantContain:   []string{"--itemize-changes", "P fixtures/downloaded/", "P *.sqlite"},
+			wantSubstring: []string{"--filter P *.sqlite --filter :- .yeagerignore"},
+		},
 		{
 			name: "port 443 fallback",
 			opts: Options{
@@ -230,6 +258,7 @@ func TestBuildArgs_GitignoreFilterBeforeExcludes(t *testing.T) {
 	assert.Greater(t, filterIdx, -1, "--filter must be present")
 	assert.Greater(t, excludeIdx, -1, "--exclude must be present")
 	assert.Less(t, filterIdx, excludeIdx, "--filter must come before --exclude")
+	assert.Equal(t, ":- .yeagerignore", args[filterIdx+1], ".yeagerignore wins over .gitignore")
 }
 
 func TestLanguageExcludes(t *testing.T) {
@@ -250,6 +279,11 @@ func TestLanguageExcludes(t *testing.T) {
 			langs:    []provision.LanguageName{provision.Rust},
 			wantHave: nil, // target/ is already in defaults
 		},
+		{
+			name:     "dotnet build output",
+			langs:    []provision.LanguageName{provision.DotNet},
+			wantHave: []string{"bin/", "obj/"},
+		},
 		{
 			name:     "multiple languages",
 			langs:    []provision.LanguageName{provision.Go, provision.Node},
@@ -297,6 +331,7 @@ Matched data: 0 bytes`,
 				TotalFiles:       847,
 				FilesTransferred: 847,
 				BytesTransferred: 12_582_912,
+				TotalBytes:       12_582_912,
 			},
 		},
 		{
@@ -311,6 +346,7 @@ Matched data: 2,862 bytes`,
 				TotalFiles:       847,
 				FilesTransferred: 3,
 				BytesTransferred: 4_096,
+				TotalBytes:       12_582_912,
 			},
 		},
 		{
@@ -323,6 +359,7 @@ Total transferred file size: 0 bytes`,
 				TotalFiles:       200,
 				FilesTransferred: 0,
 				BytesTransferred: 0,
+				TotalBytes:       5_000_000,
 			},
 		},
 		{
@@ -335,8 +372,48 @@ Total transferred file size: 512 bytes`,
 				TotalFiles:       5,
 				FilesTransferred: 2,
 				BytesTransferred: 512,
+				TotalBytes:       1024,
+			},
+		},
+		{
+			name: "rsync 3.1+ output",
+			output: `Number of files: 1,204 (reg: 1,100, dir: 104)
+Number of created files: 2 (reg: 2)
+Number of deleted files: 3 (reg: 3)
+Number of regular files transferred: 5
+Total file size: 48,000,000 bytes
+Total transferred file size: 2,000,000 bytes
+Literal data: 2,000,000 bytes
+Matched data: 0 bytes
+Total bytes sent: 612,345
+Total bytes received: 1,234`,
+			want: SyncResult{
+				TotalFiles:       1204,
+				FilesTransferred: 5,
+				BytesTransferred: 2_000_000,
+				FilesDeleted:     3,
+				TotalBytes:       48_000_000,
+				BytesSent:        612_345,
+			},
+		},
+		{
+			name: "itemized deletions",
+			output: "*deleting   gen/old/a.go\n*deleting   gen/old/\n*deleting   test.sqlite\n" +
+				">f+++++++++ main.go\n" + `Number of files: 10 (reg: 8, dir: 2)
+Number of deleted files: 3 (reg: 2, dir: 1)
+Number of regular files transferred: 1`,
+			want: SyncResult{
+				TotalFiles:       10,
+				FilesTransferred: 1,
+				FilesDeleted:     3,
+				Deleted:          []string{"gen/old/", "test.sqlite"},
 			},
 		},
+		{
+			name:   "itemized deletions without stats",
+			output: "*deleting   notes.txt\r\n",
+			want:   SyncResult{FilesDeleted: 1, Deleted: []string{"notes.txt"}},
+		},
 		{
 			name:   "empty output returns zero result",
 			output: "",
@@ -0,0 +1,181 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher reports changes to the project files a Filter keeps. Directories
+// the filter excludes aren't watched at all, so node_modules and friends
+// cost nothing.
+type Watcher struct {
+	root   string
+	filter *Filter
+	fsw    *fsnotify.Watcher
+	// relist is set when, in git mode, what git lists may have changed:
+	// git is asked again once per burst, not once per event.
+	relist bool
+}
+
+// NewWatcher starts watching every directory under root the filter keeps.
+func NewWatcher(root string, filter *Filter) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{root: root, filter: filter, fsw: fsw}
+	if err := w.addTree("."); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Close stops watching.
+func (w *Watcher) Close() error {
+	return w.fsw.Close()
+}
+
+// addTree watches dir (relative to root) and the directories under it.
+func (w *Watcher) addTree(dir string) error {
+	return filepath.WalkDir(filepath.Join(w.root, filepath.FromSlash(dir)), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Gone already: the event for that comes separately.
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(w.root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		} else if w.filter.Excluded(rel, true) {
+			return filepath.SkipDir
+		}
+		if err := w.filter.loadIgnores(rel); err != nil {
+			return err
+		}
+		return w.fsw.Add(p)
+	})
+}
+
+// Run sends the sorted, slash-separated paths that changed in each burst of
+// events, once debounce passes without another, until ctx is done. Paths
+// the filter excludes don't count.
+func (w *Watcher) Run(ctx context.Context, debounce time.Duration, changes chan<- []string) error {
+	pending := map[string]bool{} // path → decided only once git is asked again
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return nil
+			}
+			if rel, undecided, ok := w.handle(ev); ok {
+				pending[rel] = pending[rel] || undecided
+				timer.Reset(debounce)
+			}
+		case <-timer.C:
+			batch := w.settle(pending)
+			clear(pending)
+			if len(batch) == 0 {
+				continue
+			}
+			select {
+			case changes <- batch:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// handle watches new directories and returns the path an event changed,
+// if the filter keeps it. In git mode, a new file or a .gitignore change
+// may change what git lists, so the path is returned undecided, for
+// settle.
+func (w *Watcher) handle(ev fsnotify.Event) (rel string, undecided, ok bool) {
+	if ev.Op == fsnotify.Chmod {
+		return "", false, false
+	}
+	rel, err := filepath.Rel(w.root, ev.Name)
+	if err != nil || rel == "." {
+		return "", false, false
+	}
+	rel = filepath.ToSlash(rel)
+
+	if w.filter.git && (ev.Has(fsnotify.Create) || path.Base(rel) == GitignoreFile) {
+		w.relist = true
+		return rel, true, true
+	}
+	if !w.keep(rel, ev.Has(fsnotify.Create)) {
+		return "", false, false
+	}
+	return rel, false, true
+}
+
+// keep reports whether the filter keeps rel, watching it if it's a new
+// directory and reloading it if it's an ignore file.
+func (w *Watcher) keep(rel string, created bool) bool {
+	info, err := os.Lstat(filepath.Join(w.root, filepath.FromSlash(rel)))
+	isDir := err == nil && info.IsDir()
+	if w.filter.Excluded(rel, isDir) {
+		return false
+	}
+	if isDir && created {
+		// Errors here only mean changes inside it go unnoticed.
+		_ = w.addTree(rel)
+	}
+	if base := path.Base(rel); base == GitignoreFile || base == YeagerignoreFile {
+		dir := path.Dir(rel)
+		if dir == "." {
+			dir = ""
+		}
+		_ = w.filter.loadIgnores(dir)
+	}
+	return true
+}
+
+// settle returns the sorted paths of a burst the filter keeps, asking git
+// again first, once, if it has to decide some of them.
+func (w *Watcher) settle(pending map[string]bool) []string {
+	if w.relist {
+		w.relist = false
+		// The old list stands if git fails.
+		_ = w.filter.ListGitFiles()
+	}
+	batch := make([]string, 0, len(pending))
+	for p, undecided := range pending {
+		// New directories are watched now, after git listed what's in them.
+		if !undecided || w.keep(p, true) {
+			batch = append(batch, p)
+		}
+	}
+	sort.Strings(batch)
+	return batch
+}
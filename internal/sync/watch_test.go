@@ -0,0 +1,105 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gridlhq/yeager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":     "*.log\n",
+		"main.go":        "package main",
+		"node_modules/x": "",
+	})
+	f, err := NewFilter(root, config.SyncConfig{}, nil)
+	require.NoError(t, err)
+	w, err := NewWatcher(root, f)
+	require.NoError(t, err)
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan []string)
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx, 50*time.Millisecond, changes) }()
+
+	next := func() []string {
+		t.Helper()
+		select {
+		case batch := <-changes:
+			return batch
+		case <-time.After(5 * time.Second):
+			t.Fatal("no changes reported")
+			return nil
+		}
+	}
+
+	// A burst of edits is one batch; excluded paths don't count.
+	writeTree(t, root, map[string]string{
+		"main.go":        "package main // edited",
+		"debug.log":      "ignored",
+		"node_modules/y": "ignored",
+		"node_modules/x": "ignored",
+		"README.md":      "new",
+	})
+	assert.Equal(t, []string{"README.md", "main.go"}, next())
+
+	// New directories are watched as they appear.
+	require.NoError(t, os.Mkdir(filepath.Join(root, "pkg"), 0o755))
+	assert.Equal(t, []string{"pkg"}, next())
+	writeTree(t, root, map[string]string{"pkg/util.go": "package pkg"})
+	assert.Equal(t, []string{"pkg/util.go"}, next())
+
+	// Deletions count, and a changed .gitignore applies straight away.
+	require.NoError(t, os.Remove(filepath.Join(root, "main.go")))
+	assert.Equal(t, []string{"main.go"}, next())
+	writeTree(t, root, map[string]string{".gitignore": "*.log\n*.tmp\n"})
+	assert.Equal(t, []string{".gitignore"}, next())
+	writeTree(t, root, map[string]string{"scratch.tmp": "", "notes.txt": ""})
+	assert.Equal(t, []string{"notes.txt"}, next())
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestWatcherGitModeListsOncePerBurst(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	gitRepo(t, root, map[string]string{".gitignore": "*.log\n", "main.go": "package main"})
+	f, err := NewFilter(root, config.SyncConfig{Mode: config.SyncModeGit}, nil)
+	require.NoError(t, err)
+	w, err := NewWatcher(root, f)
+	require.NoError(t, err)
+	defer w.Close()
+
+	writeTree(t, root, map[string]string{
+		"pkg/util.go": "package pkg",
+		"new.go":      "package main",
+		"debug.log":   "ignored",
+	})
+	pending := map[string]bool{}
+	for _, name := range []string{"pkg", "pkg/util.go", "new.go", "debug.log"} {
+		rel, undecided, ok := w.handle(fsnotify.Event{Name: filepath.Join(root, name), Op: fsnotify.Create})
+		require.True(t, ok)
+		pending[rel] = undecided
+	}
+	// Git isn't asked while the burst lasts...
+	assert.False(t, f.tracked["new.go"])
+
+	// ...but once, when it's over.
+	assert.Equal(t, []string{"new.go", "pkg", "pkg/util.go"}, w.settle(pending))
+	assert.True(t, f.tracked["new.go"])
+	assert.False(t, w.relist)
+}